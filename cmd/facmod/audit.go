@@ -0,0 +1,72 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// Set by command-line flags.
+var auditMaxAge uint
+
+// runAudit is the entrypoint for the "audit" subcommand.
+func runAudit(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return errors.New("too many arguments")
+	}
+
+	cache, err := openFreshCache(ctx)
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	opts := mods.AuditOptions{
+		MaxAge: time.Duration(auditMaxAge) * 24 * time.Hour,
+	}
+	if opts.Game, err = targetGameVersion(ctx); err != nil {
+		slog.WarnContext(ctx, "skipping Factorio version check", "err", err)
+	}
+
+	findings, err := cache.Audit(ctx, installDir, opts)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+
+	if jsonOutput() {
+		if findings == nil {
+			findings = []mods.AuditFinding{}
+		}
+		if err := writeJSON(findings); err != nil {
+			return err
+		}
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+		if !noHeaders {
+			headers := []string{"NAME", "VERSION", "PROBLEM", "DETAIL"}
+			fmt.Fprintln(tw, strings.Join(headers, "\t"))
+		}
+		for _, f := range findings {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Name, f.Version, f.Problem, f.Detail)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(findings) > 0 {
+		return fmt.Errorf("audit found %d problems", len(findings))
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// Set by command-line flags.
+var bundleInstalled bool
+
+// runBundle is the entrypoint for the "bundle" subcommand.
+func runBundle(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("a bundle file is required")
+	}
+	dst, args := args[0], args[1:]
+
+	creds, err := loadCredentials()
+	if err != nil {
+		return fmt.Errorf("load credentials: %w", err)
+	}
+
+	cache, err := openFreshCache(ctx)
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	var archives []string
+	for _, arg := range args {
+		name, version := parseModArg(arg)
+
+		var path string
+		if version == "" {
+			path, err = cache.Get(ctx, name, creds.Username, creds.Token)
+		} else {
+			path, err = cache.GetVersion(ctx, name, version, creds.Username, creds.Token)
+		}
+		if err != nil {
+			return fmt.Errorf("download %s: %w", arg, err)
+		}
+		archives = append(archives, path)
+	}
+
+	if bundleInstalled {
+		installed, err := mods.Load(installDir)
+		if err != nil {
+			return fmt.Errorf("load mods: %w", err)
+		}
+		for _, m := range installed {
+			n := len(m.Versions)
+			if n == 0 || m.IsDir(m.Versions[n-1]) {
+				continue
+			}
+			path, err := cache.GetVersion(ctx, m.Name, m.Versions[n-1].String(), creds.Username, creds.Token)
+			if err != nil {
+				return fmt.Errorf("download %s: %w", m.Name, err)
+			}
+			archives = append(archives, path)
+		}
+	}
+
+	if dst == "-" {
+		return cache.Export(ctx, os.Stdout, archives...)
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("create bundle: %w", err)
+	}
+	defer f.Close()
+
+	if err := cache.Export(ctx, f, archives...); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// runUnbundle is the entrypoint for the "unbundle" subcommand.
+func runUnbundle(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one bundle file is required")
+	}
+
+	cache, err := openCache()
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	r := os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("open bundle: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	paths, err := cache.Import(ctx, r)
+	if err != nil {
+		return fmt.Errorf("import bundle: %w", err)
+	}
+
+	if jsonOutput() {
+		if paths == nil {
+			paths = []string{}
+		}
+		return writeJSON(paths)
+	}
+	for _, p := range paths {
+		fmt.Println(p)
+	}
+	return nil
+}
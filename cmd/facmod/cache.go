@@ -0,0 +1,206 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// Set by command-line flags.
+var (
+	cacheDir   string
+	cacheTTL   time.Duration
+	autoUpdate bool
+
+	cacheKeep    int
+	cacheMaxAge  time.Duration
+	cacheMaxSize string
+
+	doctorRepair     bool
+	doctorRedownload bool
+)
+
+// openFreshCache is like openCache, for commands that read mods from the
+// cache database.
+// When the cache is older than --cache-ttl, it is updated if --auto-update
+// was given; otherwise, or if the update fails, a warning is logged.
+func openFreshCache(ctx context.Context) (*mods.Cache, error) {
+	cache, err := openCache()
+	if err != nil {
+		return nil, err
+	}
+
+	last, err := cache.LastUpdate(ctx)
+	if err != nil {
+		cache.Close()
+		return nil, err
+	}
+	age := time.Since(last)
+	if cacheTTL <= 0 || age <= cacheTTL {
+		return cache, nil
+	}
+
+	if !autoUpdate {
+		if last.IsZero() {
+			slog.WarnContext(ctx, "the mod cache has never been updated; run facmod update")
+		} else {
+			slog.WarnContext(ctx, "the mod cache is stale; run facmod update, or pass --auto-update",
+				"last_update", last.Format(time.RFC3339),
+			)
+		}
+		return cache, nil
+	}
+
+	slog.InfoContext(ctx, "updating stale mod cache", "last_update", last.Format(time.RFC3339))
+	if !quiet {
+		cache.EnableProgressBar()
+		defer cache.DisableProgressBar()
+	}
+	if err := updateCache(ctx, cache, false); err != nil {
+		// Stale data is more useful than no data, for example when
+		// the mod portal cannot be reached.
+		slog.WarnContext(ctx, "cannot update the mod cache; using stale data", "err", err)
+	}
+	return cache, nil
+}
+
+// retentionPolicy returns the policy given with --cache-keep,
+// --cache-max-age, and --cache-max-size, for removing downloaded mods from
+// the cache.
+func retentionPolicy() (mods.PruneOptions, error) {
+	maxSize, err := parseSize(cacheMaxSize)
+	if err != nil {
+		return mods.PruneOptions{}, fmt.Errorf("--cache-max-size: %w", err)
+	}
+	if cacheKeep < 0 {
+		return mods.PruneOptions{}, errors.New("--cache-keep: must not be negative")
+	}
+	return mods.PruneOptions{
+		Keep:    cacheKeep,
+		MaxAge:  cacheMaxAge,
+		MaxSize: maxSize,
+	}, nil
+}
+
+// parseSize parses a size, like "500MB" or "2GiB"; an empty string is zero.
+func parseSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := humanize.ParseBytes(s)
+	if err != nil {
+		return 0, err
+	}
+	if n > math.MaxInt64 {
+		return 0, fmt.Errorf("%s is too large", s)
+	}
+	return int64(n), nil
+}
+
+// runCacheDoctor is the entrypoint for the "cache doctor" subcommand.
+func runCacheDoctor(ctx context.Context, args []string) error {
+	cache, err := openCache()
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	problems, err := cache.Check(ctx)
+	if err != nil {
+		return fmt.Errorf("check cache: %w", err)
+	}
+
+	type doctorResult struct {
+		mods.CacheProblem
+		Repaired bool   `json:"repaired"`
+		Error    string `json:"error,omitempty"`
+	}
+	results := make([]doctorResult, 0, len(problems))
+
+	if doctorRepair || doctorRedownload {
+		opts := mods.RepairOptions{Redownload: doctorRedownload}
+		if doctorRedownload {
+			creds, err := loadCredentials()
+			if err != nil {
+				return fmt.Errorf("load credentials: %w", err)
+			}
+			opts.Username, opts.Token = creds.Username, creds.Token
+		}
+
+		repair := func(p mods.CacheProblem) {
+			r := doctorResult{CacheProblem: p, Repaired: true}
+			if err := cache.Repair(ctx, p, opts); err != nil {
+				r.Repaired, r.Error = false, err.Error()
+			}
+			results = append(results, r)
+		}
+
+		// A rebuilt database does not know about any of the archives
+		// in the cache, so they need to be checked again.
+		if len(problems) > 0 && problems[0].Kind == mods.ProblemCorruptDB {
+			repair(problems[0])
+			if !results[0].Repaired {
+				problems = nil
+			} else if problems, err = cache.Check(ctx); err != nil {
+				return fmt.Errorf("check cache: %w", err)
+			}
+			slog.WarnContext(ctx, "the mod cache database was rebuilt; run facmod update")
+		}
+		for _, p := range problems {
+			repair(p)
+		}
+	} else {
+		for _, p := range problems {
+			results = append(results, doctorResult{CacheProblem: p})
+		}
+	}
+
+	if jsonOutput() {
+		if err := writeJSON(results); err != nil {
+			return err
+		}
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+		if !noHeaders {
+			headers := []string{"PROBLEM", "PATH", "STATUS"}
+			fmt.Fprintln(tw, strings.Join(headers, "\t"))
+		}
+		for _, r := range results {
+			status := r.Detail
+			switch {
+			case r.Error != "":
+				status = "not repaired: " + r.Error
+			case r.Repaired:
+				status = "repaired"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Kind, r.Path, status)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	var failed int
+	for _, r := range results {
+		if !r.Repaired {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("problems found in the mod cache: %d", failed)
+	}
+	return nil
+}
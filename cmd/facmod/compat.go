@@ -0,0 +1,160 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// Set by command-line flags.
+var ignoreFactorioVersion bool
+
+// compatChecker collects the mods that cannot be loaded by the version of
+// Factorio in the installation directory, or the version given with
+// --factorio-version.
+type compatChecker struct {
+	game         mods.Version
+	enabled      bool
+	incompatible []string
+
+	// Whether the target installation can load mods that require
+	// features with feature flags.
+	features bool
+}
+
+// newCompatChecker returns a compatChecker for the target version of
+// Factorio.
+// When --ignore-factorio-version is given, or the version cannot be
+// determined, the returned checker accepts every mod.
+func newCompatChecker(ctx context.Context) *compatChecker {
+	c := new(compatChecker)
+	if ignoreFactorioVersion {
+		return c
+	}
+
+	v, err := targetGameVersion(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "skipping compatibility check", "err", err)
+		return c
+	}
+	c.game, c.enabled = v, true
+
+	// Feature flags were added in Factorio 2.0, and the features they turn
+	// on come with the Space Age expansion.
+	// When another version of Factorio was given with --factorio-version,
+	// the expansion is assumed to be installed.
+	c.features = v.Major >= 2
+	if c.features && factorioVersion == "" {
+		if builtins, err := mods.Builtins(installDir); err == nil {
+			_, c.features = builtins["space-age"]
+		}
+	}
+	return c
+}
+
+// resolveOptions returns the options that make dependency resolution check
+// the version constraints that mods place on the "base" mod, and on the
+// built-in mods of the game's expansions.
+// When only "MAJOR.MINOR" was given with --factorio-version, the exact
+// version of Factorio is unknown, so those constraints are not checked.
+// Built-in mods are only checked against the installation directory when
+// no other version of Factorio was given with --factorio-version.
+func (c *compatChecker) resolveOptions(ctx context.Context) []mods.ResolveOption {
+	if !c.enabled || strings.Count(factorioVersion, ".") == 1 {
+		return nil
+	}
+	opts := []mods.ResolveOption{mods.WithGameVersion(c.game)}
+	if factorioVersion == "" {
+		builtins, err := mods.Builtins(installDir)
+		if err != nil {
+			slog.DebugContext(ctx, "skipping built-in mod checks", "err", err)
+		} else {
+			opts = append(opts, mods.WithBuiltins(builtins))
+		}
+	}
+	return opts
+}
+
+// targetGameVersion returns the version of Factorio given with
+// --factorio-version, or the version installed to the installation
+// directory.
+func targetGameVersion(ctx context.Context) (mods.Version, error) {
+	if factorioVersion == "" {
+		v, err := mods.GameVersion(ctx, installDir)
+		if err != nil {
+			return mods.Version{}, fmt.Errorf("determine version of Factorio: %w", err)
+		}
+		return v, nil
+	}
+
+	v, err := mods.ParseVersion(factorioVersion)
+	if err != nil {
+		return mods.Version{}, fmt.Errorf("--factorio-version: %w", err)
+	}
+	return v, nil
+}
+
+// check records the named mod as incompatible, unless its factorio_version is
+// supported by the target version of Factorio.
+func (c *compatChecker) check(name, version, factorioVersion string) {
+	if !c.enabled || mods.SupportsGame(factorioVersion, c.game) {
+		return
+	}
+	if factorioVersion == "" {
+		factorioVersion = "0.12"
+	}
+	c.incompatible = append(c.incompatible, fmt.Sprintf("%s %s (for Factorio %s)", name, version, factorioVersion))
+}
+
+// checkFeatures records the named mod as incompatible if it requires
+// features, with the feature flags in info, that the target version of
+// Factorio cannot provide.
+func (c *compatChecker) checkFeatures(name, version string, info mods.Info) {
+	features := info.RequiredFeatures()
+	if !c.enabled || c.features || len(features) == 0 {
+		return
+	}
+	names := make([]string, len(features))
+	for i, f := range features {
+		names[i] = string(f)
+	}
+	c.incompatible = append(c.incompatible, fmt.Sprintf("%s %s (requires Space Age features: %s)", name, version, strings.Join(names, ", ")))
+}
+
+// checkRelease checks the factorio_version and feature flags of a release
+// of the named mod.
+func (c *compatChecker) checkRelease(name, version string, r mods.Release) {
+	c.check(name, version, r.FactorioVersion())
+	if info, err := r.Info(); err == nil {
+		c.checkFeatures(name, version, info)
+	}
+}
+
+// checkPlan checks every mod in plan that is not already installed.
+func (c *compatChecker) checkPlan(plan []mods.Resolved) {
+	for _, m := range plan {
+		if !m.Installed {
+			c.checkRelease(m.Name, m.Version.String(), m.Release)
+		}
+	}
+}
+
+// err returns an error listing all of the incompatible mods, or nil if there
+// are none.
+func (c *compatChecker) err() error {
+	if len(c.incompatible) == 0 {
+		return nil
+	}
+	return fmt.Errorf("mods are not compatible with Factorio %d.%d:\n\t%s\nuse --ignore-factorio-version to install them anyway",
+		c.game.Major,
+		c.game.Minor,
+		strings.Join(c.incompatible, "\n\t"),
+	)
+}
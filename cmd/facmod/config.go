@@ -0,0 +1,91 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	ff "github.com/peterbourgon/ff/v4"
+	"github.com/peterbourgon/ff/v4/fftoml"
+)
+
+// Set by command-line flags.
+var configPath string
+
+// configValues holds the value of each flag that was set from the config
+// file, keyed by the flag's long name.
+var configValues = make(map[string]string)
+
+// defaultConfigPath returns the path to the config file that is read when
+// --config is not given, or an empty string if the user's config directory
+// cannot be determined.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "facmod", "config.toml")
+}
+
+// configOptions returns the options for parsing the config file named by
+// the --config flag.
+// Each key in the config file sets the flag with the same name, unless that
+// flag was given on the command line.
+// Keys that do not name a flag of the selected subcommand, or the root
+// command, are ignored, so that one config file can hold the flags for every
+// subcommand.
+func configOptions(flags ff.Flags) []ff.Option {
+	parse := func(r io.Reader, set func(name, value string) error) error {
+		return fftoml.Parse(r, func(name, value string) error {
+			f, ok := flags.GetFlag(name)
+			if ok && f.IsSet() {
+				return nil
+			}
+			if err := set(name, value); err != nil {
+				return err
+			}
+			if ok {
+				if long, ok := f.GetLongName(); ok {
+					configValues[long] = f.GetValue()
+				}
+			}
+			return nil
+		})
+	}
+	return []ff.Option{
+		ff.WithConfigFileFlag("config"),
+		ff.WithConfigFileParser(parse),
+		ff.WithConfigAllowMissingFile(),
+		ff.WithConfigIgnoreUndefinedFlags(),
+	}
+}
+
+// checkConfigFile returns an error if the config file given with --config
+// does not exist.
+// Only the default config file is allowed to be missing.
+func checkConfigFile(flags ff.Flags) error {
+	f, ok := flags.GetFlag("config")
+	if !ok || !f.IsSet() {
+		return nil
+	}
+	if _, err := os.Stat(configPath); err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	return nil
+}
+
+// fromConfig reports whether the value of the named flag was set from the
+// config file, and not overridden on the command line.
+func fromConfig(flags ff.Flags, name string) bool {
+	v, ok := configValues[name]
+	if !ok {
+		return false
+	}
+	f, ok := flags.GetFlag(name)
+	return ok && f.GetValue() == v
+}
@@ -0,0 +1,108 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/nesv/factorio-tools/mods"
+	"github.com/nesv/factorio-tools/xdg"
+)
+
+// apiKeyEnv is the environment variable that a factorio.com API key is read
+// from, when --api-key is not given.
+const apiKeyEnv = "FACTORIO_TOOLS_API_KEY"
+
+// passwordEnv is the environment variable that a factorio.com password is
+// read from, when --password is not given.
+const passwordEnv = "FACTORIO_TOOLS_PASSWORD"
+
+// Set by command-line flags.
+var (
+	username       string
+	token          string
+	password       string
+	apiKey         string
+	playerDataPath string
+)
+
+// openedCaches are the caches opened with a password, whose refreshed
+// credentials are saved by saveRefreshedCredentials.
+var openedCaches []*mods.Cache
+
+// saveRefreshedCredentials saves the credentials the caches got by logging in
+// again, after the mod portal rejected a token, in the file "facmod login"
+// stores credentials in, so later runs do not have to log in again.
+func saveRefreshedCredentials(ctx context.Context) {
+	for _, c := range openedCaches {
+		creds, ok := c.RefreshedCredentials()
+		if !ok {
+			continue
+		}
+		path, err := credentialsPath()
+		if err == nil {
+			err = creds.Save(path)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "cannot save refreshed credentials", "err", err)
+			return
+		}
+		slog.InfoContext(ctx, "saved refreshed credentials", "path", path)
+		return
+	}
+}
+
+// loadCredentials returns the credentials to use for downloading mods.
+// Credentials given on the command line take precedence over any found in a
+// player-data.json file.
+//
+// When --player-data is not set, the credentials saved by "facmod login" are
+// tried first, followed by the player-data.json file in the Factorio
+// installation directory, and the one in the user's ~/.factorio directory.
+//
+// If no credentials are found, empty credentials are returned: mods can still
+// be downloaded from mirrors, or used from the cache without credentials.
+func loadCredentials() (mods.Credentials, error) {
+	if username != "" && token != "" {
+		return mods.Credentials{Username: username, Token: token}, nil
+	}
+
+	paths := []string{playerDataPath}
+	if playerDataPath == "" {
+		paths = nil
+		if dir, err := xdg.UserStateDir(); err == nil {
+			paths = append(paths, filepath.Join(dir, "facmod", "credentials.json"))
+		}
+		paths = append(paths, filepath.Join(installDir, "player-data.json"))
+		if home, err := os.UserHomeDir(); err == nil {
+			paths = append(paths, filepath.Join(home, ".factorio", "player-data.json"))
+		}
+	}
+
+	for _, p := range paths {
+		creds, err := mods.LoadCredentials(p)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return mods.Credentials{}, fmt.Errorf("load %s: %w", p, err)
+		}
+
+		if username != "" {
+			creds.Username = username
+		}
+		if token != "" {
+			creds.Token = token
+		}
+		return creds, nil
+	}
+
+	return mods.Credentials{Username: username, Token: token}, nil
+}
@@ -0,0 +1,197 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// Set by command-line flags.
+var (
+	depsFromCache bool
+	depsFormat    string
+)
+
+// Values for the "deps" subcommand's --format flag.
+const (
+	depsFormatTree = "tree"
+	depsFormatDOT  = "dot"
+	depsFormatJSON = "json"
+)
+
+// runDeps is the entrypoint for the "deps" subcommand.
+func runDeps(ctx context.Context, args []string) error {
+	format := depsFormat
+	if jsonOutput() {
+		format = depsFormatJSON
+	}
+	if format == depsFormatTree && len(args) != 1 {
+		return errors.New("exactly one mod name is required")
+	}
+
+	cache, err := openFreshCache(ctx)
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	lookup := func(ctx context.Context, name string) (mods.Info, error) {
+		return archiveInfo(ctx, cache, name)
+	}
+	if depsFromCache {
+		lookup = func(ctx context.Context, name string) (mods.Info, error) {
+			return cachedInfo(ctx, cache, name)
+		}
+	}
+
+	if format != depsFormatTree {
+		return writeDepGraph(ctx, format, lookup, args)
+	}
+
+	t := &depTree{
+		w:        os.Stdout,
+		lookup:   lookup,
+		expanded: make(map[string]bool),
+	}
+	return t.print(ctx, args[0])
+}
+
+// writeDepGraph prints the dependency graph of the named mods, or of every
+// installed mod when no mods are named, as DOT or JSON.
+func writeDepGraph(ctx context.Context, format string, lookup func(context.Context, string) (mods.Info, error), names []string) error {
+	if len(names) == 0 {
+		installed, err := mods.Load(installDir)
+		if err != nil {
+			return fmt.Errorf("load mods: %w", err)
+		}
+		for _, m := range installed {
+			if len(m.Versions) > 0 && !mods.IsBuiltin(m.Name) {
+				names = append(names, m.Name)
+			}
+		}
+	}
+
+	g, err := mods.BuildGraph(ctx, lookup, names...)
+	if err != nil {
+		return err
+	}
+	if format == depsFormatJSON {
+		return writeJSON(g)
+	}
+	return g.WriteDOT(os.Stdout)
+}
+
+// archiveInfo loads the info.json for the named mod from its archive.
+// The installed archive is preferred; if the mod is not installed, its latest
+// release is downloaded into the cache.
+func archiveInfo(ctx context.Context, cache *mods.Cache, name string) (mods.Info, error) {
+	installed, err := mods.Load(installDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return mods.Info{}, fmt.Errorf("load mods: %w", err)
+	}
+	for _, m := range installed {
+		if n := len(m.Versions); m.Name == name && n > 0 {
+			return mods.LoadInfo(m.Path(installDir, m.Versions[n-1]))
+		}
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		return mods.Info{}, fmt.Errorf("load credentials: %w", err)
+	}
+
+	path, err := cache.Get(ctx, name, creds.Username, creds.Token)
+	if err != nil {
+		return mods.Info{}, err
+	}
+	return mods.LoadInfo(path)
+}
+
+// cachedInfo returns the info.json of the named mod's latest release, as
+// reported by the mod portal, without downloading the mod.
+func cachedInfo(ctx context.Context, cache *mods.Cache, name string) (mods.Info, error) {
+	full, err := cache.FullInfo(ctx, name)
+	if err != nil {
+		return mods.Info{}, err
+	}
+
+	r, ok := full.LatestRelease()
+	if !ok {
+		return mods.Info{}, fmt.Errorf("%s has no releases", name)
+	}
+	return r.Info()
+}
+
+// depTree prints the transitive dependencies of a mod as a tree.
+type depTree struct {
+	w      io.Writer
+	lookup func(context.Context, string) (mods.Info, error)
+
+	// Mods whose dependencies have already been printed.
+	expanded map[string]bool
+}
+
+func (t *depTree) print(ctx context.Context, name string) error {
+	info, err := t.lookup(ctx, name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	fmt.Fprintf(t.w, "%s %s\n", info.Name, info.Version)
+	return t.printDeps(ctx, info, "", []string{info.Name})
+}
+
+// printDeps prints the dependencies of info.
+// stack holds the names of all the mods between the root of the tree and
+// info, and is used to detect dependency cycles.
+func (t *depTree) printDeps(ctx context.Context, info mods.Info, prefix string, stack []string) error {
+	deps, err := info.ParseDependencies()
+	if err != nil {
+		return fmt.Errorf("%s: %w", info.Name, err)
+	}
+	t.expanded[info.Name] = true
+
+	for i, d := range deps {
+		branch, indent := "├── ", "│   "
+		if i == len(deps)-1 {
+			branch, indent = "└── ", "    "
+		}
+		fmt.Fprint(t.w, prefix+branch+d.String())
+
+		switch {
+		case d.Kind == mods.Incompatible || mods.IsBuiltin(d.Name):
+			fmt.Fprintln(t.w)
+			continue
+
+		case slices.Contains(stack, d.Name):
+			fmt.Fprintln(t.w, " (cycle)")
+			continue
+
+		case t.expanded[d.Name]:
+			fmt.Fprintln(t.w, " (*)")
+			continue
+		}
+
+		depInfo, err := t.lookup(ctx, d.Name)
+		if err != nil {
+			fmt.Fprintf(t.w, " (error: %v)\n", err)
+			continue
+		}
+		fmt.Fprintf(t.w, " [%s]\n", depInfo.Version)
+
+		if err := t.printDeps(ctx, depInfo, prefix+indent, append(stack, d.Name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
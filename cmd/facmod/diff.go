@@ -0,0 +1,87 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// runDiff is the entrypoint for the "diff" subcommand.
+func runDiff(ctx context.Context, args []string) error {
+	path := lockfile()
+	switch len(args) {
+	case 0:
+	case 1:
+		path = args[0]
+	default:
+		return errors.New("too many arguments")
+	}
+
+	// Manifests written by "facmod export" use the same layout as
+	// lockfiles, minus the SHA1 hashes, so either can be loaded here.
+	lock, err := mods.LoadLockfile(path)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", path, err)
+	}
+
+	changes, err := lock.Diff(installDir)
+	if err != nil {
+		return fmt.Errorf("diff: %w", err)
+	}
+
+	if jsonOutput() {
+		type change struct {
+			Name   string          `json:"name"`
+			Change mods.ChangeKind `json:"change"`
+			From   string          `json:"from,omitempty"`
+			To     string          `json:"to,omitempty"`
+		}
+		out := make([]change, len(changes))
+		for i, c := range changes {
+			out[i] = change{Name: c.Name, Change: c.Kind}
+			if !c.From.IsZero() {
+				out[i].From = c.From.String()
+			}
+			if !c.To.IsZero() {
+				out[i].To = c.To.String()
+			}
+		}
+		if err := writeJSON(out); err != nil {
+			return err
+		}
+	} else if len(changes) > 0 {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+		if !noHeaders {
+			headers := []string{"NAME", "CHANGE", "FROM", "TO"}
+			fmt.Fprintln(tw, strings.Join(headers, "\t"))
+		}
+		for _, c := range changes {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.Name, c.Kind, versionString(c.From), versionString(c.To))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(changes) > 0 {
+		return fmt.Errorf("installation differs from %s by %d changes", path, len(changes))
+	}
+	return nil
+}
+
+// versionString returns v as a string, or "-" when v is the zero value.
+func versionString(v mods.Version) string {
+	if v.IsZero() {
+		return "-"
+	}
+	return v.String()
+}
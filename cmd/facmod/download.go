@@ -0,0 +1,63 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// runDownload is the entrypoint for the "download" subcommand.
+func runDownload(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("at least one mod is required")
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		return fmt.Errorf("load credentials: %w", err)
+	}
+
+	cache, err := openFreshCache(ctx)
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	var paths []string
+	for _, arg := range args {
+		name, version := parseModArg(arg)
+
+		var path string
+		if version == "" {
+			path, err = cache.Get(ctx, name, creds.Username, creds.Token)
+		} else {
+			path, err = cache.GetVersion(ctx, name, version, creds.Username, creds.Token)
+		}
+		if err != nil {
+			return fmt.Errorf("download %s: %w", arg, err)
+		}
+
+		if !jsonOutput() {
+			fmt.Println(path)
+		}
+		paths = append(paths, path)
+	}
+
+	if jsonOutput() {
+		return writeJSON(paths)
+	}
+	return nil
+}
+
+// parseModArg splits a command-line argument of the form "MOD[==VERSION]"
+// into the mod's name, and the version.
+// If no version was specified, version will be an empty string.
+func parseModArg(arg string) (name, version string) {
+	name, version, _ = strings.Cut(arg, "==")
+	return strings.TrimSpace(name), strings.TrimSpace(version)
+}
@@ -0,0 +1,136 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// Set by command-line flags.
+var dryRun bool
+
+// dryRunPlan prints the changes that installing or removing mods would make,
+// without making them.
+// It keeps its own copy of mod-list.json, so that the changes described for
+// one mod account for the changes described for the mods before it.
+type dryRunPlan struct {
+	cache *mods.Cache
+	list  *mods.ModList
+}
+
+func newDryRunPlan(cache *mods.Cache) (*dryRunPlan, error) {
+	list, err := mods.LoadModList(installDir)
+	if err != nil {
+		return nil, fmt.Errorf("load mod list: %w", err)
+	}
+	return &dryRunPlan{cache: cache, list: list}, nil
+}
+
+// install describes the effects of downloading the release r of the named
+// mod, and passing it to [mods.Install].
+func (p *dryRunPlan) install(name string, r mods.Release, enable bool) error {
+	fileName := filepath.Base(r.FileName)
+	cached := filepath.Join(p.cache.ModsDir(), fileName)
+	if _, err := os.Stat(cached); err == nil {
+		fmt.Printf("would use cached %s\n", cached)
+	} else {
+		fmt.Printf("would download %s\n", fileName)
+	}
+
+	dst := filepath.Join(installDir, "mods", fileName)
+	fmt.Printf("would copy %s to %s\n", fileName, dst)
+	return p.place(name, dst, enable)
+}
+
+// installFile describes the effects of passing the mod archive at path to
+// [mods.InstallFile].
+func (p *dryRunPlan) installFile(path string, info mods.Info, enable bool) error {
+	dst := filepath.Join(installDir, "mods", info.FileName())
+	fmt.Printf("would copy %s to %s\n", path, dst)
+	return p.place(info.Name, dst, enable)
+}
+
+// place describes the effects of installing the named mod's archive to dst:
+// the deletion of the mod's other archives, and the changes to
+// mod-list.json.
+func (p *dryRunPlan) place(name, dst string, enable bool) error {
+	if err := p.deleteArchives(name, dst); err != nil {
+		return err
+	}
+
+	var listed, enabled bool
+	for _, e := range p.list.Mods {
+		if e.Name == name {
+			listed, enabled = true, e.Enabled
+		}
+	}
+	switch {
+	case !listed:
+		state := "disabled"
+		if enable {
+			state = "enabled"
+		}
+		fmt.Printf("would add %s to mod-list.json (%s)\n", name, state)
+	case enable && !enabled:
+		fmt.Printf("would enable %s in mod-list.json\n", name)
+	}
+	p.list.Add(name, enable)
+
+	return nil
+}
+
+// remove describes the effects of passing the named mod to [mods.Uninstall].
+func (p *dryRunPlan) remove(name string) error {
+	if err := p.deleteArchives(name, ""); err != nil {
+		return err
+	}
+
+	if p.list.Remove(name) {
+		fmt.Printf("would remove %s from mod-list.json\n", name)
+	}
+	return nil
+}
+
+// deleteArchives describes the deletion of every installed copy of the named
+// mod, as found by [mods.InstalledPaths], except for keep.
+func (p *dryRunPlan) deleteArchives(name, keep string) error {
+	paths, err := mods.InstalledPaths(installDir, name)
+	if err != nil {
+		return err
+	}
+	for _, m := range paths {
+		if m == keep {
+			continue
+		}
+		fmt.Printf("would delete %s\n", m)
+	}
+	return nil
+}
+
+// applyModList describes the changes [mods.Modpack.ApplyModList] would make
+// to mod-list.json.
+func (p *dryRunPlan) applyModList(pack *mods.Modpack) {
+	for _, m := range pack.Mods {
+		var listed, enabled bool
+		for _, e := range p.list.Mods {
+			if e.Name == m.Name {
+				listed, enabled = true, e.Enabled
+			}
+		}
+		switch {
+		case !listed:
+			// Already described by install.
+		case m.Disabled && enabled:
+			fmt.Printf("would disable %s in mod-list.json\n", m.Name)
+		case !m.Disabled && !enabled:
+			fmt.Printf("would enable %s in mod-list.json\n", m.Name)
+		}
+	}
+	pack.ApplyModList(p.list)
+}
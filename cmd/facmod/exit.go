@@ -0,0 +1,39 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"errors"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// Exit codes, so scripts can tell why facmod failed.
+const (
+	exitError     = 1 // Any error not listed below.
+	exitUsage     = 2 // Invalid command-line flags or arguments.
+	exitNotFound  = 3 // A mod, or a release of a mod, does not exist.
+	exitNotCached = 4 // A mod is not in the mod cache.
+	exitAuth      = 5 // factorio.com credentials are missing or were rejected.
+	exitChecksum  = 6 // A file does not match its published checksum.
+)
+
+// exitStatus returns the exit code for err, and a hint describing how the
+// user might resolve it, if there is one.
+func exitStatus(err error) (code int, hint string) {
+	switch {
+	case errors.Is(err, mods.ErrModNotFound), errors.Is(err, mods.ErrReleaseNotFound):
+		return exitNotFound, "check the mod's name and version with 'facmod search' or 'facmod info'"
+	case errors.Is(err, mods.ErrNotCached):
+		return exitNotCached, "run 'facmod update' to refresh the mod cache"
+	case errors.Is(err, mods.ErrAuthRequired):
+		return exitAuth, "run 'facmod login', or give --username and --token; publish requires --api-key"
+	case errors.Is(err, mods.ErrAuthFailed):
+		return exitAuth, "the token or API key may have expired; run 'facmod login' again, or set " + passwordEnv + " to log in again automatically"
+	case errors.Is(err, mods.ErrChecksumMismatch):
+		return exitChecksum, "the file may be corrupt, or may have been tampered with; delete it and download it again"
+	}
+	return exitError, ""
+}
@@ -0,0 +1,82 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// runExport is the entrypoint for the "export" subcommand.
+func runExport(ctx context.Context, args []string) error {
+	m, err := mods.Export(installDir)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+
+	if len(args) == 0 || args[0] == "-" {
+		_, err := m.WriteTo(os.Stdout)
+		return err
+	}
+
+	f, err := os.Create(args[0])
+	if err != nil {
+		return fmt.Errorf("create manifest: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := m.WriteTo(f); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// runImport is the entrypoint for the "import" subcommand.
+func runImport(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one manifest file is required")
+	}
+
+	var m mods.Manifest
+	if args[0] == "-" {
+		if _, err := m.ReadFrom(os.Stdin); err != nil {
+			return fmt.Errorf("read manifest: %w", err)
+		}
+	} else {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("open manifest: %w", err)
+		}
+		defer f.Close()
+		if _, err := m.ReadFrom(f); err != nil {
+			return fmt.Errorf("read manifest: %w", err)
+		}
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		return fmt.Errorf("load credentials: %w", err)
+	}
+
+	cache, err := openFreshCache(ctx)
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	fetch := func(ctx context.Context, name string, version mods.Version) (string, error) {
+		fmt.Printf("Installing %s %s\n", name, version)
+		return cache.GetVersion(ctx, name, version.String(), creds.Username, creds.Token)
+	}
+	if err := m.Import(ctx, installDir, fetch); err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// gitPrefix marks arguments to the "install" subcommand that name a git
+// repository, like "git+https://github.com/user/mod.git@v1.2.3".
+const gitPrefix = "git+"
+
+// isGitURL reports whether the argument to the "install" subcommand names a
+// git repository.
+func isGitURL(arg string) bool {
+	return strings.HasPrefix(arg, gitPrefix)
+}
+
+// gitSource is a parsed "git+URL[@REF][#SUBDIR]" argument.
+type gitSource struct {
+	url    string
+	ref    string // Defaults to HEAD.
+	subdir string // The mod's directory within the repository.
+}
+
+func parseGitSource(arg string) (gitSource, error) {
+	s := strings.TrimPrefix(arg, gitPrefix)
+
+	var src gitSource
+	s, src.subdir, _ = strings.Cut(s, "#")
+
+	// Only look for a ref after the last "/", so user names in URLs like
+	// "git+ssh://git@example.com/mod.git" are left alone.
+	if i := strings.LastIndex(s, "@"); i > strings.LastIndex(s, "/") {
+		s, src.ref = s[:i], s[i+1:]
+	}
+	if s == "" {
+		return gitSource{}, fmt.Errorf("missing repository url: %s", arg)
+	}
+
+	// The subdirectory must stay inside the checkout.
+	if src.subdir != "" && !filepath.IsLocal(filepath.FromSlash(src.subdir)) {
+		return gitSource{}, fmt.Errorf("subdirectory %q is outside the repository: %s", src.subdir, arg)
+	}
+	src.url = s
+	if src.ref == "" {
+		src.ref = "HEAD"
+	}
+	return src, nil
+}
+
+// fetchGit checks out the mod from the git repository named by arg, and
+// packages it into a mod archive at dst.
+// The repository is checked out into a new directory under tmpDir.
+func fetchGit(ctx context.Context, tmpDir, dst, arg string) error {
+	src, err := parseGitSource(arg)
+	if err != nil {
+		return err
+	}
+
+	repo, err := os.MkdirTemp(tmpDir, "git-*")
+	if err != nil {
+		return fmt.Errorf("make temp dir: %w", err)
+	}
+
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		// "--" keeps a url or ref starting with "-" from being read as
+		// an option.
+		{"fetch", "--quiet", "--depth", "1", "--", src.url, src.ref},
+		{"checkout", "--quiet", "FETCH_HEAD"},
+	} {
+		if err := runGit(ctx, repo, args...); err != nil {
+			return fmt.Errorf("%s: %w", arg, err)
+		}
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("create archive: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := mods.PackDir(filepath.Join(repo, filepath.FromSlash(src.subdir)), f); err != nil {
+		return fmt.Errorf("package %s: %w", arg, err)
+	}
+	return f.Close()
+}
+
+// runGit runs git with the given arguments in dir.
+// The returned error includes anything git wrote to STDERR.
+func runGit(ctx context.Context, dir string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return errors.New(msg)
+		}
+		return fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/nesv/factorio-tools/mods"
+	"github.com/nesv/factorio-tools/notify"
+)
+
+// Set by command-line flags.
+var noHooks bool
+
+// loadHooks returns the hooks configured in hooks.json, which maps each
+// event, like "pre-install", to a list of shell commands.
+// A missing hooks.json is not an error.
+func loadHooks() (*mods.Hooks, error) {
+	hooks := new(mods.Hooks)
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return hooks, nil
+	}
+	path := filepath.Join(dir, "facmod", "hooks.json")
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return hooks, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("open hooks: %w", err)
+	}
+	defer f.Close()
+
+	var configured map[mods.HookEvent][]string
+	if err := json.NewDecoder(f).Decode(&configured); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	for event, commands := range configured {
+		if !slices.Contains(mods.HookEvents, event) {
+			return nil, fmt.Errorf("%s: unknown hook event: %q", path, event)
+		}
+		for _, command := range commands {
+			hooks.Register(event, mods.CommandHook(command))
+		}
+	}
+
+	if err := registerNotifyHook(hooks); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// registerNotifyHook registers a hook posting a notification for each mod
+// that is upgraded, when facsrv's notify config exists.
+func registerNotifyHook(hooks *mods.Hooks) error {
+	path, err := notify.DefaultConfigPath()
+	if err != nil {
+		return nil
+	}
+	notifier, err := notify.Load(path)
+	if err != nil || notifier == nil {
+		return err
+	}
+
+	hooks.Register(mods.PostUpgrade, func(ctx context.Context, args mods.HookArgs) error {
+		if args.Err != nil {
+			return nil
+		}
+		for _, m := range args.Mods {
+			// The upgrade is done, so failing to notify does
+			// not fail it.
+			err := notifier.Notify(ctx, notify.Notification{
+				Event: notify.ModUpdate,
+				Mod:   m.Name,
+				From:  m.From,
+				To:    m.To,
+			})
+			if err != nil {
+				slog.WarnContext(ctx, "cannot send notification", "mod", m.Name, "err", err)
+			}
+		}
+		return nil
+	})
+	return nil
+}
+
+// withHooks runs the pre hooks for the changed mods, then fn, then the post
+// hooks, which also run when fn fails.
+// No hooks are run for dry runs, when there is nothing to change, or when
+// hooks are disabled with --no-hooks.
+func withHooks(ctx context.Context, pre, post mods.HookEvent, changed []mods.HookMod, fn func() error) error {
+	if dryRun || noHooks || len(changed) == 0 {
+		return fn()
+	}
+
+	hooks, err := loadHooks()
+	if err != nil {
+		return err
+	}
+
+	args := mods.HookArgs{Event: pre, InstallDir: installDir, Mods: changed}
+	if err := hooks.Run(ctx, args); err != nil {
+		return err
+	}
+
+	err = fn()
+	args.Event, args.Err = post, err
+	return errors.Join(err, hooks.Run(ctx, args))
+}
@@ -0,0 +1,199 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	humanize "github.com/dustin/go-humanize"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// Set by command-line flags.
+var (
+	infoThumbnail bool
+	infoChangelog bool
+)
+
+// runInfo is the entrypoint for the "info" subcommand.
+func runInfo(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one mod name is required")
+	}
+
+	cache, err := openFreshCache(ctx)
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	local, err := installedArchiveInfo(args[0])
+	if err != nil {
+		slog.WarnContext(ctx, "cannot read installed mod", "mod", args[0], "err", err)
+	}
+
+	info, err := cache.FullInfo(ctx, args[0])
+	if err != nil && local != nil {
+		// Mods that are not on the mod portal, like private mods, can
+		// still be described by their archives, as can any installed mod
+		// when the mod portal cannot be reached.
+		if !errors.Is(err, mods.ErrModNotFound) {
+			slog.WarnContext(ctx, "showing the installed mod's info.json", "err", err)
+		}
+		return printLocalInfo(local)
+	} else if err != nil {
+		return fmt.Errorf("get mod info: %w", err)
+	}
+
+	if jsonOutput() {
+		return writeJSON(info)
+	}
+
+	if infoThumbnail {
+		if local != nil && local.Thumbnail != nil {
+			err = showImage(info.Name+".png", local.Thumbnail)
+		} else {
+			err = showCachedThumbnail(ctx, cache, info.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintf(tw, "Name:\t%s\n", info.Name)
+	fmt.Fprintf(tw, "Title:\t%s\n", info.Title)
+	fmt.Fprintf(tw, "Owner:\t%s\n", info.Owner)
+	fmt.Fprintf(tw, "Category:\t%s\n", info.Category)
+	fmt.Fprintf(tw, "Tags:\t%s\n", strings.Join(info.Tags, ", "))
+	fmt.Fprintf(tw, "License:\t%s (%s)\n", info.License.Title, info.License.ID)
+	fmt.Fprintf(tw, "License URL:\t%s\n", info.License.URL)
+	fmt.Fprintf(tw, "Source:\t%s\n", info.SourceURL)
+	fmt.Fprintf(tw, "Homepage:\t%s\n", info.Homepage)
+	fmt.Fprintf(tw, "Downloads:\t%s\n", humanize.Comma(int64(info.DownloadsCount)))
+	fmt.Fprintf(tw, "Created:\t%s\n", humanize.Time(info.CreatedAt))
+	if local != nil {
+		fmt.Fprintf(tw, "Installed:\t%s\n", local.Version)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if info.Description != "" {
+		fmt.Printf("\n%s\n", strings.TrimSpace(info.Description))
+	}
+
+	fmt.Println()
+	tw = tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	if !noHeaders {
+		headers := []string{"VERSION", "FACTORIO", "RELEASED"}
+		fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	}
+
+	// Show the most-recent releases first.
+	for i := len(info.Releases) - 1; i >= 0; i-- {
+		r := info.Releases[i]
+		fmt.Fprintf(tw, "%s\t%s\t%s\n",
+			r.Version,
+			r.FactorioVersion(),
+			humanize.Time(r.ReleasedAt),
+		)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if infoChangelog {
+		// Prefer the changelog of the installed version.
+		changelog := info.Changelog
+		if local != nil && local.Changelog != "" {
+			changelog = local.Changelog
+		}
+		printChangelog(changelog)
+	}
+	return nil
+}
+
+// showCachedThumbnail displays the thumbnail of the named mod, downloading it
+// into the cache if needed.
+func showCachedThumbnail(ctx context.Context, cache *mods.Cache, name string) error {
+	path, err := cache.Thumbnail(ctx, name)
+	if err != nil {
+		return fmt.Errorf("get thumbnail: %w", err)
+	}
+	return showThumbnail(name, path)
+}
+
+// installedArchiveInfo returns the metadata of the installed version of the
+// named mod, or nil if the mod is not installed.
+func installedArchiveInfo(name string) (*mods.ArchiveInfo, error) {
+	installed, err := mods.Load(installDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("load mods: %w", err)
+	}
+
+	for _, m := range installed {
+		if n := len(m.Versions); m.Name == name && n > 0 {
+			ai, err := mods.LoadArchiveMetadata(m.Path(installDir, m.Versions[n-1]))
+			if err != nil {
+				return nil, err
+			}
+			return &ai, nil
+		}
+	}
+	return nil, nil
+}
+
+// printLocalInfo describes an installed mod using the metadata in its
+// archive.
+func printLocalInfo(ai *mods.ArchiveInfo) error {
+	if jsonOutput() {
+		return writeJSON(ai)
+	}
+
+	if infoThumbnail && ai.Thumbnail != nil {
+		if err := showImage(ai.Name+".png", ai.Thumbnail); err != nil {
+			return err
+		}
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintf(tw, "Name:\t%s\n", ai.Name)
+	fmt.Fprintf(tw, "Title:\t%s\n", ai.Title)
+	fmt.Fprintf(tw, "Author:\t%s\n", ai.Author)
+	fmt.Fprintf(tw, "Contact:\t%s\n", ai.Contact)
+	fmt.Fprintf(tw, "Homepage:\t%s\n", ai.Homepage)
+	fmt.Fprintf(tw, "Factorio:\t%s\n", ai.FactorioVersion)
+	fmt.Fprintf(tw, "Installed:\t%s\n", ai.Version)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if ai.Description != "" {
+		fmt.Printf("\n%s\n", strings.TrimSpace(ai.Description))
+	}
+	if infoChangelog {
+		printChangelog(ai.Changelog)
+	}
+	return nil
+}
+
+// printChangelog prints a mod's changelog, after a blank line.
+func printChangelog(changelog string) {
+	if changelog = strings.TrimSpace(changelog); changelog == "" {
+		changelog = "No changelog."
+	}
+	fmt.Printf("\n%s\n", changelog)
+}
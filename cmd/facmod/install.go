@@ -0,0 +1,533 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// Set by command-line flags.
+var (
+	installEnable bool
+	installNoDeps bool
+	installSHA1   string
+	installSHA256 string
+)
+
+// runInstall is the entrypoint for the "install" subcommand.
+func runInstall(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("at least one mod is required")
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		return fmt.Errorf("load credentials: %w", err)
+	}
+
+	pins, err := loadPins()
+	if err != nil {
+		return fmt.Errorf("load pins: %w", err)
+	}
+
+	cache, err := openFreshCache(ctx)
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	sums := mods.Checksums{SHA1: installSHA1, SHA256: installSHA256}
+	var urls int
+	for _, arg := range args {
+		if isURL(arg) {
+			urls++
+		}
+	}
+	if sums != (mods.Checksums{}) && urls != 1 {
+		return errors.New("--sha1 and --sha256 can only be used when installing a single mod from a URL")
+	}
+
+	tmpDir, err := os.MkdirTemp("", "facmod-install-*")
+	if err != nil {
+		return fmt.Errorf("make temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var (
+		requested []mods.Dependency
+		archives  []localArchive
+	)
+	for i, arg := range args {
+		if isGitURL(arg) || isURL(arg) || isArchivePath(arg) {
+			path := arg
+			switch {
+			case isGitURL(arg):
+				path = filepath.Join(tmpDir, strconv.Itoa(i)+".zip")
+				if err := fetchGit(ctx, tmpDir, path, arg); err != nil {
+					return err
+				}
+			case isURL(arg):
+				path = filepath.Join(tmpDir, strconv.Itoa(i)+".zip")
+				if err := fetchArchive(ctx, path, arg, sums); err != nil {
+					return err
+				}
+			}
+			info, err := mods.LoadFileInfo(path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", arg, err)
+			}
+			archives = append(archives, localArchive{path: path, source: arg, info: info})
+			continue
+		}
+
+		name, version := parseModArg(arg)
+		if mods.IsBuiltin(name) {
+			return fmt.Errorf("%s ships with the game, and cannot be installed from the mod portal", name)
+		}
+		d := mods.Dependency{Name: name}
+		if version != "" {
+			v, err := mods.ParseVersion(version)
+			if err != nil {
+				return fmt.Errorf("%s: %w", arg, err)
+			}
+			d.Op, d.Version = "=", v
+		}
+		requested = append(requested, d)
+	}
+
+	installed, err := installedVersions()
+	if err != nil {
+		return err
+	}
+
+	// Local archives are treated as installed, so they are never looked up
+	// on the mod portal, but their required dependencies are resolved
+	// through the cache like any other mod's.
+	dependencyOf := make(map[string]bool)
+	for _, a := range archives {
+		v, _ := mods.ParseVersion(a.info.Version)
+		installed[a.info.Name] = v
+		if installNoDeps {
+			continue
+		}
+
+		deps, err := a.info.ParseDependencies()
+		if err != nil {
+			return fmt.Errorf("%s: %w", a.source, err)
+		}
+		for _, d := range deps {
+			if d.Kind == mods.Required || d.Kind == mods.NoLoadOrder {
+				requested = append(requested, d)
+				dependencyOf[d.Name] = true
+			}
+		}
+	}
+	for _, arg := range args {
+		name, _ := parseModArg(arg)
+		delete(dependencyOf, name)
+	}
+
+	installedDeps, err := installedDependencies(ctx)
+	if err != nil {
+		return err
+	}
+
+	compat := newCompatChecker(ctx)
+	opts := append(compat.resolveOptions(ctx),
+		mods.WithInstalled(installed),
+		mods.WithInstalledDependencies(installedDeps),
+		mods.WithPins(pins),
+	)
+	if installNoDeps {
+		opts = append(opts, mods.WithoutDependencies())
+	}
+	plan, err := cache.Resolve(ctx, requested, opts...)
+	if err != nil {
+		return fmt.Errorf("resolve dependencies: %w", err)
+	}
+
+	compat.checkPlan(plan)
+	for _, a := range archives {
+		compat.check(a.info.Name, a.info.Version, a.info.FactorioVersion)
+		compat.checkFeatures(a.info.Name, a.info.Version, a.info)
+	}
+	if err := compat.err(); err != nil {
+		return err
+	}
+
+	var dry *dryRunPlan
+	if dryRun {
+		if dry, err = newDryRunPlan(cache); err != nil {
+			return err
+		}
+	}
+
+	changed := installChanges(plan, installed)
+	for _, a := range archives {
+		changed = append(changed, mods.HookMod{Name: a.info.Name, To: a.info.Version})
+	}
+	return withHooks(ctx, mods.PreInstall, mods.PostInstall, changed, func() error {
+		if err := installResolved(ctx, cache, creds, plan, dry, installEnable, dependencyOf); err != nil {
+			return err
+		}
+
+		for _, a := range archives {
+			if dry != nil {
+				fmt.Printf("%s %s (%s):\n", a.info.Name, a.info.Version, a.source)
+				if err := dry.installFile(a.source, a.info, installEnable); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if _, err := mods.InstallFile(installDir, a.path, installEnable); err != nil {
+				return fmt.Errorf("install %s: %w", a.source, err)
+			}
+			fmt.Printf("Installed %s %s (%s)\n", a.info.Name, a.info.Version, a.source)
+		}
+		return nil
+	})
+}
+
+// installChanges returns the mods in plan that installResolved would
+// install, for passing to hooks.
+func installChanges(plan []mods.Resolved, installed map[string]mods.Version) []mods.HookMod {
+	var changed []mods.HookMod
+	for _, m := range plan {
+		if m.Installed {
+			continue
+		}
+		hm := mods.HookMod{Name: m.Name, To: m.Version.String()}
+		if v, ok := installed[m.Name]; ok {
+			hm.From = v.String()
+		}
+		changed = append(changed, hm)
+	}
+	return changed
+}
+
+// installResolved downloads and installs each mod in plan that is not already
+// installed, or describes what it would do when dry is not nil.
+// Requested mods that are named in dependencyOf are reported as dependencies.
+func installResolved(ctx context.Context, cache *mods.Cache, creds mods.Credentials, plan []mods.Resolved, dry *dryRunPlan, enable bool, dependencyOf map[string]bool) error {
+	var paths map[string]string
+	if dry == nil {
+		var err error
+		if paths, err = downloadPlan(ctx, cache, creds, plan); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range plan {
+		requested := m.Requested && !dependencyOf[m.Name]
+		if m.Installed {
+			if requested {
+				fmt.Printf("%s %s is already installed\n", m.Name, m.Version)
+			}
+			continue
+		}
+
+		if dry != nil {
+			fmt.Printf("%s %s:\n", m.Name, m.Version)
+			if err := dry.install(m.Name, m.Release, enable); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := mods.Install(installDir, paths[m.Name], enable); err != nil {
+			return fmt.Errorf("install %s: %w", m.Name, err)
+		}
+
+		if requested {
+			fmt.Printf("Installed %s %s\n", m.Name, m.Version)
+		} else {
+			fmt.Printf("Installed %s %s (dependency)\n", m.Name, m.Version)
+		}
+	}
+	return nil
+}
+
+// downloadPlan downloads every mod in plan that is not already installed,
+// several at a time, and returns the path to each mod's archive, by name.
+// All of the downloads are attempted, even when some of them fail.
+func downloadPlan(ctx context.Context, cache *mods.Cache, creds mods.Credentials, plan []mods.Resolved) (map[string]string, error) {
+	var pending []mods.Resolved
+	for _, m := range plan {
+		if !m.Installed {
+			pending = append(pending, m)
+		}
+	}
+
+	paths := make(map[string]string, len(pending))
+	var errs []error
+	for _, r := range cache.GetAll(ctx, pending, creds.Username, creds.Token) {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("download %s: %w", r.Name, r.Err))
+			continue
+		}
+		paths[r.Name] = r.Path
+	}
+	return paths, errors.Join(errs...)
+}
+
+// localArchive is a mod archive given to the "install" subcommand by path or
+// URL, rather than by name.
+type localArchive struct {
+	path   string // The archive on the local filesystem.
+	source string // The path or URL given on the command line.
+	info   mods.Info
+}
+
+// isArchivePath reports whether the argument to the "install" subcommand
+// names a mod archive on the local filesystem, rather than a mod on the mod
+// portal.
+func isArchivePath(arg string) bool {
+	return strings.HasSuffix(strings.ToLower(arg), ".zip")
+}
+
+// isURL reports whether the argument to the "install" subcommand is the URL
+// of a mod archive.
+func isURL(arg string) bool {
+	return strings.HasPrefix(arg, "https://") || strings.HasPrefix(arg, "http://")
+}
+
+// fetchArchive downloads the mod archive at urlStr to dst.
+func fetchArchive(ctx context.Context, dst, urlStr string, sums mods.Checksums) error {
+	if sums == (mods.Checksums{}) {
+		slog.WarnContext(ctx, "no checksum given; the downloaded archive will not be verified", "url", urlStr)
+	}
+	if err := mods.DownloadFile(ctx, dst, urlStr, sums); err != nil {
+		return fmt.Errorf("download %s: %w", urlStr, err)
+	}
+	return nil
+}
+
+// installedVersions returns the latest installed version of each mod in the
+// installation directory.
+// Mods that ship with the game are not included.
+func installedVersions() (map[string]mods.Version, error) {
+	installed := make(map[string]mods.Version)
+
+	mm, err := mods.Load(installDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return installed, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("load mods: %w", err)
+	}
+
+	for _, m := range mm {
+		if n := len(m.Versions); n > 0 {
+			installed[m.Name] = m.Versions[n-1]
+		}
+	}
+	return installed, nil
+}
+
+// installedDependencies returns the dependencies declared in the info.json of
+// the latest installed version of each mod in the installation directory, so
+// the resolver can check them against the mods it selects.
+// Mods whose info.json cannot be read are left out.
+func installedDependencies(ctx context.Context) (map[string][]mods.Dependency, error) {
+	deps := make(map[string][]mods.Dependency)
+
+	mm, err := mods.Load(installDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return deps, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("load mods: %w", err)
+	}
+
+	for _, m := range mm {
+		n := len(m.Versions)
+		if n == 0 {
+			continue
+		}
+		info, err := mods.LoadInfo(m.Path(installDir, m.Versions[n-1]))
+		if err != nil {
+			slog.WarnContext(ctx, "cannot read info.json of installed mod", "mod", m.Name, "err", err)
+			continue
+		}
+		dd, err := info.ParseDependencies()
+		if err != nil {
+			slog.WarnContext(ctx, "cannot parse dependencies of installed mod", "mod", m.Name, "err", err)
+			continue
+		}
+		deps[m.Name] = dd
+	}
+	return deps, nil
+}
+
+// runUpgrade is the entrypoint for the "upgrade" subcommand.
+func runUpgrade(ctx context.Context, args []string) error {
+	loaded, err := mods.Load(installDir)
+	if err != nil {
+		return fmt.Errorf("load mods: %w", err)
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		return fmt.Errorf("load credentials: %w", err)
+	}
+
+	pins, err := loadPins()
+	if err != nil {
+		return fmt.Errorf("load pins: %w", err)
+	}
+
+	cache, err := openFreshCache(ctx)
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	only := make(map[string]bool, len(args))
+	for _, name := range args {
+		only[name] = true
+	}
+
+	var (
+		installed = make(map[string]mods.Version)
+		requested []mods.Dependency
+		names     []string
+	)
+	for _, m := range loaded {
+		// Mods without any archives in the mods directory, like "base",
+		// ship with the game and cannot be upgraded.
+		n := len(m.Versions)
+		if n == 0 {
+			continue
+		}
+		current := m.Versions[n-1]
+		installed[m.Name] = current
+
+		if len(only) > 0 && !only[m.Name] {
+			continue
+		}
+
+		// Unpacked mods are usually being developed, and are managed
+		// by hand.
+		if m.IsDir(current) {
+			slog.DebugContext(ctx, "skipping unpacked mod", "mod", m.Name, "version", current)
+			continue
+		}
+
+		requested = append(requested, mods.Dependency{Name: m.Name})
+		names = append(names, m.Name)
+	}
+	if len(requested) == 0 {
+		return nil
+	}
+
+	// Every installed mod is passed to the resolver, so that the upgraded
+	// releases are checked against the constraints of the mods that are
+	// not upgraded, and new required dependencies are installed.
+	installedDeps, err := installedDependencies(ctx)
+	if err != nil {
+		return err
+	}
+
+	compat := newCompatChecker(ctx)
+	opts := append(compat.resolveOptions(ctx),
+		mods.WithInstalled(installed),
+		mods.WithInstalledDependencies(installedDeps),
+		mods.WithPins(pins),
+		mods.WithUpgrades(names...),
+	)
+	plan, err := cache.Resolve(ctx, requested, opts...)
+	if err != nil {
+		return fmt.Errorf("resolve dependencies: %w", err)
+	}
+
+	for _, m := range plan {
+		if v, ok := pins[m.Name]; ok && m.Requested {
+			if held, err := heldByPin(ctx, cache, pins, m.Name); err != nil {
+				return err
+			} else if held {
+				fmt.Printf("%s: held at %s by pin\n", m.Name, v)
+			}
+		}
+	}
+
+	compat.checkPlan(plan)
+	if err := compat.err(); err != nil {
+		return err
+	}
+
+	var dry *dryRunPlan
+	if dryRun {
+		if dry, err = newDryRunPlan(cache); err != nil {
+			return err
+		}
+	}
+
+	changed := installChanges(plan, installed)
+	return withHooks(ctx, mods.PreUpgrade, mods.PostUpgrade, changed, func() error {
+		var paths map[string]string
+		if dry == nil {
+			var err error
+			if paths, err = downloadPlan(ctx, cache, creds, plan); err != nil {
+				return err
+			}
+		}
+
+		for _, m := range plan {
+			if m.Installed {
+				continue
+			}
+
+			// New dependencies are enabled, so that the mods that
+			// require them keep loading; upgraded mods keep their
+			// state.
+			from, upgrade := installed[m.Name]
+			if dry != nil {
+				if upgrade {
+					fmt.Printf("%s: %s -> %s:\n", m.Name, from, m.Version)
+				} else {
+					fmt.Printf("%s %s (dependency):\n", m.Name, m.Version)
+				}
+				if err := dry.install(m.Name, m.Release, !upgrade); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := mods.Install(installDir, paths[m.Name], !upgrade); err != nil {
+				return fmt.Errorf("install %s: %w", m.Name, err)
+			}
+
+			if upgrade {
+				fmt.Printf("%s: %s -> %s\n", m.Name, from, m.Version)
+			} else {
+				fmt.Printf("Installed %s %s (dependency)\n", m.Name, m.Version)
+			}
+		}
+		return nil
+	})
+}
+
+// heldByPin reports whether the latest release of the named mod is newer
+// than the version it is pinned to.
+func heldByPin(ctx context.Context, cache *mods.Cache, pins mods.Pins, name string) (bool, error) {
+	latest, err := cache.LatestRelease(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	v, err := mods.ParseVersion(latest.Version)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", name, err)
+	}
+	return !pins.Allows(name, v), nil
+}
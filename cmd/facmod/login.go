@@ -0,0 +1,99 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// Set by command-line flags.
+var loginEmailCode string
+
+// runLogin is the entrypoint for the "login" subcommand.
+func runLogin(ctx context.Context, args []string) error {
+	if len(args) > 1 {
+		return errors.New("too many arguments")
+	}
+
+	stdin := bufio.NewReader(os.Stdin)
+
+	user := username
+	if len(args) == 1 {
+		user = args[0]
+	}
+	if user == "" {
+		fmt.Fprint(os.Stderr, "Username or email: ")
+		line, err := stdin.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("read username: %w", err)
+		}
+		user = strings.TrimSpace(line)
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := readPassword(stdin)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return fmt.Errorf("read password: %w", err)
+	}
+
+	creds, err := mods.Login(ctx, user, password, loginEmailCode)
+	if errors.Is(err, mods.ErrEmailAuthRequired) && loginEmailCode == "" {
+		fmt.Fprint(os.Stderr, "An authentication code was sent to your email address.\nCode: ")
+		line, err := stdin.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("read authentication code: %w", err)
+		}
+		creds, err = mods.Login(ctx, user, password, strings.TrimSpace(line))
+	}
+	if err != nil {
+		return err
+	}
+
+	path, err := credentialsPath()
+	if err != nil {
+		return err
+	}
+	if err := creds.Save(path); err != nil {
+		return fmt.Errorf("save credentials: %w", err)
+	}
+
+	fmt.Printf("Logged in as %s\n", creds.Username)
+	return nil
+}
+
+// readPassword reads a password from the terminal without echoing it, or a
+// single line from r when standard input is not a terminal.
+func readPassword(r *bufio.Reader) (string, error) {
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		p, err := term.ReadPassword(fd)
+		return string(p), err
+	}
+	line, err := r.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// credentialsPath returns the path to the file "facmod login" stores
+// credentials in.
+func credentialsPath() (string, error) {
+	dir, err := makeStateDir()
+	if err != nil {
+		return "", fmt.Errorf("make state dir: %w", err)
+	}
+	return filepath.Join(dir, "credentials.json"), nil
+}
@@ -12,32 +12,88 @@ import (
 	"flag"
 	"fmt"
 	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	humanize "github.com/dustin/go-humanize"
 	ff "github.com/peterbourgon/ff/v4"
 	"github.com/peterbourgon/ff/v4/ffhelp"
 
+	"github.com/nesv/factorio-tools/httputil"
 	"github.com/nesv/factorio-tools/mods"
 )
 
 func main() {
+	envConfig := httputil.ConfigFromEnv()
 	rootFlags := ff.NewFlagSet("facmod")
 	rootFlags.StringVar(&installDir, 'D', "directory", "/opt/factorio", "Path to the Factorio installation directory")
 	rootFlags.BoolVar(&noHeaders, 'H', "no-headers", "Disable headers on tabular output")
+	rootFlags.StringEnumVar(&outputFormat, 'o', "output", "Output format", outputTable, outputJSON)
+	rootFlags.StringVar(&username, 'u', "username", "", "factorio.com username used for downloading mods")
+	rootFlags.StringVar(&token, 0, "token", "", "factorio.com token used for downloading mods")
+	rootFlags.StringVar(&apiKey, 0, "api-key", "", "factorio.com API key for the mod portal endpoints that require one, like publish (env: "+apiKeyEnv+")")
+	rootFlags.StringVar(&password, 0, "password", "", "factorio.com password used for logging in again when the token is rejected (env: "+passwordEnv+")")
+	rootFlags.StringVar(&playerDataPath, 0, "player-data", "", "Path to a player-data.json file to read credentials from")
+	rootFlags.StringListVar(&mirrorURLs, 0, "mirror", "Try downloading mods from this mirror first (repeatable)")
+	rootFlags.StringVar(&httpConfig.Proxy, 0, "proxy", envConfig.Proxy, "Send requests through this HTTP, HTTPS, or SOCKS5 proxy (env: "+httputil.ProxyEnv+")")
+	rootFlags.StringVar(&httpConfig.CAFile, 0, "ca-file", envConfig.CAFile, "Also trust the CA certificates in this PEM file (env: "+httputil.CAFileEnv+")")
+	rootFlags.Float64Var(&httpConfig.RateLimit, 0, "rate-limit", httputil.DefaultRateLimit, "Send at most this many requests per second, on average (0 disables)")
+	rootFlags.IntVar(&httpConfig.RateBurst, 0, "rate-burst", httputil.DefaultRateBurst, "Allow bursts of up to this many requests, before --rate-limit applies")
+	rootFlags.IntVar(&httpConfig.Retries, 0, "retries", httputil.DefaultRetries, "Retry failed requests and downloads this many times (0 disables)")
+	rootFlags.BoolVar(&dryRun, 0, "dry-run", "Print the changes install, upgrade, remove, pack apply, and publish would make, without making them")
+	rootFlags.BoolVar(&noHooks, 0, "no-hooks", "Do not run the hooks in hooks.json when installing, upgrading, or removing mods")
+	rootFlags.StringVar(&configPath, 0, "config", defaultConfigPath(), "Read default flag values from this TOML file")
+	rootFlags.StringVar(&profileName, 'P', "profile", "", "Read default flag values from this profile in profiles.json")
+	rootFlags.StringVar(&factorioVersion, 0, "factorio-version", "", "Version of Factorio that mods must support, like 1.1")
+	rootFlags.BoolVar(&ignoreFactorioVersion, 0, "ignore-factorio-version", "Install mods even if they do not support the installation's version of Factorio")
+	rootFlags.StringVar(&cacheDir, 0, "cache-dir", "", "Path to the mod cache directory (default: $XDG_CACHE_HOME/facmod)")
+	rootFlags.DurationVar(&cacheTTL, 0, "cache-ttl", 7*24*time.Hour, "Warn when the mod cache is older than this (0 disables)")
+	rootFlags.IntVar(&cacheKeep, 0, "cache-keep", 0, "After downloading a mod, only keep the newest N downloaded versions of each mod (0 keeps all)")
+	rootFlags.DurationVar(&cacheMaxAge, 0, "cache-max-age", 0, "After downloading a mod, remove downloaded mods older than this (0 keeps all)")
+	rootFlags.StringVar(&cacheMaxSize, 0, "cache-max-size", "", "After downloading a mod, remove the least-recently downloaded mods until the rest take up at most this much space, like 2GB")
+	rootFlags.BoolVar(&autoUpdate, 0, "auto-update", "Update the mod cache when it is older than --cache-ttl, instead of warning")
+	rootFlags.BoolVar(&verbose, 'v', "verbose", "Log debugging information, such as HTTP requests and SQL queries")
+	rootFlags.BoolVar(&quiet, 'q', "quiet", "Only log errors, and hide progress bars")
+	rootFlags.StringEnumVar(&logFormat, 0, "log-format", "Log format", logFormatText, logFormatJSON)
 
 	cleanFlags := ff.NewFlagSet("clean").SetParent(rootFlags)
+	cleanFlags.BoolVar(&cleanDownloads, 0, "downloads", "Remove all downloaded mods from the cache")
+	cleanFlags.UintVar(&cleanOlderThan, 0, "older-than", 0, "Remove downloaded mods older than this many days")
+	cleanFlags.IntVar(&cleanKeep, 0, "keep", 0, "Only keep the newest N downloaded versions of each mod")
+	cleanFlags.StringVar(&cleanMaxSize, 0, "max-size", "", "Remove the least-recently downloaded mods until the rest take up at most this much space, like 2GB")
 	cleanCmd := &ff.Command{
 		Name:      "clean",
-		Usage:     "facmod clean",
+		Usage:     "facmod clean [FLAGS]",
 		ShortHelp: "Clean the cache",
 		Flags:     cleanFlags,
 		Exec:      runClean,
 	}
 
+	cacheFlags := ff.NewFlagSet("cache").SetParent(rootFlags)
+	cacheDoctorFlags := ff.NewFlagSet("doctor").SetParent(cacheFlags)
+	cacheDoctorFlags.BoolVar(&doctorRepair, 0, "repair", "Fix the problems that were found")
+	cacheDoctorFlags.BoolVar(&doctorRedownload, 0, "redownload", "Fix the problems that were found, and download truncated and missing mods again")
+	cacheDoctorCmd := &ff.Command{
+		Name:      "doctor",
+		Usage:     "facmod cache doctor [FLAGS]",
+		ShortHelp: "Check the cache for corruption, and optionally repair it",
+		Flags:     cacheDoctorFlags,
+		Exec:      runCacheDoctor,
+	}
+	cacheCmd := &ff.Command{
+		Name:      "cache",
+		Usage:     "facmod cache [FLAGS] SUBCOMMAND ...",
+		ShortHelp: "Maintain the mod cache",
+		Flags:     cacheFlags,
+		Subcommands: []*ff.Command{
+			cacheDoctorCmd,
+		},
+	}
+
 	listFlags := ff.NewFlagSet("list").SetParent(rootFlags)
 	listCmd := &ff.Command{
 		Name:      "list",
@@ -48,6 +104,7 @@ func main() {
 	}
 
 	updateFlags := ff.NewFlagSet("update").SetParent(rootFlags)
+	updateFlags.BoolVar(&updateFull, 0, "full", "Retrieve the entire mod list, instead of only the mods released since the last update")
 	updateCmd := &ff.Command{
 		Name:      "update",
 		Usage:     "facmod update [FLAGS]",
@@ -57,8 +114,16 @@ func main() {
 	}
 
 	searchFlags := ff.NewFlagSet("search").SetParent(rootFlags)
-	searchFlags.BoolVar(&searchSortByDate, 't', "sort-by-date", "Sort results by release date")
+	searchFlags.BoolVar(&searchSortByDate, 't', "sort-by-date", "Deprecated: same as --sort date")
+	searchFlags.StringEnumVar(&searchSort, 0, "sort", "Sort results by", "", searchSortDate, searchSortDateAsc, searchSortName, searchSortDownloads)
+	searchFlags.BoolVar(&searchFullText, 'f', "full-text", "Match the search term against titles, summaries, and descriptions")
+	searchFlags.BoolVar(&searchRegexp, 'r', "regexp", "Treat the search term as a regular expression")
+	searchFlags.BoolVar(&searchNameOnly, 0, "name-only", "Only match the search term against mod names")
 	searchFlags.StringEnumVar(&searchCategory, 'c', "category", "Only show mods in the given category", mods.Categories()...)
+	searchFlags.StringListVar(&searchTags, 0, "tag", "Only show mods with this tag (repeatable)")
+	searchFlags.StringListVar(&searchLicenses, 0, "license", "Only show mods with this license, like MIT (repeatable)")
+	searchFlags.UintVar(&searchLimit, 'n', "limit", 0, "Show at most this many mods (0 for all)")
+	searchFlags.UintVar(&searchOffset, 0, "offset", 0, "Skip this many mods")
 	searchCmd := &ff.Command{
 		Name:      "search",
 		Usage:     "facmod search [FLAGS] SEARCH_TERM",
@@ -76,26 +141,405 @@ func main() {
 		Exec:      runCategories,
 	}
 
+	infoFlags := ff.NewFlagSet("info").SetParent(rootFlags)
+	infoFlags.BoolVar(&infoThumbnail, 0, "thumbnail", "Display the mod's thumbnail")
+	infoFlags.BoolVar(&infoChangelog, 0, "changelog", "Show the changelog of the installed version, or of the latest release")
+	infoCmd := &ff.Command{
+		Name:      "info",
+		Usage:     "facmod info [FLAGS] MOD",
+		ShortHelp: "Show detailed information about a mod",
+		Flags:     infoFlags,
+		Exec:      runInfo,
+	}
+
+	downloadFlags := ff.NewFlagSet("download").SetParent(rootFlags)
+	downloadCmd := &ff.Command{
+		Name:      "download",
+		Usage:     "facmod download [FLAGS] MOD[==VERSION] ...",
+		ShortHelp: "Download mods into the cache, without installing them",
+		Flags:     downloadFlags,
+		Exec:      runDownload,
+	}
+
+	bundleFlags := ff.NewFlagSet("bundle").SetParent(rootFlags)
+	bundleFlags.BoolVar(&bundleInstalled, 0, "installed", "Also bundle the installed versions of the installed mods")
+	bundleCmd := &ff.Command{
+		Name:      "bundle",
+		Usage:     "facmod bundle [FLAGS] FILE [MOD[==VERSION] ...]",
+		ShortHelp: "Write the mod cache and downloaded mods to a file, for servers without internet access",
+		Flags:     bundleFlags,
+		Exec:      runBundle,
+	}
+
+	unbundleFlags := ff.NewFlagSet("unbundle").SetParent(rootFlags)
+	unbundleCmd := &ff.Command{
+		Name:      "unbundle",
+		Usage:     "facmod unbundle [FLAGS] FILE",
+		ShortHelp: "Load a mod cache bundle written by facmod bundle",
+		Flags:     unbundleFlags,
+		Exec:      runUnbundle,
+	}
+
+	installFlags := ff.NewFlagSet("install").SetParent(rootFlags)
+	installFlags.BoolVarDefault(&installEnable, 'e', "enable", true, "Enable mods after installing them")
+	installFlags.BoolVar(&installNoDeps, 0, "no-deps", "Only install the named mods, without their required dependencies")
+	installFlags.StringVar(&installSHA1, 0, "sha1", "", "Expected SHA1 of a mod archive installed from a URL")
+	installFlags.StringVar(&installSHA256, 0, "sha256", "", "Expected SHA256 of a mod archive installed from a URL")
+	installCmd := &ff.Command{
+		Name:      "install",
+		Usage:     "facmod install [FLAGS] MOD[==VERSION]|FILE.zip|URL|git+URL[@REF] ...",
+		ShortHelp: "Install mods",
+		Flags:     installFlags,
+		Exec:      runInstall,
+	}
+
+	publishFlags := ff.NewFlagSet("publish").SetParent(rootFlags)
+	publishCmd := &ff.Command{
+		Name:      "publish",
+		Usage:     "facmod publish [FLAGS] MOD.zip",
+		ShortHelp: "Upload a new release of a mod to the mod portal",
+		Flags:     publishFlags,
+		Exec:      runPublish,
+	}
+
+	upgradeFlags := ff.NewFlagSet("upgrade").SetParent(rootFlags)
+	upgradeCmd := &ff.Command{
+		Name:      "upgrade",
+		Usage:     "facmod upgrade [FLAGS] [MOD ...]",
+		ShortHelp: "Upgrade installed mods",
+		Flags:     upgradeFlags,
+		Exec:      runUpgrade,
+	}
+
+	pinFlags := ff.NewFlagSet("pin").SetParent(rootFlags)
+	loginFlags := ff.NewFlagSet("login").SetParent(rootFlags)
+	loginFlags.StringVar(&loginEmailCode, 0, "email-code", "", "Email authentication code")
+	loginCmd := &ff.Command{
+		Name:      "login",
+		Usage:     "facmod login [FLAGS] [USERNAME]",
+		ShortHelp: "Log in to factorio.com and store a token for downloading mods",
+		Flags:     loginFlags,
+		Exec:      runLogin,
+	}
+
+	pinCmd := &ff.Command{
+		Name:      "pin",
+		Usage:     "facmod pin [FLAGS] MOD[==VERSION] ...",
+		ShortHelp: "Prevent mods from being upgraded past a version",
+		Flags:     pinFlags,
+		Exec:      runPin,
+	}
+
+	unpinFlags := ff.NewFlagSet("unpin").SetParent(rootFlags)
+	unpinCmd := &ff.Command{
+		Name:      "unpin",
+		Usage:     "facmod unpin [FLAGS] MOD ...",
+		ShortHelp: "Remove version pins from mods",
+		Flags:     unpinFlags,
+		Exec:      runUnpin,
+	}
+
+	lockFlags := ff.NewFlagSet("lock").SetParent(rootFlags)
+	lockFlags.StringVar(&lockfilePath, 'f', "lockfile", "", "Path to the lockfile (default: DIRECTORY/facmod.lock)")
+	lockCmd := &ff.Command{
+		Name:      "lock",
+		Usage:     "facmod lock [FLAGS]",
+		ShortHelp: "Record the installed mods in a lockfile",
+		Flags:     lockFlags,
+		Exec:      runLock,
+	}
+
+	syncFlags := ff.NewFlagSet("sync").SetParent(rootFlags)
+	syncFlags.StringVar(&lockfilePath, 'f', "lockfile", "", "Path to the lockfile (default: DIRECTORY/facmod.lock)")
+	syncCmd := &ff.Command{
+		Name:      "sync",
+		Usage:     "facmod sync [FLAGS]",
+		ShortHelp: "Make the installed mods match the lockfile",
+		Flags:     syncFlags,
+		Exec:      runSync,
+	}
+
+	syncSaveFlags := ff.NewFlagSet("sync-save").SetParent(rootFlags)
+	syncSaveCmd := &ff.Command{
+		Name:      "sync-save",
+		Usage:     "facmod sync-save [FLAGS] SAVE",
+		ShortHelp: "Install and enable exactly the mods a save was made with",
+		Flags:     syncSaveFlags,
+		Exec:      runSyncSave,
+	}
+
+	exportFlags := ff.NewFlagSet("export").SetParent(rootFlags)
+	exportCmd := &ff.Command{
+		Name:      "export",
+		Usage:     "facmod export [FLAGS] [FILE]",
+		ShortHelp: "Write a manifest of the installed mods",
+		Flags:     exportFlags,
+		Exec:      runExport,
+	}
+
+	importFlags := ff.NewFlagSet("import").SetParent(rootFlags)
+	importCmd := &ff.Command{
+		Name:      "import",
+		Usage:     "facmod import [FLAGS] FILE",
+		ShortHelp: "Install the mods described by a manifest",
+		Flags:     importFlags,
+		Exec:      runImport,
+	}
+
+	depsFlags := ff.NewFlagSet("deps").SetParent(rootFlags)
+	depsFlags.BoolVar(&depsFromCache, 'c', "cache", "Resolve dependencies using the mod portal's metadata, instead of downloading mods")
+	depsFlags.StringEnumVar(&depsFormat, 0, "format", "Print the dependencies as a tree, or as a graph in DOT or JSON", depsFormatTree, depsFormatDOT, depsFormatJSON)
+	depsCmd := &ff.Command{
+		Name:      "deps",
+		Usage:     "facmod deps [FLAGS] MOD ...",
+		ShortHelp: "Show the dependency tree of a mod",
+		Flags:     depsFlags,
+		Exec:      runDeps,
+	}
+
+	settingsFlags := ff.NewFlagSet("settings").SetParent(rootFlags)
+	settingsFlags.StringVar(&settingsScope, 's', "scope", "", "Only use settings in this scope (startup, runtime-global, or runtime-per-user)")
+	settingsListCmd := &ff.Command{
+		Name:      "list",
+		Usage:     "facmod settings list [FLAGS]",
+		ShortHelp: "List mod settings",
+		Flags:     ff.NewFlagSet("list").SetParent(settingsFlags),
+		Exec:      runSettingsList,
+	}
+	settingsGetCmd := &ff.Command{
+		Name:      "get",
+		Usage:     "facmod settings get [FLAGS] NAME",
+		ShortHelp: "Print the value of a mod setting",
+		Flags:     ff.NewFlagSet("get").SetParent(settingsFlags),
+		Exec:      runSettingsGet,
+	}
+	settingsSetCmd := &ff.Command{
+		Name:      "set",
+		Usage:     "facmod settings set [FLAGS] NAME VALUE",
+		ShortHelp: "Change the value of a mod setting",
+		Flags:     ff.NewFlagSet("set").SetParent(settingsFlags),
+		Exec:      runSettingsSet,
+	}
+	settingsCmd := &ff.Command{
+		Name:      "settings",
+		Usage:     "facmod settings [FLAGS] SUBCOMMAND ...",
+		ShortHelp: "View and change mod settings in mod-settings.dat",
+		Flags:     settingsFlags,
+		Subcommands: []*ff.Command{
+			settingsGetCmd,
+			settingsListCmd,
+			settingsSetCmd,
+		},
+	}
+
+	packFlags := ff.NewFlagSet("pack").SetParent(rootFlags)
+	packApplyFlags := ff.NewFlagSet("apply").SetParent(packFlags)
+	packApplyFlags.BoolVar(&packPrune, 0, "prune", "Remove installed mods that are not in the modpack, or required by it")
+	packApplyCmd := &ff.Command{
+		Name:      "apply",
+		Usage:     "facmod pack apply [FLAGS] FILE",
+		ShortHelp: "Install the mods in a modpack, and apply its settings",
+		Flags:     packApplyFlags,
+		Exec:      runPackApply,
+	}
+	packCreateFlags := ff.NewFlagSet("create").SetParent(packFlags)
+	packCreateFlags.StringVar(&packName, 0, "name", "", "Name of the modpack (default: the installation directory's name)")
+	packCreateFlags.BoolVar(&packSettings, 0, "settings", "Include the values of all settings in mod-settings.dat")
+	packCreateCmd := &ff.Command{
+		Name:      "create",
+		Usage:     "facmod pack create [FLAGS] [FILE]",
+		ShortHelp: "Write a modpack of the installed mods",
+		Flags:     packCreateFlags,
+		Exec:      runPackCreate,
+	}
+	packCmd := &ff.Command{
+		Name:      "pack",
+		Usage:     "facmod pack [FLAGS] SUBCOMMAND ...",
+		ShortHelp: "Create and apply modpacks",
+		Flags:     packFlags,
+		Subcommands: []*ff.Command{
+			packApplyCmd,
+			packCreateCmd,
+		},
+	}
+
+	popularFlags := ff.NewFlagSet("popular").SetParent(rootFlags)
+	popularFlags.StringEnumVar(&popularCategory, 'c', "category", "Only show mods in the given category", mods.Categories()...)
+	popularFlags.UintVar(&popularLimit, 'n', "limit", 20, "Show at most this many mods (0 for all)")
+	popularCmd := &ff.Command{
+		Name:      "popular",
+		Usage:     "facmod popular [FLAGS]",
+		ShortHelp: "List the most-downloaded mods",
+		Flags:     popularFlags,
+		Exec:      runPopular,
+	}
+
+	removeFlags := ff.NewFlagSet("remove").SetParent(rootFlags)
+	removeCmd := &ff.Command{
+		Name:      "remove",
+		Usage:     "facmod remove [FLAGS] MOD ...",
+		ShortHelp: "Uninstall mods",
+		Flags:     removeFlags,
+		Exec:      runRemove,
+	}
+
+	diffFlags := ff.NewFlagSet("diff").SetParent(rootFlags)
+	diffCmd := &ff.Command{
+		Name:      "diff",
+		Usage:     "facmod diff [FLAGS] [FILE]",
+		ShortHelp: "Compare the installed mods against a lockfile or manifest",
+		Flags:     diffFlags,
+		Exec:      runDiff,
+	}
+
+	auditFlags := ff.NewFlagSet("audit").SetParent(rootFlags)
+	auditFlags.UintVar(&auditMaxAge, 0, "max-age", 730, "Flag mods whose latest release is older than this many days (0 disables)")
+	auditCmd := &ff.Command{
+		Name:      "audit",
+		Usage:     "facmod audit [FLAGS]",
+		ShortHelp: "Find installed mods that look abandoned or risky",
+		Flags:     auditFlags,
+		Exec:      runAudit,
+	}
+
+	verifyFlags := ff.NewFlagSet("verify").SetParent(rootFlags)
+	verifyCmd := &ff.Command{
+		Name:      "verify",
+		Usage:     "facmod verify [FLAGS]",
+		ShortHelp: "Check installed mods against the mod portal's SHA1 hashes",
+		Flags:     verifyFlags,
+		Exec:      runVerify,
+	}
+
+	validateFlags := ff.NewFlagSet("validate").SetParent(rootFlags)
+	validateCmd := &ff.Command{
+		Name:      "validate",
+		Usage:     "facmod validate [FLAGS] DIR|FILE.zip",
+		ShortHelp: "Check a mod's info.json for problems",
+		Flags:     validateFlags,
+		Exec:      runValidate,
+	}
+
+	watchFlags := ff.NewFlagSet("watch").SetParent(rootFlags)
+	watchFlags.BoolVar(&watchSymlink, 0, "symlink", "Link the mod directory into the mods directory once, instead of watching it")
+	watchFlags.DurationVar(&watchInterval, 0, "interval", time.Second, "How often to check the mod directory for changes")
+	watchCmd := &ff.Command{
+		Name:      "watch",
+		Usage:     "facmod watch [FLAGS] DIR",
+		ShortHelp: "Reinstall a mod under development whenever its files change",
+		Flags:     watchFlags,
+		Exec:      runWatch,
+	}
+
+	zipFlags := ff.NewFlagSet("zip").SetParent(rootFlags)
+	zipFlags.StringVar(&zipDest, 0, "dest", ".", "Directory to write the mod archive to")
+	zipCmd := &ff.Command{
+		Name:      "zip",
+		Usage:     "facmod zip [FLAGS] DIR",
+		ShortHelp: "Package a mod source directory into a mod archive",
+		Flags:     zipFlags,
+		Exec:      runZip,
+	}
+
 	root := &ff.Command{
 		Name:      "facmod",
 		Usage:     "facmod [FLAGS] SUBCOMMAND ...",
 		ShortHelp: "Factorio server mod manager",
 		Flags:     rootFlags,
 		Subcommands: []*ff.Command{
+			auditCmd,
+			bundleCmd,
+			cacheCmd,
 			categoriesCmd,
 			cleanCmd,
+			depsCmd,
+			diffCmd,
+			downloadCmd,
+			exportCmd,
+			importCmd,
+			infoCmd,
+			installCmd,
 			listCmd,
+			lockCmd,
+			loginCmd,
+			packCmd,
+			pinCmd,
+			popularCmd,
+			publishCmd,
+			removeCmd,
 			searchCmd,
+			settingsCmd,
+			syncCmd,
+			syncSaveCmd,
+			unbundleCmd,
+			unpinCmd,
 			updateCmd,
+			upgradeCmd,
+			validateCmd,
+			verifyCmd,
+			watchCmd,
+			zipCmd,
 		},
 	}
-	if err := root.ParseAndRun(context.Background(), os.Args[1:]); err != nil {
-		fmt.Fprintln(os.Stderr, ffhelp.Command(root))
+	err := root.Parse(os.Args[1:], configOptions(rootFlags)...)
+	setupLogging()
+	if err == nil && verbose && quiet {
+		err = errors.New("--verbose and --quiet are mutually exclusive")
+	}
+	usageErr := err != nil
+	if err == nil {
+		err = checkConfigFile(rootFlags)
+	}
+	if err == nil {
+		// Environment variables take precedence over the config file.
+		if envConfig.Proxy != "" && fromConfig(rootFlags, "proxy") {
+			httpConfig.Proxy = envConfig.Proxy
+		}
+		if envConfig.CAFile != "" && fromConfig(rootFlags, "ca-file") {
+			httpConfig.CAFile = envConfig.CAFile
+		}
+		// The API key is not the flag's default, so that it is not
+		// printed in the help text.
+		if key := os.Getenv(apiKeyEnv); key != "" && (apiKey == "" || fromConfig(rootFlags, "api-key")) {
+			apiKey = key
+		}
+		if p := os.Getenv(passwordEnv); p != "" && (password == "" || fromConfig(rootFlags, "password")) {
+			password = p
+		}
+	}
+	if err == nil {
+		err = applyProfile(rootFlags)
+	}
+	if err == nil {
+		err = httputil.Configure(httpConfig)
+	}
+	if err == nil {
+		ctx := context.Background()
+		err = root.Run(ctx)
+		saveRefreshedCredentials(ctx)
+	}
+	if err != nil {
 		if errors.Is(err, flag.ErrHelp) || errors.Is(err, ff.ErrNoExec) {
+			fmt.Fprintln(os.Stderr, ffhelp.Command(root))
 			return
 		}
-		fmt.Fprintln(os.Stderr, "error: ", err)
-		os.Exit(1)
+
+		code, hint := exitStatus(err)
+		if usageErr {
+			// Only print the help text when the command line was
+			// invalid.
+			fmt.Fprintln(os.Stderr, ffhelp.Command(root))
+			code = exitUsage
+		}
+		if logFormat == logFormatJSON {
+			slog.Error("command failed", "err", err, "exit_code", code, "hint", hint)
+		} else {
+			fmt.Fprintln(os.Stderr, "error: ", err)
+			if hint != "" {
+				fmt.Fprintln(os.Stderr, "hint: ", hint)
+			}
+		}
+		os.Exit(code)
 	}
 }
 
@@ -103,24 +547,44 @@ func main() {
 var (
 	installDir string
 	noHeaders  bool
+	httpConfig httputil.Config
 )
 
+// Set by command-line flags.
+var updateFull bool
+
 // runUpdate is the entrypoint for the "update" subcommand.
 func runUpdate(ctx context.Context, args []string) error {
-	// Fetch all pages from the mod portal, and write them to the cache dir.
-	cacheDir, err := makeCacheDir()
+	// Fetch the pages from the mod portal, and write them to the cache dir.
+	cache, err := openCache()
 	if err != nil {
-		return fmt.Errorf("make cache dir: %w", err)
+		return err
+	}
+	defer cache.Close()
+	if !quiet {
+		cache.EnableProgressBar()
 	}
 
-	cache, err := mods.OpenCache(cacheDir)
+	return updateCache(ctx, cache, updateFull)
+}
+
+// updateCache pulls the mod list from the mod portal, and updates the cache
+// database.
+// Unless full is true, only the mods released since the last update are
+// pulled.
+func updateCache(ctx context.Context, cache *mods.Cache, full bool) error {
+	last, err := cache.LastUpdate(ctx)
 	if err != nil {
-		return fmt.Errorf("open cache: %w", err)
+		return err
 	}
-	defer cache.Close()
-	cache.EnableProgressBar()
-
-	if err := cache.Pull(ctx); err != nil {
+	if full || last.IsZero() {
+		err = cache.Pull(ctx)
+	} else {
+		// Overlap with the previous update, in case this host's clock
+		// is ahead of the mod portal's.
+		err = cache.PullSince(ctx, last.Add(-time.Hour))
+	}
+	if err != nil {
 		return fmt.Errorf("pull latest mod list: %w", err)
 	}
 
@@ -131,13 +595,57 @@ func runUpdate(ctx context.Context, args []string) error {
 	return nil
 }
 
-func makeCacheDir() (string, error) {
-	dir, err := os.UserCacheDir()
+// openCache opens the mod cache, and configures it with any mirrors set with
+// --mirror or in mirrors.json.
+func openCache() (*mods.Cache, error) {
+	dir, err := makeCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("make cache dir: %w", err)
+	}
+
+	mirrors, err := loadMirrors()
 	if err != nil {
-		return "", fmt.Errorf("user cache dir: %w", err)
+		return nil, fmt.Errorf("load mirrors: %w", err)
+	}
+
+	cache, err := mods.OpenCache(dir)
+	if err != nil {
+		return nil, fmt.Errorf("open cache: %w", err)
+	}
+	cache.SetMirrors(mirrors...)
+
+	policy, err := retentionPolicy()
+	if err != nil {
+		cache.Close()
+		return nil, err
+	}
+	cache.SetRetention(policy)
+
+	if password != "" {
+		creds, err := loadCredentials()
+		if err != nil {
+			cache.Close()
+			return nil, fmt.Errorf("load credentials: %w", err)
+		}
+		cache.SetLogin(creds.Username, password)
+		openedCaches = append(openedCaches, cache)
+	}
+
+	return cache, nil
+}
+
+// makeCacheDir creates the directory given with --cache-dir, or the default
+// cache directory, and returns its path.
+func makeCacheDir() (string, error) {
+	dir := cacheDir
+	if dir == "" {
+		d, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("user cache dir: %w", err)
+		}
+		dir = filepath.Join(d, "facmod")
 	}
 
-	dir = filepath.Join(dir, "facmod")
 	if err := os.MkdirAll(dir, fs.ModePerm); err != nil {
 		return "", fmt.Errorf("make directory %q: %w", dir, err)
 	}
@@ -145,16 +653,19 @@ func makeCacheDir() (string, error) {
 	return dir, nil
 }
 
+// Set by command-line flags.
+var (
+	cleanDownloads bool
+	cleanOlderThan uint
+	cleanKeep      int
+	cleanMaxSize   string
+)
+
 // runClean is the entrypoint for the "clean" subcommand.
 func runClean(ctx context.Context, args []string) error {
-	cacheDir, err := makeCacheDir()
-	if err != nil {
-		return fmt.Errorf("make cache dir: %w", err)
-	}
-
-	cache, err := mods.OpenCache(cacheDir)
+	cache, err := openCache()
 	if err != nil {
-		return fmt.Errorf("open cache: %w", err)
+		return err
 	}
 	defer cache.Close()
 
@@ -162,7 +673,33 @@ func runClean(ctx context.Context, args []string) error {
 		return err
 	}
 
-	return cache.Clean()
+	maxSize, err := parseSize(cleanMaxSize)
+	if err != nil {
+		return fmt.Errorf("--max-size: %w", err)
+	}
+	opts := mods.PruneOptions{
+		All:     cleanDownloads,
+		MaxAge:  time.Duration(cleanOlderThan) * 24 * time.Hour,
+		Keep:    cleanKeep,
+		MaxSize: maxSize,
+	}
+	if opts == (mods.PruneOptions{}) {
+		// Without any flags, apply the cache's retention policy.
+		if opts, err = retentionPolicy(); err != nil {
+			return err
+		}
+	}
+	if opts == (mods.PruneOptions{}) {
+		return nil
+	}
+
+	result, err := cache.PruneDownloads(opts)
+	if err != nil {
+		return fmt.Errorf("prune downloads: %w", err)
+	}
+	fmt.Printf("Removed %d downloaded mods, reclaiming %s\n", result.Files, humanize.Bytes(uint64(result.Bytes)))
+
+	return nil
 }
 
 // runList is the entrypoint for the "list" subcommand.
@@ -172,6 +709,31 @@ func runList(ctx context.Context, args []string) error {
 		return fmt.Errorf("load mods: %w", err)
 	}
 
+	if jsonOutput() {
+		licenses, err := installedLicenses(ctx, mm)
+		if err != nil {
+			return err
+		}
+
+		type listEntry struct {
+			Name    string        `json:"name"`
+			Version string        `json:"version,omitempty"`
+			Enabled bool          `json:"enabled"`
+			License *mods.License `json:"license,omitempty"`
+		}
+		entries := make([]listEntry, len(mm))
+		for i, m := range mm {
+			entries[i] = listEntry{Name: m.Name, Enabled: m.Enabled}
+			if n := len(m.Versions); n != 0 {
+				entries[i].Version = m.Versions[n-1].String()
+			}
+			if l, ok := licenses[m.Name]; ok {
+				entries[i].License = &l
+			}
+		}
+		return writeJSON(entries)
+	}
+
 	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
 	defer tw.Flush()
 
@@ -195,10 +757,46 @@ func runList(ctx context.Context, args []string) error {
 	return nil
 }
 
+// installedLicenses returns the licenses of the installed mods mm that are
+// known to the mod cache.
+func installedLicenses(ctx context.Context, mm []mods.M) (map[string]mods.License, error) {
+	cache, err := openCache()
+	if err != nil {
+		return nil, err
+	}
+	defer cache.Close()
+
+	names := make([]string, len(mm))
+	for i, m := range mm {
+		names[i] = m.Name
+	}
+	licenses, err := cache.Licenses(ctx, names...)
+	if err != nil {
+		return nil, fmt.Errorf("get licenses: %w", err)
+	}
+	return licenses, nil
+}
+
+// Values for the "search" subcommand's --sort flag.
+const (
+	searchSortDate      = "date"
+	searchSortDateAsc   = "date-asc"
+	searchSortName      = "name"
+	searchSortDownloads = "downloads"
+)
+
 // Set by command-line flags.
 var (
 	searchSortByDate bool
+	searchSort       string
+	searchFullText   bool
+	searchRegexp     bool
+	searchNameOnly   bool
 	searchCategory   string
+	searchTags       []string
+	searchLicenses   []string
+	searchLimit      uint
+	searchOffset     uint
 )
 
 func runSearch(ctx context.Context, args []string) error {
@@ -206,36 +804,110 @@ func runSearch(ctx context.Context, args []string) error {
 		return errors.New("at least one search term is required")
 	}
 
-	cacheDir, err := makeCacheDir()
+	cache, err := openFreshCache(ctx)
 	if err != nil {
-		return fmt.Errorf("make cache dir: %w", err)
+		return err
 	}
+	defer cache.Close()
 
-	cache, err := mods.OpenCache(cacheDir)
-	if err != nil {
-		return fmt.Errorf("open cache: %w", err)
+	// --sort-by-date is kept for the scripts that still use it; --sort
+	// takes precedence.
+	if searchSortByDate {
+		slog.WarnContext(ctx, "--sort-by-date is deprecated; use --sort date")
+		if searchSort == "" {
+			searchSort = searchSortDate
+		}
 	}
-	defer cache.Close()
 
 	var options []mods.SearchOption
-	if searchSortByDate {
+	switch searchSort {
+	case searchSortDate:
 		options = append(options, mods.SortByDate())
+	case searchSortDateAsc:
+		options = append(options, mods.SortByDateAscending())
+	case searchSortName:
+		options = append(options, mods.SortByName())
+	case searchSortDownloads:
+		options = append(options, mods.SortByDownloads())
+	}
+	if searchFullText {
+		options = append(options, mods.FullText())
+	}
+	if factorioVersion != "" {
+		options = append(options, mods.ForFactorioVersion(factorioVersion))
+	}
+	if searchRegexp {
+		options = append(options, mods.RegexpTerm())
+	}
+	if searchNameOnly {
+		options = append(options, mods.NameOnly())
 	}
 	if searchCategory != "" {
 		c := mods.Category(searchCategory)
 		options = append(options, mods.WithCategories(c))
 	}
+	if len(searchTags) > 0 {
+		options = append(options, mods.WithTags(searchTags...))
+	}
+	if len(searchLicenses) > 0 {
+		options = append(options, mods.WithLicenses(searchLicenses...))
+	}
+	var total int
+	options = append(options,
+		mods.WithLimit(int(searchLimit)),
+		mods.WithOffset(int(searchOffset)),
+		mods.WithTotal(&total),
+	)
 
 	mm, err := cache.Search(ctx, args[0], options...)
 	if err != nil {
 		return err
 	}
+	if len(mm) < total {
+		slog.InfoContext(ctx, "showing some of the results",
+			"from", int(searchOffset)+1,
+			"to", int(searchOffset)+len(mm),
+			"total", total)
+	}
+
+	if jsonOutput() {
+		type searchResult struct {
+			Name            string    `json:"name"`
+			Title           string    `json:"title"`
+			Owner           string    `json:"owner"`
+			Category        string    `json:"category"`
+			Version         string    `json:"version"`
+			FactorioVersion string    `json:"factorio_version"`
+			ReleasedAt      time.Time `json:"released_at"`
+			Downloads       int       `json:"downloads_count"`
+			Summary         string    `json:"summary"`
+			ThumbnailURL    string    `json:"thumbnail_url,omitempty"`
+		}
+		results := make([]searchResult, len(mm))
+		for i, m := range mm {
+			results[i] = searchResult{
+				Name:            m.Name,
+				Title:           m.Title,
+				Owner:           m.Owner,
+				Category:        m.Category,
+				Version:         m.Versions[0].String(),
+				FactorioVersion: m.FactorioVersion,
+				ReleasedAt:      m.ReleasedAt,
+				Downloads:       m.Downloads,
+				Summary:         m.Summary,
+				ThumbnailURL:    m.ThumbnailURL,
+			}
+		}
+		return writeJSON(results)
+	}
 
 	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
 	defer tw.Flush()
 
-	headers := []string{"NAME", "CATEGORY", "VERSION", "RELEASED", "SUMMARY"}
-	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	if !noHeaders {
+		headers := []string{"NAME", "CATEGORY", "VERSION", "RELEASED", "SUMMARY"}
+		fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	}
 
 	for _, m := range mm {
 		relt := humanize.Time(m.ReleasedAt)
@@ -256,10 +928,19 @@ func runSearch(ctx context.Context, args []string) error {
 }
 
 func runCategories(ctx context.Context, args []string) error {
+	var categories []string
 	for _, c := range mods.Categories() {
 		if c == "" {
 			continue
 		}
+		categories = append(categories, c)
+	}
+
+	if jsonOutput() {
+		return writeJSON(categories)
+	}
+
+	for _, c := range categories {
 		fmt.Println(c)
 	}
 	return nil
@@ -0,0 +1,49 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// Set by command-line flags.
+var mirrorURLs []string
+
+// loadMirrors returns the mirrors to download mods from: those given with
+// --mirror, followed by those listed in mirrors.json.
+// A missing mirrors.json is not an error.
+func loadMirrors() ([]mods.Mirror, error) {
+	var mirrors []mods.Mirror
+	for _, u := range mirrorURLs {
+		mirrors = append(mirrors, mods.Mirror{URL: u})
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return mirrors, nil
+	}
+	path := filepath.Join(dir, "facmod", "mirrors.json")
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return mirrors, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("open mirrors: %w", err)
+	}
+	defer f.Close()
+
+	var configured []mods.Mirror
+	if err := json.NewDecoder(f).Decode(&configured); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	return append(mirrors, configured...), nil
+}
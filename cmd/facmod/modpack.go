@@ -0,0 +1,219 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/nesv/factorio-tools/mods"
+	"github.com/nesv/factorio-tools/mods/settings"
+)
+
+// Set by command-line flags.
+var (
+	packPrune    bool
+	packName     string
+	packSettings bool
+)
+
+// runPackApply is the entrypoint for the "pack apply" subcommand.
+func runPackApply(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one modpack file is required")
+	}
+
+	pack, err := mods.LoadModpack(args[0])
+	if err != nil {
+		return err
+	}
+	requested, err := pack.Dependencies()
+	if err != nil {
+		return err
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		return fmt.Errorf("load credentials: %w", err)
+	}
+
+	pins, err := loadPins()
+	if err != nil {
+		return fmt.Errorf("load pins: %w", err)
+	}
+
+	cache, err := openFreshCache(ctx)
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	installed, err := installedVersions()
+	if err != nil {
+		return err
+	}
+
+	installedDeps, err := installedDependencies(ctx)
+	if err != nil {
+		return err
+	}
+
+	compat := newCompatChecker(ctx)
+	opts := append(compat.resolveOptions(ctx),
+		mods.WithInstalled(installed),
+		mods.WithInstalledDependencies(installedDeps),
+		mods.WithPins(pins),
+	)
+	plan, err := cache.Resolve(ctx, requested, opts...)
+	if err != nil {
+		return fmt.Errorf("resolve dependencies: %w", err)
+	}
+
+	compat.checkPlan(plan)
+	if err := compat.err(); err != nil {
+		return err
+	}
+
+	var dry *dryRunPlan
+	if dryRun {
+		if dry, err = newDryRunPlan(cache); err != nil {
+			return err
+		}
+	}
+
+	err = withHooks(ctx, mods.PreInstall, mods.PostInstall, installChanges(plan, installed), func() error {
+		return installResolved(ctx, cache, creds, plan, dry, true, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	if packPrune {
+		if err := prunePack(ctx, plan, installed, dry); err != nil {
+			return err
+		}
+	}
+
+	if dry != nil {
+		dry.applyModList(pack)
+	} else {
+		list, err := mods.LoadModList(installDir)
+		if err != nil {
+			return fmt.Errorf("load mod list: %w", err)
+		}
+		pack.ApplyModList(list)
+		if err := list.Save(installDir); err != nil {
+			return fmt.Errorf("save mod list: %w", err)
+		}
+	}
+
+	if len(pack.Settings) == 0 {
+		return nil
+	}
+	return applyPackSettings(pack, dry != nil)
+}
+
+// prunePack removes the installed mods that are not in plan.
+func prunePack(ctx context.Context, plan []mods.Resolved, installed map[string]mods.Version, dry *dryRunPlan) error {
+	var names []string
+	for name := range installed {
+		if !slices.ContainsFunc(plan, func(m mods.Resolved) bool { return m.Name == name }) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	changed := make([]mods.HookMod, len(names))
+	for i, name := range names {
+		changed[i] = mods.HookMod{Name: name, From: installed[name].String()}
+	}
+	return withHooks(ctx, mods.PreRemove, mods.PostRemove, changed, func() error {
+		for _, name := range names {
+			if dry != nil {
+				fmt.Printf("%s %s:\n", name, installed[name])
+				if err := dry.remove(name); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := mods.Uninstall(installDir, name); err != nil {
+				return fmt.Errorf("remove %s: %w", name, err)
+			}
+			fmt.Printf("Removed %s %s\n", name, installed[name])
+		}
+		return nil
+	})
+}
+
+// applyPackSettings applies the modpack's settings to mod-settings.dat.
+func applyPackSettings(pack *mods.Modpack, dry bool) error {
+	s, err := settings.Load(installDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return errors.New("mod-settings.dat does not exist; start Factorio once to create it, and apply the modpack again")
+	} else if err != nil {
+		return err
+	}
+
+	if err := pack.ApplySettings(s); err != nil {
+		return err
+	}
+
+	if dry {
+		for _, scope := range settings.Scopes() {
+			names := make([]string, 0, len(pack.Settings[scope]))
+			for name := range pack.Settings[scope] {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			for _, name := range names {
+				v, _ := s.Get(scope, name)
+				fmt.Printf("would set %s setting %s to %s\n", scope, name, formatSetting(v))
+			}
+		}
+		return nil
+	}
+
+	if err := s.Save(installDir); err != nil {
+		return fmt.Errorf("save mod settings: %w", err)
+	}
+	return nil
+}
+
+// runPackCreate is the entrypoint for the "pack create" subcommand.
+func runPackCreate(ctx context.Context, args []string) error {
+	if len(args) > 1 {
+		return errors.New("too many arguments")
+	}
+
+	name := packName
+	if name == "" {
+		name = filepath.Base(installDir)
+	}
+	pack, err := mods.CreateModpack(name, installDir, packSettings)
+	if err != nil {
+		return fmt.Errorf("create modpack: %w", err)
+	}
+
+	if len(args) == 0 || args[0] == "-" {
+		_, err := pack.WriteTo(os.Stdout)
+		return err
+	}
+
+	f, err := os.Create(args[0])
+	if err != nil {
+		return fmt.Errorf("create modpack: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := pack.WriteTo(f); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,112 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/nesv/factorio-tools/mods"
+	"github.com/nesv/factorio-tools/xdg"
+)
+
+// runPin is the entrypoint for the "pin" subcommand.
+func runPin(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("at least one mod is required")
+	}
+
+	pins, err := loadPins()
+	if err != nil {
+		return fmt.Errorf("load pins: %w", err)
+	}
+
+	var installed []mods.M
+	for _, arg := range args {
+		name, vs := parseModArg(arg)
+
+		var v mods.Version
+		if vs != "" {
+			if v, err = mods.ParseVersion(vs); err != nil {
+				return err
+			}
+		} else {
+			// Pin the mod at its currently-installed version.
+			if installed == nil {
+				if installed, err = mods.Load(installDir); err != nil {
+					return fmt.Errorf("load mods: %w", err)
+				}
+			}
+			for _, m := range installed {
+				if m.Name == name && len(m.Versions) > 0 {
+					v = m.Versions[len(m.Versions)-1]
+				}
+			}
+			if v.IsZero() {
+				return fmt.Errorf("%s is not installed; specify a version with %s==VERSION", name, name)
+			}
+		}
+
+		pins[name] = v
+		fmt.Printf("Pinned %s at %s\n", name, v)
+	}
+
+	return savePins(pins)
+}
+
+// runUnpin is the entrypoint for the "unpin" subcommand.
+func runUnpin(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("at least one mod is required")
+	}
+
+	pins, err := loadPins()
+	if err != nil {
+		return fmt.Errorf("load pins: %w", err)
+	}
+
+	for _, name := range args {
+		if _, ok := pins[name]; !ok {
+			return fmt.Errorf("%s is not pinned", name)
+		}
+		delete(pins, name)
+	}
+
+	return savePins(pins)
+}
+
+func makeStateDir() (string, error) {
+	dir, err := xdg.UserStateDir()
+	if err != nil {
+		return "", fmt.Errorf("user state dir: %w", err)
+	}
+
+	dir = filepath.Join(dir, "facmod")
+	if err := os.MkdirAll(dir, fs.ModePerm); err != nil {
+		return "", fmt.Errorf("make directory %q: %w", dir, err)
+	}
+
+	return dir, nil
+}
+
+func loadPins() (mods.Pins, error) {
+	dir, err := makeStateDir()
+	if err != nil {
+		return nil, fmt.Errorf("make state dir: %w", err)
+	}
+	return mods.LoadPins(filepath.Join(dir, "pins.json"))
+}
+
+func savePins(pins mods.Pins) error {
+	dir, err := makeStateDir()
+	if err != nil {
+		return fmt.Errorf("make state dir: %w", err)
+	}
+	return pins.Save(filepath.Join(dir, "pins.json"))
+}
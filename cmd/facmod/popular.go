@@ -0,0 +1,96 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	humanize "github.com/dustin/go-humanize"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// Set by command-line flags.
+var (
+	popularCategory string
+	popularLimit    uint
+)
+
+// runPopular is the entrypoint for the "popular" subcommand.
+func runPopular(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return errors.New("too many arguments")
+	}
+
+	cache, err := openFreshCache(ctx)
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	var options []mods.SearchOption
+	if factorioVersion != "" {
+		options = append(options, mods.ForFactorioVersion(factorioVersion))
+	}
+	if popularCategory != "" {
+		options = append(options, mods.WithCategories(mods.Category(popularCategory)))
+	}
+	options = append(options, mods.WithLimit(int(popularLimit)))
+
+	mm, err := cache.Popular(ctx, options...)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput() {
+		type popularResult struct {
+			Name      string `json:"name"`
+			Category  string `json:"category"`
+			Downloads int    `json:"downloads_count"`
+			Version   string `json:"version"`
+			Summary   string `json:"summary"`
+		}
+		results := make([]popularResult, len(mm))
+		for i, m := range mm {
+			results[i] = popularResult{
+				Name:      m.Name,
+				Category:  m.Category,
+				Downloads: m.Downloads,
+				Version:   m.Versions[0].String(),
+				Summary:   m.Summary,
+			}
+		}
+		return writeJSON(results)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	defer tw.Flush()
+
+	if !noHeaders {
+		headers := []string{"NAME", "CATEGORY", "DOWNLOADS", "VERSION", "SUMMARY"}
+		fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	}
+
+	for _, m := range mm {
+		summary := m.Summary
+		if len(summary) > 30 {
+			summary = summary[0:30] + "..."
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			m.Name,
+			m.Category,
+			humanize.Comma(int64(m.Downloads)),
+			m.Versions[0],
+			summary,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	ff "github.com/peterbourgon/ff/v4"
+)
+
+// Set by command-line flags.
+var (
+	profileName     string
+	factorioVersion string
+)
+
+// profile is a named Factorio installation, read from profiles.json.
+// Each field provides the value of the root command-line flag with the same
+// name, unless that flag was given on the command line.
+type profile struct {
+	Directory       string `json:"directory,omitempty"`
+	Username        string `json:"username,omitempty"`
+	Token           string `json:"token,omitempty"`
+	PlayerData      string `json:"player-data,omitempty"`
+	FactorioVersion string `json:"factorio-version,omitempty"`
+}
+
+// profilesPath returns the path to the file profiles are read from.
+func profilesPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("user config dir: %w", err)
+	}
+	return filepath.Join(dir, "facmod", "profiles.json"), nil
+}
+
+// loadProfiles reads all of the profiles in profiles.json, keyed by name.
+func loadProfiles() (map[string]profile, error) {
+	path, err := profilesPath()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open profiles: %w", err)
+	}
+	defer f.Close()
+
+	var profiles map[string]profile
+	if err := json.NewDecoder(f).Decode(&profiles); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	return profiles, nil
+}
+
+// applyProfile sets the root flags from the profile selected with --profile.
+// Flags that were given on the command line are left alone, but a profile
+// overrides values from the config file.
+func applyProfile(flags ff.Flags) error {
+	if profileName == "" {
+		return nil
+	}
+
+	profiles, err := loadProfiles()
+	if err != nil {
+		return fmt.Errorf("load profiles: %w", err)
+	}
+	p, ok := profiles[profileName]
+	if !ok {
+		return fmt.Errorf("no such profile: %s", profileName)
+	}
+
+	values := map[string]string{
+		"directory":        p.Directory,
+		"username":         p.Username,
+		"token":            p.Token,
+		"player-data":      p.PlayerData,
+		"factorio-version": p.FactorioVersion,
+	}
+	for name, value := range values {
+		if value == "" {
+			continue
+		}
+		f, ok := flags.GetFlag(name)
+		if !ok || (f.IsSet() && !fromConfig(flags, name)) {
+			continue
+		}
+		if err := f.SetValue(value); err != nil {
+			return fmt.Errorf("profile %s: %s: %w", profileName, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// runPublish is the entrypoint for the "publish" subcommand.
+func runPublish(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one mod archive is required")
+	}
+
+	if dryRun {
+		info, err := mods.LoadFileInfo(args[0])
+		if err != nil {
+			return fmt.Errorf("%s: %w", args[0], err)
+		}
+		fmt.Printf("Would publish %s %s\n", info.Name, info.Version)
+		return nil
+	}
+
+	info, err := mods.Publish(ctx, apiKey, args[0])
+	if err != nil {
+		return fmt.Errorf("publish %s: %w", args[0], err)
+	}
+	fmt.Printf("Published %s %s\n", info.Name, info.Version)
+	return nil
+}
@@ -0,0 +1,76 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// runRemove is the entrypoint for the "remove" subcommand.
+func runRemove(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("at least one mod is required")
+	}
+
+	list, err := mods.LoadModList(installDir)
+	if err != nil {
+		return fmt.Errorf("load mod list: %w", err)
+	}
+	installed, err := installedVersions()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range args {
+		_, ok := installed[name]
+		_, listed := list.Lookup(name)
+		if !ok && !listed {
+			return fmt.Errorf("%s is not installed", name)
+		}
+		if !ok {
+			// Mods that ship with the game can be disabled, but not
+			// removed.
+			return fmt.Errorf("%s cannot be removed; it is not a downloaded mod", name)
+		}
+	}
+
+	if dryRun {
+		cache, err := openCache()
+		if err != nil {
+			return err
+		}
+		defer cache.Close()
+
+		dry, err := newDryRunPlan(cache)
+		if err != nil {
+			return err
+		}
+		for _, name := range args {
+			fmt.Printf("%s %s:\n", name, installed[name])
+			if err := dry.remove(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	changed := make([]mods.HookMod, len(args))
+	for i, name := range args {
+		changed[i] = mods.HookMod{Name: name, From: installed[name].String()}
+	}
+	return withHooks(ctx, mods.PreRemove, mods.PostRemove, changed, func() error {
+		for _, name := range args {
+			if err := mods.Uninstall(installDir, name); err != nil {
+				return fmt.Errorf("remove %s: %w", name, err)
+			}
+			fmt.Printf("Removed %s %s\n", name, installed[name])
+		}
+		return nil
+	})
+}
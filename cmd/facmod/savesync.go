@@ -0,0 +1,106 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nesv/factorio-tools/mods"
+	"github.com/nesv/factorio-tools/server"
+)
+
+// runSyncSave is the entrypoint for the "sync-save" subcommand.
+func runSyncSave(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one save is required")
+	}
+
+	info, err := server.InspectSave(savePath(args[0]))
+	if err != nil {
+		return err
+	}
+	m, err := saveManifest(info)
+	if err != nil {
+		return err
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		return fmt.Errorf("load credentials: %w", err)
+	}
+
+	cache, err := openFreshCache(ctx)
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	fetch := func(ctx context.Context, name string, version mods.Version) (string, error) {
+		fmt.Printf("Installing %s %s\n", name, version)
+		return cache.GetVersion(ctx, name, version.String(), creds.Username, creds.Token)
+	}
+	if err := m.Import(ctx, installDir, fetch); err != nil {
+		return fmt.Errorf("sync save: %w", err)
+	}
+	return nil
+}
+
+// saveManifest returns a [mods.Manifest] enabling exactly the mods in a
+// save, at the versions it was saved with, and disabling every other
+// installed mod.
+// The built-in mods the save needs have to be provided by the installation.
+func saveManifest(info server.SaveInfo) (*mods.Manifest, error) {
+	builtins, err := mods.Builtins(installDir)
+	if err != nil {
+		return nil, err
+	}
+	installed, err := mods.Load(installDir)
+	if err != nil {
+		return nil, fmt.Errorf("load mods: %w", err)
+	}
+
+	m := new(mods.Manifest)
+	inSave := make(map[string]bool, len(info.Mods))
+	for _, sm := range info.Mods {
+		inSave[sm.Name] = true
+		if !mods.IsBuiltin(sm.Name) {
+			m.Mods = append(m.Mods, mods.ManifestEntry{Name: sm.Name, Enabled: true, Version: sm.Version.String()})
+			continue
+		}
+
+		v, ok := builtins[sm.Name]
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("the save needs %s, which is not part of the installed game", sm.Name)
+		case v.Compare(sm.Version) < 0:
+			fmt.Fprintf(os.Stderr, "The save was made with %s %s, which is newer than the installed %s; the game may not load it\n", sm.Name, sm.Version, v)
+		}
+		m.Mods = append(m.Mods, mods.ManifestEntry{Name: sm.Name, Enabled: true})
+	}
+	for _, im := range installed {
+		if !inSave[im.Name] {
+			m.Mods = append(m.Mods, mods.ManifestEntry{Name: im.Name, Enabled: false})
+		}
+	}
+	return m, nil
+}
+
+// savePath returns the path to a save given on the command line: either a
+// path to a file, or the name of a save in the installation's saves
+// directory, with or without its ".zip" extension.
+func savePath(arg string) string {
+	if strings.ContainsRune(arg, os.PathSeparator) {
+		return arg
+	}
+	if _, err := os.Stat(arg); err == nil {
+		return arg
+	}
+	return filepath.Join(installDir, "saves", strings.TrimSuffix(arg, ".zip")+".zip")
+}
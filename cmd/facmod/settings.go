@@ -0,0 +1,224 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nesv/factorio-tools/mods/settings"
+)
+
+// Set by command-line flags.
+var settingsScope string
+
+// runSettingsList is the entrypoint for the "settings list" subcommand.
+func runSettingsList(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return errors.New("too many arguments")
+	}
+
+	s, err := settings.Load(installDir)
+	if err != nil {
+		return err
+	}
+
+	scopes, err := selectedScopes()
+	if err != nil {
+		return err
+	}
+
+	type setting struct {
+		Scope settings.Scope `json:"scope"`
+		Name  string         `json:"name"`
+		Type  string         `json:"type"`
+		Value any            `json:"value"`
+	}
+	var all []setting
+	for _, scope := range scopes {
+		for _, name := range s.Names(scope) {
+			v, _ := s.Get(scope, name)
+			all = append(all, setting{
+				Scope: scope,
+				Name:  name,
+				Type:  v.Type.String(),
+				Value: v.Interface(),
+			})
+		}
+	}
+
+	if jsonOutput() {
+		return writeJSON(all)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	if !noHeaders {
+		headers := []string{"SCOPE", "NAME", "TYPE", "VALUE"}
+		fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	}
+	for _, st := range all {
+		v, _ := s.Get(st.Scope, st.Name)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", st.Scope, st.Name, st.Type, formatSetting(v))
+	}
+	return tw.Flush()
+}
+
+// runSettingsGet is the entrypoint for the "settings get" subcommand.
+func runSettingsGet(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one setting name is required")
+	}
+
+	s, err := settings.Load(installDir)
+	if err != nil {
+		return err
+	}
+
+	v, _, err := findSetting(s, args[0])
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput() {
+		return writeJSON(v.Interface())
+	}
+	fmt.Println(formatSetting(v))
+	return nil
+}
+
+// runSettingsSet is the entrypoint for the "settings set" subcommand.
+func runSettingsSet(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return errors.New("a setting name and value are required")
+	}
+	name, raw := args[0], args[1]
+
+	s, err := settings.Load(installDir)
+	if err != nil {
+		return err
+	}
+
+	old, scope, err := findSetting(s, name)
+	if err != nil {
+		return err
+	}
+
+	v, err := parseSetting(old.Type, raw)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	v.AnyType = old.AnyType
+	s.Set(scope, name, v)
+
+	if err := s.Save(installDir); err != nil {
+		return fmt.Errorf("save mod settings: %w", err)
+	}
+	return nil
+}
+
+// findSetting looks up the named setting in the scope given by --scope, or in
+// every scope when --scope is not set.
+// Only existing settings can be found; mods add their settings to
+// mod-settings.dat the first time the game is started with the mod enabled.
+func findSetting(s *settings.Settings, name string) (settings.Value, settings.Scope, error) {
+	scopes, err := selectedScopes()
+	if err != nil {
+		return settings.Value{}, "", err
+	}
+
+	for _, scope := range scopes {
+		if v, ok := s.Get(scope, name); ok {
+			return v, scope, nil
+		}
+	}
+	return settings.Value{}, "", fmt.Errorf("no such setting: %s", name)
+}
+
+// selectedScopes returns the scope given by --scope, or all scopes when
+// --scope is not set.
+func selectedScopes() ([]settings.Scope, error) {
+	if settingsScope == "" {
+		return settings.Scopes(), nil
+	}
+	for _, scope := range settings.Scopes() {
+		if string(scope) == settingsScope {
+			return []settings.Scope{scope}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown scope: %s", settingsScope)
+}
+
+// formatSetting formats a setting's value for display.
+// Dictionaries, such as color settings, are formatted as JSON.
+func formatSetting(v settings.Value) string {
+	switch v.Type {
+	case settings.Bool:
+		return strconv.FormatBool(v.Bool)
+	case settings.Number:
+		return strconv.FormatFloat(v.Number, 'g', -1, 64)
+	case settings.String:
+		return v.String
+	case settings.Int:
+		return strconv.FormatInt(v.Int, 10)
+	case settings.Uint:
+		return strconv.FormatUint(v.Uint, 10)
+	case settings.None:
+		return ""
+	}
+	p, err := json.Marshal(v.Interface())
+	if err != nil {
+		return "(" + v.Type.String() + ")"
+	}
+	return string(p)
+}
+
+// parseSetting parses s as a setting value of type t.
+func parseSetting(t settings.Type, s string) (settings.Value, error) {
+	v := settings.Value{Type: t}
+	var err error
+	switch t {
+	case settings.Bool:
+		v.Bool, err = strconv.ParseBool(s)
+	case settings.Number:
+		v.Number, err = strconv.ParseFloat(s, 64)
+	case settings.String:
+		v.String = s
+	case settings.Int:
+		v.Int, err = strconv.ParseInt(s, 10, 64)
+	case settings.Uint:
+		v.Uint, err = strconv.ParseUint(s, 10, 64)
+	case settings.Dictionary:
+		// Color settings are stored as dictionaries of numbers, e.g.
+		// {"r": 1, "g": 0, "b": 0, "a": 1}.
+		var m map[string]float64
+		if err := json.Unmarshal([]byte(s), &m); err != nil {
+			return settings.Value{}, fmt.Errorf("parse %q as a JSON object of numbers: %w", s, err)
+		}
+		keys := []string{"r", "g", "b", "a"}
+		for k := range m {
+			if !slices.Contains(keys, k) {
+				return settings.Value{}, fmt.Errorf("parse %q: unknown color key %q; use r, g, b, and a", s, k)
+			}
+		}
+		for _, k := range keys {
+			if f, ok := m[k]; ok {
+				v.Entries = append(v.Entries, settings.Entry{Key: k, Value: settings.NumberValue(f)})
+			}
+		}
+	default:
+		return settings.Value{}, fmt.Errorf("cannot set %s values", t)
+	}
+	if err != nil {
+		return settings.Value{}, fmt.Errorf("parse %q as a %s: %w", s, t, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,71 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// Set by command-line flags.
+var lockfilePath string
+
+// lockfile returns the path to the lockfile to use.
+// Unless set with --lockfile, the lockfile is "facmod.lock" in the Factorio
+// installation directory.
+func lockfile() string {
+	if lockfilePath != "" {
+		return lockfilePath
+	}
+	return filepath.Join(installDir, "facmod.lock")
+}
+
+// runLock is the entrypoint for the "lock" subcommand.
+func runLock(ctx context.Context, args []string) error {
+	lock, err := mods.Lock(installDir)
+	if err != nil {
+		return fmt.Errorf("lock installed mods: %w", err)
+	}
+
+	path := lockfile()
+	if err := lock.Save(path); err != nil {
+		return fmt.Errorf("save lockfile: %w", err)
+	}
+
+	fmt.Printf("Locked %d mods in %s\n", len(lock.Mods), path)
+	return nil
+}
+
+// runSync is the entrypoint for the "sync" subcommand.
+func runSync(ctx context.Context, args []string) error {
+	lock, err := mods.LoadLockfile(lockfile())
+	if err != nil {
+		return fmt.Errorf("load lockfile: %w", err)
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		return fmt.Errorf("load credentials: %w", err)
+	}
+
+	cache, err := openFreshCache(ctx)
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	fetch := func(ctx context.Context, name string, version mods.Version) (string, error) {
+		fmt.Printf("Installing %s %s\n", name, version)
+		return cache.GetVersion(ctx, name, version.String(), creds.Username, creds.Token)
+	}
+	if err := lock.Sync(ctx, installDir, fetch); err != nil {
+		return fmt.Errorf("sync: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,228 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "image/gif"
+	_ "image/jpeg"
+
+	"golang.org/x/term"
+)
+
+// Terminal graphics protocols supported by showImage.
+const (
+	protoNone  = ""
+	protoKitty = "kitty"
+	protoITerm = "iterm"
+	protoSixel = "sixel"
+)
+
+// imageProtocol guesses which graphics protocol the terminal attached to
+// STDOUT supports, from the environment.
+// It returns protoNone when STDOUT is not a terminal, or the terminal is not
+// known to support any of them.
+func imageProtocol() string {
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return protoNone
+	}
+
+	termName, termProgram := os.Getenv("TERM"), os.Getenv("TERM_PROGRAM")
+	switch {
+	case termName == "xterm-kitty", os.Getenv("KITTY_WINDOW_ID") != "", termProgram == "ghostty":
+		return protoKitty
+	case termProgram == "iTerm.app", termProgram == "WezTerm", os.Getenv("LC_TERMINAL") == "iTerm2":
+		return protoITerm
+	case strings.Contains(termName, "sixel"), termName == "foot", termName == "mlterm", termProgram == "mlterm":
+		return protoSixel
+	}
+	return protoNone
+}
+
+// showThumbnail displays the image at path in the terminal.
+// When the terminal does not support any graphics protocol, the image is
+// copied to the current directory instead, as NAME.EXT.
+func showThumbnail(name, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read thumbnail: %w", err)
+	}
+	return showImage(name+filepath.Ext(path), data)
+}
+
+// showImage displays the image in the terminal.
+// When the terminal does not support any graphics protocol, the image is
+// written to the file dst, in the current directory, instead.
+func showImage(dst string, data []byte) error {
+	proto := imageProtocol()
+	if proto == protoNone {
+		if err := os.WriteFile(dst, data, 0o644); err != nil {
+			return fmt.Errorf("save thumbnail: %w", err)
+		}
+		fmt.Printf("Saved thumbnail to %s\n", dst)
+		return nil
+	}
+
+	var err error
+	w := bufio.NewWriter(os.Stdout)
+	switch proto {
+	case protoKitty:
+		err = writeKitty(w, data)
+	case protoITerm:
+		err = writeITerm(w, data)
+	case protoSixel:
+		err = writeSixel(w, data)
+	}
+	if err != nil {
+		return fmt.Errorf("display thumbnail: %w", err)
+	}
+	fmt.Fprintln(w)
+	return w.Flush()
+}
+
+// writeKitty writes the image using the kitty graphics protocol, which only
+// accepts PNG images, in base64-encoded chunks of at most 4096 bytes.
+//
+// See https://sw.kovidgoyal.net/kitty/graphics-protocol/.
+func writeKitty(w io.Writer, data []byte) error {
+	if !bytes.HasPrefix(data, []byte("\x89PNG")) {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("decode image: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return fmt.Errorf("encode png: %w", err)
+		}
+		data = buf.Bytes()
+	}
+
+	enc := base64.StdEncoding.EncodeToString(data)
+	for first := true; ; first = false {
+		chunk := enc
+		if len(chunk) > 4096 {
+			chunk = chunk[:4096]
+		}
+		enc = enc[len(chunk):]
+
+		more := 0
+		if enc != "" {
+			more = 1
+		}
+		if first {
+			fmt.Fprintf(w, "\x1b_Gf=100,a=T,m=%d;%s\x1b\\", more, chunk)
+		} else {
+			fmt.Fprintf(w, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+		if enc == "" {
+			return nil
+		}
+	}
+}
+
+// writeITerm writes the image using iTerm2's inline image protocol.
+//
+// See https://iterm2.com/documentation-images.html.
+func writeITerm(w io.Writer, data []byte) error {
+	_, err := fmt.Fprintf(w, "\x1b]1337;File=inline=1;size=%d;preserveAspectRatio=1:%s\a",
+		len(data),
+		base64.StdEncoding.EncodeToString(data),
+	)
+	return err
+}
+
+// writeSixel writes the image as DEC sixel graphics, using a fixed palette of
+// 216 colors (6 levels each of red, green, and blue).
+// Pixels that are mostly transparent are left blank.
+func writeSixel(w io.Writer, data []byte) error {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("decode image: %w", err)
+	}
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+
+	// Map every pixel to a palette index, or -1 for transparent pixels.
+	pixels := make([]int, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, bl, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			if a < 0x8000 {
+				pixels[y*width+x] = -1
+				continue
+			}
+			level := func(c uint32) int { return int(c * 5 / 0xffff) }
+			pixels[y*width+x] = level(r)*36 + level(g)*6 + level(bl)
+		}
+	}
+
+	fmt.Fprintf(w, "\x1bPq\"1;1;%d;%d", width, height)
+	for i := 0; i < 216; i++ {
+		fmt.Fprintf(w, "#%d;2;%d;%d;%d", i, i/36*20, i/6%6*20, i%6*20)
+	}
+
+	// Each line of sixels covers a band of 6 rows of pixels, and is drawn
+	// once per color used in the band.
+	for top := 0; top < height; top += 6 {
+		var used [216]bool
+		for y := top; y < top+6 && y < height; y++ {
+			for x := 0; x < width; x++ {
+				if p := pixels[y*width+x]; p >= 0 {
+					used[p] = true
+				}
+			}
+		}
+
+		for color, ok := range used {
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(w, "#%d", color)
+
+			var (
+				prev  byte
+				count int
+			)
+			flush := func() {
+				switch {
+				case count > 3:
+					fmt.Fprintf(w, "!%d%c", count, prev)
+				case count > 0:
+					w.Write(bytes.Repeat([]byte{prev}, count))
+				}
+			}
+			for x := 0; x < width; x++ {
+				var bits byte
+				for dy := 0; dy < 6 && top+dy < height; dy++ {
+					if pixels[(top+dy)*width+x] == color {
+						bits |= 1 << dy
+					}
+				}
+				c := 63 + bits
+				if c != prev {
+					flush()
+					prev, count = c, 0
+				}
+				count++
+			}
+			flush()
+			io.WriteString(w, "$")
+		}
+		io.WriteString(w, "-")
+	}
+
+	_, err = io.WriteString(w, "\x1b\\")
+	return err
+}
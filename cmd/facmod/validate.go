@@ -0,0 +1,67 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// runValidate is the entrypoint for the "validate" subcommand.
+func runValidate(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one mod directory or archive is required")
+	}
+	path := args[0]
+
+	var (
+		info mods.Info
+		err  error
+	)
+	if st, serr := os.Stat(path); serr == nil && st.IsDir() {
+		info, err = mods.LoadDirInfo(path)
+	} else {
+		info, err = mods.LoadInfo(path)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	var problems []string
+	if err := info.Validate(); err != nil {
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range joined.Unwrap() {
+				problems = append(problems, e.Error())
+			}
+		} else {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if jsonOutput() {
+		if err := writeJSON(struct {
+			Path     string   `json:"path"`
+			Valid    bool     `json:"valid"`
+			Problems []string `json:"problems"`
+		}{path, len(problems) == 0, append([]string{}, problems...)}); err != nil {
+			return err
+		}
+	} else if len(problems) == 0 {
+		fmt.Printf("%s: %s %s is valid\n", path, info.Name, info.Version)
+	} else {
+		for _, p := range problems {
+			fmt.Printf("%s: %s\n", path, p)
+		}
+	}
+
+	if n := len(problems); n > 0 {
+		return fmt.Errorf("info.json has %d problems", n)
+	}
+	return nil
+}
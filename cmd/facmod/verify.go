@@ -0,0 +1,83 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// runVerify is the entrypoint for the "verify" subcommand.
+func runVerify(ctx context.Context, args []string) error {
+	cache, err := openFreshCache(ctx)
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	results, err := cache.Verify(ctx, installDir)
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+
+	var failed, mismatched int
+	for _, r := range results {
+		if !r.OK() {
+			failed++
+		}
+		if !r.OK() && r.Err == nil {
+			mismatched++
+		}
+	}
+
+	if jsonOutput() {
+		type verifyResult struct {
+			mods.VerifyResult
+			OK    bool   `json:"ok"`
+			Error string `json:"error,omitempty"`
+		}
+		out := make([]verifyResult, len(results))
+		for i, r := range results {
+			out[i] = verifyResult{VerifyResult: r, OK: r.OK()}
+			if r.Err != nil {
+				out[i].Error = r.Err.Error()
+			}
+		}
+		if err := writeJSON(out); err != nil {
+			return err
+		}
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+		if !noHeaders {
+			headers := []string{"NAME", "VERSION", "STATUS"}
+			fmt.Fprintln(tw, strings.Join(headers, "\t"))
+		}
+		for _, r := range results {
+			status := "ok"
+			switch {
+			case r.Err != nil:
+				status = "unverified: " + r.Err.Error()
+			case !r.OK():
+				status = "MISMATCH"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Name, r.Version, status)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if mismatched > 0 {
+		return fmt.Errorf("%d of %d mods failed verification: %w", failed, len(results), mods.ErrChecksumMismatch)
+	} else if failed > 0 {
+		return fmt.Errorf("%d of %d mods failed verification", failed, len(results))
+	}
+	return nil
+}
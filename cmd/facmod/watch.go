@@ -0,0 +1,105 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// Set by command-line flags.
+var (
+	watchSymlink  bool
+	watchInterval time.Duration
+)
+
+// runWatch is the entrypoint for the "watch" subcommand.
+func runWatch(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one mod directory is required")
+	}
+	dir := args[0]
+
+	if watchSymlink {
+		// The game reads the linked directory directly, so there is
+		// nothing to watch.
+		info, err := mods.LinkDir(installDir, dir)
+		if err != nil {
+			return fmt.Errorf("link %s: %w", dir, err)
+		}
+		fmt.Printf("Linked %s %s to %s\n", info.Name, info.Version, dir)
+		return nil
+	}
+	if watchInterval <= 0 {
+		return errors.New("--interval must be greater than zero")
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	var last uint64
+	for {
+		sum, err := dirFingerprint(dir)
+		if err != nil {
+			return fmt.Errorf("watch %s: %w", dir, err)
+		}
+		if sum != last {
+			last = sum
+			// A broken build should not stop the watch; the next
+			// change may fix it.
+			if info, err := mods.InstallDir(installDir, dir); err != nil {
+				slog.ErrorContext(ctx, "install failed", "dir", dir, "err", err)
+			} else {
+				fmt.Printf("%s Installed %s %s\n", time.Now().Format(time.TimeOnly), info.Name, info.Version)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(watchInterval):
+		}
+	}
+}
+
+// dirFingerprint returns a hash of the path, size, and modification time of
+// every file in dir, which changes whenever a file is added, removed, or
+// modified.
+// Hidden files and directories, like ".git", are skipped.
+func dirFingerprint(dir string) (uint64, error) {
+	h := fnv.New64a()
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\x00", p, fi.Size(), fi.ModTime().UnixNano())
+		return nil
+	})
+	return h.Sum64(), err
+}
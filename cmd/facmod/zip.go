@@ -0,0 +1,38 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// Set by command-line flags.
+var zipDest string
+
+// runZip is the entrypoint for the "zip" subcommand.
+func runZip(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one mod directory is required")
+	}
+
+	path, info, err := mods.PackDirFile(args[0], zipDest)
+	if err != nil {
+		return fmt.Errorf("package %s: %w", args[0], err)
+	}
+
+	if jsonOutput() {
+		return writeJSON(struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+			Path    string `json:"path"`
+		}{info.Name, info.Version, path})
+	}
+	fmt.Printf("Packaged %s %s to %s\n", info.Name, info.Version, path)
+	return nil
+}
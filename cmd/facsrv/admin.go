@@ -0,0 +1,87 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nesv/factorio-tools/server"
+)
+
+// runAdminList is the entrypoint for the "admin list" subcommand.
+func runAdminList(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errors.New("too many arguments")
+	}
+
+	a, err := server.LoadAdminlist(installDir)
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		return writeJSON(a)
+	}
+	for _, name := range a {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+// runAdminAdd is the entrypoint for the "admin add" subcommand.
+func runAdminAdd(ctx context.Context, args []string) error {
+	return changeAdminlist(ctx, args, true)
+}
+
+// runAdminRemove is the entrypoint for the "admin remove" subcommand.
+func runAdminRemove(ctx context.Context, args []string) error {
+	return changeAdminlist(ctx, args, false)
+}
+
+// changeAdminlist adds the players named in args to the adminlist, or
+// removes them from it, and promotes or demotes them on the server, when it
+// is running.
+func changeAdminlist(ctx context.Context, args []string, add bool) error {
+	if len(args) == 0 {
+		return errors.New("at least one player is required")
+	}
+	for _, name := range args {
+		if !server.ValidPlayerName(name) {
+			return fmt.Errorf("invalid player name %q", name)
+		}
+	}
+
+	a, err := server.LoadAdminlist(installDir)
+	if err != nil {
+		return err
+	}
+	for _, name := range args {
+		switch {
+		case add && !a.Add(name):
+			fmt.Printf("%s is already an admin\n", name)
+		case !add && !a.Remove(name):
+			fmt.Printf("%s is not an admin\n", name)
+		}
+	}
+	if err := a.Save(installDir); err != nil {
+		return err
+	}
+
+	return withRunningServer(ctx, func(conn *server.RCON) error {
+		for _, name := range args {
+			var err error
+			if add {
+				err = conn.Promote(ctx, name)
+			} else {
+				err = conn.Demote(ctx, name)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
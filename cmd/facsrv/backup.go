@@ -0,0 +1,61 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nesv/factorio-tools/server"
+)
+
+// Set by command-line flags.
+var (
+	backupDir       string
+	backupSettings  bool
+	backupRetention server.Retention
+)
+
+// runBackup is the entrypoint for the "backup" subcommand.
+func runBackup(ctx context.Context, args []string) error {
+	if len(args) > 1 {
+		return errors.New("too many arguments")
+	}
+	if backupRetention.KeepLast < 0 || backupRetention.KeepDaily < 0 || backupRetention.KeepWeekly < 0 {
+		return errors.New("--keep-last, --keep-daily, and --keep-weekly cannot be negative")
+	}
+
+	opts := server.BackupOptions{
+		Dir:       backupDir,
+		Settings:  backupSettings,
+		Retention: backupRetention,
+	}
+	if len(args) == 1 {
+		opts.Save = args[0]
+	}
+	result, err := server.Backup(installDir, opts)
+	for _, path := range result.Removed {
+		fmt.Printf("Removed %s\n", path)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Backed up to %s\n", result.Path)
+	return nil
+}
+
+// runRestore is the entrypoint for the "restore" subcommand.
+func runRestore(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one backup is required")
+	}
+
+	restored, err := server.Restore(installDir, args[0])
+	for _, rel := range restored {
+		fmt.Printf("Restored %s\n", rel)
+	}
+	return err
+}
@@ -0,0 +1,125 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nesv/factorio-tools/server"
+)
+
+// runBan is the entrypoint for the "ban" subcommand.
+func runBan(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("a player is required")
+	}
+	ban := server.Ban{Username: args[0], Reason: strings.Join(args[1:], " ")}
+	if !server.ValidPlayerName(ban.Username) {
+		return fmt.Errorf("invalid player name %q", ban.Username)
+	}
+
+	bans, err := server.LoadBanlist(installDir)
+	if err != nil {
+		return err
+	}
+	if !bans.Add(ban) && ban.Reason == "" {
+		fmt.Printf("%s is already banned\n", ban.Username)
+	}
+	if err := bans.Save(installDir); err != nil {
+		return err
+	}
+
+	return withRunningServer(ctx, func(conn *server.RCON) error {
+		return conn.Ban(ctx, ban.Username, ban.Reason)
+	})
+}
+
+// runUnban is the entrypoint for the "unban" subcommand.
+func runUnban(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("at least one player is required")
+	}
+	for _, name := range args {
+		if !server.ValidPlayerName(name) {
+			return fmt.Errorf("invalid player name %q", name)
+		}
+	}
+
+	bans, err := server.LoadBanlist(installDir)
+	if err != nil {
+		return err
+	}
+	for _, name := range args {
+		if !bans.Remove(name) {
+			fmt.Printf("%s is not banned\n", name)
+		}
+	}
+	if err := bans.Save(installDir); err != nil {
+		return err
+	}
+
+	return withRunningServer(ctx, func(conn *server.RCON) error {
+		for _, name := range args {
+			if err := conn.Unban(ctx, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// runBans is the entrypoint for the "bans" subcommand.
+func runBans(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errors.New("too many arguments")
+	}
+
+	bans, err := server.LoadBanlist(installDir)
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		return writeJSON(bans)
+	}
+
+	if len(bans) == 0 {
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	if !noHeaders {
+		headers := []string{"NAME", "ADDRESS", "REASON"}
+		fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	}
+	for _, b := range bans {
+		addr := b.Address
+		if addr == "" {
+			addr = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", b.Username, addr, b.Reason)
+	}
+	return tw.Flush()
+}
+
+// withRunningServer calls fn with a connection to the server's RCON
+// interface, so that a change made to one of its files also takes effect
+// without restarting it.
+// When the server cannot be reached, fn is not called.
+func withRunningServer(ctx context.Context, fn func(*server.RCON) error) error {
+	conn, err := dialRunningRCON(ctx)
+	if err != nil {
+		return err
+	}
+	if conn == nil {
+		fmt.Println("The server is not running, or RCON is not set up; the change takes effect when it starts")
+		return nil
+	}
+	defer conn.Close()
+	return fn(conn)
+}
@@ -0,0 +1,226 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/nesv/factorio-tools/mods"
+	"github.com/nesv/factorio-tools/server"
+	"github.com/nesv/factorio-tools/server/logs"
+)
+
+// Set by command-line flags.
+var (
+	exporterListen string
+	exporterLog    string
+	exporterTicks  bool
+)
+
+// scrapeTimeout bounds the time spent collecting metrics for a scrape.
+const scrapeTimeout = 10 * time.Second
+
+// runExporter is the entrypoint for the "exporter" subcommand.
+func runExporter(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errors.New("too many arguments")
+	}
+	if exporterLog == "" {
+		exporterLog = filepath.Join(installDir, "factorio-current.log")
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", &exporter{})
+	srv := &http.Server{
+		Addr:              exporterListen,
+		Handler:           mux,
+		ReadHeaderTimeout: scrapeTimeout,
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+	slog.InfoContext(ctx, "serving metrics", "address", exporterListen)
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("serve metrics: %w", err)
+	case <-ctx.Done():
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), scrapeTimeout)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
+
+// exporter serves the server's metrics in the Prometheus text format.
+// Metrics are collected when they are scraped.
+type exporter struct {
+	// The tick read by the last scrape, and when, for working out the
+	// game's updates per second.
+	mu         sync.Mutex
+	lastTick   uint64
+	lastTickAt time.Time
+}
+
+func (e *exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), scrapeTimeout)
+	defer cancel()
+
+	var buf bytes.Buffer
+	e.collect(ctx, &buf)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// collect writes the server's metrics to w.
+// Metrics that cannot be collected, like the players online when the server
+// is not running, are left out.
+func (e *exporter) collect(ctx context.Context, w io.Writer) {
+	up := 0.0
+	if conn, err := dialRCON(ctx); err != nil {
+		slog.DebugContext(ctx, "server is not reachable through rcon", "err", err)
+	} else {
+		defer conn.Close()
+		up = 1
+		e.collectRCON(ctx, w, conn)
+	}
+	writeGauge(w, "factorio_up", "Whether the server answered through RCON.", up)
+
+	if s, err := readLogSummary(exporterLog); err != nil {
+		slog.DebugContext(ctx, "cannot read server log", "path", exporterLog, "err", err)
+	} else {
+		s.write(w, up == 1)
+	}
+
+	if list, err := mods.LoadModList(installDir); err != nil {
+		slog.WarnContext(ctx, "cannot read mod list", "err", err)
+	} else {
+		enabled := 0
+		for _, m := range list.Mods {
+			if m.Enabled {
+				enabled++
+			}
+		}
+		writeGauge(w, "factorio_mods_enabled", "Number of mods enabled in mod-list.json, including base and the expansions.", float64(enabled))
+	}
+}
+
+// collectRCON writes the metrics read through RCON to w.
+func (e *exporter) collectRCON(ctx context.Context, w io.Writer, conn *server.RCON) {
+	if players, err := conn.Players(ctx); err != nil {
+		slog.WarnContext(ctx, "cannot list players", "err", err)
+	} else {
+		writeGauge(w, "factorio_players_online", "Number of players connected to the server.", float64(len(players)))
+	}
+
+	if !exporterTicks {
+		return
+	}
+	tick, err := conn.Tick(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "cannot read tick", "err", err)
+		return
+	}
+	now := time.Now()
+	writeGauge(w, "factorio_game_tick", "Ticks since the map was created.", float64(tick))
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if elapsed := now.Sub(e.lastTickAt).Seconds(); !e.lastTickAt.IsZero() && tick >= e.lastTick && elapsed > 0 {
+		writeGauge(w, "factorio_ups", "Game updates per second, since the previous scrape.", float64(tick-e.lastTick)/elapsed)
+	}
+	e.lastTick, e.lastTickAt = tick, now
+}
+
+// logSummary holds the metrics read from the server's log.
+type logSummary struct {
+	version string
+	started time.Time
+
+	// Saves that finished, and the time they took.
+	saves    int
+	saveTime time.Duration
+	lastSave time.Duration
+}
+
+// readLogSummary reads the server's version, when it started, and how long
+// its saves took from the log at path.
+func readLogSummary(path string) (logSummary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return logSummary{}, err
+	}
+	defer f.Close()
+
+	var (
+		s           logSummary
+		saveStarted time.Time
+	)
+	sc := logs.NewScanner(f)
+	for sc.Scan() {
+		ev := sc.Event()
+		switch ev.Kind {
+		case logs.KindVersion:
+			s = logSummary{version: ev.Message, started: ev.Time}
+		case logs.KindSaveStarted:
+			saveStarted = ev.Time
+		case logs.KindSaveFinished:
+			if !saveStarted.IsZero() && !ev.Time.IsZero() {
+				s.lastSave = ev.Time.Sub(saveStarted)
+				s.saveTime += s.lastSave
+				s.saves++
+			}
+			saveStarted = time.Time{}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return logSummary{}, err
+	}
+	if s.started.IsZero() {
+		return logSummary{}, errors.New("the log does not say when the server started")
+	}
+	return s, nil
+}
+
+// write writes the metrics in s to w.
+// The server's uptime is only written when it is running, since the log is
+// left behind when it stops.
+func (s logSummary) write(w io.Writer, running bool) {
+	fmt.Fprintf(w, "# HELP factorio_build_info Version of the running server.\n# TYPE factorio_build_info gauge\nfactorio_build_info{version=%q} 1\n", s.version)
+	writeGauge(w, "factorio_start_time_seconds", "When the server started, in seconds since the Unix epoch.", float64(s.started.Unix()))
+	if running {
+		writeGauge(w, "factorio_uptime_seconds", "Seconds since the server started.", time.Since(s.started).Seconds())
+	}
+
+	fmt.Fprint(w, "# HELP factorio_save_duration_seconds Time taken to save the map, including autosaves.\n# TYPE factorio_save_duration_seconds summary\n")
+	fmt.Fprintf(w, "factorio_save_duration_seconds_sum %s\n", formatFloat(s.saveTime.Seconds()))
+	fmt.Fprintf(w, "factorio_save_duration_seconds_count %d\n", s.saves)
+	if s.saves > 0 {
+		writeGauge(w, "factorio_last_save_duration_seconds", "Time taken by the last save.", s.lastSave.Seconds())
+	}
+}
+
+// writeGauge writes a gauge, in the Prometheus text format, to w.
+func writeGauge(w io.Writer, name, help string, v float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", name, help, name, name, formatFloat(v))
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
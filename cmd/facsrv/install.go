@@ -0,0 +1,44 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/nesv/factorio-tools/server"
+)
+
+// Set by command-line flags.
+var (
+	installVersion     string
+	installDownloadDir string
+)
+
+// runInstall is the entrypoint for the "install" subcommand.
+func runInstall(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one installation directory is required")
+	}
+	dir := args[0]
+
+	opts := server.InstallOptions{
+		Version:     installVersion,
+		DownloadDir: installDownloadDir,
+	}
+	if !quiet {
+		opts.Progress = os.Stderr
+	}
+
+	version, err := server.Install(ctx, dir, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Installed Factorio %s in %s\n", version, dir)
+	return nil
+}
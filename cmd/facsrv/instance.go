@@ -0,0 +1,206 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	ff "github.com/peterbourgon/ff/v4"
+
+	"github.com/nesv/factorio-tools/server"
+)
+
+// Set by command-line flags.
+var instanceName string
+
+// instanceTimeout bounds the time "instances list" waits for each instance to
+// answer through RCON.
+const instanceTimeout = 5 * time.Second
+
+// instance is a named server installation, read from instances.json.
+// Each field provides the value of the subcommand flag with the same name,
+// unless that flag was given on the command line.
+type instance struct {
+	Directory string `json:"directory"`
+
+	// The RCON address, when the port in the instance's server settings,
+	// on localhost, is not the one to use.
+	Address string `json:"address,omitempty"`
+
+	// The UDP port, and server settings, "serve" hosts the game with.
+	Port           int    `json:"port,omitempty"`
+	ServerSettings string `json:"server-settings,omitempty"`
+}
+
+// instancesPath returns the path to the file instances are read from.
+func instancesPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("user config dir: %w", err)
+	}
+	return filepath.Join(dir, "facsrv", "instances.json"), nil
+}
+
+// loadInstances reads all of the instances in instances.json, keyed by name.
+func loadInstances() (map[string]instance, error) {
+	path, err := instancesPath()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open instances: %w", err)
+	}
+	defer f.Close()
+
+	var instances map[string]instance
+	if err := json.NewDecoder(f).Decode(&instances); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	for name, inst := range instances {
+		if inst.Directory == "" {
+			return nil, fmt.Errorf("%s: instance %s: directory is required", path, name)
+		}
+	}
+	return instances, nil
+}
+
+// applyInstance sets the flags of the selected subcommand from the instance
+// selected with --instance.
+// Flags that were given on the command line, and flags the subcommand does
+// not have, are left alone.
+func applyInstance(flags ff.Flags) error {
+	if instanceName == "" {
+		return nil
+	}
+
+	instances, err := loadInstances()
+	if err != nil {
+		return fmt.Errorf("load instances: %w", err)
+	}
+	inst, ok := instances[instanceName]
+	if !ok {
+		return fmt.Errorf("no such instance: %s", instanceName)
+	}
+
+	values := map[string]string{
+		"directory":       inst.Directory,
+		"address":         inst.Address,
+		"server-settings": inst.ServerSettings,
+	}
+	if inst.Port != 0 {
+		values["port"] = strconv.Itoa(inst.Port)
+	}
+	for name, value := range values {
+		if value == "" {
+			continue
+		}
+		f, ok := flags.GetFlag(name)
+		if !ok || f.IsSet() {
+			continue
+		}
+		if err := f.SetValue(value); err != nil {
+			return fmt.Errorf("instance %s: %s: %w", instanceName, name, err)
+		}
+	}
+	return nil
+}
+
+// runInstancesList is the entrypoint for the "instances list" subcommand.
+func runInstancesList(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errors.New("too many arguments")
+	}
+	instances, err := loadInstances()
+	if err != nil {
+		return err
+	}
+
+	type status struct {
+		Name      string `json:"name"`
+		Directory string `json:"directory"`
+		Port      int    `json:"port,omitempty"`
+
+		// "running", "stopped", or "unknown", when the instance cannot
+		// be reached through RCON.
+		Status string `json:"status"`
+
+		// Null when the players cannot be listed.
+		Players *int `json:"players"`
+	}
+	names := make([]string, 0, len(instances))
+	for name := range instances {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	statuses := make([]status, len(names))
+	for i, name := range names {
+		inst := instances[name]
+		statuses[i] = status{Name: name, Directory: inst.Directory, Port: inst.Port}
+		statuses[i].Status, statuses[i].Players = instanceStatus(ctx, inst)
+	}
+
+	if jsonOutput() {
+		return writeJSON(statuses)
+	}
+	if len(statuses) == 0 {
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	if !noHeaders {
+		headers := []string{"NAME", "DIRECTORY", "PORT", "STATUS", "PLAYERS"}
+		fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	}
+	for _, s := range statuses {
+		port, players := "-", "-"
+		if s.Port != 0 {
+			port = strconv.Itoa(s.Port)
+		}
+		if s.Players != nil {
+			players = strconv.Itoa(*s.Players)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", s.Name, s.Directory, port, s.Status, players)
+	}
+	return tw.Flush()
+}
+
+// instanceStatus reports whether the server of inst is running, and how many
+// players are connected to it, by connecting to it through RCON.
+func instanceStatus(ctx context.Context, inst instance) (string, *int) {
+	ctx, cancel := context.WithTimeout(ctx, instanceTimeout)
+	defer cancel()
+
+	addr, password, err := rconSettingsFor(inst.Directory, inst.Address, "")
+	if err != nil {
+		return "unknown", nil
+	}
+	conn, err := server.DialRCON(ctx, addr, password)
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return "stopped", nil
+	} else if err != nil {
+		return "unknown", nil
+	}
+	defer conn.Close()
+
+	players, err := conn.Players(ctx)
+	if err != nil {
+		return "running", nil
+	}
+	n := len(players)
+	return "running", &n
+}
@@ -0,0 +1,181 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/nesv/factorio-tools/mods"
+	"github.com/nesv/factorio-tools/server"
+)
+
+// Set by command-line flags.
+var (
+	listingName     string
+	listingPort     int
+	listingUsername string
+)
+
+// runListing is the entrypoint for the "listing" subcommand.
+func runListing(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errors.New("too many arguments")
+	}
+
+	settings, err := server.LoadSettings(installDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("load server settings: %w", err)
+	}
+	name := listingName
+	if name == "" {
+		name = settings.Name
+	}
+	if name == "" {
+		return errors.New("the game has no name; give --name, or set name in server-settings.json")
+	}
+
+	creds, err := listingCredentials(ctx, settings)
+	if err != nil {
+		return err
+	}
+
+	var client server.ListingClient
+	games, err := client.Games(ctx, creds.Username, creds.Token)
+	if err != nil {
+		return fmt.Errorf("list games: %w", err)
+	}
+
+	var found []server.Game
+	for _, g := range games {
+		if g.Name != name || !listingPortMatches(g.HostAddress) {
+			continue
+		}
+		// The list does not include each game's mods; the details do.
+		details, err := client.GameDetails(ctx, g.ID)
+		if errors.Is(err, server.ErrGameNotFound) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("get game details: %w", err)
+		}
+		found = append(found, *details)
+	}
+	if len(found) == 0 {
+		msg := fmt.Sprintf("%q is not listed", name)
+		if !settings.Visibility.Public && listingName == "" {
+			msg += "; visibility.public is not set in server-settings.json"
+		}
+		return errors.New(msg)
+	}
+
+	if jsonOutput() {
+		return writeJSON(found)
+	}
+	for i, g := range found {
+		if i > 0 {
+			fmt.Println()
+		}
+		if err := printGame(g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listingPortMatches reports whether addr, a game's host address, is on the
+// port given with --port, when one was.
+func listingPortMatches(addr string) bool {
+	if listingPort == 0 {
+		return true
+	}
+	_, port, err := net.SplitHostPort(addr)
+	return err == nil && port == strconv.Itoa(listingPort)
+}
+
+// listingCredentials returns the factorio.com credentials to list games with:
+// the ones the server publishes the game with, from its server settings, or
+// the ones in the installation's player-data.json.
+// When the server settings have a password, instead of a token, it is
+// exchanged for a token.
+func listingCredentials(ctx context.Context, settings server.Settings) (mods.Credentials, error) {
+	creds := mods.Credentials{Username: settings.Username, Token: settings.Token}
+	if creds.Token == "" {
+		c, err := mods.LoadCredentials(filepath.Join(installDir, "player-data.json"))
+		if err == nil {
+			creds = c
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return mods.Credentials{}, fmt.Errorf("load player-data.json: %w", err)
+		}
+	}
+	if listingUsername != "" {
+		creds.Username = listingUsername
+	}
+	if creds.Token == "" && settings.Password != "" && creds.Username != "" {
+		c, err := mods.Login(ctx, creds.Username, settings.Password, "")
+		if err != nil {
+			return mods.Credentials{}, fmt.Errorf("login: %w", err)
+		}
+		creds = c
+	}
+	if creds.Username == "" || creds.Token == "" {
+		return mods.Credentials{}, fmt.Errorf("%w: set username, and token or password, in server-settings.json", mods.ErrAuthRequired)
+	}
+	return creds, nil
+}
+
+// printGame prints what the matchmaking server reports about g.
+func printGame(g server.Game) error {
+	maxPlayers := "unlimited"
+	if g.MaxPlayers > 0 {
+		maxPlayers = strconv.Itoa(g.MaxPlayers)
+	}
+	players := fmt.Sprintf("%d/%s", len(g.Players), maxPlayers)
+	if len(g.Players) > 0 {
+		players += ": " + strings.Join(g.Players, ", ")
+	}
+	v := g.ApplicationVersion
+	version := fmt.Sprintf("%s (build %d, %s, %s)", v.GameVersion, v.BuildVersion, v.BuildMode, v.Platform)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintf(tw, "Name:\t%s\n", g.Name)
+	fmt.Fprintf(tw, "Game ID:\t%d\n", g.ID)
+	fmt.Fprintf(tw, "Address:\t%s\n", g.HostAddress)
+	fmt.Fprintf(tw, "Version:\t%s\n", version)
+	fmt.Fprintf(tw, "Players:\t%s\n", players)
+	fmt.Fprintf(tw, "Password:\t%t\n", g.HasPassword)
+	fmt.Fprintf(tw, "Tags:\t%s\n", strings.Join(g.Tags, ", "))
+	fmt.Fprintf(tw, "Played:\t%s\n", time.Duration(g.GameTimeElapsed)*time.Minute)
+	if t := g.LastHeartbeatTime(); !t.IsZero() {
+		fmt.Fprintf(tw, "Heartbeat:\t%s\n", humanize.Time(t))
+	}
+	fmt.Fprintf(tw, "Mods:\t%d\n", g.ModCount)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(g.Mods) == 0 {
+		return nil
+	}
+	fmt.Println()
+	tw = tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	if !noHeaders {
+		fmt.Fprintln(tw, "MOD\tVERSION")
+	}
+	for _, m := range g.Mods {
+		fmt.Fprintf(tw, "%s\t%s\n", m.Name, m.Version)
+	}
+	return tw.Flush()
+}
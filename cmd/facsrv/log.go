@@ -0,0 +1,43 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"log/slog"
+	"os"
+)
+
+// Log formats accepted by --log-format.
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// Set by command-line flags.
+var (
+	verbose   bool
+	quiet     bool
+	logFormat string
+)
+
+// setupLogging installs the default [slog.Logger], writing to STDERR at the
+// level selected by --verbose and --quiet, in the format selected by
+// --log-format.
+func setupLogging() {
+	level := slog.LevelInfo
+	switch {
+	case verbose:
+		level = slog.LevelDebug
+	case quiet:
+		level = slog.LevelError
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler = slog.NewTextHandler(os.Stderr, opts)
+	if logFormat == logFormatJSON {
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(h))
+}
@@ -0,0 +1,232 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nesv/factorio-tools/server/logs"
+)
+
+// Set by command-line flags.
+var (
+	logsFollow bool
+	logsOnly   string
+)
+
+// followInterval is how often "logs --follow" checks the log for new lines.
+const followInterval = 500 * time.Millisecond
+
+// logsGroups are the names, other than the kinds of events, accepted by
+// --only.
+var logsGroups = map[string][]logs.Kind{
+	"chat":   {logs.KindChat},
+	"joins":  {logs.KindJoin, logs.KindLeave},
+	"saves":  {logs.KindSaveStarted, logs.KindSaveFinished},
+	"errors": {logs.KindError, logs.KindDesync},
+}
+
+// runLogs is the entrypoint for the "logs" subcommand.
+func runLogs(ctx context.Context, args []string) error {
+	if len(args) > 1 {
+		return errors.New("too many arguments")
+	}
+	path := filepath.Join(installDir, "factorio-current.log")
+	if len(args) == 1 {
+		path = args[0]
+	}
+
+	only, err := parseLogsOnly(logsOnly)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("open log: %w", err)
+		}
+		if logsFollow {
+			fl := &follower{ctx: ctx, path: path, f: f}
+			defer fl.Close()
+			r = fl
+		} else {
+			defer f.Close()
+			r = f
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	sc := logs.NewScanner(r)
+	for sc.Scan() {
+		e := sc.Event()
+		if only != nil && !only[e.Kind] {
+			continue
+		}
+		if jsonOutput() {
+			// One event per line, so that events can be read as
+			// they are written.
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+			continue
+		}
+		printEvent(os.Stdout, e)
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("read log: %w", err)
+	}
+	return nil
+}
+
+// parseLogsOnly parses the value of --only: a comma-separated list of kinds
+// of events, or of the names in logsGroups.
+// It returns nil when every kind of event is selected.
+func parseLogsOnly(s string) (map[logs.Kind]bool, error) {
+	if s == "" {
+		return nil, nil
+	}
+	only := make(map[logs.Kind]bool)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if kinds, ok := logsGroups[name]; ok {
+			for _, k := range kinds {
+				only[k] = true
+			}
+			continue
+		}
+		if !slices.Contains(logs.Kinds(), logs.Kind(name)) {
+			names := make([]string, 0, len(logsGroups))
+			for g := range logsGroups {
+				names = append(names, g)
+			}
+			slices.Sort(names)
+			for _, k := range logs.Kinds() {
+				if !slices.Contains(names, string(k)) {
+					names = append(names, string(k))
+				}
+			}
+			return nil, fmt.Errorf("--only: unknown kind of event %q; use one of %s", name, strings.Join(names, ", "))
+		}
+		only[logs.Kind(name)] = true
+	}
+	return only, nil
+}
+
+// printEvent writes a line describing e to w.
+func printEvent(w io.Writer, e logs.Event) {
+	var msg string
+	switch e.Kind {
+	case logs.KindVersion:
+		msg = "Factorio " + e.Message + " started"
+	case logs.KindJoin:
+		msg = e.Player + " joined the game"
+	case logs.KindLeave:
+		msg = e.Player + " left the game"
+	case logs.KindChat:
+		msg = e.Player + ": " + e.Message
+	case logs.KindSaveStarted:
+		msg = "Saving " + e.Message
+	case logs.KindSaveFinished:
+		msg = "Saving finished"
+	case logs.KindError:
+		msg = "Error: " + e.Message
+	case logs.KindDesync:
+		msg = "Desync: " + e.Message
+	}
+
+	// Events read before the server's banner have no time.
+	t := "-"
+	if !e.Time.IsZero() {
+		t = e.Time.Format(time.DateTime)
+	}
+	fmt.Fprintf(w, "%-19s %s\n", t, msg)
+}
+
+// follower reads a log like "tail -F": at the end of the file, it waits for
+// more lines to be written, and it reopens the file when it is replaced, or
+// truncated, like the server does with "factorio-current.log" when it
+// restarts.
+// Reads return [io.EOF] once ctx is done.
+type follower struct {
+	ctx  context.Context
+	path string
+	f    *os.File
+	off  int64
+}
+
+func (fl *follower) Read(p []byte) (int, error) {
+	for {
+		n, err := fl.f.Read(p)
+		fl.off += int64(n)
+		if n > 0 {
+			return n, nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+
+		select {
+		case <-fl.ctx.Done():
+			return 0, io.EOF
+		case <-time.After(followInterval):
+		}
+		if err := fl.reopen(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// reopen opens the file at fl.path when it is not the one being read, and
+// starts over when it was truncated.
+func (fl *follower) reopen() error {
+	fi, err := os.Stat(fl.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil // The server is restarting; wait for the new log.
+	} else if err != nil {
+		return fmt.Errorf("stat log: %w", err)
+	}
+	cur, err := fl.f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat log: %w", err)
+	}
+
+	if !os.SameFile(fi, cur) {
+		f, err := os.Open(fl.path)
+		if err != nil {
+			return fmt.Errorf("open log: %w", err)
+		}
+		fl.f.Close()
+		fl.f, fl.off = f, 0
+		return nil
+	}
+	if fi.Size() < fl.off {
+		if _, err := fl.f.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("seek log: %w", err)
+		}
+		fl.off = 0
+	}
+	return nil
+}
+
+// Close closes the file being read.
+func (fl *follower) Close() error {
+	return fl.f.Close()
+}
@@ -0,0 +1,487 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package main provides the facsrv executable, for installing and managing
+// the Factorio headless server.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	ff "github.com/peterbourgon/ff/v4"
+	"github.com/peterbourgon/ff/v4/ffhelp"
+
+	"github.com/nesv/factorio-tools/httputil"
+	"github.com/nesv/factorio-tools/server"
+)
+
+func main() {
+	envConfig := httputil.ConfigFromEnv()
+	rootFlags := ff.NewFlagSet("facsrv")
+	rootFlags.StringVar(&httpConfig.Proxy, 0, "proxy", envConfig.Proxy, "Send requests through this HTTP, HTTPS, or SOCKS5 proxy (env: "+httputil.ProxyEnv+")")
+	rootFlags.StringVar(&httpConfig.CAFile, 0, "ca-file", envConfig.CAFile, "Also trust the CA certificates in this PEM file (env: "+httputil.CAFileEnv+")")
+	rootFlags.IntVar(&httpConfig.Retries, 0, "retries", httputil.DefaultRetries, "Retry failed requests and downloads this many times (0 disables)")
+	rootFlags.BoolVar(&verbose, 'v', "verbose", "Log debugging information, such as HTTP requests")
+	rootFlags.BoolVar(&quiet, 'q', "quiet", "Only log errors, and hide progress bars")
+	rootFlags.StringEnumVar(&logFormat, 0, "log-format", "Log format", logFormatText, logFormatJSON)
+	rootFlags.BoolVar(&noHeaders, 'H', "no-headers", "Disable headers on tabular output")
+	rootFlags.StringEnumVar(&outputFormat, 'o', "output", "Output format", outputTable, outputJSON)
+	rootFlags.StringVar(&notifyConfig, 0, "notify-config", defaultNotifyConfig(), "Send notifications to the targets in this notify.json")
+	rootFlags.StringVar(&instanceName, 'I', "instance", "", "Manage this instance from instances.json")
+
+	adminFlags := ff.NewFlagSet("admin").SetParent(rootFlags)
+	addRCONFlags(adminFlags)
+	adminAddCmd := &ff.Command{
+		Name:      "add",
+		Usage:     "facsrv admin add [FLAGS] PLAYER ...",
+		ShortHelp: "Make players admins",
+		Flags:     ff.NewFlagSet("add").SetParent(adminFlags),
+		Exec:      runAdminAdd,
+	}
+	adminListCmd := &ff.Command{
+		Name:      "list",
+		Usage:     "facsrv admin list [FLAGS]",
+		ShortHelp: "List the server's admins",
+		Flags:     ff.NewFlagSet("list").SetParent(adminFlags),
+		Exec:      runAdminList,
+	}
+	adminRemoveCmd := &ff.Command{
+		Name:      "remove",
+		Usage:     "facsrv admin remove [FLAGS] PLAYER ...",
+		ShortHelp: "Remove players from the server's admins",
+		Flags:     ff.NewFlagSet("remove").SetParent(adminFlags),
+		Exec:      runAdminRemove,
+	}
+	adminCmd := &ff.Command{
+		Name:      "admin",
+		Usage:     "facsrv admin [FLAGS] SUBCOMMAND ...",
+		ShortHelp: "Manage the server's admins",
+		Flags:     adminFlags,
+		Subcommands: []*ff.Command{
+			adminAddCmd,
+			adminListCmd,
+			adminRemoveCmd,
+		},
+	}
+
+	backupFlags := ff.NewFlagSet("backup").SetParent(rootFlags)
+	addDirFlag(backupFlags)
+	backupFlags.StringVar(&backupDir, 0, "backup-dir", "", "Write the backup to this directory (default: the installation's backups directory)")
+	backupFlags.BoolVar(&backupSettings, 0, "settings", "Also back up the server settings, admin, ban, and white lists, mod list, and mod settings")
+	backupFlags.IntVar(&backupRetention.KeepLast, 0, "keep-last", 0, "Keep this many of the newest backups of the same save, and remove the others")
+	backupFlags.IntVar(&backupRetention.KeepDaily, 0, "keep-daily", 0, "Keep the newest backup of each of this many days")
+	backupFlags.IntVar(&backupRetention.KeepWeekly, 0, "keep-weekly", 0, "Keep the newest backup of each of this many weeks")
+	backupCmd := &ff.Command{
+		Name:      "backup",
+		Usage:     "facsrv backup [FLAGS] [SAVE]",
+		ShortHelp: "Back up the server's saves",
+		Flags:     backupFlags,
+		Exec:      runBackup,
+	}
+
+	banFlags := ff.NewFlagSet("ban").SetParent(rootFlags)
+	addRCONFlags(banFlags)
+	banCmd := &ff.Command{
+		Name:      "ban",
+		Usage:     "facsrv ban [FLAGS] PLAYER [REASON ...]",
+		ShortHelp: "Ban a player from the server",
+		Flags:     banFlags,
+		Exec:      runBan,
+	}
+
+	bansFlags := ff.NewFlagSet("bans").SetParent(rootFlags)
+	addRCONFlags(bansFlags)
+	bansCmd := &ff.Command{
+		Name:      "bans",
+		Usage:     "facsrv bans [FLAGS]",
+		ShortHelp: "List the players banned from the server",
+		Flags:     bansFlags,
+		Exec:      runBans,
+	}
+
+	exporterFlags := ff.NewFlagSet("exporter").SetParent(rootFlags)
+	addRCONFlags(exporterFlags)
+	exporterFlags.StringVar(&exporterListen, 'l', "listen", ":9186", "Serve metrics on this address")
+	exporterFlags.StringVar(&exporterLog, 0, "log", "", "Read the server's start time and save durations from this log (default: factorio-current.log)")
+	exporterFlags.BoolVar(&exporterTicks, 0, "ticks", "Also report the game's tick, and updates per second, which disables achievements")
+	exporterCmd := &ff.Command{
+		Name:      "exporter",
+		Usage:     "facsrv exporter [FLAGS]",
+		ShortHelp: "Serve the server's metrics to Prometheus",
+		Flags:     exporterFlags,
+		Exec:      runExporter,
+	}
+
+	installFlags := ff.NewFlagSet("install").SetParent(rootFlags)
+	installFlags.StringVar(&installVersion, 0, "version", "stable", "Version to install: an exact version like 2.0.28, a series like 2.0.x, stable, or experimental")
+	installFlags.StringVar(&installDownloadDir, 0, "download-dir", "", "Download the server into this directory (default: $XDG_CACHE_HOME/facsrv)")
+	installCmd := &ff.Command{
+		Name:      "install",
+		Usage:     "facsrv install [FLAGS] DIR",
+		ShortHelp: "Install or upgrade the Factorio headless server",
+		Flags:     installFlags,
+		Exec:      runInstall,
+	}
+
+	instancesFlags := ff.NewFlagSet("instances").SetParent(rootFlags)
+	instancesListCmd := &ff.Command{
+		Name:      "list",
+		Usage:     "facsrv instances list [FLAGS]",
+		ShortHelp: "List the instances in instances.json, and whether each is running",
+		Flags:     ff.NewFlagSet("list").SetParent(instancesFlags),
+		Exec:      runInstancesList,
+	}
+	instancesCmd := &ff.Command{
+		Name:      "instances",
+		Usage:     "facsrv instances [FLAGS] SUBCOMMAND ...",
+		ShortHelp: "Manage the instances in instances.json",
+		Flags:     instancesFlags,
+		Subcommands: []*ff.Command{
+			instancesListCmd,
+		},
+	}
+
+	listingFlags := ff.NewFlagSet("listing").SetParent(rootFlags)
+	addDirFlag(listingFlags)
+	listingFlags.StringVar(&listingName, 0, "name", "", "Look for the game with this name (default: the name in server-settings.json)")
+	listingFlags.IntVar(&listingPort, 0, "port", 0, "Only match games on this UDP port")
+	listingFlags.StringVar(&listingUsername, 0, "username", "", "Use this factorio.com username, instead of the one in server-settings.json")
+	listingCmd := &ff.Command{
+		Name:      "listing",
+		Usage:     "facsrv listing [FLAGS]",
+		ShortHelp: "Show what the public games listing reports about the server",
+		Flags:     listingFlags,
+		Exec:      runListing,
+	}
+
+	logsFlags := ff.NewFlagSet("logs").SetParent(rootFlags)
+	addDirFlag(logsFlags)
+	logsFlags.BoolVar(&logsFollow, 'f', "follow", "Wait for new events, like tail -F")
+	logsFlags.StringVar(&logsOnly, 0, "only", "", "Only print these comma-separated kinds of events, like chat,joins,errors")
+	logsCmd := &ff.Command{
+		Name:      "logs",
+		Usage:     "facsrv logs [FLAGS] [FILE]",
+		ShortHelp: "Print the events in the server's log",
+		Flags:     logsFlags,
+		Exec:      runLogs,
+	}
+
+	mapFlags := ff.NewFlagSet("map").SetParent(rootFlags)
+	addDirFlag(mapFlags)
+	mapCreateFlags := ff.NewFlagSet("create").SetParent(mapFlags)
+	mapCreateFlags.StringVar(&mapPreset, 0, "preset", "", "Start from this map generation preset, like rich-resources or death-world")
+	mapCreateFlags.StringVar(&mapGenSettingsFile, 0, "map-gen-settings", "", "Generate the map with the settings in this map-gen-settings.json")
+	mapCreateFlags.StringVar(&mapSettingsFile, 0, "map-settings", "", "Use the settings in this map-settings.json")
+	mapCreateFlags.StringVar(&mapSeed, 0, "seed", "", "Map generation seed (default: random)")
+	mapCreateCmd := &ff.Command{
+		Name:      "create",
+		Usage:     "facsrv map create [FLAGS] NAME",
+		ShortHelp: "Generate a new map, and save it in the installation's saves directory",
+		Flags:     mapCreateFlags,
+		Exec:      runMapCreate,
+	}
+	mapCmd := &ff.Command{
+		Name:      "map",
+		Usage:     "facsrv map [FLAGS] SUBCOMMAND ...",
+		ShortHelp: "Manage maps",
+		Flags:     mapFlags,
+		Subcommands: []*ff.Command{
+			mapCreateCmd,
+		},
+	}
+
+	notifyFlags := ff.NewFlagSet("notify").SetParent(rootFlags)
+	notifyFlags.StringVar(&notifyNotification.Player, 0, "player", "", "The player who joined or left")
+	notifyFlags.StringVar(&notifyNotification.Mod, 0, "mod", "", "The mod that was updated")
+	notifyFlags.StringVar(&notifyNotification.From, 0, "from", "", "The version upgraded from")
+	notifyFlags.StringVar(&notifyNotification.To, 0, "to", "", "The version upgraded to")
+	notifyFlags.StringVar(&notifyNotification.Error, 0, "error", "", "The error the server crashed with")
+	notifyCmd := &ff.Command{
+		Name:      "notify",
+		Usage:     "facsrv notify [FLAGS] EVENT",
+		ShortHelp: "Send a notification to the targets in the notify config",
+		Flags:     notifyFlags,
+		Exec:      runNotify,
+	}
+
+	pingFlags := ff.NewFlagSet("ping").SetParent(rootFlags)
+	pingFlags.IntVar(&pingPort, 0, "port", server.DefaultGamePort, "The server's UDP port, when HOST does not include one")
+	pingFlags.DurationVar(&pingTimeout, 0, "timeout", 5*time.Second, "Wait this long for a reply")
+	pingCmd := &ff.Command{
+		Name:      "ping",
+		Usage:     "facsrv ping [FLAGS] [HOST[:PORT]]",
+		ShortHelp: "Check that the server is reachable on its game port, and measure its latency",
+		Flags:     pingFlags,
+		Exec:      runPing,
+	}
+
+	playersFlags := ff.NewFlagSet("players").SetParent(rootFlags)
+	addRCONFlags(playersFlags)
+	playersFlags.StringVar(&playersLog, 'l', "log", "", "Read join times from this console log, or - for standard input")
+	playersCmd := &ff.Command{
+		Name:      "players",
+		Usage:     "facsrv players [FLAGS]",
+		ShortHelp: "List the players connected to the server",
+		Flags:     playersFlags,
+		Exec:      runPlayers,
+	}
+
+	rconFlags := ff.NewFlagSet("rcon").SetParent(rootFlags)
+	addRCONFlags(rconFlags)
+	rconFlags.BoolVar(&rconInteractive, 'i', "interactive", "Read commands from a prompt, or one per line from standard input")
+	rconCmd := &ff.Command{
+		Name:      "rcon",
+		Usage:     "facsrv rcon [FLAGS] COMMAND ...",
+		ShortHelp: "Run a command on the server through RCON",
+		Flags:     rconFlags,
+		Exec:      runRCON,
+	}
+
+	restoreFlags := ff.NewFlagSet("restore").SetParent(rootFlags)
+	addDirFlag(restoreFlags)
+	restoreCmd := &ff.Command{
+		Name:      "restore",
+		Usage:     "facsrv restore [FLAGS] FILE",
+		ShortHelp: "Restore a backup made by backup or upgrade",
+		Flags:     restoreFlags,
+		Exec:      runRestore,
+	}
+
+	rollbackFlags := ff.NewFlagSet("rollback").SetParent(rootFlags)
+	rollbackCmd := &ff.Command{
+		Name:      "rollback",
+		Usage:     "facsrv rollback [FLAGS] DIR",
+		ShortHelp: "Switch back to the version in use before the last upgrade",
+		Flags:     rollbackFlags,
+		Exec:      runRollback,
+	}
+
+	savesFlags := ff.NewFlagSet("saves").SetParent(rootFlags)
+	addDirFlag(savesFlags)
+	savesInspectCmd := &ff.Command{
+		Name:      "inspect",
+		Usage:     "facsrv saves inspect [FLAGS] SAVE",
+		ShortHelp: "List the mods, and versions, a save was made with",
+		Flags:     ff.NewFlagSet("inspect").SetParent(savesFlags),
+		Exec:      runSavesInspect,
+	}
+	savesPruneFlags := ff.NewFlagSet("prune").SetParent(savesFlags)
+	savesPruneFlags.IntVar(&savesRetention.Keep, 0, "keep", 0, "Keep this many of the newest autosaves")
+	savesPruneFlags.DurationVar(&savesRetention.MaxAge, 0, "max-age", 0, "Remove autosaves older than this, like 72h")
+	savesPruneCmd := &ff.Command{
+		Name:      "prune",
+		Usage:     "facsrv saves prune [FLAGS]",
+		ShortHelp: "Remove old autosaves",
+		Flags:     savesPruneFlags,
+		Exec:      runSavesPrune,
+	}
+	savesCmd := &ff.Command{
+		Name:      "saves",
+		Usage:     "facsrv saves [FLAGS] SUBCOMMAND ...",
+		ShortHelp: "Manage the server's saves",
+		Flags:     savesFlags,
+		Subcommands: []*ff.Command{
+			savesInspectCmd,
+			savesPruneCmd,
+		},
+	}
+
+	runFlags := ff.NewFlagSet("run").SetParent(rootFlags)
+	addRCONFlags(runFlags)
+	runFlags.StringVar(&runSchedule, 0, "schedule", defaultSchedulePath(), "Run the tasks in this schedule file")
+	runFlags.StringVar(&runSave, 0, "save", "", "Host this save (default: the latest save)")
+	runFlags.IntVar(&runPort, 0, "port", 0, "Host the game on this UDP port (default: 34197)")
+	runFlags.StringVar(&runServerSettings, 0, "server-settings", "", "Use this server settings file (default: data/server-settings.json)")
+	runFlags.BoolVar(&runUseWhitelist, 0, "use-server-whitelist", "Only let the players on the whitelist join")
+	runFlags.IntVar(&runAutosaveRetention.Keep, 0, "autosave-keep", 0, "Keep this many of the newest autosaves while the server runs")
+	runFlags.DurationVar(&runAutosaveRetention.MaxAge, 0, "autosave-max-age", 0, "Remove autosaves older than this while the server runs, like 72h")
+	runFlags.StringVar(&runPIDFile, 0, "pid-file", "", "Write the server's process ID to this file while it runs")
+	runCmd := &ff.Command{
+		Name:      "run",
+		Usage:     "facsrv run [FLAGS]",
+		ShortHelp: "Run the server in the foreground, with scheduled restarts, backups, and messages",
+		Flags:     runFlags,
+		Exec:      runRun,
+	}
+
+	serveFlags := ff.NewFlagSet("serve").SetParent(rootFlags)
+	addRCONFlags(serveFlags)
+	serveFlags.StringVar(&serveListen, 'l', "listen", "localhost:8080", "Serve the API on this address")
+	serveFlags.StringVar(&serveTokenFile, 0, "token-file", "", "Read the API token from this file (default: $"+apiTokenEnv+")")
+	serveFlags.BoolVar(&serveStart, 0, "start", "Start the server along with the API")
+	serveFlags.StringVar(&serveSave, 0, "save", "", "Host this save (default: the latest save)")
+	serveFlags.IntVar(&servePort, 0, "port", 0, "Host the game on this UDP port (default: 34197)")
+	serveFlags.StringVar(&serveServerSettings, 0, "server-settings", "", "Use this server settings file (default: data/server-settings.json)")
+	serveFlags.BoolVar(&serveUseWhitelist, 0, "use-server-whitelist", "Only let the players on the whitelist join")
+	serveFlags.IntVar(&serveAutosaveRetention.Keep, 0, "autosave-keep", 0, "Keep this many of the newest autosaves while the server runs")
+	serveFlags.DurationVar(&serveAutosaveRetention.MaxAge, 0, "autosave-max-age", 0, "Remove autosaves older than this while the server runs, like 72h")
+	serveFlags.StringVar(&servePIDFile, 0, "pid-file", "", "Write the server's process ID to this file while it runs")
+	serveCmd := &ff.Command{
+		Name:      "serve",
+		Usage:     "facsrv serve [FLAGS]",
+		ShortHelp: "Run the server, and an HTTP API for managing it",
+		Flags:     serveFlags,
+		Exec:      runServe,
+	}
+
+	unbanFlags := ff.NewFlagSet("unban").SetParent(rootFlags)
+	addRCONFlags(unbanFlags)
+	unbanCmd := &ff.Command{
+		Name:      "unban",
+		Usage:     "facsrv unban [FLAGS] PLAYER ...",
+		ShortHelp: "Lift the bans of players",
+		Flags:     unbanFlags,
+		Exec:      runUnban,
+	}
+
+	upgradeFlags := ff.NewFlagSet("upgrade").SetParent(rootFlags)
+	upgradeFlags.StringVar(&upgradeVersion, 0, "version", "stable", "Version to upgrade to: an exact version like 2.0.28, a series like 2.0.x, stable, or experimental")
+	upgradeFlags.StringVar(&upgradeDownloadDir, 0, "download-dir", "", "Download the server into this directory (default: $XDG_CACHE_HOME/facsrv)")
+	upgradeFlags.StringVar(&upgradeCheck, 0, "check", "", "After upgrading, run this shell command to restart the server and check that it started, and roll back if it fails")
+	upgradeCmd := &ff.Command{
+		Name:      "upgrade",
+		Usage:     "facsrv upgrade [FLAGS] DIR",
+		ShortHelp: "Install a new version of the server alongside the old one, and switch to it",
+		Flags:     upgradeFlags,
+		Exec:      runUpgrade,
+	}
+
+	whitelistFlags := ff.NewFlagSet("whitelist").SetParent(rootFlags)
+	addRCONFlags(whitelistFlags)
+	whitelistFlags.BoolVar(&whitelistLive, 0, "live", "Also change the running server's whitelist through RCON")
+	whitelistListCmd := &ff.Command{
+		Name:      "list",
+		Usage:     "facsrv whitelist list [FLAGS]",
+		ShortHelp: "List the players on the whitelist",
+		Flags:     ff.NewFlagSet("list").SetParent(whitelistFlags),
+		Exec:      runWhitelistList,
+	}
+	whitelistAddCmd := &ff.Command{
+		Name:      "add",
+		Usage:     "facsrv whitelist add [FLAGS] PLAYER ...",
+		ShortHelp: "Add players to the whitelist",
+		Flags:     ff.NewFlagSet("add").SetParent(whitelistFlags),
+		Exec:      runWhitelistAdd,
+	}
+	whitelistRemoveCmd := &ff.Command{
+		Name:      "remove",
+		Usage:     "facsrv whitelist remove [FLAGS] PLAYER ...",
+		ShortHelp: "Remove players from the whitelist",
+		Flags:     ff.NewFlagSet("remove").SetParent(whitelistFlags),
+		Exec:      runWhitelistRemove,
+	}
+	whitelistEnableCmd := &ff.Command{
+		Name:      "enable",
+		Usage:     "facsrv whitelist enable [FLAGS]",
+		ShortHelp: "Turn on the running server's whitelist",
+		Flags:     ff.NewFlagSet("enable").SetParent(whitelistFlags),
+		Exec:      runWhitelistEnable,
+	}
+	whitelistDisableCmd := &ff.Command{
+		Name:      "disable",
+		Usage:     "facsrv whitelist disable [FLAGS]",
+		ShortHelp: "Turn off the running server's whitelist",
+		Flags:     ff.NewFlagSet("disable").SetParent(whitelistFlags),
+		Exec:      runWhitelistDisable,
+	}
+	whitelistCmd := &ff.Command{
+		Name:      "whitelist",
+		Usage:     "facsrv whitelist [FLAGS] SUBCOMMAND ...",
+		ShortHelp: "Manage the players allowed to join the server",
+		Flags:     whitelistFlags,
+		Subcommands: []*ff.Command{
+			whitelistAddCmd,
+			whitelistDisableCmd,
+			whitelistEnableCmd,
+			whitelistListCmd,
+			whitelistRemoveCmd,
+		},
+	}
+
+	root := &ff.Command{
+		Name:      "facsrv",
+		Usage:     "facsrv SUBCOMMAND ...",
+		ShortHelp: "Install and manage the Factorio headless server",
+		Flags:     rootFlags,
+		Subcommands: []*ff.Command{
+			adminCmd,
+			backupCmd,
+			banCmd,
+			bansCmd,
+			exporterCmd,
+			installCmd,
+			instancesCmd,
+			listingCmd,
+			logsCmd,
+			mapCmd,
+			notifyCmd,
+			pingCmd,
+			playersCmd,
+			rconCmd,
+			restoreCmd,
+			rollbackCmd,
+			runCmd,
+			savesCmd,
+			serveCmd,
+			unbanCmd,
+			upgradeCmd,
+			whitelistCmd,
+		},
+	}
+	err := root.Parse(os.Args[1:])
+	setupLogging()
+	if err == nil && verbose && quiet {
+		err = errors.New("--verbose and --quiet are mutually exclusive")
+	}
+	usageErr := err != nil
+	if err == nil {
+		if cmd := root.GetSelected(); cmd != nil {
+			err = applyInstance(cmd.Flags)
+		}
+	}
+	if err == nil {
+		err = httputil.Configure(httpConfig)
+	}
+	if err == nil {
+		err = root.Run(context.Background())
+	}
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) || errors.Is(err, ff.ErrNoExec) {
+			fmt.Fprintln(os.Stderr, ffhelp.Command(root))
+			return
+		}
+
+		code := 1
+		if usageErr {
+			fmt.Fprintln(os.Stderr, ffhelp.Command(root))
+			code = 2
+		}
+		if logFormat == logFormatJSON {
+			slog.Error("command failed", "err", err, "exit_code", code)
+		} else {
+			fmt.Fprintln(os.Stderr, "error: ", err)
+		}
+		os.Exit(code)
+	}
+}
+
+// Set by command-line flags.
+var (
+	httpConfig httputil.Config
+	installDir string
+)
+
+// addDirFlag adds the --directory flag, for the installation to manage, to
+// fs.
+// The installation's server-settings.json is also where the RCON port and
+// password are read from.
+func addDirFlag(fs *ff.FlagSet) {
+	fs.StringVar(&installDir, 'D', "directory", "/opt/factorio", "The server's installation directory")
+}
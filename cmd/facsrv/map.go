@@ -0,0 +1,75 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/nesv/factorio-tools/server"
+)
+
+// Set by command-line flags.
+var (
+	mapPreset          string
+	mapGenSettingsFile string
+	mapSettingsFile    string
+	mapSeed            string
+)
+
+// runMapCreate is the entrypoint for the "map create" subcommand.
+func runMapCreate(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one save name is required")
+	}
+
+	opts := server.CreateMapOptions{Preset: mapPreset}
+	if !quiet {
+		opts.Output = os.Stderr
+	}
+	if mapGenSettingsFile != "" {
+		f, err := os.Open(mapGenSettingsFile)
+		if err != nil {
+			return fmt.Errorf("open map gen settings: %w", err)
+		}
+		defer f.Close()
+		s, err := server.ReadMapGenSettings(f)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", mapGenSettingsFile, err)
+		}
+		opts.MapGenSettings = &s
+	}
+	if mapSettingsFile != "" {
+		f, err := os.Open(mapSettingsFile)
+		if err != nil {
+			return fmt.Errorf("open map settings: %w", err)
+		}
+		defer f.Close()
+		s, err := server.ReadMapSettings(f)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", mapSettingsFile, err)
+		}
+		opts.MapSettings = &s
+	}
+	if mapSeed != "" {
+		n, err := strconv.ParseUint(mapSeed, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid seed %q: must be between 0 and 4294967295", mapSeed)
+		}
+		seed := uint32(n)
+		opts.Seed = &seed
+	}
+
+	inst := &server.Installation{Dir: installDir}
+	save, err := inst.CreateMap(ctx, args[0], opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created %s\n", save)
+	return nil
+}
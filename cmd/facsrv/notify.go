@@ -0,0 +1,107 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/nesv/factorio-tools/notify"
+	"github.com/nesv/factorio-tools/server/logs"
+)
+
+// Set by command-line flags.
+var (
+	notifyConfig       string
+	notifyNotification notify.Notification
+)
+
+// runNotify is the entrypoint for the "notify" subcommand.
+func runNotify(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one event is required")
+	}
+	notifyNotification.Event = notify.Event(args[0])
+	if !slices.Contains(notify.Events, notifyNotification.Event) {
+		return fmt.Errorf("unknown event %q", args[0])
+	}
+
+	n, err := loadNotifier()
+	if err != nil {
+		return err
+	}
+	if n == nil {
+		return fmt.Errorf("no notify config at %s", notifyConfig)
+	}
+	return n.Notify(ctx, notifyNotification)
+}
+
+// loadNotifier loads the notify config given with --notify-config.
+// It returns a nil Notifier, which posts nothing, when there is no config.
+func loadNotifier() (*notify.Notifier, error) {
+	if notifyConfig == "" {
+		return nil, nil
+	}
+	return notify.Load(notifyConfig)
+}
+
+// defaultNotifyConfig returns the default value of --notify-config.
+func defaultNotifyConfig() string {
+	path, err := notify.DefaultConfigPath()
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// sendNotification posts n, logging the error when it cannot be posted, since
+// failing to notify should not fail the command that sends it.
+func sendNotification(ctx context.Context, notifier *notify.Notifier, n notify.Notification) {
+	if err := notifier.Notify(ctx, n); err != nil {
+		slog.WarnContext(ctx, "cannot send notification", "event", n.Event, "err", err)
+	}
+}
+
+// joinNotifier is an [io.Writer] for the server's output, which posts
+// notifications when players join or leave.
+type joinNotifier struct {
+	ctx      context.Context
+	notifier *notify.Notifier
+
+	mu  sync.Mutex
+	buf []byte // The last, incomplete, line.
+}
+
+func (w *joinNotifier) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(bytes.TrimRight(w.buf[:i], "\r"))
+		w.buf = w.buf[i+1:]
+
+		e, ok := logs.Parse(line)
+		if !ok {
+			continue
+		}
+		switch e.Kind {
+		case logs.KindJoin:
+			go sendNotification(w.ctx, w.notifier, notify.Notification{Event: notify.PlayerJoin, Time: e.Time, Player: e.Player})
+		case logs.KindLeave:
+			go sendNotification(w.ctx, w.notifier, notify.Notification{Event: notify.PlayerLeave, Time: e.Time, Player: e.Player})
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,34 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// Values accepted by the --output flag.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// Set by command-line flags.
+var (
+	outputFormat string
+	noHeaders    bool
+)
+
+// jsonOutput reports whether the user asked for JSON output.
+func jsonOutput() bool {
+	return outputFormat == outputJSON
+}
+
+// writeJSON writes v to STDOUT as indented JSON.
+func writeJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
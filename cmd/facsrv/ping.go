@@ -0,0 +1,66 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/nesv/factorio-tools/server"
+)
+
+// Set by command-line flags.
+var (
+	pingPort    int
+	pingTimeout time.Duration
+)
+
+// runPing is the entrypoint for the "ping" subcommand.
+func runPing(ctx context.Context, args []string) error {
+	if len(args) > 1 {
+		return errors.New("too many arguments")
+	}
+	if pingTimeout <= 0 {
+		return errors.New("--timeout must be greater than zero")
+	}
+	addr := "localhost"
+	if len(args) == 1 {
+		addr = args[0]
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, strconv.Itoa(pingPort))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	result, err := server.Ping(ctx, addr)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput() {
+		type reply struct {
+			Address string  `json:"address"`
+			RTT     float64 `json:"rtt_ms"`
+			Version string  `json:"version,omitempty"`
+		}
+		out := reply{Address: addr, RTT: float64(result.RTT) / float64(time.Millisecond)}
+		if !result.Version.IsZero() {
+			out.Version = result.Version.String()
+		}
+		return writeJSON(out)
+	}
+
+	msg := fmt.Sprintf("Reply from %s in %s", addr, result.RTT.Round(time.Microsecond))
+	if !result.Version.IsZero() {
+		msg += ", Factorio " + result.Version.String()
+	}
+	fmt.Println(msg)
+	return nil
+}
@@ -0,0 +1,96 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/nesv/factorio-tools/server"
+)
+
+// Set by command-line flags.
+var playersLog string
+
+// runPlayers is the entrypoint for the "players" subcommand.
+func runPlayers(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errors.New("too many arguments")
+	}
+
+	conn, err := dialRCON(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	players, err := conn.Players(ctx)
+	if err != nil {
+		return err
+	}
+	if playersLog != "" {
+		joined, err := readJoinTimes(playersLog)
+		if err != nil {
+			return err
+		}
+		server.SetJoinTimes(players, joined)
+	}
+
+	if jsonOutput() {
+		type player struct {
+			Name     string     `json:"name"`
+			Admin    bool       `json:"admin"`
+			JoinedAt *time.Time `json:"joined_at,omitempty"`
+		}
+		out := make([]player, len(players))
+		for i, p := range players {
+			out[i] = player{Name: p.Name, Admin: p.Admin}
+			if !p.JoinedAt.IsZero() {
+				out[i].JoinedAt = &p.JoinedAt
+			}
+		}
+		return writeJSON(out)
+	}
+
+	if len(players) == 0 {
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	if !noHeaders {
+		headers := []string{"NAME", "ADMIN", "JOINED"}
+		fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	}
+	for _, p := range players {
+		joined := "-"
+		if !p.JoinedAt.IsZero() {
+			joined = humanize.Time(p.JoinedAt)
+		}
+		fmt.Fprintf(tw, "%s\t%t\t%s\n", p.Name, p.Admin, joined)
+	}
+	return tw.Flush()
+}
+
+// readJoinTimes reads the join times of players from the console log at
+// path, or from STDIN when path is "-".
+func readJoinTimes(path string) (map[string]time.Time, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open console log: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	return server.JoinTimes(r)
+}
@@ -0,0 +1,196 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	ff "github.com/peterbourgon/ff/v4"
+	"golang.org/x/term"
+
+	"github.com/nesv/factorio-tools/server"
+)
+
+// Set by command-line flags.
+var (
+	rconAddress     string
+	rconPassword    string
+	rconInteractive bool
+)
+
+// addRCONFlags adds the flags for connecting to the server's RCON interface
+// to fs.
+func addRCONFlags(fs *ff.FlagSet) {
+	addDirFlag(fs)
+	fs.StringVar(&rconAddress, 'a', "address", "", "Connect to this HOST[:PORT] (default: localhost, on rcon_port or 27015)")
+	fs.StringVar(&rconPassword, 0, "password", "", "RCON password (default: rcon_password)")
+}
+
+// runRCON is the entrypoint for the "rcon" subcommand.
+func runRCON(ctx context.Context, args []string) error {
+	switch {
+	case rconInteractive && len(args) > 0:
+		return errors.New("commands cannot be given with --interactive")
+	case !rconInteractive && len(args) == 0:
+		return errors.New("a command is required, unless --interactive is given")
+	}
+
+	conn, err := dialRCON(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if !rconInteractive {
+		out, err := conn.Exec(ctx, strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+		printOutput(os.Stdout, out)
+		return nil
+	}
+
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		return rconConsole(ctx, conn, fd)
+	}
+
+	// Run one command per line, when commands are piped in.
+	sc := bufio.NewScanner(os.Stdin)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		out, err := conn.Exec(ctx, line)
+		if err != nil {
+			return err
+		}
+		printOutput(os.Stdout, out)
+	}
+	return sc.Err()
+}
+
+// dialRCON connects to the server's RCON interface, with the address and
+// password returned by rconSettings.
+func dialRCON(ctx context.Context) (*server.RCON, error) {
+	addr, password, err := rconSettings()
+	if err != nil {
+		return nil, err
+	}
+	return server.DialRCON(ctx, addr, password)
+}
+
+// errNoRCONPassword is returned by rconSettings when no password was given,
+// or found in the server settings.
+var errNoRCONPassword = errors.New("an rcon password is required; give --password, or set rcon_password in server-settings.json")
+
+// dialRunningRCON is like dialRCON, but returns a nil connection, without an
+// error, when there is no RCON password, or nothing is listening on the RCON
+// port, which means changes cannot be made to a running server.
+func dialRunningRCON(ctx context.Context) (*server.RCON, error) {
+	conn, err := dialRCON(ctx)
+	if errors.Is(err, errNoRCONPassword) || errors.Is(err, syscall.ECONNREFUSED) {
+		slog.DebugContext(ctx, "server is not reachable through rcon", "err", err)
+		return nil, nil
+	}
+	return conn, err
+}
+
+// rconSettings returns the address and password to connect to.
+// Flags take precedence over the "rcon_port" and "rcon_password" settings in
+// the installation's server settings.
+func rconSettings() (addr, password string, err error) {
+	return rconSettingsFor(installDir, rconAddress, rconPassword)
+}
+
+// rconSettingsFor returns the address and password to connect to the server
+// installed in dir.
+// address and password, when they are not empty, take precedence over the
+// server settings, like the flags do for rconSettings.
+func rconSettingsFor(dir, address, password string) (string, string, error) {
+	port := server.DefaultRCONPort
+	var settingsPassword string
+	s, err := server.LoadSettings(dir)
+	if err == nil {
+		if s.RCONPort != 0 {
+			port = int(s.RCONPort)
+		}
+		settingsPassword = s.RCONPassword
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", "", err
+	}
+
+	addr := net.JoinHostPort("localhost", strconv.Itoa(port))
+	if address != "" {
+		addr = address
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, strconv.Itoa(port))
+		}
+	}
+	if password == "" {
+		password = settingsPassword
+	}
+	if password == "" {
+		return "", "", errNoRCONPassword
+	}
+	return addr, password, nil
+}
+
+// rconConsole runs an interactive prompt on the terminal, with line editing,
+// and a history of the commands run, which can be recalled with the up and
+// down arrow keys.
+func rconConsole(ctx context.Context, conn *server.RCON, fd int) error {
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("make raw terminal: %w", err)
+	}
+	defer term.Restore(fd, state)
+
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "> ")
+	fmt.Fprintln(t, "Connected. Press Ctrl-D to exit.")
+	for {
+		line, err := t.ReadLine()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		out, err := conn.Exec(ctx, line)
+		if err != nil {
+			return err
+		}
+		printOutput(t, out)
+	}
+}
+
+// printOutput writes a command's output to w, ending it with a newline.
+func printOutput(w io.Writer, out string) {
+	if out == "" {
+		return
+	}
+	if !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	io.WriteString(w, out)
+}
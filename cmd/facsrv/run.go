@@ -0,0 +1,349 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/nesv/factorio-tools/schedule"
+	"github.com/nesv/factorio-tools/server"
+)
+
+// Set by command-line flags.
+var (
+	runSchedule       string
+	runSave           string
+	runPort           int
+	runServerSettings string
+	runUseWhitelist   bool
+	runPIDFile        string
+
+	runAutosaveRetention server.AutosaveRetention
+)
+
+// Actions of the tasks in schedule.json.
+const (
+	actionRestart = "restart"
+	actionBackup  = "backup"
+	actionMessage = "message"
+)
+
+// task is something "run" does on a schedule, read from schedule.json.
+type task struct {
+	// Schedule is a cron expression, like "0 4 * * *"; see
+	// [schedule.Parse].
+	Schedule string `json:"schedule"`
+	Action   string `json:"action"`
+
+	// The message a "message" task posts to the game's chat.
+	Message string `json:"message,omitempty"`
+
+	// How long before a "restart" task the players are warned, like
+	// ["10m", "1m"].
+	Warnings []string `json:"warnings,omitempty"`
+
+	// The save a "backup" task backs up, instead of every save, and the
+	// number of backups of it to keep; 0 keeps every backup.
+	Save string `json:"save,omitempty"`
+	Keep int    `json:"keep,omitempty"`
+
+	schedule schedule.Schedule
+	warnings []time.Duration // Longest first.
+}
+
+// defaultSchedulePath returns the default value of --schedule.
+func defaultSchedulePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "facsrv", "schedule.json")
+}
+
+// loadSchedule reads the tasks in the schedule file at path, like:
+//
+//	{"tasks": [{"schedule": "@hourly", "action": "backup", "keep": 24}]}
+//
+// A missing file is not an error; there are no tasks.
+func loadSchedule(path string) ([]task, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read schedule: %w", err)
+	}
+
+	var file struct {
+		Tasks []task `json:"tasks"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	for i := range file.Tasks {
+		if err := file.Tasks[i].check(); err != nil {
+			return nil, fmt.Errorf("%s: task %d: %w", path, i+1, err)
+		}
+	}
+	return file.Tasks, nil
+}
+
+// check checks t, and parses its schedule, and warnings.
+func (t *task) check() error {
+	var err error
+	if t.schedule, err = schedule.Parse(t.Schedule); err != nil {
+		return err
+	}
+	switch t.Action {
+	case actionRestart, actionBackup:
+	case actionMessage:
+		if t.Message == "" {
+			return errors.New("message is required")
+		}
+	default:
+		return fmt.Errorf("unknown action %q", t.Action)
+	}
+	if len(t.Warnings) > 0 && t.Action != actionRestart {
+		return errors.New("only restart tasks have warnings")
+	}
+	for _, w := range t.Warnings {
+		d, err := time.ParseDuration(w)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid warning %q", w)
+		}
+		t.warnings = append(t.warnings, d)
+	}
+	slices.SortFunc(t.warnings, func(a, b time.Duration) int { return cmp.Compare(b, a) })
+	return nil
+}
+
+// runRun is the entrypoint for the "run" subcommand.
+func runRun(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errors.New("too many arguments")
+	}
+	if runAutosaveRetention.Keep < 0 || runAutosaveRetention.MaxAge < 0 {
+		return errors.New("--autosave-keep and --autosave-max-age cannot be negative")
+	}
+	tasks, err := loadSchedule(runSchedule)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	r := &runner{
+		inst: &server.Installation{Dir: installDir},
+		opts: server.StartOptions{
+			Save:              runSave,
+			Port:              runPort,
+			ServerSettings:    runServerSettings,
+			UseWhitelist:      runUseWhitelist,
+			Stdout:            os.Stdout,
+			Stderr:            os.Stderr,
+			AutosaveRetention: runAutosaveRetention,
+			PIDFile:           runPIDFile,
+		},
+		exited:   make(chan error, 1),
+		restarts: make(chan chan error),
+	}
+	if err := r.start(ctx); err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		go r.schedule(ctx, t)
+	}
+	return r.loop(ctx)
+}
+
+// runner runs the server in the foreground, and its scheduled tasks.
+type runner struct {
+	inst *server.Installation
+	opts server.StartOptions
+
+	// exited receives the error the server exited with.
+	exited chan error
+
+	// restarts receives requests from restart tasks to restart the
+	// server, which loop answers on the channel sent.
+	restarts chan chan error
+}
+
+// start starts the server, until ctx is done.
+func (r *runner) start(ctx context.Context) error {
+	if err := r.inst.Start(ctx, r.opts); err != nil {
+		return err
+	}
+	go func() { r.exited <- r.inst.Wait() }()
+	return nil
+}
+
+// loop waits for the server to exit, and restarts it when asked to.
+// It returns once the server exits, other than when it is restarted, or
+// once it has been stopped because ctx is done.
+func (r *runner) loop(ctx context.Context) error {
+	for {
+		select {
+		case err := <-r.exited:
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("server exited: %w", err)
+			}
+			return nil
+
+		case reply := <-r.restarts:
+			err := r.restart(ctx)
+			reply <- err
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// restart stops the server, which saves the map, and starts it again.
+func (r *runner) restart(ctx context.Context) error {
+	stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), stopTimeout)
+	defer cancel()
+	if err := r.inst.Stop(stopCtx); err != nil && !errors.Is(err, server.ErrNotRunning) {
+		slog.WarnContext(ctx, "stop server", "err", err)
+	}
+	if err := <-r.exited; err != nil {
+		slog.DebugContext(ctx, "server exited", "err", err)
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err := r.start(ctx); err != nil {
+		return fmt.Errorf("restart server: %w", err)
+	}
+	return nil
+}
+
+// schedule runs t each time it is scheduled, until ctx is done.
+func (r *runner) schedule(ctx context.Context, t task) {
+	for {
+		next := t.schedule.Next(time.Now())
+		if next.IsZero() {
+			slog.WarnContext(ctx, "task never runs", "schedule", t.Schedule)
+			return
+		}
+		for _, w := range t.warnings {
+			at := next.Add(-w)
+			if time.Now().After(at) {
+				continue
+			}
+			if !sleepUntil(ctx, at) {
+				return
+			}
+			if err := say(ctx, restartWarning(w)); err != nil {
+				slog.WarnContext(ctx, "cannot warn players of restart", "err", err)
+			}
+		}
+		if !sleepUntil(ctx, next) {
+			return
+		}
+
+		slog.InfoContext(ctx, "running scheduled task", "action", t.Action, "schedule", t.Schedule)
+		if err := r.run(ctx, t); err != nil {
+			slog.ErrorContext(ctx, "scheduled task failed", "action", t.Action, "err", err)
+		}
+	}
+}
+
+// run runs t's action.
+func (r *runner) run(ctx context.Context, t task) error {
+	switch t.Action {
+	case actionRestart:
+		reply := make(chan error, 1)
+		select {
+		case r.restarts <- reply:
+		case <-ctx.Done():
+			return nil
+		}
+		select {
+		case err := <-reply:
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+
+	case actionBackup:
+		result, err := server.Backup(installDir, server.BackupOptions{
+			Save:      t.Save,
+			Retention: server.Retention{KeepLast: t.Keep},
+		})
+		if err != nil {
+			return err
+		}
+		slog.InfoContext(ctx, "backed up", "path", result.Path)
+		return nil
+
+	case actionMessage:
+		return say(ctx, t.Message)
+	}
+	return fmt.Errorf("unknown action %q", t.Action)
+}
+
+// say posts message to the game's chat, through RCON.
+func say(ctx context.Context, message string) error {
+	conn, err := dialRCON(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Say(ctx, message)
+}
+
+// restartWarning returns the message warning the players that the server
+// restarts in d.
+func restartWarning(d time.Duration) string {
+	var in string
+	switch {
+	case d == time.Minute:
+		in = "1 minute"
+	case d%time.Minute == 0:
+		in = fmt.Sprintf("%d minutes", d/time.Minute)
+	case d == time.Second:
+		in = "1 second"
+	case d%time.Second == 0 && d < time.Minute:
+		in = fmt.Sprintf("%d seconds", d/time.Second)
+	default:
+		in = d.String()
+	}
+	return "The server restarts in " + in + "."
+}
+
+// sleepUntil waits until t, and reports whether it did, before ctx was done.
+func sleepUntil(ctx context.Context, t time.Time) bool {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,77 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nesv/factorio-tools/server"
+)
+
+// Set by command-line flags.
+var savesRetention server.AutosaveRetention
+
+// runSavesPrune is the entrypoint for the "saves prune" subcommand.
+func runSavesPrune(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errors.New("too many arguments")
+	}
+	switch {
+	case savesRetention.IsZero():
+		return errors.New("--keep or --max-age is required")
+	case savesRetention.Keep < 0 || savesRetention.MaxAge < 0:
+		return errors.New("--keep and --max-age cannot be negative")
+	}
+
+	removed, err := server.PruneAutosaves(installDir, savesRetention)
+	for _, path := range removed {
+		fmt.Printf("Removed %s\n", path)
+	}
+	return err
+}
+
+// runSavesInspect is the entrypoint for the "saves inspect" subcommand.
+func runSavesInspect(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one save is required")
+	}
+
+	info, err := server.InspectSave(savePath(args[0]))
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		return writeJSON(info)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	if !noHeaders {
+		headers := []string{"NAME", "VERSION"}
+		fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	}
+	for _, m := range info.Mods {
+		fmt.Fprintf(tw, "%s\t%s\n", m.Name, m.Version)
+	}
+	return tw.Flush()
+}
+
+// savePath returns the path to a save given on the command line: either a
+// path to a file, or the name of a save in the installation's saves
+// directory, with or without its ".zip" extension.
+func savePath(arg string) string {
+	if strings.ContainsRune(arg, os.PathSeparator) {
+		return arg
+	}
+	if _, err := os.Stat(arg); err == nil {
+		return arg
+	}
+	return filepath.Join(installDir, "saves", strings.TrimSuffix(arg, ".zip")+".zip")
+}
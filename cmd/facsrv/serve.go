@@ -0,0 +1,412 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/nesv/factorio-tools/mods"
+	"github.com/nesv/factorio-tools/notify"
+	"github.com/nesv/factorio-tools/server"
+)
+
+// apiTokenEnv is the environment variable "serve" reads the API token from,
+// when --token-file is not given.
+const apiTokenEnv = "FACSRV_API_TOKEN"
+
+// stopTimeout is how long the server is given to save the map and exit, when
+// it is stopped through the API, or restarted by "run", before it is killed.
+const stopTimeout = time.Minute
+
+// Set by command-line flags.
+var (
+	serveListen         string
+	serveTokenFile      string
+	serveStart          bool
+	serveSave           string
+	servePort           int
+	serveServerSettings string
+	serveUseWhitelist   bool
+	servePIDFile        string
+
+	serveAutosaveRetention server.AutosaveRetention
+)
+
+// runServe is the entrypoint for the "serve" subcommand.
+func runServe(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errors.New("too many arguments")
+	}
+	if serveAutosaveRetention.Keep < 0 || serveAutosaveRetention.MaxAge < 0 {
+		return errors.New("--autosave-keep and --autosave-max-age cannot be negative")
+	}
+	token, err := loadAPIToken()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	notifier, err := loadNotifier()
+	if err != nil {
+		return err
+	}
+
+	api := &api{
+		ctx:      ctx,
+		token:    token,
+		notifier: notifier,
+		inst:     &server.Installation{Dir: installDir},
+		startOpts: server.StartOptions{
+			Save:              serveSave,
+			Port:              servePort,
+			ServerSettings:    serveServerSettings,
+			UseWhitelist:      serveUseWhitelist,
+			Stdout:            os.Stdout,
+			Stderr:            os.Stderr,
+			AutosaveRetention: serveAutosaveRetention,
+			PIDFile:           servePIDFile,
+		},
+	}
+	if serveStart {
+		if err := api.startServer(ctx); err != nil {
+			return err
+		}
+	}
+
+	srv := &http.Server{
+		Addr:              serveListen,
+		Handler:           api.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+	slog.InfoContext(ctx, "serving api", "address", serveListen)
+
+	select {
+	case err = <-errc:
+		err = fmt.Errorf("serve api: %w", err)
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err = srv.Shutdown(shutdownCtx)
+	}
+
+	// The server was started with ctx, so it is asked to exit once ctx
+	// is done; wait for it to save the map.
+	stop()
+	if api.inst.Status().Running {
+		slog.InfoContext(ctx, "waiting for the server to exit")
+	}
+	api.wg.Wait()
+	return err
+}
+
+// loadAPIToken returns the token clients of the API authenticate with, read
+// from --token-file, or the environment.
+// The token is not taken as a flag, since other users on the host could read
+// it from the command line.
+func loadAPIToken() (string, error) {
+	token := os.Getenv(apiTokenEnv)
+	if serveTokenFile != "" {
+		data, err := os.ReadFile(serveTokenFile)
+		if err != nil {
+			return "", fmt.Errorf("read token: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token == "" {
+		return "", errors.New("an api token is required; give --token-file, or set " + apiTokenEnv)
+	}
+	return token, nil
+}
+
+// api serves the HTTP management API.
+type api struct {
+	// ctx is the context of the "serve" command, which the server is
+	// started with.
+	ctx context.Context
+
+	token     string
+	notifier  *notify.Notifier
+	inst      *server.Installation
+	startOpts server.StartOptions
+
+	// stopping is set while the server is being stopped through the API,
+	// so that it is not reported as having crashed.
+	mu       sync.Mutex
+	stopping bool
+
+	// wg waits for the goroutines watching the server to exit.
+	wg sync.WaitGroup
+}
+
+// startServer starts the server, and notifies that it started, and, later,
+// that it stopped, or crashed.
+func (a *api) startServer(ctx context.Context) error {
+	opts := a.startOpts
+	if a.notifier != nil {
+		opts.Stdout = io.MultiWriter(opts.Stdout, &joinNotifier{ctx: a.ctx, notifier: a.notifier})
+	}
+	if err := a.inst.Start(a.ctx, opts); err != nil {
+		return err
+	}
+	sendNotification(ctx, a.notifier, notify.Notification{Event: notify.ServerStart})
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		err := a.inst.Wait()
+
+		a.mu.Lock()
+		requested := a.stopping || a.ctx.Err() != nil
+		a.stopping = false
+		a.mu.Unlock()
+
+		n := notify.Notification{Event: notify.ServerStop}
+		if err != nil && !requested {
+			n = notify.Notification{Event: notify.ServerCrash, Error: err.Error()}
+			slog.ErrorContext(a.ctx, "server exited", "err", err)
+		}
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(a.ctx), 10*time.Second)
+		defer cancel()
+		sendNotification(ctx, a.notifier, n)
+	}()
+	return nil
+}
+
+// handler returns the API's routes, behind its authentication.
+func (a *api) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/status", a.status)
+	mux.HandleFunc("POST /api/server/start", a.start)
+	mux.HandleFunc("POST /api/server/stop", a.stop)
+	mux.HandleFunc("POST /api/server/restart", a.restart)
+	mux.HandleFunc("GET /api/server/output", a.output)
+	mux.HandleFunc("GET /api/mods", a.listMods)
+	mux.HandleFunc("POST /api/mods/{name}/enable", a.setModEnabled(true))
+	mux.HandleFunc("POST /api/mods/{name}/disable", a.setModEnabled(false))
+	mux.HandleFunc("POST /api/backups", a.backup)
+	return a.authenticate(mux)
+}
+
+// authenticate only lets requests with the API token, given as a bearer
+// token in their Authorization header, through to next.
+func (a *api) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeAPIError(w, http.StatusUnauthorized, errors.New("a valid api token is required"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// status serves the state of the server, and, when it can be reached
+// through RCON, the players connected to it.
+func (a *api) status(w http.ResponseWriter, r *http.Request) {
+	type status struct {
+		Running   bool       `json:"running"`
+		PID       int        `json:"pid,omitempty"`
+		StartedAt *time.Time `json:"started_at,omitempty"`
+		ExitError string     `json:"exit_error,omitempty"`
+
+		// Null when the players cannot be listed.
+		Players []string `json:"players"`
+	}
+
+	s := a.inst.Status()
+	out := status{Running: s.Running, PID: s.PID}
+	if !s.StartedAt.IsZero() {
+		out.StartedAt = &s.StartedAt
+	}
+	if s.ExitErr != nil {
+		out.ExitError = s.ExitErr.Error()
+	}
+	if s.Running {
+		out.Players = a.players(r.Context())
+	}
+	writeAPIJSON(w, http.StatusOK, out)
+}
+
+// players returns the names of the players connected to the server, or nil
+// when they cannot be listed.
+func (a *api) players(ctx context.Context) []string {
+	conn, err := dialRunningRCON(ctx)
+	if err != nil || conn == nil {
+		slog.DebugContext(ctx, "cannot list players", "err", err)
+		return nil
+	}
+	defer conn.Close()
+
+	players, err := conn.Players(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "cannot list players", "err", err)
+		return nil
+	}
+	names := make([]string, len(players))
+	for i, p := range players {
+		names[i] = p.Name
+	}
+	return names
+}
+
+func (a *api) start(w http.ResponseWriter, r *http.Request) {
+	if a.inst.Status().Running {
+		writeAPIError(w, http.StatusConflict, errors.New("server is already running"))
+		return
+	}
+	if err := a.startServer(r.Context()); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "started server")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// stopServer saves the map, and stops the server, with
+// [server.Installation.Stop].
+func (a *api) stopServer(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, stopTimeout)
+	defer cancel()
+	a.mu.Lock()
+	a.stopping = true
+	a.mu.Unlock()
+	err := a.inst.Stop(ctx)
+	if errors.Is(err, server.ErrNotRunning) {
+		a.mu.Lock()
+		a.stopping = false
+		a.mu.Unlock()
+	}
+	return err
+}
+
+// stop asks the server to save the map and exit, and responds once it has.
+// The server is stopped even when the client goes away first.
+func (a *api) stop(w http.ResponseWriter, r *http.Request) {
+	err := a.stopServer(context.WithoutCancel(r.Context()))
+	if errors.Is(err, server.ErrNotRunning) {
+		writeAPIError(w, http.StatusConflict, err)
+		return
+	} else if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "stopped server")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// restart stops the server, like stop, when it is running, and starts it
+// again, for example to load a changed mod list.
+func (a *api) restart(w http.ResponseWriter, r *http.Request) {
+	err := a.stopServer(context.WithoutCancel(r.Context()))
+	if err != nil && !errors.Is(err, server.ErrNotRunning) {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := a.startServer(r.Context()); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "restarted server")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// output serves the last lines of the server's output.
+func (a *api) output(w http.ResponseWriter, r *http.Request) {
+	lines := a.inst.Output()
+	if lines == nil {
+		lines = []string{}
+	}
+	writeAPIJSON(w, http.StatusOK, lines)
+}
+
+// listMods serves the installed mods, like "facmod export".
+func (a *api) listMods(w http.ResponseWriter, r *http.Request) {
+	m, err := mods.Export(installDir)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, m.Mods)
+}
+
+// setModEnabled returns a handler enabling, or disabling, the mod named in
+// the request's path.
+// Like with facmod, the change takes effect when the server is restarted.
+func (a *api) setModEnabled(enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		var err error
+		if enabled {
+			err = mods.EnableMods(installDir, name)
+		} else {
+			err = mods.DisableMods(installDir, name)
+		}
+		if errors.Is(err, mods.ErrModNotFound) {
+			writeAPIError(w, http.StatusNotFound, err)
+			return
+		} else if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err)
+			return
+		}
+		slog.InfoContext(r.Context(), "changed mod list", "mod", name, "enabled", enabled)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// backup backs up the server's saves, like "facsrv backup", to the default
+// backup directory.
+// The request's body, which is optional, selects the save, and whether the
+// server's settings are backed up too, like:
+//
+//	{"save": "mymap", "settings": true}
+func (a *api) backup(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Save     string `json:"save"`
+		Settings bool   `json:"settings"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("decode request: %w", err))
+		return
+	}
+
+	result, err := server.Backup(installDir, server.BackupOptions{Save: req.Save, Settings: req.Settings})
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "backed up", "path", result.Path)
+	writeAPIJSON(w, http.StatusCreated, map[string]string{"path": result.Path})
+}
+
+// writeAPIJSON writes v to w as JSON, with the status code.
+func writeAPIJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeAPIError writes err to w as a JSON object, like
+// {"error": "server is not running"}, with the status code.
+func writeAPIError(w http.ResponseWriter, code int, err error) {
+	writeAPIJSON(w, code, map[string]string{"error": err.Error()})
+}
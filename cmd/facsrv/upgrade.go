@@ -0,0 +1,100 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/nesv/factorio-tools/notify"
+	"github.com/nesv/factorio-tools/server"
+)
+
+// Set by command-line flags.
+var (
+	upgradeVersion     string
+	upgradeDownloadDir string
+	upgradeCheck       string
+)
+
+// runUpgrade is the entrypoint for the "upgrade" subcommand.
+func runUpgrade(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one directory is required")
+	}
+	dir := args[0]
+
+	opts := server.UpgradeOptions{
+		InstallOptions: server.InstallOptions{
+			Version:     upgradeVersion,
+			DownloadDir: upgradeDownloadDir,
+		},
+	}
+	if !quiet {
+		opts.Progress = os.Stderr
+	}
+	if upgradeCheck != "" {
+		opts.Check = func(ctx context.Context, installDir string) error {
+			cmd := exec.CommandContext(ctx, "sh", "-c", upgradeCheck)
+			cmd.Env = append(os.Environ(), "FACSRV_INSTALL_DIR="+installDir)
+			cmd.Stdout = os.Stderr
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil {
+				return fmt.Errorf("run %q: %w", upgradeCheck, err)
+			}
+			return nil
+		}
+	}
+
+	result, err := server.Upgrade(ctx, dir, opts)
+	if result.Backup != "" {
+		fmt.Printf("Backed up saves and server settings to %s\n", result.Backup)
+	}
+	if err != nil {
+		return err
+	}
+
+	if result.From == "" {
+		fmt.Printf("Installed Factorio %s in %s\n", result.To, filepath.Join(dir, "current"))
+	} else {
+		fmt.Printf("Upgraded Factorio %s -> %s\n", result.From, result.To)
+	}
+
+	notifier, err := loadNotifier()
+	if err != nil {
+		slog.WarnContext(ctx, "cannot send notification", "err", err)
+		return nil
+	}
+	sendNotification(ctx, notifier, notify.Notification{
+		Event: notify.Upgrade,
+		From:  result.From,
+		To:    result.To,
+	})
+	return nil
+}
+
+// runRollback is the entrypoint for the "rollback" subcommand.
+func runRollback(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("exactly one directory is required")
+	}
+
+	installDir, err := server.Rollback(args[0])
+	if err != nil {
+		return err
+	}
+
+	version, err := server.InstalledVersion(ctx, installDir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Rolled back to Factorio %s\n", version)
+	return nil
+}
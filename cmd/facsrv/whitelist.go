@@ -0,0 +1,122 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nesv/factorio-tools/server"
+)
+
+// Set by command-line flags.
+var whitelistLive bool
+
+// runWhitelistList is the entrypoint for the "whitelist list" subcommand.
+func runWhitelistList(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errors.New("too many arguments")
+	}
+
+	w, err := server.LoadWhitelist(installDir)
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		return writeJSON(w)
+	}
+	for _, name := range w {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+// runWhitelistAdd is the entrypoint for the "whitelist add" subcommand.
+func runWhitelistAdd(ctx context.Context, args []string) error {
+	return changeWhitelist(ctx, args, true)
+}
+
+// runWhitelistRemove is the entrypoint for the "whitelist remove"
+// subcommand.
+func runWhitelistRemove(ctx context.Context, args []string) error {
+	return changeWhitelist(ctx, args, false)
+}
+
+// changeWhitelist adds the players named in args to the whitelist, or
+// removes them from it, and does the same on the running server when --live
+// is given.
+func changeWhitelist(ctx context.Context, args []string, add bool) error {
+	if len(args) == 0 {
+		return errors.New("at least one player is required")
+	}
+	for _, name := range args {
+		if !server.ValidPlayerName(name) {
+			return fmt.Errorf("invalid player name %q", name)
+		}
+	}
+
+	w, err := server.LoadWhitelist(installDir)
+	if err != nil {
+		return err
+	}
+	for _, name := range args {
+		switch {
+		case add && !w.Add(name):
+			fmt.Printf("%s is already on the whitelist\n", name)
+		case !add && !w.Remove(name):
+			fmt.Printf("%s is not on the whitelist\n", name)
+		}
+	}
+	if err := w.Save(installDir); err != nil {
+		return err
+	}
+
+	if !whitelistLive {
+		return nil
+	}
+	conn, err := dialRCON(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for _, name := range args {
+		if add {
+			err = conn.WhitelistAdd(ctx, name)
+		} else {
+			err = conn.WhitelistRemove(ctx, name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runWhitelistEnable is the entrypoint for the "whitelist enable"
+// subcommand.
+func runWhitelistEnable(ctx context.Context, args []string) error {
+	return enableWhitelist(ctx, args, true)
+}
+
+// runWhitelistDisable is the entrypoint for the "whitelist disable"
+// subcommand.
+func runWhitelistDisable(ctx context.Context, args []string) error {
+	return enableWhitelist(ctx, args, false)
+}
+
+// enableWhitelist turns the running server's whitelist on or off.
+func enableWhitelist(ctx context.Context, args []string, enabled bool) error {
+	if len(args) > 0 {
+		return errors.New("too many arguments")
+	}
+
+	conn, err := dialRCON(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.SetWhitelistEnabled(ctx, enabled)
+}
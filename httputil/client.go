@@ -6,9 +6,16 @@ package httputil
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
 	"sync"
 	"time"
 )
@@ -19,8 +26,113 @@ const UserAgent = "factorio-tools/0.1"
 var (
 	clientOnce sync.Once
 	client     *http.Client
+
+	// The transport used by the client; set by Configure.
+	transport http.RoundTripper = http.DefaultTransport
+
+	// Limits the rate of requests sent by do; set by Configure.
+	// A nil limiter does not limit requests.
+	rateLimiter *limiter
+
+	// The number of times do and Retry try again after a temporary
+	// failure; set by Configure.
+	retries int
+)
+
+// Environment variables read by [ConfigFromEnv].
+const (
+	ProxyEnv  = "FACTORIO_TOOLS_PROXY"
+	CAFileEnv = "FACTORIO_TOOLS_CA_FILE"
 )
 
+// Config holds the network settings for the client returned by [Client].
+type Config struct {
+	// URL of the proxy to send all requests through.
+	// The "http", "https", and "socks5" schemes are supported.
+	// When empty, the proxy is read from the standard HTTP_PROXY,
+	// HTTPS_PROXY, and NO_PROXY environment variables.
+	Proxy string
+
+	// Path to a file containing one or more PEM-encoded CA certificates,
+	// which are trusted in addition to the system's certificate pool.
+	CAFile string
+
+	// RateLimit is the maximum number of requests sent per second, on
+	// average, and RateBurst the number of requests that may be sent at
+	// once before RateLimit applies.
+	// Requests are not limited when RateLimit is zero.
+	RateLimit float64
+	RateBurst int
+
+	// Retries is the number of times a GET request is retried after a
+	// temporary failure, like a dropped connection, or a "429 Too Many
+	// Requests" or "503 Service Unavailable" response.
+	// The delay between retries grows exponentially, unless the server
+	// sets the "Retry-After" header.
+	// Zero disables retries.
+	Retries int
+}
+
+// ConfigFromEnv returns a [Config] populated from the [ProxyEnv] and
+// [CAFileEnv] environment variables.
+func ConfigFromEnv() Config {
+	return Config{
+		Proxy:  os.Getenv(ProxyEnv),
+		CAFile: os.Getenv(CAFileEnv),
+	}
+}
+
+// Configure applies cfg to the client returned by [Client].
+// It must be called before the first call to Client; later calls have no
+// effect on a client that has already been created.
+func Configure(cfg Config) error {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.Proxy != "" {
+		u, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return fmt.Errorf("parse proxy url: %w", err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("unsupported proxy scheme: %q", u.Scheme)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return fmt.Errorf("read ca file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+		t.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	if cfg.RateLimit < 0 {
+		return fmt.Errorf("invalid rate limit: %v", cfg.RateLimit)
+	}
+	if cfg.RateBurst < 0 {
+		return fmt.Errorf("invalid rate burst: %d", cfg.RateBurst)
+	}
+
+	if cfg.Retries < 0 {
+		return fmt.Errorf("invalid number of retries: %d", cfg.Retries)
+	}
+
+	transport = t
+	retries = cfg.Retries
+	rateLimiter = newLimiter(cfg.RateLimit, cfg.RateBurst)
+	return nil
+}
+
 // Client returns a [net/http.Client] that will set the "user-agent" header to
 // [UserAgent] for all requests.
 // Similar to [net/http.DefaultClient], the returned client will stop after 10
@@ -30,7 +142,7 @@ var (
 func Client() *http.Client {
 	clientOnce.Do(func() {
 		client = &http.Client{
-			Transport: http.DefaultTransport,
+			Transport: transport,
 			CheckRedirect: func(req *http.Request, via []*http.Request) error {
 				if len(via) > 10 {
 					return errors.New("stopped after 10 redirects")
@@ -45,10 +157,124 @@ func Client() *http.Client {
 }
 
 func Get(ctx context.Context, urlStr string) (*http.Response, error) {
+	return GetHeader(ctx, urlStr, nil)
+}
+
+// GetHeader issues a GET request to urlStr, with the given headers added to
+// the request.
+func GetHeader(ctx context.Context, urlStr string, header http.Header) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
 	if err != nil {
 		return nil, fmt.Errorf("new request: %w", err)
 	}
+	for k, vv := range header {
+		req.Header[k] = vv
+	}
 	req.Header.Set("user-agent", UserAgent)
-	return Client().Do(req)
+	return do(req)
+}
+
+// PostForm issues a POST request to urlStr, with the URL-encoded form as the
+// request body.
+func PostForm(ctx context.Context, urlStr string, form url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, urlStr, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+	req.Header.Set("user-agent", UserAgent)
+	req.Header.Set("content-type", "application/x-www-form-urlencoded")
+	return do(req)
+}
+
+// Do sends req with the client returned by [Client], after setting the
+// "user-agent" header to [UserAgent].
+// Use Do for requests that [GetHeader] and [PostForm] cannot make, like
+// uploads.
+func Do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("user-agent", UserAgent)
+	return do(req)
+}
+
+// do sends req with the client returned by [Client], and logs the request at
+// the debug level.
+// Query strings are not logged, since they may contain credentials.
+//
+// GET and HEAD requests that fail with a temporary network error, or a
+// response asking the client to try again later, are retried as many times
+// as [Config.Retries] allows.
+// Other requests, like uploads, are only sent once.
+func do(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return send(req)
+	}
+
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		resp, err := send(req)
+
+		var delay time.Duration
+		switch {
+		case attempt >= retries:
+			return resp, err
+		case err != nil:
+			if !isTemporary(err) {
+				return nil, err
+			}
+			delay = backoff(attempt)
+		default:
+			d, ok := retryDelay(resp, attempt)
+			if !ok {
+				return resp, nil
+			}
+			delay = d
+			err = errors.New(resp.Status)
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+			resp.Body.Close()
+		}
+
+		u := *req.URL
+		u.RawQuery = ""
+		slog.WarnContext(ctx, "retrying request",
+			"url", u.Redacted(),
+			"err", err,
+			"attempt", attempt+1,
+			"delay", delay,
+		)
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// send sends req once, with the client returned by [Client].
+//
+// Requests are delayed as needed to stay within the rate limit set by
+// [Configure], which applies to every request made through this package.
+// Redirects followed by the client are not counted separately.
+func send(req *http.Request) (*http.Response, error) {
+	u := *req.URL
+	u.RawQuery = ""
+
+	if err := rateLimiter.wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := Client().Do(req)
+	if err != nil {
+		slog.DebugContext(req.Context(), "http request failed",
+			"method", req.Method,
+			"url", u.Redacted(),
+			"err", err,
+		)
+		return nil, err
+	}
+
+	slog.DebugContext(req.Context(), "http request",
+		"method", req.Method,
+		"url", u.Redacted(),
+		"status", resp.StatusCode,
+		"duration", time.Since(start),
+	)
+	return resp, nil
 }
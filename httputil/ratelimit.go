@@ -0,0 +1,86 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package httputil
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Default values for [Config.RateLimit] and [Config.RateBurst], chosen so
+// that batch operations, like downloading many mods, do not flood the mod
+// portal with requests.
+const (
+	DefaultRateLimit = 5
+	DefaultRateBurst = 10
+)
+
+// limiter is a token bucket, which allows bursts of up to burst requests, and
+// refills at rate requests per second.
+type limiter struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// newLimiter returns a limiter allowing rate requests per second, with bursts
+// of up to burst requests.
+// It returns nil, which never waits, when rate is not positive.
+func newLimiter(rate float64, burst int) *limiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &limiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until a request may be sent, or ctx is done.
+func (l *limiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	// Take the token now, even if it has not been refilled yet, so that
+	// concurrent callers queue up behind each other.
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		// Give the token back, since the request was not sent.
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,136 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package httputil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// DefaultRetries is the default value for [Config.Retries].
+const DefaultRetries = 3
+
+const (
+	// The delay before the first retry, which doubles with every retry,
+	// up to maxBackoff.
+	baseBackoff = time.Second
+	maxBackoff  = 30 * time.Second
+
+	// Responses asking to be retried after longer than this are returned
+	// to the caller instead.
+	maxRetryAfter = 2 * time.Minute
+)
+
+// Retry calls fn until it succeeds, returns an error that is not temporary,
+// or has been retried as many times as [Config.Retries] allows, waiting
+// longer between each attempt.
+// Use Retry for work that can fail after a request has been sent, like
+// reading a large response body.
+//
+// Errors are temporary when they are caused by a network timeout, or by the
+// connection being reset or closed early.
+func Retry(ctx context.Context, fn func(ctx context.Context) error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= retries || !isTemporary(err) {
+			return err
+		}
+
+		delay := backoff(attempt)
+		slog.WarnContext(ctx, "retrying", "err", err, "attempt", attempt+1, "delay", delay)
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// isTemporary reports whether err is a network error that may not happen
+// again.
+func isTemporary(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	case errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNABORTED),
+		errors.Is(err, syscall.EPIPE):
+		return true
+	}
+	return false
+}
+
+// retryDelay returns how long to wait before retrying a request that
+// returned resp, and whether it should be retried at all.
+// Requests are retried when the server is rate limiting them, or is
+// temporarily unavailable; the "Retry-After" header is honored when it is
+// set.
+func retryDelay(resp *http.Response, attempt int) (time.Duration, bool) {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+	default:
+		return 0, false
+	}
+
+	d, ok := parseRetryAfter(resp.Header.Get("retry-after"), time.Now())
+	if !ok {
+		return backoff(attempt), true
+	}
+	return d, d <= maxRetryAfter
+}
+
+// parseRetryAfter parses the value of a "Retry-After" header, which is
+// either a number of seconds, or an HTTP date.
+func parseRetryAfter(s string, now time.Time) (time.Duration, bool) {
+	if s == "" {
+		return 0, false
+	}
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return time.Duration(n) * time.Second, true
+	}
+	if t, err := http.ParseTime(s); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// backoff returns the delay before the given retry, which grows
+// exponentially, with jitter so that concurrent requests do not all retry
+// at the same time.
+func backoff(attempt int) time.Duration {
+	d := maxBackoff
+	if attempt < 16 {
+		d = min(baseBackoff<<attempt, maxBackoff)
+	}
+	return d/2 + rand.N(d/2)
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,241 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Archive is an open mod archive.
+//
+// Mod archives contain a single top-level directory, which is usually, but
+// not always, named "NAME_VERSION".
+// Archive is an [fs.FS] rooted at that directory, so the mod's files can be
+// opened by the same paths the game uses, like "info.json" or
+// "locale/en/locale.cfg".
+type Archive struct {
+	fs.FS
+
+	zr   *zip.ReadCloser
+	root string
+}
+
+// OpenArchive opens the mod archive at path.
+// The caller must close the returned archive.
+func OpenArchive(path string) (*Archive, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("open zip: %w", err)
+	}
+
+	root, err := archiveRoot(&zr.Reader)
+	if err != nil {
+		zr.Close()
+		return nil, err
+	}
+	fsys, err := fs.Sub(&zr.Reader, root)
+	if err != nil {
+		zr.Close()
+		return nil, fmt.Errorf("sub %s: %w", root, err)
+	}
+	return &Archive{FS: fsys, zr: zr, root: root}, nil
+}
+
+// ArchiveInfo holds the metadata of a mod archive, or of an unpacked mod
+// directory: its info.json file, and its changelog and thumbnail, when it
+// has them.
+type ArchiveInfo struct {
+	Info
+
+	// The contents of the mod's changelog.txt file, or an empty string if
+	// the mod does not have one.
+	// Use [ParseChangelog] to split it into versions.
+	Changelog string
+
+	// The contents of the mod's thumbnail.png file, or nil if the mod
+	// does not have one.
+	// The thumbnail is not included in the info's JSON encoding.
+	Thumbnail []byte
+}
+
+// MarshalJSON implements [encoding/json.Marshaler].
+// The info.json fields are followed by "changelog", when the mod has one.
+func (ai ArchiveInfo) MarshalJSON() ([]byte, error) {
+	kv := ai.Info.fields()
+	if ai.Changelog != "" {
+		kv = append(kv, "changelog", ai.Changelog)
+	}
+	return marshalObject(ai.extra, kv...)
+}
+
+// LoadArchiveMetadata reads the info.json, changelog.txt, and thumbnail.png
+// files out of the mod archive at path.
+// Like [LoadInfo], path may also be an unpacked mod directory.
+func LoadArchiveMetadata(path string) (ArchiveInfo, error) {
+	var (
+		ai   ArchiveInfo
+		fsys fs.FS
+		err  error
+	)
+	if fi, statErr := os.Stat(path); statErr == nil && fi.IsDir() {
+		if ai.Info, err = LoadDirInfo(path); err != nil {
+			return ArchiveInfo{}, err
+		}
+		fsys = os.DirFS(path)
+	} else {
+		a, err := OpenArchive(path)
+		if err != nil {
+			return ArchiveInfo{}, err
+		}
+		defer a.Close()
+		if ai.Info, err = a.Info(); err != nil {
+			return ArchiveInfo{}, err
+		}
+		fsys = a
+	}
+
+	changelog, err := fs.ReadFile(fsys, "changelog.txt")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return ArchiveInfo{}, fmt.Errorf("read changelog.txt: %w", err)
+	}
+	ai.Changelog = string(changelog)
+
+	if ai.Thumbnail, err = fs.ReadFile(fsys, "thumbnail.png"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return ArchiveInfo{}, fmt.Errorf("read thumbnail.png: %w", err)
+	}
+	return ai, nil
+}
+
+// archiveRoot returns the name of the top-level directory holding the mod's
+// info.json file.
+func archiveRoot(zr *zip.Reader) (string, error) {
+	for _, f := range zr.File {
+		dir, file := path.Split(f.Name)
+		if file == "info.json" && strings.Count(dir, "/") == 1 {
+			return strings.TrimSuffix(dir, "/"), nil
+		}
+	}
+	return "", errors.New("info.json not found in archive")
+}
+
+// Close closes the archive.
+func (a *Archive) Close() error {
+	return a.zr.Close()
+}
+
+// Root returns the name of the archive's top-level directory.
+func (a *Archive) Root() string {
+	return a.root
+}
+
+// Info reads the mod's info.json file.
+func (a *Archive) Info() (Info, error) {
+	data, err := fs.ReadFile(a, "info.json")
+	if err != nil {
+		return Info{}, err
+	}
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		return Info{}, fmt.Errorf("decode %s/info.json: %w", a.root, err)
+	}
+	return info, nil
+}
+
+// Changelog returns the contents of the mod's changelog.txt file.
+// The returned error wraps [fs.ErrNotExist] when the mod has no changelog.
+func (a *Archive) Changelog() (string, error) {
+	data, err := fs.ReadFile(a, "changelog.txt")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Thumbnail returns the contents of the mod's thumbnail.png file.
+// The returned error wraps [fs.ErrNotExist] when the mod has no thumbnail.
+func (a *Archive) Thumbnail() ([]byte, error) {
+	return fs.ReadFile(a, "thumbnail.png")
+}
+
+// Locales returns the languages the mod has locale files for, like "en" or
+// "de", sorted by name.
+func (a *Archive) Locales() ([]string, error) {
+	entries, err := fs.ReadDir(a, "locale")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var langs []string
+	for _, e := range entries {
+		if e.IsDir() {
+			langs = append(langs, e.Name())
+		}
+	}
+	slices.Sort(langs)
+	return langs, nil
+}
+
+// Locale reads every locale file (".cfg") the mod has for the given
+// language, and returns the translations, keyed by "SECTION.KEY", or by
+// "KEY" for keys that are not in a section.
+// Where files disagree, the file whose name sorts last wins.
+func (a *Archive) Locale(lang string) (map[string]string, error) {
+	matches, err := fs.Glob(a, path.Join("locale", lang, "*.cfg"))
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("locale %s: %w", lang, fs.ErrNotExist)
+	}
+
+	strs := make(map[string]string)
+	for _, m := range matches {
+		data, err := fs.ReadFile(a, m)
+		if err != nil {
+			return nil, err
+		}
+		parseLocale(string(data), strs)
+	}
+	return strs, nil
+}
+
+// parseLocale parses the contents of a locale file into strs.
+// Locale files are INI-style files of "key=value" lines, grouped into
+// "[section]"s; lines starting with "#" or ";" are comments.
+func parseLocale(data string, strs map[string]string) {
+	var section string
+	sc := bufio.NewScanner(strings.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "", strings.HasPrefix(line, "#"), strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if section != "" {
+			key = section + "." + key
+		}
+		strs[key] = value
+	}
+}
@@ -0,0 +1,114 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AuditProblem describes why [Cache.Audit] flagged an installed mod.
+type AuditProblem int
+
+const (
+	Abandoned AuditProblem = iota // The latest release is older than the maximum age.
+	Behind                        // The latest release is for an older version of the game.
+	Unlisted                      // The mod is not on the mod portal.
+)
+
+func (p AuditProblem) String() string {
+	switch p {
+	case Abandoned:
+		return "abandoned"
+	case Behind:
+		return "behind"
+	case Unlisted:
+		return "unlisted"
+	}
+	return fmt.Sprintf("AuditProblem(%d)", int(p))
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (p AuditProblem) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// AuditFinding is a single problem with an installed mod.
+type AuditFinding struct {
+	Name    string       `json:"name"`
+	Version Version      `json:"version"` // The installed version.
+	Problem AuditProblem `json:"problem"`
+	Detail  string       `json:"detail"`
+}
+
+// AuditOptions control which checks [Cache.Audit] runs.
+type AuditOptions struct {
+	// Flag mods whose latest release is older than MaxAge.
+	// Zero disables this check.
+	MaxAge time.Duration
+
+	// Flag mods whose latest release does not support this version of
+	// Factorio.
+	// The zero value disables this check.
+	Game Version
+}
+
+// Audit checks the mods installed to installDir for signs that they are
+// abandoned or risky to keep using, according to opts.
+// Mods that are not in the cache database are reported as [Unlisted], so
+// the cache should be updated with [Cache.Update] first.
+//
+// Mods that ship with the game, like "base", are not checked.
+func (c *Cache) Audit(ctx context.Context, installDir string, opts AuditOptions) ([]AuditFinding, error) {
+	installed, err := Load(installDir)
+	if err != nil {
+		return nil, fmt.Errorf("load mods: %w", err)
+	}
+
+	var findings []AuditFinding
+	for _, m := range installed {
+		n := len(m.Versions)
+		if n == 0 {
+			continue
+		}
+		add := func(p AuditProblem, format string, args ...any) {
+			findings = append(findings, AuditFinding{
+				Name:    m.Name,
+				Version: m.Versions[n-1],
+				Problem: p,
+				Detail:  fmt.Sprintf(format, args...),
+			})
+		}
+
+		latest, err := c.LatestRelease(ctx, m.Name)
+		if errors.Is(err, ErrNotCached) {
+			add(Unlisted, "not found on the mod portal")
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if age := time.Since(latest.ReleasedAt); opts.MaxAge > 0 && age > opts.MaxAge {
+			add(Abandoned, "latest release %s was published %s", latest.Version, latest.ReleasedAt.Format(time.DateOnly))
+		}
+		if fv := latest.FactorioVersion(); !opts.Game.IsZero() && !SupportsGame(fv, opts.Game) && targetsOlderGame(fv, opts.Game) {
+			add(Behind, "latest release %s is for Factorio %s", latest.Version, fv)
+		}
+	}
+
+	return findings, nil
+}
+
+// targetsOlderGame reports whether the "factorio_version" of a mod is older
+// than the major and minor version of game.
+func targetsOlderGame(factorioVersion string, game Version) bool {
+	if factorioVersion == "" {
+		factorioVersion = "0.12"
+	}
+	v := parseVersion(factorioVersion)
+	return v.Compare(Version{Major: game.Major, Minor: game.Minor}) < 0
+}
@@ -0,0 +1,97 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/nesv/factorio-tools/httputil"
+)
+
+// ErrEmailAuthRequired is returned by [Login] when the account requires an
+// authentication code, which factorio.com has sent to the account's email
+// address.
+// Call [Login] again, with the code.
+var ErrEmailAuthRequired = errors.New("email authentication code required")
+
+// Login exchanges a factorio.com username (or email address) and password for
+// the service token required for downloading mods, using the [web
+// authentication API].
+// emailCode is only needed when a previous call to Login returned
+// [ErrEmailAuthRequired]; otherwise, it should be empty.
+// An error wrapping [ErrAuthFailed] is returned when factorio.com rejects the
+// username or password.
+//
+// [web authentication API]: https://wiki.factorio.com/Web_authentication_API
+func Login(ctx context.Context, username, password, emailCode string) (Credentials, error) {
+	form := url.Values{
+		"username":    {username},
+		"password":    {password},
+		"api_version": {"6"},
+	}
+	if emailCode != "" {
+		form.Set("email_authentication_code", emailCode)
+	}
+
+	resp, err := httputil.PostForm(ctx, "https://auth.factorio.com/api-login", form)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("post: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error   string `json:"error"`
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil {
+			return Credentials{}, fmt.Errorf("unexpected response: %s", resp.Status)
+		}
+		if apiErr.Error == "email-authentication-required" {
+			return Credentials{}, ErrEmailAuthRequired
+		}
+		return Credentials{}, fmt.Errorf("%w: login failed: %s (%s)", ErrAuthFailed, apiErr.Message, apiErr.Error)
+	}
+
+	var result struct {
+		Username string `json:"username"`
+		Token    string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return Credentials{}, fmt.Errorf("decode json: %w", err)
+	}
+
+	return Credentials{Username: result.Username, Token: result.Token}, nil
+}
+
+// Save writes the credentials to path, in the same format used by Factorio's
+// "player-data.json" file, so they can be read back with [LoadCredentials].
+// The file is only readable by the current user.
+func (c Credentials) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), fs.ModePerm); err != nil {
+		return fmt.Errorf("make directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("open credentials: %w", err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(c); err != nil {
+		return fmt.Errorf("encode json: %w", err)
+	}
+	return f.Close()
+}
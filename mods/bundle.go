@@ -0,0 +1,254 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Names of the files within a cache bundle.
+const (
+	bundleDB      = "mods.db"
+	bundleModsDir = "mods"
+)
+
+// Export writes a bundle of the cache to w, for copying the cache to a
+// server without internet access, where it can be loaded with
+// [Cache.Import].
+//
+// A bundle is a gzip-compressed tar archive, holding a copy of the cache
+// database, and the given mod archives, which are usually files in the
+// cache's mods directory returned by [Cache.Get] or [Cache.GetVersion].
+func (c *Cache) Export(ctx context.Context, w io.Writer, archives ...string) error {
+	dir, err := os.MkdirTemp(c.dir, "facmod-bundle-*")
+	if err != nil {
+		return fmt.Errorf("make temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// VACUUM INTO writes a consistent copy of the database, even while
+	// it is being used.
+	dbPath := filepath.Join(dir, bundleDB)
+	if err := c.withLock(func() error {
+		_, err := c.db.ExecContext(ctx, `VACUUM INTO ?`, dbPath)
+		return err
+	}); err != nil {
+		return fmt.Errorf("copy database: %w", err)
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	if err := addBundleFile(tw, bundleDB, dbPath); err != nil {
+		return err
+	}
+	for _, p := range archives {
+		if err := addBundleFile(tw, path.Join(bundleModsDir, filepath.Base(p)), p); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("close tar writer: %w", err)
+	}
+	if err := gw.Close(); err != nil {
+		return fmt.Errorf("close gzip writer: %w", err)
+	}
+	return nil
+}
+
+// addBundleFile adds the file at src to the bundle, as name.
+func addBundleFile(tw *tar.Writer, name, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", src, err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", src, err)
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", src)
+	}
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    fi.Size(),
+		ModTime: fi.ModTime().UTC().Truncate(time.Second),
+	}); err != nil {
+		return fmt.Errorf("write header for %s: %w", name, err)
+	}
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+// Import loads a bundle written by [Cache.Export].
+// The cache database is replaced by the bundle's copy, and the bundle's mod
+// archives are added to the cache's mods directory, replacing any archives
+// with the same names.
+//
+// Import returns the paths to the mod archives it added.
+// Nothing is changed if the bundle cannot be read completely, or its
+// database cannot be opened.
+func (c *Cache) Import(ctx context.Context, r io.Reader) ([]string, error) {
+	dir, err := os.MkdirTemp(c.dir, "facmod-bundle-*")
+	if err != nil {
+		return nil, fmt.Errorf("make temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Extract the whole bundle before changing anything, so that a
+	// truncated bundle does not leave the cache half-updated.
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("read bundle: %w", err)
+	}
+	var (
+		tr       = tar.NewReader(gr)
+		dbPath   string
+		archives []string
+	)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("read bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(hdr.Name)
+		switch {
+		case name == bundleDB:
+			dbPath = filepath.Join(dir, bundleDB)
+			if err := extractBundleFile(tr, dbPath); err != nil {
+				return nil, err
+			}
+		case path.Dir(name) == bundleModsDir && strings.HasSuffix(name, ".zip"):
+			base := path.Base(name)
+			if err := extractBundleFile(tr, filepath.Join(dir, base)); err != nil {
+				return nil, err
+			}
+			archives = append(archives, base)
+		default:
+			slog.WarnContext(ctx, "skipping unknown file in bundle", "name", hdr.Name)
+		}
+	}
+
+	if dbPath != "" {
+		// Make sure the database can be used, and bring its schema up to
+		// date, before it replaces the current one.
+		db, err := openCacheDB(dbPath)
+		if err != nil {
+			return nil, fmt.Errorf("bundled database: %w", err)
+		}
+		db.Close()
+		if err := c.replaceDB(dbPath); err != nil {
+			return nil, err
+		}
+	}
+
+	modsDir := c.ModsDir()
+	if err := os.MkdirAll(modsDir, fs.ModePerm); err != nil {
+		return nil, fmt.Errorf("make directory %q: %w", modsDir, err)
+	}
+	paths := make([]string, len(archives))
+	for i, base := range archives {
+		paths[i] = filepath.Join(modsDir, base)
+		if err := os.Rename(filepath.Join(dir, base), paths[i]); err != nil {
+			return nil, fmt.Errorf("move %s into cache: %w", base, err)
+		}
+	}
+
+	// The bundled database records the archives that were downloaded
+	// on the machine the bundle was made on, rather than the ones in this
+	// cache.
+	downloaded := paths
+	if dbPath != "" {
+		if _, err := c.db.ExecContext(ctx, `DELETE FROM downloads`); err != nil {
+			return nil, fmt.Errorf("reset downloads: %w", err)
+		}
+		if downloaded, err = filepath.Glob(filepath.Join(modsDir, "*.zip")); err != nil {
+			return nil, fmt.Errorf("glob: %w", err)
+		}
+	}
+	for _, p := range downloaded {
+		if err := c.recordDownload(ctx, p); err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+// extractBundleFile copies the current file in tr to dst.
+func extractBundleFile(tr *tar.Reader, dst string) error {
+	f, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", dst, err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, tr); err != nil {
+		return fmt.Errorf("extract %s: %w", filepath.Base(dst), err)
+	}
+	return f.Close()
+}
+
+// replaceDB replaces the cache database with the database at src, and
+// reopens it.
+// When the new database cannot be put in place, or opened, the original
+// database is restored and reopened, so the cache is left usable.
+func (c *Cache) replaceDB(src string) error {
+	return c.withLock(func() error {
+		dbPath := filepath.Join(c.dir, "mods.db")
+		oldPath := dbPath + ".old"
+		if err := c.db.Close(); err != nil {
+			return fmt.Errorf("close database: %w", err)
+		}
+
+		restore := func(err error) error {
+			if rerr := os.Rename(oldPath, dbPath); rerr != nil && !errors.Is(rerr, fs.ErrNotExist) {
+				return fmt.Errorf("%w; restore database: %w", err, rerr)
+			}
+			db, rerr := openCacheDB(dbPath)
+			if rerr != nil {
+				return fmt.Errorf("%w; reopen database: %w", err, rerr)
+			}
+			c.db = db
+			return err
+		}
+
+		if err := os.Rename(dbPath, oldPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return restore(fmt.Errorf("move database aside: %w", err))
+		}
+		if err := os.Rename(src, dbPath); err != nil {
+			return restore(fmt.Errorf("replace database: %w", err))
+		}
+		db, err := openCacheDB(dbPath)
+		if err != nil {
+			return restore(err)
+		}
+		c.db = db
+		os.Remove(oldPath)
+		return nil
+	})
+}
@@ -7,24 +7,56 @@ package mods
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/Masterminds/squirrel"
 	progressbar "github.com/schollz/progressbar/v3"
-	_ "modernc.org/sqlite"
-
-	"github.com/nesv/factorio-tools/httputil"
+	"modernc.org/sqlite"
 )
 
+func init() {
+	// SQLite parses "X REGEXP Y" as a call to regexp(Y, X), but does not
+	// provide an implementation of the function.
+	sqlite.MustRegisterDeterministicScalarFunction("regexp", 2, sqliteRegexp)
+}
+
+// Compiled regular expressions used by sqliteRegexp, keyed by pattern.
+var regexpCache sync.Map
+
+func sqliteRegexp(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	pattern, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("regexp: pattern must be a string, got %T", args[0])
+	}
+	s, ok := args[1].(string)
+	if !ok {
+		// NULL values never match.
+		return false, nil
+	}
+
+	v, ok := regexpCache.Load(pattern)
+	if !ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("compile regexp: %w", err)
+		}
+		v, _ = regexpCache.LoadOrStore(pattern, re)
+	}
+	return v.(*regexp.Regexp).MatchString(s), nil
+}
+
 // Cache is a local database that is used for caching information about Factorio mods.
 type Cache struct {
 	dir string
@@ -32,53 +64,86 @@ type Cache struct {
 
 	mu                sync.Mutex
 	cachedResultsPath string
+	pulledAt          time.Time
 	showProgressBar   bool
+	mirrors           []Mirror
+	portal            *PortalClient
+	retention         PruneOptions
+	pruned            PruneResult
+	handedOut         map[string]bool // Archives returned by Download.
+	login             loginCredentials
+	refreshed         Credentials
+	metrics           Metrics
+
+	// Held while logging in again, so that concurrent downloads whose
+	// token was rejected only log in once.
+	loginMu sync.Mutex
 }
 
 func OpenCache(dir string) (*Cache, error) {
 	dbPath := filepath.Join(dir, "mods.db")
 
-	// If the database does not already exist, we will need to initialize it.
-	var initp bool
 	info, err := os.Stat(dbPath)
-	if errors.Is(err, fs.ErrNotExist) {
-		initp = true
-	} else if err != nil {
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return nil, fmt.Errorf("stat %q: %w", dbPath, err)
 	} else if err == nil && info.IsDir() {
 		return nil, fmt.Errorf("%s is a directory", dbPath)
 	}
 
-	db, err := sql.Open("sqlite", filepath.Join(dir, "mods.db"))
+	db, err := openCacheDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cache{
+		dir: dir,
+		db:  db,
+	}
+
+	return c, nil
+}
+
+// openCacheDB opens the cache database at dbPath, creating it if it does not
+// exist.
+func openCacheDB(dbPath string) (*sql.DB, error) {
+	// Wait for other connections to finish writing, instead of failing
+	// with SQLITE_BUSY, since downloads made by Cache.GetAll record
+	// themselves concurrently.
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=busy_timeout(5000)")
 	if err != nil {
 		return nil, fmt.Errorf("open mods.db: %w", err)
 	}
 
-	if initp {
-		if err := initCacheDB(db); err != nil {
-			return nil, fmt.Errorf("initialize cache database: %w", err)
-		}
+	// Always run the initialization statements, even if the database
+	// already exists, so that tables added in newer versions are created
+	// in older cache databases.
+	if err := initCacheDB(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("initialize cache database: %w", err)
 	}
 
 	// SQLite does not currently enforce foreign keys automatically, and
 	// we need to enable a pragma to have it do so.
 	if _, err := db.Exec(`PRAGMA foriegn_keys = ON`); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("enable foreign_keys pragma: %w", err)
 	}
 
-	c := &Cache{
-		dir: dir,
-		db:  db,
-	}
-
-	return c, nil
+	return db, nil
 }
 
 func initCacheDB(db *sql.DB) error {
 	statements := []string{
 		`CREATE TABLE IF NOT EXISTS categories (name TEXT PRIMARY KEY) STRICT`,
-		`CREATE TABLE IF NOT EXISTS mods (name TEXT PRIMARY KEY, title TEXT, owner TEXT, summary TEXT, category TEXT REFERENCES categories(name)) STRICT`,
+		`CREATE TABLE IF NOT EXISTS mods (name TEXT PRIMARY KEY, title TEXT, owner TEXT, summary TEXT, category TEXT REFERENCES categories(name), downloads_count INTEGER, thumbnail TEXT) STRICT`,
 		`CREATE TABLE IF NOT EXISTS latest_releases (name TEXT PRIMARY KEY, download_url TEXT, file_name TEXT, info_json TEXT, released_at TEXT, version TEXT, sha1 TEXT) STRICT`,
+		`CREATE TABLE IF NOT EXISTS full_info (name TEXT PRIMARY KEY, info TEXT, fetched_at TEXT) STRICT`,
+		`CREATE VIRTUAL TABLE IF NOT EXISTS mods_fts USING fts5(name, title, summary, description, tokenize = 'porter unicode61')`,
+		`CREATE TABLE IF NOT EXISTS releases (name TEXT, version TEXT, download_url TEXT, file_name TEXT, info_json TEXT, released_at TEXT, sha1 TEXT, PRIMARY KEY (name, version)) STRICT`,
+		`CREATE TABLE IF NOT EXISTS cache_meta (key TEXT PRIMARY KEY, value TEXT) STRICT`,
+		`CREATE TABLE IF NOT EXISTS tags (name TEXT PRIMARY KEY) STRICT`,
+		`CREATE TABLE IF NOT EXISTS mod_tags (mod TEXT, tag TEXT REFERENCES tags(name), PRIMARY KEY (mod, tag)) STRICT`,
+		`CREATE TABLE IF NOT EXISTS downloads (file_name TEXT PRIMARY KEY, downloaded_at TEXT) STRICT`,
 	}
 
 	for i, s := range statements {
@@ -87,6 +152,41 @@ func initCacheDB(db *sql.DB) error {
 		}
 	}
 
+	// Columns added to tables after they were first created.
+	if err := addColumn(db, "mods", "downloads_count", "INTEGER"); err != nil {
+		return err
+	}
+	if err := addColumn(db, "mods", "thumbnail", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumn(db, "mods", "license", "TEXT"); err != nil {
+		return err
+	}
+
+	// Licenses were only stored in full_info before they had a column.
+	if _, err := db.Exec(`UPDATE mods SET license = (SELECT f.info -> '$.license' FROM full_info AS f WHERE f.name = mods.name)
+		WHERE license IS NULL AND name IN (SELECT name FROM full_info)`,
+	); err != nil {
+		return fmt.Errorf("copy licenses from full info: %w", err)
+	}
+
+	return nil
+}
+
+// addColumn adds a column to an existing table, unless the table already has
+// a column with the same name.
+func addColumn(db *sql.DB, table, column, typ string) error {
+	var n int
+	if err := db.QueryRow(`SELECT count(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n); err != nil {
+		return fmt.Errorf("query columns of %s: %w", table, err)
+	}
+	if n > 0 {
+		return nil
+	}
+
+	if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, typ)); err != nil {
+		return fmt.Errorf("add column %s.%s: %w", table, column, err)
+	}
 	return nil
 }
 
@@ -108,6 +208,23 @@ func (c *Cache) DisableProgressBar() {
 	c.showProgressBar = false
 }
 
+// SetPortalClient sets the client used to make requests to the mod portal.
+// By default, the zero value of [PortalClient] is used.
+func (c *Cache) SetPortalClient(p *PortalClient) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.portal = p
+}
+
+func (c *Cache) portalClient() *PortalClient {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.portal == nil {
+		return new(PortalClient)
+	}
+	return c.portal
+}
+
 // Pull retrieves the mod list from the [Mods portal API], and caches the results,
 // returning the path to the file holding the partially-processed results.
 // The file holding the results contains a stream of mod entries, with each
@@ -116,16 +233,50 @@ func (c *Cache) DisableProgressBar() {
 //
 // To update the cache database, call [Cache.Update] afterwards.
 func (c *Cache) Pull(ctx context.Context) error {
-	resp, err := httputil.Get(ctx, "https://mods.factorio.com/api/mods")
-	if err != nil {
-		return fmt.Errorf("get first page: %w", err)
+	return c.pull(ctx, time.Time{})
+}
+
+// PullSince is like [Cache.Pull], but only retrieves the mods whose latest
+// release was published after since, which is usually the time returned by
+// [Cache.LastUpdate].
+// Mods are requested in order of when they were last updated, and pages are
+// retrieved until a page without any newer releases is found, so that only
+// a handful of requests are needed when the cache is updated regularly.
+//
+// Mods that were not retrieved are left as-is by [Cache.Update], so their
+// download counts will not be updated; use [Cache.Pull] for a full refresh.
+func (c *Cache) PullSince(ctx context.Context, since time.Time) error {
+	return c.pull(ctx, since)
+}
+
+func (c *Cache) pull(ctx context.Context, since time.Time) error {
+	started := time.Now()
+
+	var (
+		portal = c.portalClient()
+		opts   = ListOptions{Page: 1}
+	)
+	if since.IsZero() {
+		// Ask for every mod in one response, instead of the ~90 pages
+		// it takes with the default page size.
+		opts.PageSize = -1
+	} else {
+		// Only a page or two is needed when pulling recent updates.
+		opts.Sort = "updated_at"
+		opts.SortOrder = "desc"
 	}
-	defer resp.Body.Close()
 
-	var list modlist
-	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
-		return fmt.Errorf("decode json: %w", err)
+	list, err := portal.List(ctx, opts)
+	var se *statusError
+	if errors.As(err, &se) && opts.PageSize != 0 && se.code >= 400 && se.code < 500 {
+		slog.DebugContext(ctx, "page size rejected; retrying with the default page size", "err", err)
+		opts.PageSize = 0
+		list, err = portal.List(ctx, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("get first page: %w", err)
 	}
+	add(c.getMetrics().PagesPulled, 1)
 
 	results, err := c.makeTempFile("results.json")
 	if err != nil {
@@ -141,6 +292,27 @@ func (c *Cache) Pull(ctx context.Context) error {
 		bar          *progressbar.ProgressBar
 	)
 
+	// write adds the mods in a page of results to the results file, and
+	// reports whether the next page should be retrieved.
+	write := func(mods []ListResult) (bool, error) {
+		more := since.IsZero()
+		for _, m := range mods {
+			if !since.IsZero() && !m.LatestRelease.ReleasedAt.After(since) {
+				continue
+			}
+			more = true
+			if err := enc.Encode(m); err != nil {
+				return false, fmt.Errorf("encode mod: %w", err)
+			}
+		}
+		return more, nil
+	}
+
+	more, err := write(list.Results)
+	if err != nil {
+		return err
+	}
+
 	if showProgress {
 		bar = progressbar.NewOptions(totalPages,
 			progressbar.OptionShowCount(),
@@ -153,25 +325,16 @@ func (c *Cache) Pull(ctx context.Context) error {
 		defer bar.Exit()
 	}
 
-	for i := 2; i <= totalPages; i++ {
-		urlStr := fmt.Sprintf("https://mods.factorio.com/api/mods?page=%d", i)
-		resp, err := httputil.Get(ctx, urlStr)
+	for i := 2; more && i <= totalPages; i++ {
+		opts.Page = i
+		page, err := portal.List(ctx, opts)
 		if err != nil {
-			return fmt.Errorf("http get %q: %w", urlStr, err)
+			return fmt.Errorf("get page %d: %w", i, err)
 		}
+		add(c.getMetrics().PagesPulled, 1)
 
-		// NOTE: resp.Body does not need to be closed, since it will be
-		// done by decodeResults.
-
-		mods, err := c.decodeResults(resp.Body)
-		if err != nil {
-			return fmt.Errorf("decode results for page %d: %w", i, err)
-		}
-
-		for _, m := range mods {
-			if err := enc.Encode(m); err != nil {
-				return fmt.Errorf("encode mod: %w", err)
-			}
+		if more, err = write(page.Results); err != nil {
+			return err
 		}
 
 		if showProgress {
@@ -182,25 +345,36 @@ func (c *Cache) Pull(ctx context.Context) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.cachedResultsPath = results.Name()
+	c.pulledAt = started
 
 	return nil
 }
 
+// LastUpdate returns the time at which the mod list was last retrieved for
+// [Cache.Update].
+// LastUpdate returns the zero time if the cache has never been updated.
+func (c *Cache) LastUpdate(ctx context.Context) (time.Time, error) {
+	var value string
+	err := c.db.QueryRowContext(ctx, `SELECT value FROM cache_meta WHERE key = 'last_update'`).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, fmt.Errorf("query last update: %w", err)
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse last update: %w", err)
+	}
+	return t, nil
+}
+
 func (c *Cache) progressBarEnabled() bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	return c.showProgressBar
 }
 
-func (c *Cache) decodeResults(r io.ReadCloser) ([]modlistResult, error) {
-	defer r.Close()
-	var list modlist
-	if err := json.NewDecoder(r).Decode(&list); err != nil {
-		return nil, fmt.Errorf("decode json: %w", err)
-	}
-	return list.Results, nil
-}
-
 // makeTempFile creates a new file with name in a directory created by [os.MkdirTemp].
 // The caller is responsible for deleting the file and its parent directory.
 func (c *Cache) makeTempFile(name string) (*os.File, error) {
@@ -249,9 +423,12 @@ func (c *Cache) Update(ctx context.Context) error {
 		}
 	}
 
-	var resultsFile string
+	var (
+		resultsFile string
+		pulledAt    time.Time
+	)
 	c.withLock(func() error {
-		resultsFile = c.cachedResultsPath
+		resultsFile, pulledAt = c.cachedResultsPath, c.pulledAt
 		return nil
 	})
 	f, err := os.Open(resultsFile)
@@ -261,25 +438,44 @@ func (c *Cache) Update(ctx context.Context) error {
 	defer f.Close()
 
 	dec := json.NewDecoder(f)
-	return c.withTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+	var upserted int64
+	err = c.withTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
 		// Prepare statements.
 		insertCategory, err := tx.PrepareContext(ctx, `INSERT OR IGNORE INTO categories (name) VALUES (?)`)
 		if err != nil {
 			return fmt.Errorf("prepare insert category statement: %w", err)
 		}
 
-		insertMod, err := tx.PrepareContext(ctx, `INSERT OR REPLACE INTO mods (name, title, owner, summary, category) VALUES (?, ?, ?, ?, ?)`)
+		// The mod list does not include licenses, so keep any license
+		// stored by FullInfo.
+		insertMod, err := tx.PrepareContext(ctx, `INSERT INTO mods (name, title, owner, summary, category, downloads_count, thumbnail, license) VALUES (?, ?, ?, ?, ?, ?, ?, json(?))
+			ON CONFLICT (name) DO UPDATE SET
+				title = excluded.title,
+				owner = excluded.owner,
+				summary = excluded.summary,
+				category = excluded.category,
+				downloads_count = excluded.downloads_count,
+				thumbnail = excluded.thumbnail,
+				license = coalesce(excluded.license, mods.license)`)
 		if err != nil {
 			return fmt.Errorf("prepare insert mod statement: %w", err)
 		}
 
-		insertRelease, err := tx.PrepareContext(ctx, `INSERT OR REPLACE INTO latest_releases (name, download_url, file_name, info_json, released_at, version, sha1) VALUES (?, ?, ?, json(?), ?, ?, ?)`)
+		insertLatestRelease, err := tx.PrepareContext(ctx, `INSERT OR REPLACE INTO latest_releases (name, download_url, file_name, info_json, released_at, version, sha1) VALUES (?, ?, ?, json(?), ?, ?, ?)`)
+		if err != nil {
+			return fmt.Errorf("prepare insert latest release statement: %w", err)
+		}
+
+		// Only insert into the release history when the release is not
+		// already known, since releases from the "full" endpoint have a
+		// more-complete copy of info.json.
+		insertRelease, err := tx.PrepareContext(ctx, insertReleaseSQL("OR IGNORE"))
 		if err != nil {
 			return fmt.Errorf("prepare insert release statement: %w", err)
 		}
 
 		for {
-			var m modlistResult
+			var m ListResult
 			if err := dec.Decode(&m); errors.Is(err, io.EOF) {
 				break
 			} else if err != nil {
@@ -290,18 +486,23 @@ func (c *Cache) Update(ctx context.Context) error {
 				return fmt.Errorf("insert into categories: %w", err)
 			}
 
-			if _, err := insertMod.ExecContext(ctx,
+			res, err := insertMod.ExecContext(ctx,
 				m.Name,
 				m.Title,
 				m.Owner,
 				m.Summary,
 				m.Category,
-			); err != nil {
+				m.DownloadsCount,
+				m.Thumbnail,
+				licenseJSON(m.License),
+			)
+			if err != nil {
 				return fmt.Errorf("insert into mods: %w", err)
 			}
+			upserted += rowsAffected(res)
 
 			r := m.LatestRelease
-			if _, err := insertRelease.ExecContext(ctx,
+			res, err = insertLatestRelease.ExecContext(ctx,
 				m.Name,
 				r.DownloadURL,
 				r.FileName,
@@ -309,15 +510,347 @@ func (c *Cache) Update(ctx context.Context) error {
 				r.ReleasedAt.Format(time.RFC3339),
 				r.Version,
 				r.SHA1,
-			); err != nil {
+			)
+			if err != nil {
 				return fmt.Errorf("insert into latest releases: %w", err)
 			}
+			upserted += rowsAffected(res)
 
-			bar.Add(1)
+			res, err = insertRelease.ExecContext(ctx, releaseArgs(m.Name, r)...)
+			if err != nil {
+				return fmt.Errorf("insert into releases: %w", err)
+			}
+			upserted += rowsAffected(res)
+
+			// Only replace a mod's tags when the portal sent them, so
+			// tags stored by FullInfo are kept.
+			if m.Tags != nil {
+				if err := replaceTags(ctx, tx, m.Name, m.Tags); err != nil {
+					return err
+				}
+			}
+
+			if showProgress {
+				bar.Add(1)
+			}
 		}
-		return nil
+
+		if _, err := tx.ExecContext(ctx,
+			`INSERT OR REPLACE INTO cache_meta (key, value) VALUES ('last_update', ?)`,
+			pulledAt.UTC().Format(time.RFC3339),
+		); err != nil {
+			return fmt.Errorf("record last update: %w", err)
+		}
+
+		return rebuildSearchIndex(ctx, tx)
 	})
+	if err != nil {
+		return err
+	}
+	add(c.getMetrics().RowsUpserted, float64(upserted))
+	return nil
+}
+
+// rebuildSearchIndex repopulates the full-text search index from the mods
+// table, including the descriptions of any mods with cached full info.
+func rebuildSearchIndex(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mods_fts`); err != nil {
+		return fmt.Errorf("clear search index: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO mods_fts (name, title, summary, description)
+		SELECT m.name, m.title, m.summary, coalesce(f.info ->> '$.description', '')
+		FROM mods AS m
+		LEFT JOIN full_info AS f USING (name)`,
+	); err != nil {
+		return fmt.Errorf("populate search index: %w", err)
+	}
+
+	return nil
+}
+
+// fullInfoMaxAge is how long a response from the "full" mod endpoint is kept
+// in the cache before it is considered stale.
+const fullInfoMaxAge = 24 * time.Hour
+
+// FullInfo returns all of the information the [Mod portal API] has about the
+// named mod, as returned by the "/api/mods/{name}/full" endpoint.
+// Responses are stored in the cache database, and are re-fetched once they
+// are older than a day.
+//
+// [Mod portal API]: https://wiki.factorio.com/Mod_portal_API
+func (c *Cache) FullInfo(ctx context.Context, name string) (*ModInfo, error) {
+	if name == "" {
+		return nil, errors.New("empty mod name")
+	}
+
+	if info, err := c.cachedFullInfo(ctx, name); err != nil || info != nil {
+		return info, err
+	}
+
+	info, err := c.portalClient().GetFull(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(info)
+	if err != nil {
+		return nil, fmt.Errorf("encode json: %w", err)
+	}
+
+	var upserted int64
+	if err := c.withLock(func() error {
+		return c.withTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx,
+				`INSERT OR REPLACE INTO full_info (name, info, fetched_at) VALUES (?, json(?), ?)`,
+				info.Name,
+				string(body),
+				time.Now().UTC().Format(time.RFC3339),
+			); err != nil {
+				return err
+			}
+
+			for _, r := range info.Releases {
+				res, err := tx.ExecContext(ctx, insertReleaseSQL("OR REPLACE"), releaseArgs(info.Name, r)...)
+				if err != nil {
+					return fmt.Errorf("insert into releases: %w", err)
+				}
+				upserted += rowsAffected(res)
+			}
+
+			if err := replaceTags(ctx, tx, info.Name, info.Tags); err != nil {
+				return err
+			}
+
+			res, err := tx.ExecContext(ctx,
+				`UPDATE mods SET license = json(?) WHERE name = ?`,
+				licenseJSON(info.License), info.Name,
+			)
+			if err != nil {
+				return fmt.Errorf("update license: %w", err)
+			}
+			upserted += rowsAffected(res)
+
+			// Index the mod's description for full-text searches.
+			if _, err := tx.ExecContext(ctx, `DELETE FROM mods_fts WHERE name = ?`, info.Name); err != nil {
+				return fmt.Errorf("delete from search index: %w", err)
+			}
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO mods_fts (name, title, summary, description) VALUES (?, ?, ?, ?)`,
+				info.Name, info.Title, info.Summary, info.Description,
+			); err != nil {
+				return fmt.Errorf("insert into search index: %w", err)
+			}
+			return nil
+		})
+	}); err != nil {
+		return nil, fmt.Errorf("cache full info: %w", err)
+	}
+	add(c.getMetrics().RowsUpserted, float64(upserted))
+
+	return info, nil
+}
+
+// cachedFullInfo returns the full information about the named mod stored by
+// [Cache.FullInfo], or nil if it is not stored or is older than a day.
+func (c *Cache) cachedFullInfo(ctx context.Context, name string) (*ModInfo, error) {
+	defer c.observeQuery(time.Now())
+
+	var (
+		infoJSON  string
+		fetchedAt string
+	)
+	err := c.db.QueryRowContext(ctx, `SELECT info, fetched_at FROM full_info WHERE name = ?`, name).Scan(&infoJSON, &fetchedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("query cached info: %w", err)
+	}
+
+	t, err := time.Parse(time.RFC3339, fetchedAt)
+	if err != nil || time.Since(t) >= fullInfoMaxAge {
+		return nil, nil
+	}
+	var info ModInfo
+	if err := json.Unmarshal([]byte(infoJSON), &info); err != nil {
+		return nil, fmt.Errorf("decode cached info: %w", err)
+	}
+	return &info, nil
+}
+
+// ShortInfo returns the information about the named mod returned by the
+// "/api/mods/{name}" endpoint of the [Mod portal API], which includes every
+// release of the mod, but not their dependencies.
+// When the mod's full information is stored in the cache database, and is
+// less than a day old, it is returned instead, without making a request.
+//
+// The mod's releases are added to the cache's release history, without
+// replacing releases stored by [Cache.FullInfo].
+//
+// [Mod portal API]: https://wiki.factorio.com/Mod_portal_API
+func (c *Cache) ShortInfo(ctx context.Context, name string) (*ModInfo, error) {
+	if name == "" {
+		return nil, errors.New("empty mod name")
+	}
+	if info, err := c.cachedFullInfo(ctx, name); err != nil || info != nil {
+		return info, err
+	}
+
+	info, err := c.portalClient().Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var upserted int64
+	if err := c.withLock(func() error {
+		return c.withTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+			for _, r := range info.Releases {
+				res, err := tx.ExecContext(ctx, insertReleaseSQL("OR IGNORE"), releaseArgs(info.Name, r)...)
+				if err != nil {
+					return fmt.Errorf("insert into releases: %w", err)
+				}
+				upserted += rowsAffected(res)
+			}
+			return nil
+		})
+	}); err != nil {
+		return nil, fmt.Errorf("cache releases: %w", err)
+	}
+	add(c.getMetrics().RowsUpserted, float64(upserted))
+
+	return info, nil
+}
+
+// licenseJSON returns l encoded as JSON, for the mods table's license column,
+// or nil if l is empty.
+func licenseJSON(l License) any {
+	if l == (License{}) {
+		return nil
+	}
+	b, err := json.Marshal(l)
+	if err != nil {
+		return nil
+	}
+	return string(b)
+}
+
+// Licenses returns the license of each of the named mods, as reported by the
+// mod portal.
+// The mod portal only reports a mod's license in its full details, so
+// licenses are only known for mods whose details have been fetched with
+// [Cache.FullInfo]; other mods are not included in the returned map.
+func (c *Cache) Licenses(ctx context.Context, names ...string) (map[string]License, error) {
+	defer c.observeQuery(time.Now())
+
+	query, args, err := squirrel.Select("name", "license").
+		From("mods").
+		Where(squirrel.Eq{"name": names}).
+		Where("license IS NOT NULL").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query licenses: %w", err)
+	}
+	defer rows.Close()
+
+	licenses := make(map[string]License, len(names))
+	for rows.Next() {
+		var name, raw string
+		if err := rows.Scan(&name, &raw); err != nil {
+			return nil, fmt.Errorf("scan row: %w", err)
+		}
+		var l License
+		if err := json.Unmarshal([]byte(raw), &l); err != nil {
+			return nil, fmt.Errorf("decode license of %s: %w", name, err)
+		}
+		licenses[name] = l
+	}
+	return licenses, rows.Err()
+}
+
+// replaceTags replaces the tags stored for the named mod.
+func replaceTags(ctx context.Context, tx *sql.Tx, name string, tags []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mod_tags WHERE mod = ?`, name); err != nil {
+		return fmt.Errorf("delete from mod tags: %w", err)
+	}
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO tags (name) VALUES (?)`, tag); err != nil {
+			return fmt.Errorf("insert into tags: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO mod_tags (mod, tag) VALUES (?, ?)`, name, tag); err != nil {
+			return fmt.Errorf("insert into mod tags: %w", err)
+		}
+	}
+	return nil
+}
+
+// insertReleaseSQL returns the statement for inserting a row into the
+// releases table, using the given conflict resolution ("OR REPLACE" or
+// "OR IGNORE").
+// Use [releaseArgs] for the statement's arguments.
+func insertReleaseSQL(onConflict string) string {
+	return `INSERT ` + onConflict + ` INTO releases (name, version, download_url, file_name, info_json, released_at, sha1) VALUES (?, ?, ?, ?, json(?), ?, ?)`
+}
+
+func releaseArgs(name string, r Release) []any {
+	return []any{
+		name,
+		r.Version,
+		r.DownloadURL,
+		r.FileName,
+		string(r.InfoJSON),
+		r.ReleasedAt.Format(time.RFC3339),
+		r.SHA1,
+	}
+}
+
+// Release returns a specific release of the named mod from the cache's
+// release history.
+// If the release is not in the cache database, the mod's full release history
+// is fetched with [Cache.FullInfo].
+func (c *Cache) Release(ctx context.Context, name, version string) (Release, error) {
+	r, err := c.queryRelease(ctx, name, version)
+	if err == nil || !errors.Is(err, sql.ErrNoRows) {
+		return r, err
+	}
+
+	info, err := c.FullInfo(ctx, name)
+	if err != nil {
+		return Release{}, fmt.Errorf("get mod info: %w", err)
+	}
+	for _, r := range info.Releases {
+		if sameVersion(r.Version, version) {
+			return r, nil
+		}
+	}
+
+	return Release{}, fmt.Errorf("%w: no release of %s with version %s", ErrReleaseNotFound, name, version)
+}
+
+func (c *Cache) queryRelease(ctx context.Context, name, version string) (Release, error) {
+	defer c.observeQuery(time.Now())
+
+	var (
+		r          = Release{Version: version}
+		infoJSON   string
+		releasedAt string
+	)
+	err := c.db.QueryRowContext(ctx,
+		`SELECT download_url, file_name, info_json, released_at, sha1 FROM releases WHERE name = ? AND version = ?`,
+		name, version,
+	).Scan(&r.DownloadURL, &r.FileName, &infoJSON, &releasedAt, &r.SHA1)
+	if err != nil {
+		return Release{}, err
+	}
 
+	r.InfoJSON = json.RawMessage(infoJSON)
+	if r.ReleasedAt, err = time.Parse(time.RFC3339, releasedAt); err != nil {
+		return Release{}, fmt.Errorf("parse released at timestamp: %w", err)
+	}
+	return r, nil
 }
 
 // withTx wraps a function in a database transaction.
@@ -377,27 +910,100 @@ func (c *Cache) Search(ctx context.Context, searchTerm string, options ...Search
 		}
 	}
 
+	return c.search(ctx, sopts)
+}
+
+// Popular returns every mod in the cache, ordered by the number of times each
+// mod has been downloaded, most-downloaded first.
+// Options that filter the results, like [WithCategories], can be used to
+// narrow down the list; options that apply to a search term are ignored.
+func (c *Cache) Popular(ctx context.Context, options ...SearchOption) ([]M, error) {
+	var sopts searchOptions
+	for _, opt := range options {
+		if err := opt(&sopts); err != nil {
+			return nil, fmt.Errorf("apply option: %w", err)
+		}
+	}
+	sopts.term = ""
+	sopts.sortBy = sortDownloads
+
+	return c.search(ctx, sopts)
+}
+
+// search runs the query described by sopts.
+// An empty search term matches every mod.
+func (c *Cache) search(ctx context.Context, sopts searchOptions) ([]M, error) {
+	defer c.observeQuery(time.Now())
+
 	// Build the query.
 	//
-	// SELECT m.name, m.summary, r.released_at, r.version
+	// SELECT m.name, m.title, m.owner, m.summary, r.released_at, r.version, ...
 	// FROM mods AS m
 	// JOIN latest_releases USING (name)
 	// WHERE r.info_json ->> '$.factorio_version' >= '1.1'
-	// AND m.name LIKE '%$1%'
+	// AND (m.name LIKE '%$1%' OR m.title LIKE '%$1%' OR m.summary LIKE '%$1%')
+	//
+	// When the search term is a regular expression, each LIKE is replaced
+	// with "REGEXP $1", using the function registered in init().
 	selectQuery := squirrel.Select(
 		"m.name",
+		"coalesce(m.title, '')",
+		"coalesce(m.owner, '')",
 		"m.summary",
 		"m.category",
+		"coalesce(m.downloads_count, 0)",
+		"coalesce(m.thumbnail, '')",
 		"r.released_at",
 		"r.version",
+		`coalesce(r.info_json ->> '$.factorio_version', '')`,
 	).
 		From("mods AS m").
-		Join("latest_releases AS r USING (name)").
-		Where(squirrel.GtOrEq{`r.info_json ->> '$.factorio_version'`: "1.1"}).
-		Where(squirrel.Like{"m.name": "%" + sopts.term + "%"})
+		Join("latest_releases AS r USING (name)")
+
+	if sopts.factorioVersion != "" {
+		selectQuery = selectQuery.Where(squirrel.Eq{`r.info_json ->> '$.factorio_version'`: sopts.factorioVersion})
+	} else {
+		selectQuery = selectQuery.Where(squirrel.GtOrEq{`r.info_json ->> '$.factorio_version'`: "1.1"})
+	}
+
+	switch {
+	case sopts.term == "":
+	case sopts.fullText:
+		selectQuery = selectQuery.
+			Join("mods_fts AS f ON f.name = m.name").
+			Where("mods_fts MATCH ?", sopts.term)
+	default:
+		columns := []string{"m.name", "m.title", "m.summary"}
+		if sopts.nameOnly {
+			columns = columns[:1]
+		}
+
+		var match squirrel.Or
+		for _, col := range columns {
+			if sopts.isRegexp {
+				match = append(match, squirrel.Expr(col+" REGEXP ?", sopts.term))
+			} else {
+				match = append(match, squirrel.Like{col: "%" + sopts.term + "%"})
+			}
+		}
+		selectQuery = selectQuery.Where(match)
+	}
 
-	if sopts.sortByDate {
+	switch {
+	case sopts.sortBy == sortDate:
 		selectQuery = selectQuery.OrderBy("r.released_at DESC")
+	case sopts.sortBy == sortDateAscending:
+		selectQuery = selectQuery.OrderBy("r.released_at ASC")
+	case sopts.sortBy == sortName:
+		selectQuery = selectQuery.OrderBy("m.name COLLATE NOCASE ASC")
+	case sopts.sortBy == sortDownloads:
+		selectQuery = selectQuery.OrderBy("m.downloads_count DESC")
+	case sopts.fullText:
+		selectQuery = selectQuery.OrderBy("f.rank")
+	}
+	if sopts.limit > 0 || sopts.offset > 0 {
+		// Break ties by name, so that pages do not overlap.
+		selectQuery = selectQuery.OrderBy("m.name")
 	}
 
 	if nc := len(sopts.categories); nc > 0 {
@@ -408,16 +1014,61 @@ func (c *Cache) Search(ctx context.Context, searchTerm string, options ...Search
 		selectQuery = selectQuery.Where(squirrel.Eq{"m.category": cc})
 	}
 
+	if len(sopts.licenses) > 0 {
+		var match squirrel.Or
+		for _, l := range sopts.licenses {
+			match = append(match, squirrel.Expr(
+				`(lower(m.license ->> '$.id') IN (?, ?) OR lower(m.license ->> '$.name') = ? OR lower(m.license ->> '$.title') = ?)`,
+				l, "default_"+l, l, l,
+			))
+		}
+		selectQuery = selectQuery.Where(match)
+	}
+
+	for _, tag := range sopts.tags {
+		selectQuery = selectQuery.Where("EXISTS (SELECT 1 FROM mod_tags AS t WHERE t.mod = m.name AND t.tag = ?)", tag)
+	}
+
+	var (
+		countQuery string
+		countArgs  []any
+	)
+	if sopts.total != nil {
+		q, args, err := squirrel.Select("count(*)").FromSelect(selectQuery, "q").ToSql()
+		if err != nil {
+			return nil, fmt.Errorf("build count query: %w", err)
+		}
+		countQuery, countArgs = q, args
+	}
+
+	switch {
+	case sopts.limit > 0:
+		selectQuery = selectQuery.Limit(uint64(sopts.limit))
+		if sopts.offset > 0 {
+			selectQuery = selectQuery.Offset(uint64(sopts.offset))
+		}
+	case sopts.offset > 0:
+		// SQLite only accepts OFFSET after a LIMIT, and a negative
+		// LIMIT means there is none.
+		selectQuery = selectQuery.Suffix("LIMIT -1 OFFSET ?", sopts.offset)
+	}
+
 	query, args, err := selectQuery.ToSql()
 	if err != nil {
 		return nil, fmt.Errorf("build query: %w", err)
 	}
 
-	println("SQL: " + query)
+	slog.DebugContext(ctx, "search", "query", query, "args", args)
 
 	var mm []M
 	if err := c.withLock(func() error {
 		return c.withTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+			if countQuery != "" {
+				if err := tx.QueryRowContext(ctx, countQuery, countArgs...).Scan(sopts.total); err != nil {
+					return fmt.Errorf("count results: %w", err)
+				}
+			}
+
 			rows, err := tx.QueryContext(ctx, query, args...)
 			if err != nil {
 				return err
@@ -425,22 +1076,48 @@ func (c *Cache) Search(ctx context.Context, searchTerm string, options ...Search
 			defer rows.Close()
 
 			for rows.Next() {
-				var name, summary, category, releasedAt, version string
-				if err := rows.Scan(&name, &summary, &category, &releasedAt, &version); err != nil {
+				var (
+					name, title, owner, summary, category string
+					thumbnail, releasedAt, version        string
+					factorioVersion                       string
+					downloads                             int
+				)
+				if err := rows.Scan(
+					&name,
+					&title,
+					&owner,
+					&summary,
+					&category,
+					&downloads,
+					&thumbnail,
+					&releasedAt,
+					&version,
+					&factorioVersion,
+				); err != nil {
 					return fmt.Errorf("scan row: %w", err)
 				}
 
+				var thumbURL string
+				if thumbnail != "" && thumbnail != noThumbnail {
+					thumbURL = thumbnailURL(thumbnail)
+				}
+
 				relAt, err := time.Parse(time.RFC3339, releasedAt)
 				if err != nil {
 					return fmt.Errorf("parse released at timestamp: %w", err)
 				}
 
 				mm = append(mm, M{
-					Name:       name,
-					Versions:   []Version{parseVersion(version)},
-					ReleasedAt: relAt,
-					Summary:    summary,
-					Category:   category,
+					Name:            name,
+					Versions:        []Version{parseVersion(version)},
+					ReleasedAt:      relAt,
+					Summary:         summary,
+					Category:        category,
+					Downloads:       downloads,
+					Title:           title,
+					Owner:           owner,
+					ThumbnailURL:    thumbURL,
+					FactorioVersion: factorioVersion,
 				})
 			}
 
@@ -463,16 +1140,38 @@ type searchOptions struct {
 	// Options that apply to how term is used or interpreted.
 	nameOnly bool // Only attempt to match the search term to a mod's name.
 	isRegexp bool // Interpret term as a regular expression.
+	fullText bool // Interpret term as a full-text search query.
 
 	// Options that filter the results.
-	categories []Category // Limit the search term to these mod categories.
-
-	// Options that pertain to filtering.
-	sortByDate bool // Sort by released_at date, descending.
+	categories      []Category // Limit the search term to these mod categories.
+	tags            []string   // Only match mods with all of these tags.
+	licenses        []string   // Only match mods with one of these licenses, in lower case.
+	factorioVersion string     // Only match mods whose latest release supports this version of Factorio.
+
+	// Options that pertain to sorting.
+	sortBy searchSort
+
+	// Options that page through the results.
+	limit  int  // Return at most this many mods; 0 means no limit.
+	offset int  // Skip this many mods.
+	total  *int // When not nil, set to the number of matching mods.
 }
 
+// searchSort is the order of the results of a search.
+type searchSort int
+
+const (
+	sortDefault       searchSort = iota // Unordered, or by relevance for full-text searches.
+	sortDate                            // By released_at date, descending.
+	sortDateAscending                   // By released_at date, ascending.
+	sortName                            // By name, ascending.
+	sortDownloads                       // By downloads count, descending.
+)
+
 // NameOnly restricts the mod search to only match on a mod's name.
-// By default, a mod's name and summary will be considered.
+// By default, a mod's name, title, and summary will be considered.
+//
+// NameOnly has no effect on [FullText] searches.
 func NameOnly() SearchOption {
 	return func(o *searchOptions) error {
 		o.nameOnly = true
@@ -493,6 +1192,21 @@ func RegexpTerm() SearchOption {
 	}
 }
 
+// FullText tells [Cache.Search] to treat the search term as an SQLite [FTS5]
+// query, matched against each mod's name, title, summary, and description.
+// Results are ordered by relevance, unless [SortByDate] is also given.
+//
+// Descriptions are only available for mods whose details have been fetched
+// with [Cache.FullInfo].
+//
+// [FTS5]: https://www.sqlite.org/fts5.html#full_text_query_syntax
+func FullText() SearchOption {
+	return func(o *searchOptions) error {
+		o.fullText = true
+		return nil
+	}
+}
+
 // WithCategories limits the results of a search to only return mods with the
 // specified categories.
 func WithCategories(categories ...Category) SearchOption {
@@ -520,11 +1234,124 @@ func WithCategories(categories ...Category) SearchOption {
 	}
 }
 
+// WithTags limits the results of a search to mods that have all of the given
+// tags, like "trains" or "circuit-network".
+//
+// The mod portal only reports a mod's tags in its full details, so tags are
+// only known for mods whose details have been fetched with [Cache.FullInfo],
+// unless the mod list returned them.
+func WithTags(tags ...string) SearchOption {
+	return func(o *searchOptions) error {
+		for _, t := range tags {
+			if t == "" {
+				continue
+			}
+			o.tags = append(o.tags, t)
+		}
+		return nil
+	}
+}
+
+// WithLicenses limits the results of a search to mods that use one of the
+// given licenses.
+// Licenses are matched, ignoring case, against the license's ID, like
+// "default_mit", with or without the "default_" prefix, its name, and its
+// title, like "MIT".
+//
+// As with [WithTags], licenses are only known for mods whose details have
+// been fetched with [Cache.FullInfo]; see [Cache.Licenses].
+func WithLicenses(licenses ...string) SearchOption {
+	return func(o *searchOptions) error {
+		for _, l := range licenses {
+			if l == "" {
+				continue
+			}
+			o.licenses = append(o.licenses, strings.ToLower(l))
+		}
+		return nil
+	}
+}
+
+// ForFactorioVersion limits the results of a search to mods whose latest
+// release targets the given major version of Factorio, like "1.1".
+// By default, mods targeting Factorio 1.1 or later are returned.
+func ForFactorioVersion(version string) SearchOption {
+	return func(o *searchOptions) error {
+		o.factorioVersion = version
+		return nil
+	}
+}
+
 // SortByDate sorts the results by the date the latest version of the mod was
 // released, in descending order (most-recently-released mod first).
+//
+// When more than one sort option is given, the last one is used.
 func SortByDate() SearchOption {
 	return func(o *searchOptions) error {
-		o.sortByDate = true
+		o.sortBy = sortDate
+		return nil
+	}
+}
+
+// SortByDateAscending sorts the results by the date the latest version of the
+// mod was released, in ascending order (least-recently-released mod first).
+func SortByDateAscending() SearchOption {
+	return func(o *searchOptions) error {
+		o.sortBy = sortDateAscending
+		return nil
+	}
+}
+
+// SortByName sorts the results by the mods' names, in ascending order,
+// ignoring case.
+func SortByName() SearchOption {
+	return func(o *searchOptions) error {
+		o.sortBy = sortName
+		return nil
+	}
+}
+
+// SortByDownloads sorts the results by the number of times each mod has been
+// downloaded, in descending order (most-downloaded mod first).
+func SortByDownloads() SearchOption {
+	return func(o *searchOptions) error {
+		o.sortBy = sortDownloads
+		return nil
+	}
+}
+
+// WithLimit returns at most n mods from a search.
+// It is meant to be used with [WithOffset] and [WithTotal], to page through
+// large numbers of results.
+// When paging, mods that are equal under the sort option, or every mod when
+// no sort option is given, are ordered by name, so that pages do not
+// overlap.
+func WithLimit(n int) SearchOption {
+	return func(o *searchOptions) error {
+		if n < 0 {
+			return fmt.Errorf("negative limit: %d", n)
+		}
+		o.limit = n
+		return nil
+	}
+}
+
+// WithOffset skips the first n mods that match a search.
+func WithOffset(n int) SearchOption {
+	return func(o *searchOptions) error {
+		if n < 0 {
+			return fmt.Errorf("negative offset: %d", n)
+		}
+		o.offset = n
+		return nil
+	}
+}
+
+// WithTotal sets *total to the number of mods that match a search, before
+// [WithLimit] and [WithOffset] are applied.
+func WithTotal(total *int) SearchOption {
+	return func(o *searchOptions) error {
+		o.total = total
 		return nil
 	}
 }
@@ -0,0 +1,224 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Changelog is a mod's parsed changelog.txt file, with one section per
+// version of the mod, in the order they appear in the file, which is
+// usually newest first.
+//
+// [Mod changelog format]: https://wiki.factorio.com/Tutorial:Mod_changelog_format
+type Changelog []ChangelogSection
+
+// ChangelogSection holds the changes made in a single version of a mod.
+type ChangelogSection struct {
+	Version Version
+
+	// The release date, as written in the changelog; the format is not
+	// enforced by the game, so it is not parsed.
+	Date string
+
+	// Groups of changes, like "Features" or "Bugfixes", in the order they
+	// appear in the file.
+	Categories []ChangelogCategory
+}
+
+// ChangelogCategory is a group of changes within a [ChangelogSection].
+type ChangelogCategory struct {
+	Name string
+
+	// Each of the changes in the category.
+	// Changes that span several lines are joined with "\n".
+	Entries []string
+}
+
+// ParseChangelog parses the contents of a mod's changelog.txt file, which is
+// written in the [Mod changelog format].
+//
+// The parser is lenient about indentation and separator lines, since the
+// mod portal does not enforce the format; it only returns an error when a
+// version cannot be parsed, or when changes appear outside of a version's
+// section.
+//
+// [Mod changelog format]: https://wiki.factorio.com/Tutorial:Mod_changelog_format
+func ParseChangelog(s string) (Changelog, error) {
+	var (
+		cl      Changelog
+		section *ChangelogSection
+		cat     *ChangelogCategory
+		lineno  int
+	)
+	sc := bufio.NewScanner(strings.NewReader(s))
+	for sc.Scan() {
+		lineno++
+		line := strings.TrimSpace(sc.Text())
+
+		switch {
+		case line == "", strings.Trim(line, "-") == "":
+			// Blank lines, and the separators between versions.
+			continue
+
+		case strings.HasPrefix(line, "Version:"):
+			v, err := ParseVersion(strings.TrimSpace(strings.TrimPrefix(line, "Version:")))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineno, err)
+			}
+			cl = append(cl, ChangelogSection{Version: v})
+			section, cat = &cl[len(cl)-1], nil
+			continue
+		}
+
+		if section == nil {
+			return nil, fmt.Errorf("line %d: not in a version section", lineno)
+		}
+
+		switch {
+		case strings.HasPrefix(line, "Date:") && cat == nil:
+			section.Date = strings.TrimSpace(strings.TrimPrefix(line, "Date:"))
+
+		case strings.HasPrefix(line, "-"):
+			if cat == nil {
+				// Changes that are not in a category are put in an
+				// unnamed one.
+				section.Categories = append(section.Categories, ChangelogCategory{})
+				cat = &section.Categories[len(section.Categories)-1]
+			}
+			cat.Entries = append(cat.Entries, strings.TrimSpace(line[1:]))
+
+		case strings.HasSuffix(line, ":"):
+			section.Categories = append(section.Categories, ChangelogCategory{
+				Name: strings.TrimSpace(strings.TrimSuffix(line, ":")),
+			})
+			cat = &section.Categories[len(section.Categories)-1]
+
+		case cat != nil && len(cat.Entries) > 0:
+			// Continuation of the previous change.
+			cat.Entries[len(cat.Entries)-1] += "\n" + line
+
+		default:
+			return nil, fmt.Errorf("line %d: unexpected line %q", lineno, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return cl, nil
+}
+
+// Between returns the sections for versions after from, up to and including
+// to, which are the changes made by upgrading a mod from version from to
+// version to.
+// A zero to includes every version after from.
+func (cl Changelog) Between(from, to Version) Changelog {
+	var out Changelog
+	for _, s := range cl {
+		if s.Version.Compare(from) <= 0 {
+			continue
+		}
+		if !to.IsZero() && s.Version.Compare(to) > 0 {
+			continue
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// String returns the changelog in the [Mod changelog format].
+//
+// [Mod changelog format]: https://wiki.factorio.com/Tutorial:Mod_changelog_format
+func (cl Changelog) String() string {
+	var b strings.Builder
+	for _, s := range cl {
+		b.WriteString(strings.Repeat("-", 99))
+		fmt.Fprintf(&b, "\nVersion: %s\n", s.Version)
+		if s.Date != "" {
+			fmt.Fprintf(&b, "Date: %s\n", s.Date)
+		}
+		for _, c := range s.Categories {
+			if c.Name != "" {
+				fmt.Fprintf(&b, "  %s:\n", c.Name)
+			}
+			for _, e := range c.Entries {
+				fmt.Fprintf(&b, "    - %s\n", strings.ReplaceAll(e, "\n", "\n      "))
+			}
+		}
+	}
+	return b.String()
+}
+
+// Changelog returns the parsed changelog of the given version of the named
+// mod.
+//
+// When the release's archive has been downloaded into the cache's mods
+// directory, the changelog is read from the archive.
+// Otherwise, the changelog of the mod's latest release is retrieved with
+// [Cache.FullInfo], without the sections for versions newer than version.
+// A zero version returns the changelog of the latest release.
+//
+// Changelog returns an empty changelog, and no error, when the mod does not
+// have one.
+func (c *Cache) Changelog(ctx context.Context, name string, version Version) (Changelog, error) {
+	if !version.IsZero() {
+		cl, err := c.archiveChangelog(name, version)
+		if err == nil {
+			return cl, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	info, err := c.FullInfo(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("get mod info: %w", err)
+	}
+	cl, err := ParseChangelog(info.Changelog)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s changelog: %w", name, err)
+	}
+	if !version.IsZero() {
+		cl = cl.Between(Version{}, version)
+	}
+	return cl, nil
+}
+
+// archiveChangelog reads the changelog out of a release's archive in the
+// cache's mods directory.
+// The returned error wraps [fs.ErrNotExist] when the archive has not been
+// downloaded; an archive without a changelog returns an empty changelog.
+func (c *Cache) archiveChangelog(name string, version Version) (Changelog, error) {
+	path := filepath.Join(c.ModsDir(), Info{Name: name, Version: version.String()}.FileName())
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	a, err := OpenArchive(path)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	defer a.Close()
+
+	s, err := a.Changelog()
+	if errors.Is(err, fs.ErrNotExist) {
+		return Changelog{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read %s changelog: %w", name, err)
+	}
+
+	cl, err := ParseChangelog(s)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s changelog: %w", name, err)
+	}
+	return cl, nil
+}
@@ -0,0 +1,289 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// CacheProblemKind identifies the kind of a [CacheProblem].
+type CacheProblemKind string
+
+const (
+	// The cache database failed SQLite's integrity check.
+	ProblemCorruptDB CacheProblemKind = "corrupt-database"
+
+	// An archive in the cache's mods directory was not downloaded by the
+	// cache, so the cache database does not know about it.
+	// Archives downloaded by versions of facmod that did not record
+	// downloads are also reported as orphaned.
+	ProblemOrphaned CacheProblemKind = "orphaned"
+
+	// An archive in the cache's mods directory cannot be opened, usually
+	// because its download was cut short.
+	ProblemTruncated CacheProblemKind = "truncated"
+
+	// An archive that the cache database records as downloaded is missing
+	// from the cache's mods directory.
+	ProblemMissing CacheProblemKind = "missing"
+)
+
+// CacheProblem is a problem with the cache, found by [Cache.Check].
+type CacheProblem struct {
+	Kind CacheProblemKind `json:"kind"`
+
+	// Path to the archive the problem is about, or to the cache database
+	// for a [ProblemCorruptDB].
+	Path string `json:"path"`
+
+	// Detail describes the problem, such as the error returned when
+	// opening an archive.
+	Detail string `json:"detail,omitempty"`
+}
+
+func (p CacheProblem) String() string {
+	if p.Detail == "" {
+		return fmt.Sprintf("%s: %s", p.Path, p.Kind)
+	}
+	return fmt.Sprintf("%s: %s: %s", p.Path, p.Kind, p.Detail)
+}
+
+// Check looks for problems with the cache database, and the mod archives in
+// the cache's mods directory, which can be fixed with [Cache.Repair].
+//
+// The database is checked with SQLite's "integrity_check" pragma.
+// Every archive in the mods directory is opened, to make sure that it is a
+// readable zip file, and is compared against the archives the cache has
+// recorded downloading with [Cache.Download], or importing with
+// [Cache.Import].
+func (c *Cache) Check(ctx context.Context) ([]CacheProblem, error) {
+	var problems []CacheProblem
+
+	rows, err := c.db.QueryContext(ctx, `PRAGMA integrity_check`)
+	if err != nil {
+		return nil, fmt.Errorf("check database integrity: %w", err)
+	}
+	var messages []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan integrity check: %w", err)
+		}
+		if msg != "ok" {
+			messages = append(messages, msg)
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return nil, fmt.Errorf("check database integrity: %w", err)
+	}
+	if len(messages) > 0 {
+		problems = append(problems, CacheProblem{
+			Kind:   ProblemCorruptDB,
+			Path:   filepath.Join(c.dir, "mods.db"),
+			Detail: strings.Join(messages, "; "),
+		})
+	}
+
+	downloaded, err := c.downloads(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	archives, err := filepath.Glob(filepath.Join(c.ModsDir(), "*.zip"))
+	if err != nil {
+		return nil, fmt.Errorf("glob: %w", err)
+	}
+	for _, p := range archives {
+		base := filepath.Base(p)
+		recorded := slices.Contains(downloaded, base)
+		if recorded {
+			downloaded = slices.DeleteFunc(downloaded, func(s string) bool { return s == base })
+		}
+
+		zr, err := zip.OpenReader(p)
+		if err != nil {
+			problems = append(problems, CacheProblem{Kind: ProblemTruncated, Path: p, Detail: err.Error()})
+			continue
+		}
+		zr.Close()
+
+		if !recorded {
+			problems = append(problems, CacheProblem{
+				Kind:   ProblemOrphaned,
+				Path:   p,
+				Detail: "not downloaded by the cache",
+			})
+		}
+	}
+
+	for _, base := range downloaded {
+		problems = append(problems, CacheProblem{
+			Kind:   ProblemMissing,
+			Path:   filepath.Join(c.ModsDir(), base),
+			Detail: "downloaded, but no longer in the cache",
+		})
+	}
+
+	return problems, nil
+}
+
+// downloads returns the file names of the archives the cache has recorded
+// downloading.
+func (c *Cache) downloads(ctx context.Context) ([]string, error) {
+	rows, err := c.db.QueryContext(ctx, `SELECT file_name FROM downloads ORDER BY file_name`)
+	if err != nil {
+		return nil, fmt.Errorf("query downloads: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scan download: %w", err)
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// RepairOptions control how [Cache.Repair] fixes problems.
+type RepairOptions struct {
+	// Download truncated and missing archives again.
+	// Otherwise, truncated archives are removed, and missing archives are
+	// forgotten.
+	Redownload bool
+
+	// Credentials used for downloading archives again.
+	Username, Token string
+}
+
+// Repair fixes a problem found by [Cache.Check]:
+//
+//   - A corrupt database is moved aside, to "mods.db.corrupt", and replaced
+//     with an empty one, which needs to be filled with [Cache.Update].
+//     Since the empty database does not know about any archives, Check
+//     should be run again afterwards.
+//   - An orphaned archive is kept, and recorded as downloaded, when its
+//     SHA1 matches the release the cache knows about, or when the release
+//     is unknown, and the archive's info.json matches its file name.
+//     Otherwise, it is removed.
+//   - A truncated archive is removed, and downloaded again if
+//     opts.Redownload is set.
+//   - A missing archive is downloaded again if opts.Redownload is set, or
+//     forgotten otherwise.
+func (c *Cache) Repair(ctx context.Context, p CacheProblem, opts RepairOptions) error {
+	switch p.Kind {
+	case ProblemCorruptDB:
+		return c.rebuildDB()
+
+	case ProblemOrphaned:
+		ok, err := c.adoptable(ctx, p.Path)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return c.recordDownload(ctx, p.Path)
+		}
+		if err := os.Remove(p.Path); err != nil {
+			return fmt.Errorf("remove orphaned archive: %w", err)
+		}
+		return nil
+
+	case ProblemTruncated, ProblemMissing:
+		if err := os.Remove(p.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("remove archive: %w", err)
+		}
+		if !opts.Redownload {
+			return c.forgetDownload(ctx, p.Path)
+		}
+
+		// The download is still recorded if it fails, so that Check
+		// reports the archive as missing.
+		mp := modpath(p.Path)
+		if _, err := c.GetVersion(ctx, mp.name(), mp.version().String(), opts.Username, opts.Token); err != nil {
+			return fmt.Errorf("download %s again: %w", filepath.Base(p.Path), err)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown cache problem: %q", p.Kind)
+}
+
+// adoptable reports whether an orphaned archive is a release of the mod its
+// file name says it is.
+func (c *Cache) adoptable(ctx context.Context, path string) (bool, error) {
+	want, err := c.knownSHA1(ctx, filepath.Base(path))
+	if err != nil {
+		return false, err
+	}
+	if want != "" {
+		have, err := fileSHA1(path)
+		if err != nil {
+			return false, err
+		}
+		return strings.EqualFold(have, want), nil
+	}
+
+	a, err := OpenArchive(path)
+	if err != nil {
+		return false, nil
+	}
+	defer a.Close()
+	info, err := a.Info()
+	if err != nil {
+		return false, nil
+	}
+	return info.FileName() == filepath.Base(path), nil
+}
+
+// knownSHA1 returns the SHA1 of the release with the given archive file
+// name, as recorded in the cache database, without making any requests to
+// the mod portal.
+// An empty string is returned if the release is not in the database.
+func (c *Cache) knownSHA1(ctx context.Context, fileName string) (string, error) {
+	var sha1 string
+	err := c.db.QueryRowContext(ctx,
+		`SELECT sha1 FROM latest_releases WHERE file_name = ?1 UNION ALL SELECT sha1 FROM releases WHERE file_name = ?1 LIMIT 1`,
+		fileName,
+	).Scan(&sha1)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("query release: %w", err)
+	}
+	return sha1, nil
+}
+
+// rebuildDB moves a corrupt cache database aside, and replaces it with an
+// empty one.
+func (c *Cache) rebuildDB() error {
+	return c.withLock(func() error {
+		dbPath := filepath.Join(c.dir, "mods.db")
+		if err := c.db.Close(); err != nil {
+			return fmt.Errorf("close database: %w", err)
+		}
+		if err := os.Rename(dbPath, dbPath+".corrupt"); err != nil {
+			return fmt.Errorf("move corrupt database: %w", err)
+		}
+
+		db, err := openCacheDB(dbPath)
+		if err != nil {
+			return err
+		}
+		c.db = db
+		return nil
+	})
+}
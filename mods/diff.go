@@ -0,0 +1,139 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ChangeKind describes how an installed mod differs from a [Lockfile].
+type ChangeKind int
+
+const (
+	Add       ChangeKind = iota // The mod is not installed.
+	Remove                      // The mod is installed, but not locked.
+	Upgrade                     // The locked version is newer than the installed one.
+	Downgrade                   // The locked version is older than the installed one.
+	Modified                    // The installed archive does not match the locked SHA1.
+	Enable                      // The mod is disabled, but locked as enabled.
+	Disable                     // The mod is enabled, but locked as disabled.
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Add:
+		return "add"
+	case Remove:
+		return "remove"
+	case Upgrade:
+		return "upgrade"
+	case Downgrade:
+		return "downgrade"
+	case Modified:
+		return "modified"
+	case Enable:
+		return "enable"
+	case Disable:
+		return "disable"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (k ChangeKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Change is a single difference between an installation and a [Lockfile].
+type Change struct {
+	Name string
+	Kind ChangeKind
+
+	// The installed and locked versions of the mod.
+	// From is the zero value for added mods, and To is the zero value for
+	// removed mods.
+	From Version
+	To   Version
+}
+
+// Diff compares the mods installed to installDir with the lockfile, and
+// returns the changes that [Lockfile.Sync] would make, sorted by name.
+// Diff returns an empty slice when the installation matches the lockfile.
+//
+// Entries without a version, such as the built-in mods recorded in a
+// [Manifest], are only checked for whether they are enabled, and entries
+// without a SHA1 are not hashed.
+func (l *Lockfile) Diff(installDir string) ([]Change, error) {
+	installed, err := Load(installDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("load mods: %w", err)
+	}
+
+	current := make(map[string]M, len(installed))
+	for _, m := range installed {
+		current[m.Name] = m
+	}
+
+	var changes []Change
+	locked := make(map[string]bool, len(l.Mods))
+	for _, lm := range l.Mods {
+		locked[lm.Name] = true
+
+		m, ok := current[lm.Name]
+		var have Version
+		if n := len(m.Versions); n > 0 {
+			have = m.Versions[n-1]
+		}
+
+		if !lm.Version.IsZero() {
+			switch c := have.Compare(lm.Version); {
+			case have.IsZero():
+				changes = append(changes, Change{Name: lm.Name, Kind: Add, To: lm.Version})
+				continue
+			case c < 0:
+				changes = append(changes, Change{Name: lm.Name, Kind: Upgrade, From: have, To: lm.Version})
+			case c > 0:
+				changes = append(changes, Change{Name: lm.Name, Kind: Downgrade, From: have, To: lm.Version})
+			case lm.SHA1 != "":
+				sum, err := fileSHA1(filepath.Join(installDir, "mods", lm.FileName()))
+				if err != nil {
+					return nil, fmt.Errorf("hash %s: %w", lm.FileName(), err)
+				}
+				if !strings.EqualFold(sum, lm.SHA1) {
+					changes = append(changes, Change{Name: lm.Name, Kind: Modified, From: have, To: lm.Version})
+				}
+			}
+		}
+
+		switch {
+		case !ok:
+			if lm.Version.IsZero() {
+				changes = append(changes, Change{Name: lm.Name, Kind: Add})
+			}
+		case lm.Enabled && !m.Enabled:
+			changes = append(changes, Change{Name: lm.Name, Kind: Enable, From: have, To: lm.Version})
+		case !lm.Enabled && m.Enabled:
+			changes = append(changes, Change{Name: lm.Name, Kind: Disable, From: have, To: lm.Version})
+		}
+	}
+
+	for _, m := range installed {
+		n := len(m.Versions)
+		if locked[m.Name] || n == 0 {
+			continue
+		}
+		changes = append(changes, Change{Name: m.Name, Kind: Remove, From: m.Versions[n-1]})
+	}
+
+	slices.SortStableFunc(changes, func(a, b Change) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return changes, nil
+}
@@ -0,0 +1,729 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nesv/factorio-tools/httputil"
+)
+
+// Credentials are the factorio.com credentials required for downloading mods
+// from the [Mod portal API].
+//
+// [Mod portal API]: https://wiki.factorio.com/Mod_portal_API
+type Credentials struct {
+	Username string `json:"service-username"`
+	Token    string `json:"service-token"`
+}
+
+// LoadCredentials reads the service username and token from a Factorio
+// "player-data.json" file.
+func LoadCredentials(playerDataPath string) (Credentials, error) {
+	f, err := os.Open(playerDataPath)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("open player data: %w", err)
+	}
+	defer f.Close()
+
+	var creds Credentials
+	if err := json.NewDecoder(f).Decode(&creds); err != nil {
+		return Credentials{}, fmt.Errorf("decode json: %w", err)
+	}
+	return creds, nil
+}
+
+// ModsDir returns the path to the directory that downloaded mods are stored
+// in.
+func (c *Cache) ModsDir() string {
+	return filepath.Join(c.dir, "mods")
+}
+
+// LatestRelease returns the latest release of the named mod, as recorded in
+// the cache database by [Cache.Update].
+func (c *Cache) LatestRelease(ctx context.Context, name string) (Release, error) {
+	defer c.observeQuery(time.Now())
+
+	var (
+		r          Release
+		infoJSON   string
+		releasedAt string
+	)
+	err := c.db.QueryRowContext(ctx,
+		`SELECT download_url, file_name, info_json, released_at, version, sha1 FROM latest_releases WHERE name = ?`,
+		name,
+	).Scan(&r.DownloadURL, &r.FileName, &infoJSON, &releasedAt, &r.Version, &r.SHA1)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Release{}, fmt.Errorf("%w: %s", ErrNotCached, name)
+	} else if err != nil {
+		return Release{}, fmt.Errorf("query latest release: %w", err)
+	}
+
+	r.InfoJSON = json.RawMessage(infoJSON)
+	if r.ReleasedAt, err = time.Parse(time.RFC3339, releasedAt); err != nil {
+		return Release{}, fmt.Errorf("parse released at timestamp: %w", err)
+	}
+
+	return r, nil
+}
+
+// Get downloads the latest release of the named mod into the cache's mods
+// directory, and returns the path to the downloaded file.
+// If the release has already been downloaded, the path to the previously
+// downloaded file is returned.
+// Files are checked against the release's SHA1 checksum, as described by
+// [Cache.Download].
+//
+// The latest release is looked up in the cache database, so [Cache.Update]
+// must have been called at least once.
+func (c *Cache) Get(ctx context.Context, name, username, token string) (string, error) {
+	r, err := c.LatestRelease(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return c.Download(ctx, r, username, token)
+}
+
+// GetVersion downloads a specific release of the named mod into the cache's
+// mods directory, alongside the latest releases downloaded by [Cache.Get],
+// and returns the path to the downloaded file.
+// If the release has already been downloaded, the path to the previously
+// downloaded file is returned.
+//
+// The release is looked up in the cache's release history.
+// When it is not there, the mod's releases are retrieved with
+// [Cache.ShortInfo].
+func (c *Cache) GetVersion(ctx context.Context, name, version, username, token string) (string, error) {
+	r, err := c.queryRelease(ctx, name, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		r, err = c.shortRelease(ctx, name, version)
+	}
+	if err != nil {
+		return "", err
+	}
+	return c.Download(ctx, r, username, token)
+}
+
+// getAllConcurrency is the number of downloads [Cache.GetAll] runs at once.
+const getAllConcurrency = 4
+
+// DownloadResult is the result of downloading one mod with [Cache.GetAll].
+type DownloadResult struct {
+	Name    string
+	Version Version
+
+	// Path to the downloaded file, when Err is nil.
+	Path string
+	Err  error
+}
+
+// GetAll downloads every mod in plan into the cache's mods directory,
+// several at a time, and returns the result of each download, in the same
+// order as plan.
+// A failed download does not stop the others; check each result's Err.
+//
+// Mods are downloaded with [Cache.Download] when their Release is set, and
+// with [Cache.GetVersion] otherwise, like the mods [Cache.Resolve] reports as
+// already installed.
+// Callers that only want to download mods that are not installed yet should
+// leave those out of plan.
+func (c *Cache) GetAll(ctx context.Context, plan []Resolved, username, token string) []DownloadResult {
+	results := make([]DownloadResult, len(plan))
+	sem := make(chan struct{}, getAllConcurrency)
+	var wg sync.WaitGroup
+	for i, m := range plan {
+		results[i] = DownloadResult{Name: m.Name, Version: m.Version}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[i].Err = ctx.Err()
+				return
+			}
+
+			var path string
+			var err error
+			if m.Release.DownloadURL != "" {
+				path, err = c.Download(ctx, m.Release, username, token)
+			} else {
+				path, err = c.GetVersion(ctx, m.Name, m.Version.String(), username, token)
+			}
+			results[i].Path, results[i].Err = path, err
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// shortRelease returns the release of the named mod with the given version,
+// using [Cache.ShortInfo].
+func (c *Cache) shortRelease(ctx context.Context, name, version string) (Release, error) {
+	info, err := c.ShortInfo(ctx, name)
+	if err != nil {
+		return Release{}, fmt.Errorf("get mod info: %w", err)
+	}
+	for _, r := range info.Releases {
+		if sameVersion(r.Version, version) {
+			return r, nil
+		}
+	}
+	return Release{}, fmt.Errorf("%w: no release of %s with version %s", ErrReleaseNotFound, name, version)
+}
+
+// Download downloads the given release into the cache's mods directory, and
+// returns the path to the downloaded file.
+// If the release has already been downloaded, no request is made, and the
+// path to the previously-downloaded file is returned.
+//
+// Downloaded files are checked against the release's SHA1 checksum, when it
+// is known, and so are previously-downloaded files; a previously-downloaded
+// file that does not match is deleted, and downloaded again.
+// An error wrapping [ErrChecksumMismatch] is returned when the downloaded
+// file does not match.
+//
+// An error wrapping [ErrAuthFailed] is returned when the mod portal rejects
+// the username and token, unless a password was set with [Cache.SetLogin],
+// and logging in again succeeds.
+func (c *Cache) Download(ctx context.Context, r Release, username, token string) (string, error) {
+	if r.FileName == "" || r.DownloadURL == "" {
+		return "", errors.New("release is missing a file name or download url")
+	}
+
+	dir := c.ModsDir()
+	if err := os.MkdirAll(dir, fs.ModePerm); err != nil {
+		return "", fmt.Errorf("make directory %q: %w", dir, err)
+	}
+
+	dst := filepath.Join(dir, filepath.Base(r.FileName))
+
+	// Protect the archive from the retention policy applied after other
+	// downloads, before it is even in place, since the caller is about
+	// to use it.
+	c.handOut(dst)
+
+	if info, err := os.Stat(dst); err == nil && info.Mode().IsRegular() {
+		if r.SHA1 == "" {
+			add(c.getMetrics().CacheHits, 1)
+			return dst, c.recordDownload(ctx, dst)
+		}
+		sum, err := fileSHA1(dst)
+		if err != nil {
+			return "", err
+		}
+		if strings.EqualFold(sum, r.SHA1) {
+			add(c.getMetrics().CacheHits, 1)
+			return dst, c.recordDownload(ctx, dst)
+		}
+		slog.WarnContext(ctx, "removing corrupt download", "file", dst, "sha1", sum, "want", r.SHA1)
+		if err := os.Remove(dst); err != nil {
+			return "", fmt.Errorf("remove corrupt download: %w", err)
+		}
+	}
+
+	add(c.getMetrics().CacheMisses, 1)
+
+	var errs []error
+	for _, m := range c.getMirrors() {
+		urlStr, err := url.JoinPath(m.URL, filepath.Base(r.FileName))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("mirror %s: %w", m.URL, err))
+			continue
+		}
+		if err := downloadFile(ctx, httputil.GetHeader, dst, urlStr, m.Headers, Checksums{SHA1: r.SHA1}); err != nil {
+			slog.WarnContext(ctx, "download from mirror failed", "mirror", m.URL, "file", r.FileName, "err", err)
+			errs = append(errs, fmt.Errorf("mirror %s: %w", m.URL, err))
+			continue
+		}
+		return dst, c.downloaded(ctx, dst)
+	}
+
+	c.mu.Lock()
+	if c.refreshed.Token != "" {
+		username, token = c.refreshed.Username, c.refreshed.Token
+	}
+	canLogin := c.login.password != ""
+	c.mu.Unlock()
+
+	err := c.portalClient().Download(ctx, r, dst, username, token)
+	if canLogin && (errors.Is(err, ErrAuthFailed) || errors.Is(err, ErrAuthRequired)) {
+		slog.InfoContext(ctx, "logging in to factorio.com again", "err", err)
+		creds, lerr := c.relogin(ctx, token)
+		if lerr != nil {
+			err = fmt.Errorf("%w; log in again: %w", err, lerr)
+		} else {
+			err = c.portalClient().Download(ctx, r, dst, creds.Username, creds.Token)
+		}
+	}
+	if err != nil {
+		errs = append(errs, fmt.Errorf("download %s: %w", r.FileName, err))
+		return "", errors.Join(errs...)
+	}
+
+	return dst, c.downloaded(ctx, dst)
+}
+
+// loginCredentials are the factorio.com username and password set with
+// [Cache.SetLogin].
+type loginCredentials struct {
+	username, password string
+}
+
+// SetLogin sets the factorio.com username (or email address) and password
+// that [Cache.Download] uses to get a new token with [Login], and retry the
+// download once, when the mod portal rejects the token it was given, or when
+// it was not given one.
+// Accounts that require an email authentication code cannot log in this way.
+//
+// The new token is used for all later downloads, and is returned by
+// [Cache.RefreshedCredentials], so it can be saved.
+func (c *Cache) SetLogin(username, password string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.login = loginCredentials{username: username, password: password}
+}
+
+// RefreshedCredentials returns the credentials [Cache.Download] got by
+// logging in again, and whether it had to.
+func (c *Cache) RefreshedCredentials() (Credentials, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.refreshed, c.refreshed.Token != ""
+}
+
+// relogin logs in to factorio.com with the credentials set by
+// [Cache.SetLogin], after the mod portal rejected the token.
+// When another download has already logged in again since, the new token is
+// returned without logging in again.
+func (c *Cache) relogin(ctx context.Context, rejected string) (Credentials, error) {
+	c.loginMu.Lock()
+	defer c.loginMu.Unlock()
+
+	c.mu.Lock()
+	login, refreshed := c.login, c.refreshed
+	c.mu.Unlock()
+	if refreshed.Token != "" && refreshed.Token != rejected {
+		return refreshed, nil
+	}
+
+	creds, err := Login(ctx, login.username, login.password, "")
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	c.mu.Lock()
+	c.refreshed = creds
+	c.mu.Unlock()
+	return creds, nil
+}
+
+// downloaded records a newly-downloaded archive, and removes older archives
+// according to the retention policy set with [Cache.SetRetention].
+func (c *Cache) downloaded(ctx context.Context, path string) error {
+	c.countDownload(path)
+	if err := c.recordDownload(ctx, path); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	policy := c.retention
+	c.mu.Unlock()
+	if policy == (PruneOptions{}) {
+		return nil
+	}
+
+	c.mu.Lock()
+	keep := maps.Clone(c.handedOut)
+	c.mu.Unlock()
+
+	result, err := c.prune(ctx, policy, keep)
+	if err != nil {
+		// The download itself succeeded, so only warn.
+		slog.WarnContext(ctx, "cannot apply cache retention policy", "err", err)
+		return nil
+	}
+	if result.Files > 0 {
+		slog.DebugContext(ctx, "pruned downloaded mods", "files", result.Files, "bytes", result.Bytes)
+	}
+
+	c.mu.Lock()
+	c.pruned.Files += result.Files
+	c.pruned.Bytes += result.Bytes
+	c.mu.Unlock()
+	return nil
+}
+
+// handOut records that [Cache.Download] is returning the archive at path, so
+// that the retention policy does not remove it.
+func (c *Cache) handOut(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.handedOut == nil {
+		c.handedOut = make(map[string]bool)
+	}
+	c.handedOut[path] = true
+}
+
+// downloadTimes returns when each archive recorded by [Cache.recordDownload]
+// was last downloaded or used, by file name.
+func (c *Cache) downloadTimes(ctx context.Context) (map[string]time.Time, error) {
+	rows, err := c.db.QueryContext(ctx, `SELECT file_name, downloaded_at FROM downloads`)
+	if err != nil {
+		return nil, fmt.Errorf("query downloads: %w", err)
+	}
+	defer rows.Close()
+
+	times := make(map[string]time.Time)
+	for rows.Next() {
+		var name string
+		var at sql.NullString
+		if err := rows.Scan(&name, &at); err != nil {
+			return nil, fmt.Errorf("scan download: %w", err)
+		}
+		if t, err := time.Parse(time.RFC3339, at.String); err == nil {
+			times[name] = t
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query downloads: %w", err)
+	}
+	return times, nil
+}
+
+// recordDownload records that the archive at path is in the cache's mods
+// directory, and when it was last downloaded or used, for [Cache.Check] and
+// the retention policy.
+func (c *Cache) recordDownload(ctx context.Context, path string) error {
+	if _, err := c.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO downloads (file_name, downloaded_at) VALUES (?, ?)`,
+		filepath.Base(path), time.Now().UTC().Format(time.RFC3339),
+	); err != nil {
+		return fmt.Errorf("record download of %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// forgetDownload removes the record of an archive that was removed from the
+// cache's mods directory.
+func (c *Cache) forgetDownload(ctx context.Context, path string) error {
+	if _, err := c.db.ExecContext(ctx, `DELETE FROM downloads WHERE file_name = ?`, filepath.Base(path)); err != nil {
+		return fmt.Errorf("forget download of %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// Mirror is a server that hosts copies of mod archives, which [Cache.Download]
+// tries before downloading mods from the mod portal.
+// Archives are expected to be served at URL/FILENAME, where FILENAME is the
+// archive's name on the mod portal, like "flib_0.12.9.zip".
+type Mirror struct {
+	URL string `json:"url"`
+
+	// Headers are added to every request to the mirror, and can be used
+	// to authenticate with it, for example with an "Authorization" header.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// SetMirrors sets the mirrors [Cache.Download] tries, in order, before falling
+// back to the mod portal.
+// Archives downloaded from a mirror are checked against the SHA1 published
+// by the mod portal, when it is known.
+func (c *Cache) SetMirrors(mirrors ...Mirror) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mirrors = mirrors
+}
+
+func (c *Cache) getMirrors() []Mirror {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.mirrors
+}
+
+// Checksums are the expected hex-encoded hashes of a downloaded file.
+// Empty fields are not checked.
+type Checksums struct {
+	SHA1   string
+	SHA256 string
+}
+
+// check returns a non-nil error when the SHA1 or SHA256 hash of a file does
+// not match the corresponding checksum.
+func (c Checksums) check(sha1sum, sha256sum string) error {
+	if c.SHA1 != "" && !strings.EqualFold(sha1sum, c.SHA1) {
+		return fmt.Errorf("sha1 %w: have %s, want %s", ErrChecksumMismatch, sha1sum, c.SHA1)
+	}
+	if c.SHA256 != "" && !strings.EqualFold(sha256sum, c.SHA256) {
+		return fmt.Errorf("sha256 %w: have %s, want %s", ErrChecksumMismatch, sha256sum, c.SHA256)
+	}
+	return nil
+}
+
+// DownloadFile downloads the file at urlStr to dst, for mods that are
+// distributed outside of the mod portal.
+// The downloaded file must match sums; otherwise, dst is left untouched.
+func DownloadFile(ctx context.Context, dst, urlStr string, sums Checksums) error {
+	return downloadFile(ctx, httputil.GetHeader, dst, urlStr, nil, sums)
+}
+
+// getHeaderFunc issues a GET request to a URL, with the given headers added
+// to the request, like [httputil.GetHeader].
+type getHeaderFunc func(ctx context.Context, urlStr string, header http.Header) (*http.Response, error)
+
+// downloadFile downloads urlStr to dst with get, setting the given headers on
+// the request.
+// The downloaded file must match sums.
+// The file is written to a temporary file first, so an interrupted download
+// never leaves a partial file at dst.
+// Downloads that are cut short by a network error are started over, as
+// [httputil.Retry] allows.
+func downloadFile(ctx context.Context, get getHeaderFunc, dst, urlStr string, headers map[string]string, sums Checksums) error {
+	header := make(http.Header, len(headers))
+	for k, v := range headers {
+		header.Set(k, v)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".download-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h1, h256 := sha1.New(), sha256.New()
+	if err := httputil.Retry(ctx, func(ctx context.Context) error {
+		if err := tmp.Truncate(0); err != nil {
+			return fmt.Errorf("truncate temp file: %w", err)
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("seek temp file: %w", err)
+		}
+		h1.Reset()
+		h256.Reset()
+
+		resp, err := get(ctx, urlStr, header)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return fmt.Errorf("%w: %s", ErrAuthFailed, resp.Status)
+		default:
+			return errors.New(resp.Status)
+		}
+
+		if _, err := io.Copy(io.MultiWriter(tmp, h1, h256), resp.Body); err != nil {
+			return fmt.Errorf("write %s: %w", filepath.Base(dst), err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+
+	if err := sums.check(hex.EncodeToString(h1.Sum(nil)), hex.EncodeToString(h256.Sum(nil))); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return fmt.Errorf("rename temp file: %w", err)
+	}
+	return nil
+}
+
+// PruneOptions control which downloaded mods are removed by
+// [Cache.PruneDownloads], and by the retention policy set with
+// [Cache.SetRetention].
+// When multiple options are set, an archive is removed if any of them apply.
+type PruneOptions struct {
+	// Remove all downloaded mods.
+	All bool
+
+	// Remove archives that were downloaded more than MaxAge ago.
+	// Zero disables this option.
+	MaxAge time.Duration
+
+	// Only keep the newest Keep versions of each mod.
+	// Zero disables this option.
+	Keep int
+
+	// Remove the least-recently downloaded archives, until the archives
+	// left take up at most MaxSize bytes.
+	// Zero disables this option.
+	MaxSize int64
+}
+
+// PruneResult reports what was removed by [Cache.PruneDownloads].
+type PruneResult struct {
+	Files int   // Number of archives removed.
+	Bytes int64 // Total size of the removed archives.
+}
+
+// SetRetention sets the policy that is applied to the cache's mods directory
+// every time [Cache.Download] downloads an archive, so that the cache does
+// not grow without bound.
+// Archives that [Cache.Download] has returned since the cache was opened,
+// including the one that was just downloaded, are never removed.
+// Archives are aged by when they were last downloaded or returned by
+// [Cache.Download], not by their modification times.
+// The zero PruneOptions, which is the default, keeps every archive.
+//
+// Use [Cache.Pruned] to find out how much space the policy has reclaimed.
+func (c *Cache) SetRetention(policy PruneOptions) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.retention = policy
+}
+
+// Pruned returns the total number of archives, and bytes, removed by the
+// retention policy set with [Cache.SetRetention], since the cache was
+// opened.
+func (c *Cache) Pruned() PruneResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pruned
+}
+
+// PruneDownloads removes mod archives from the cache's mods directory,
+// according to opts.
+func (c *Cache) PruneDownloads(opts PruneOptions) (PruneResult, error) {
+	return c.prune(context.Background(), opts, nil)
+}
+
+// prune implements [Cache.PruneDownloads], without removing the archives in
+// keep.
+// Archives are aged by when they were last downloaded, as recorded by
+// [Cache.recordDownload], or by their modification times when there is no
+// record of them.
+func (c *Cache) prune(ctx context.Context, opts PruneOptions, keep map[string]bool) (PruneResult, error) {
+	var result PruneResult
+
+	archives, err := filepath.Glob(filepath.Join(c.ModsDir(), "*.zip"))
+	if err != nil {
+		return result, fmt.Errorf("glob: %w", err)
+	}
+
+	downloadedAt, err := c.downloadTimes(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	infos := make(map[string]fs.FileInfo, len(archives))
+	ages := make(map[string]time.Time, len(archives))
+	for _, a := range archives {
+		info, err := os.Stat(a)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Removed by a concurrent prune.
+			continue
+		} else if err != nil {
+			return result, fmt.Errorf("stat %s: %w", a, err)
+		}
+		infos[a] = info
+		ages[a] = info.ModTime()
+		if t, ok := downloadedAt[filepath.Base(a)]; ok {
+			ages[a] = t
+		}
+	}
+
+	remove := func(p string) error {
+		if err := os.Remove(p); errors.Is(err, fs.ErrNotExist) {
+			delete(infos, p)
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("remove %s: %w", p, err)
+		}
+		if err := c.forgetDownload(ctx, p); err != nil {
+			return err
+		}
+		result.Files++
+		result.Bytes += infos[p].Size()
+		delete(infos, p)
+		return nil
+	}
+
+	// Group the archives by mod, newest version first.
+	byName := make(map[string][]string)
+	for a := range infos {
+		name := modpath(a).name()
+		byName[name] = append(byName[name], a)
+	}
+
+	now := time.Now()
+	for _, paths := range byName {
+		slices.SortFunc(paths, func(a, b string) int {
+			return modpath(b).version().Compare(modpath(a).version())
+		})
+
+		for i, p := range paths {
+			if keep[p] {
+				continue
+			}
+			if opts.All ||
+				(opts.MaxAge > 0 && now.Sub(ages[p]) > opts.MaxAge) ||
+				(opts.Keep > 0 && i >= opts.Keep) {
+				if err := remove(p); err != nil {
+					return result, err
+				}
+			}
+		}
+	}
+
+	if opts.MaxSize <= 0 {
+		return result, nil
+	}
+
+	// Remove the least-recently downloaded archives first.
+	var total int64
+	remaining := make([]string, 0, len(infos))
+	for p, info := range infos {
+		total += info.Size()
+		remaining = append(remaining, p)
+	}
+	slices.SortFunc(remaining, func(a, b string) int {
+		return ages[a].Compare(ages[b])
+	})
+	for _, p := range remaining {
+		if total <= opts.MaxSize {
+			break
+		}
+		if keep[p] {
+			continue
+		}
+		size := infos[p].Size()
+		if err := remove(p); err != nil {
+			return result, err
+		}
+		total -= size
+	}
+
+	return result, nil
+}
@@ -0,0 +1,40 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import "errors"
+
+// Errors that callers may want to handle differently from other failures.
+// Functions in this package wrap these errors with more detail, so use
+// [errors.Is] to check for them.
+var (
+	// ErrModNotFound is returned when the mod portal has no mod with
+	// the requested name.
+	ErrModNotFound = errors.New("mod not found")
+
+	// ErrReleaseNotFound is returned when a mod has no release with the
+	// requested version, or no release that satisfies a set of
+	// constraints.
+	ErrReleaseNotFound = errors.New("release not found")
+
+	// ErrNotCached is returned when a mod is not in the cache database,
+	// either because it is not on the mod portal, or because the cache
+	// has not been updated since it was published.
+	ErrNotCached = errors.New("mod not in cache")
+
+	// ErrAuthRequired is returned when downloading a mod from the mod
+	// portal without a factorio.com username and token, or using an
+	// endpoint that requires an API key without one.
+	ErrAuthRequired = errors.New("factorio.com credentials required")
+
+	// ErrAuthFailed is returned when the mod portal rejects a
+	// factorio.com token or API key, usually because it has expired,
+	// or been revoked.
+	ErrAuthFailed = errors.New("factorio.com credentials rejected")
+
+	// ErrChecksumMismatch is returned when a downloaded or installed
+	// file does not match its published checksum.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
@@ -0,0 +1,88 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+)
+
+// builtinMods are the mods that ship with the game, or with its
+// expansions, and are never downloaded from the mod portal.
+var builtinMods = []string{"base", "elevated-rails", "quality", "space-age"}
+
+// IsBuiltin reports whether the named mod ships with the game, like "base",
+// or with one of its expansions, like "space-age".
+// Built-in mods are never downloaded from the mod portal.
+func IsBuiltin(name string) bool {
+	return slices.Contains(builtinMods, name)
+}
+
+// Builtins returns the built-in mods provided by the Factorio installation
+// in installDir, and their versions, read from each mod's info.json file in
+// the installation's "data" directory.
+// The "base" mod is always provided; the mods that come with an expansion,
+// like "space-age" and "quality", are only provided when the expansion is
+// installed.
+func Builtins(installDir string) (map[string]Version, error) {
+	builtins := make(map[string]Version)
+	for _, name := range builtinMods {
+		info, err := readInfoFile(filepath.Join(installDir, "data", name, "info.json"))
+		if errors.Is(err, fs.ErrNotExist) && name != "base" {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("read %s mod: %w", name, err)
+		}
+		v, err := ParseVersion(info.Version)
+		if err != nil {
+			return nil, fmt.Errorf("read %s mod: %w", name, err)
+		}
+		builtins[name] = v
+	}
+	return builtins, nil
+}
+
+// GameVersion returns the version of Factorio installed to installDir.
+// The version is read from the "base" mod's info.json file, which ships with
+// the game, and, failing that, from the output of "factorio --version".
+func GameVersion(ctx context.Context, installDir string) (Version, error) {
+	info, err := readInfoFile(filepath.Join(installDir, "data", "base", "info.json"))
+	if err == nil {
+		return ParseVersion(info.Version)
+	}
+
+	out, berr := exec.CommandContext(ctx, filepath.Join(installDir, "bin", "x64", "factorio"), "--version").Output()
+	if berr != nil {
+		return Version{}, fmt.Errorf("read base mod version: %w", err)
+	}
+	m := gameVersionRe.FindSubmatch(out)
+	if m == nil {
+		return Version{}, fmt.Errorf("no version in output of factorio --version: %q", out)
+	}
+	return ParseVersion(string(m[1]))
+}
+
+var gameVersionRe = regexp.MustCompile(`(?m)^Version: (\d+\.\d+\.\d+)`)
+
+// SupportsGame reports whether a mod whose info.json has the given
+// "factorio_version", like "1.1", can be loaded by version game of Factorio.
+// As with the game, a missing factorio_version is treated as "0.12", and
+// Factorio 1.0 also loads mods made for 0.18.
+func SupportsGame(factorioVersion string, game Version) bool {
+	if factorioVersion == "" {
+		factorioVersion = "0.12"
+	}
+	v := parseVersion(factorioVersion)
+	if v.Major == game.Major && v.Minor == game.Minor {
+		return true
+	}
+	return game.Major == 1 && game.Minor == 0 && v.Major == 0 && v.Minor == 18
+}
@@ -0,0 +1,173 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Graph is the dependency graph of a set of mods.
+// Nodes are sorted by name, and edges by the names of the mods they
+// connect.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// GraphNode is a mod in a [Graph].
+type GraphNode struct {
+	Name string `json:"name"`
+
+	// The version of the mod whose dependencies were followed.
+	// Version is empty for built-in mods, and for mods that could not be
+	// looked up.
+	Version string `json:"version,omitempty"`
+
+	// Builtin is true for mods that ship with the game, like "base",
+	// whose dependencies are not followed.
+	Builtin bool `json:"builtin,omitempty"`
+
+	// Error describes why the mod could not be looked up.
+	Error string `json:"error,omitempty"`
+}
+
+// GraphEdge is a dependency of one mod on another.
+type GraphEdge struct {
+	From       string     `json:"from"`
+	To         string     `json:"to"`
+	Dependency Dependency `json:"dependency"`
+}
+
+// BuildGraph builds the dependency graph of the given mods, and all of their
+// dependencies, using lookup to read each mod's info.json.
+//
+// The dependencies of built-in mods, and of mods the given mods are
+// incompatible with, are not followed.
+// Mods that lookup returns an error for are kept in the graph, with the
+// error recorded in [GraphNode.Error]; BuildGraph only returns an error when
+// one of the mods has an invalid dependency.
+func BuildGraph(ctx context.Context, lookup func(context.Context, string) (Info, error), names ...string) (*Graph, error) {
+	var (
+		g       = Graph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+		visited = make(map[string]bool)
+		visit   func(name string) error
+	)
+	visit = func(name string) error {
+		if visited[name] {
+			return nil
+		}
+		visited[name] = true
+
+		if IsBuiltin(name) {
+			g.Nodes = append(g.Nodes, GraphNode{Name: name, Builtin: true})
+			return nil
+		}
+
+		info, err := lookup(ctx, name)
+		if err != nil {
+			g.Nodes = append(g.Nodes, GraphNode{Name: name, Error: err.Error()})
+			return nil
+		}
+		g.Nodes = append(g.Nodes, GraphNode{Name: name, Version: info.Version})
+
+		deps, err := info.ParseDependencies()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		for _, d := range deps {
+			g.Edges = append(g.Edges, GraphEdge{From: name, To: d.Name, Dependency: d})
+		}
+		for _, d := range deps {
+			if d.Kind == Incompatible {
+				continue
+			}
+			if err := visit(d.Name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+
+	// Incompatible mods are not visited, but still need a node for
+	// their edges to point to.
+	for _, e := range g.Edges {
+		if !visited[e.To] {
+			visited[e.To] = true
+			g.Nodes = append(g.Nodes, GraphNode{Name: e.To, Builtin: IsBuiltin(e.To)})
+		}
+	}
+
+	slices.SortFunc(g.Nodes, func(a, b GraphNode) int { return cmp.Compare(a.Name, b.Name) })
+	slices.SortStableFunc(g.Edges, func(a, b GraphEdge) int {
+		return cmp.Or(cmp.Compare(a.From, b.From), cmp.Compare(a.To, b.To))
+	})
+	return &g, nil
+}
+
+// WriteDOT writes the graph to w in the [DOT language], for rendering with
+// Graphviz.
+//
+// Built-in mods are drawn as boxes, and mods that could not be looked up in
+// red.
+// Optional dependencies are drawn with dashed lines, and incompatibilities
+// with red, dotted lines.
+//
+// [DOT language]: https://graphviz.org/doc/info/lang.html
+func (g *Graph) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph mods {")
+	fmt.Fprintln(bw, "\trankdir=LR;")
+	fmt.Fprintln(bw, "\tnode [shape=ellipse];")
+
+	for _, n := range g.Nodes {
+		label := n.Name
+		if n.Version != "" {
+			label += "\n" + n.Version
+		}
+		attrs := "label=" + strconv.Quote(label)
+		switch {
+		case n.Builtin:
+			attrs += ", shape=box"
+		case n.Error != "":
+			attrs += ", color=red, tooltip=" + strconv.Quote(n.Error)
+		}
+		fmt.Fprintf(bw, "\t%s [%s];\n", strconv.Quote(n.Name), attrs)
+	}
+
+	for _, e := range g.Edges {
+		var attrs []string
+		if e.Dependency.Op != "" {
+			attrs = append(attrs, "label="+strconv.Quote(e.Dependency.Op+" "+e.Dependency.Version.String()))
+		}
+		switch e.Dependency.Kind {
+		case Optional, HiddenOptional:
+			attrs = append(attrs, "style=dashed")
+		case Incompatible:
+			attrs = append(attrs, "style=dotted", "color=red", "arrowhead=tee")
+		}
+
+		fmt.Fprintf(bw, "\t%s -> %s", strconv.Quote(e.From), strconv.Quote(e.To))
+		if len(attrs) > 0 {
+			fmt.Fprintf(bw, " [%s]", strings.Join(attrs, ", "))
+		}
+		fmt.Fprintln(bw, ";")
+	}
+
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
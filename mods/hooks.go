@@ -0,0 +1,159 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// HookEvent identifies when a [Hook] runs.
+type HookEvent string
+
+const (
+	PreInstall  HookEvent = "pre-install"
+	PostInstall HookEvent = "post-install"
+	PreUpgrade  HookEvent = "pre-upgrade"
+	PostUpgrade HookEvent = "post-upgrade"
+	PreRemove   HookEvent = "pre-remove"
+	PostRemove  HookEvent = "post-remove"
+)
+
+// HookEvents lists every [HookEvent], in the order they can happen.
+var HookEvents = []HookEvent{PreInstall, PostInstall, PreUpgrade, PostUpgrade, PreRemove, PostRemove}
+
+// HookMod is a mod being installed, upgraded, or removed.
+type HookMod struct {
+	Name string `json:"name"`
+
+	// The version being replaced, when upgrading or removing.
+	From string `json:"from,omitempty"`
+
+	// The version being installed, when installing or upgrading.
+	To string `json:"to,omitempty"`
+}
+
+// HookArgs describes the change a [Hook] is run for.
+type HookArgs struct {
+	Event      HookEvent `json:"event"`
+	InstallDir string    `json:"install_dir"`
+	Mods       []HookMod `json:"mods"`
+
+	// The error the change failed with, for "post-" events.
+	// Post hooks are run even when the change fails, so that whatever the
+	// pre hooks did, like stopping a server, can be undone.
+	Err error `json:"-"`
+}
+
+// MarshalJSON adds the error message to the encoded arguments.
+func (a HookArgs) MarshalJSON() ([]byte, error) {
+	type args HookArgs
+	v := struct {
+		args
+		Error string `json:"error,omitempty"`
+	}{args: args(a)}
+	if a.Err != nil {
+		v.Error = a.Err.Error()
+	}
+	return json.Marshal(v)
+}
+
+// Hook is a function that runs before or after mods are installed, upgraded,
+// or removed.
+// Returning an error from a "pre-" hook stops the change from being made.
+type Hook func(ctx context.Context, args HookArgs) error
+
+// Hooks is a set of hooks, registered for the events they run on.
+// The zero value is ready to use, and runs no hooks.
+type Hooks struct {
+	mu    sync.Mutex
+	hooks map[HookEvent][]Hook
+}
+
+// Register adds a hook to run on the given event.
+// Hooks run in the order they are registered.
+func (h *Hooks) Register(event HookEvent, hook Hook) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.hooks == nil {
+		h.hooks = make(map[HookEvent][]Hook)
+	}
+	h.hooks[event] = append(h.hooks[event], hook)
+}
+
+// Run runs the hooks registered for args.Event.
+// The hooks for "pre-" events stop at the first error, which is returned.
+// Every hook for "post-" events is run, and their errors are joined.
+func (h *Hooks) Run(ctx context.Context, args HookArgs) error {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	hooks := h.hooks[args.Event]
+	h.mu.Unlock()
+
+	pre := strings.HasPrefix(string(args.Event), "pre-")
+	var errs []error
+	for i, hook := range hooks {
+		if err := hook(ctx, args); err != nil {
+			err = fmt.Errorf("%s hook %d: %w", args.Event, i+1, err)
+			if pre {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CommandHook returns a hook that runs command with "sh -c".
+//
+// The command is given the [HookArgs] as JSON on its standard input, and in
+// its environment:
+//
+//   - FACMOD_EVENT is the event, like "pre-install".
+//   - FACMOD_INSTALL_DIR is the Factorio installation directory.
+//   - FACMOD_MODS is a space-separated list of the names of the mods being
+//     changed.
+//   - FACMOD_ERROR is the error the change failed with, for "post-" events.
+//
+// The command's output goes to facmod's standard error.
+func CommandHook(command string) Hook {
+	return func(ctx context.Context, args HookArgs) error {
+		input, err := json.Marshal(args)
+		if err != nil {
+			return fmt.Errorf("encode hook arguments: %w", err)
+		}
+
+		names := make([]string, len(args.Mods))
+		for i, m := range args.Mods {
+			names[i] = m.Name
+		}
+
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Env = append(os.Environ(),
+			"FACMOD_EVENT="+string(args.Event),
+			"FACMOD_INSTALL_DIR="+args.InstallDir,
+			"FACMOD_MODS="+strings.Join(names, " "),
+		)
+		if args.Err != nil {
+			cmd.Env = append(cmd.Env, "FACMOD_ERROR="+args.Err.Error())
+		}
+		cmd.Stdin = bytes.NewReader(append(input, '\n'))
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("run %q: %w", command, err)
+		}
+		return nil
+	}
+}
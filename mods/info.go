@@ -0,0 +1,408 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Info holds the contents of a mod's info.json file.
+//
+// See https://wiki.factorio.com/Tutorial:Mod_structure#info.json for a
+// description of each field.
+//
+// Fields in the file that Info does not know about are kept, and written
+// back out by [Info.Marshal].
+type Info struct {
+	Name            string   `json:"name"`
+	Version         string   `json:"version"`
+	Title           string   `json:"title"`
+	Author          string   `json:"author"`
+	Contact         string   `json:"contact,omitempty"`
+	Homepage        string   `json:"homepage,omitempty"`
+	Description     string   `json:"description,omitempty"`
+	FactorioVersion string   `json:"factorio_version,omitempty"`
+	Dependencies    []string `json:"dependencies,omitempty"`
+
+	// Feature flags added in Factorio 2.0, which turn on game features
+	// that are part of the Space Age expansion.
+	// Use [Info.RequiredFeatures] to list the ones that are set.
+	QualityRequired          bool `json:"quality_required,omitempty"`
+	RailBridgesRequired      bool `json:"rail_bridges_required,omitempty"`
+	SpaceTravelRequired      bool `json:"space_travel_required,omitempty"`
+	SpoilingRequired         bool `json:"spoiling_required,omitempty"`
+	FreezingRequired         bool `json:"freezing_required,omitempty"`
+	SegmentedUnitsRequired   bool `json:"segmented_units_required,omitempty"`
+	ExpansionShadersRequired bool `json:"expansion_shaders_required,omitempty"`
+
+	extra map[string]json.RawMessage
+}
+
+// Feature is a game feature that mods can require with a feature flag in
+// their info.json file, like "quality" for "quality_required".
+type Feature string
+
+// Features that mods can require, as of Factorio 2.0.
+const (
+	FeatureQuality          Feature = "quality"
+	FeatureRailBridges      Feature = "rail_bridges"
+	FeatureSpaceTravel      Feature = "space_travel"
+	FeatureSpoiling         Feature = "spoiling"
+	FeatureFreezing         Feature = "freezing"
+	FeatureSegmentedUnits   Feature = "segmented_units"
+	FeatureExpansionShaders Feature = "expansion_shaders"
+)
+
+// featureFlags returns the feature flags, and pointers to the fields that
+// hold them, in the order they are written to info.json.
+func (i *Info) featureFlags() []struct {
+	feature Feature
+	value   *bool
+} {
+	return []struct {
+		feature Feature
+		value   *bool
+	}{
+		{FeatureQuality, &i.QualityRequired},
+		{FeatureRailBridges, &i.RailBridgesRequired},
+		{FeatureSpaceTravel, &i.SpaceTravelRequired},
+		{FeatureSpoiling, &i.SpoilingRequired},
+		{FeatureFreezing, &i.FreezingRequired},
+		{FeatureSegmentedUnits, &i.SegmentedUnitsRequired},
+		{FeatureExpansionShaders, &i.ExpansionShadersRequired},
+	}
+}
+
+// RequiredFeatures returns the features the mod turns on with feature
+// flags.
+// Mods that require features can only be loaded by Factorio 2.0, or newer,
+// with the Space Age expansion.
+func (i Info) RequiredFeatures() []Feature {
+	var features []Feature
+	for _, f := range i.featureFlags() {
+		if *f.value {
+			features = append(features, f.feature)
+		}
+	}
+	return features
+}
+
+// FileName returns the name the mod portal gives to the mod's archive,
+// "NAME_VERSION.zip".
+func (i Info) FileName() string {
+	return i.Name + "_" + i.Version + ".zip"
+}
+
+// SetDependencies replaces the info's dependencies with deps, written in the
+// format used by info.json.
+// SetDependencies returns an error, and leaves the info unchanged, if any of
+// the dependencies could not be read back by [ParseDependency].
+func (i *Info) SetDependencies(deps []Dependency) error {
+	ss := make([]string, len(deps))
+	for j, d := range deps {
+		b, err := d.MarshalText()
+		if err != nil {
+			return err
+		}
+		ss[j] = string(b)
+	}
+	i.Dependencies = ss
+	return nil
+}
+
+// Marshal returns the info as an info.json file: indented JSON, with the
+// fields in the order the wiki lists them, followed by any other fields,
+// sorted by name.
+func (i Info) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(i); err != nil {
+		return nil, fmt.Errorf("encode json: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteTo writes the info to w, as written by [Info.Marshal].
+func (i Info) WriteTo(w io.Writer) (int64, error) {
+	data, err := i.Marshal()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// fields returns the info's known fields as key/value pairs, in order, for
+// [marshalObject]; empty optional fields are left out.
+func (i Info) fields() []any {
+	kv := []any{
+		"name", i.Name,
+		"version", i.Version,
+		"title", i.Title,
+		"author", i.Author,
+	}
+	for _, f := range []struct{ key, value string }{
+		{"contact", i.Contact},
+		{"homepage", i.Homepage},
+		{"description", i.Description},
+		{"factorio_version", i.FactorioVersion},
+	} {
+		if f.value != "" {
+			kv = append(kv, f.key, f.value)
+		}
+	}
+	if len(i.Dependencies) > 0 {
+		kv = append(kv, "dependencies", i.Dependencies)
+	}
+	for _, f := range i.featureFlags() {
+		if *f.value {
+			kv = append(kv, string(f.feature)+"_required", true)
+		}
+	}
+	return kv
+}
+
+// MarshalJSON implements [encoding/json.Marshaler].
+func (i Info) MarshalJSON() ([]byte, error) {
+	return marshalObject(i.extra, i.fields()...)
+}
+
+// UnmarshalJSON implements [encoding/json.Unmarshaler].
+func (i *Info) UnmarshalJSON(data []byte) error {
+	var v Info
+	extra, err := unmarshalObject(data,
+		"name", &v.Name,
+		"version", &v.Version,
+		"title", &v.Title,
+		"author", &v.Author,
+		"contact", &v.Contact,
+		"homepage", &v.Homepage,
+		"description", &v.Description,
+		"factorio_version", &v.FactorioVersion,
+		"dependencies", &v.Dependencies,
+		"quality_required", &v.QualityRequired,
+		"rail_bridges_required", &v.RailBridgesRequired,
+		"space_travel_required", &v.SpaceTravelRequired,
+		"spoiling_required", &v.SpoilingRequired,
+		"freezing_required", &v.FreezingRequired,
+		"segmented_units_required", &v.SegmentedUnitsRequired,
+		"expansion_shaders_required", &v.ExpansionShadersRequired,
+	)
+	if err != nil {
+		return err
+	}
+	v.extra = extra
+	*i = v
+	return nil
+}
+
+// ParseDependencies parses each of the strings in i.Dependencies.
+func (i Info) ParseDependencies() ([]Dependency, error) {
+	deps := make([]Dependency, len(i.Dependencies))
+	for j, s := range i.Dependencies {
+		d, err := ParseDependency(s)
+		if err != nil {
+			return nil, err
+		}
+		deps[j] = d
+	}
+	return deps, nil
+}
+
+// LoadInfo reads the info.json file out of the mod archive at zipPath.
+// When zipPath is a directory, like an unpacked mod in the mods directory,
+// its info.json is read with [LoadDirInfo].
+func LoadInfo(zipPath string) (Info, error) {
+	if fi, err := os.Stat(zipPath); err == nil && fi.IsDir() {
+		return LoadDirInfo(zipPath)
+	}
+
+	a, err := OpenArchive(zipPath)
+	if err != nil {
+		return Info{}, err
+	}
+	defer a.Close()
+	return a.Info()
+}
+
+// Info decodes the release's copy of the mod's info.json.
+// Only releases returned by [Cache.FullInfo] include the mod's dependencies.
+func (r Release) Info() (Info, error) {
+	var info Info
+	if err := json.Unmarshal(r.InfoJSON, &info); err != nil {
+		return Info{}, fmt.Errorf("decode info json: %w", err)
+	}
+	return info, nil
+}
+
+// DependencyKind describes how a mod depends on another mod.
+type DependencyKind int
+
+const (
+	Required       DependencyKind = iota // The dependency must be installed.
+	Optional                             // "?" The dependency is loaded first, if it is installed.
+	HiddenOptional                       // "(?)" Same as Optional, but not shown to the user.
+	Incompatible                         // "!" The mods cannot be installed together.
+	NoLoadOrder                          // "~" Required, but does not affect load order.
+)
+
+// String returns the prefix used for the dependency kind in info.json.
+func (k DependencyKind) String() string {
+	switch k {
+	case Optional:
+		return "?"
+	case HiddenOptional:
+		return "(?)"
+	case Incompatible:
+		return "!"
+	case NoLoadOrder:
+		return "~"
+	}
+	return ""
+}
+
+// Dependency is a single, parsed entry from the "dependencies" field of a
+// mod's info.json.
+type Dependency struct {
+	Kind DependencyKind
+	Name string
+
+	// An optional version constraint.
+	// When Op is empty, any version of the dependency satisfies the
+	// constraint.
+	Op      string // One of "<", "<=", "=", ">=", ">".
+	Version Version
+}
+
+var dependencyRe = regexp.MustCompile(`^(.+?)\s*(<=|>=|<|=|>)\s*(\S+)$`)
+
+// ParseDependency parses a dependency string, like "? bobplates >= 0.18.0".
+//
+// Parsing is the inverse of [Dependency.String]: for any dependency d that
+// ParseDependency returns, ParseDependency(d.String()) returns d.
+func ParseDependency(s string) (Dependency, error) {
+	var d Dependency
+
+	rest := strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(rest, "(?)"):
+		d.Kind, rest = HiddenOptional, rest[3:]
+	case strings.HasPrefix(rest, "?"):
+		d.Kind, rest = Optional, rest[1:]
+	case strings.HasPrefix(rest, "!"):
+		d.Kind, rest = Incompatible, rest[1:]
+	case strings.HasPrefix(rest, "~"):
+		d.Kind, rest = NoLoadOrder, rest[1:]
+	}
+	rest = strings.TrimSpace(rest)
+
+	if m := dependencyRe.FindStringSubmatch(rest); m != nil {
+		v, err := ParseVersion(m[3])
+		if err != nil {
+			return Dependency{}, fmt.Errorf("parse dependency %q: %w", s, err)
+		}
+		rest, d.Op, d.Version = m[1], m[2], v
+	}
+	d.Name = rest
+
+	if err := d.validate(); err != nil {
+		return Dependency{}, fmt.Errorf("parse dependency %q: %w", s, err)
+	}
+	return d, nil
+}
+
+// validate checks that the dependency can be written with
+// [Dependency.String], and read back with [ParseDependency].
+func (d Dependency) validate() error {
+	switch {
+	case d.Kind < Required || d.Kind > NoLoadOrder:
+		return fmt.Errorf("invalid dependency kind %d", d.Kind)
+	case d.Name == "":
+		return errors.New("missing mod name")
+	case d.Name != strings.TrimSpace(d.Name):
+		return fmt.Errorf("mod name %q has leading or trailing space", d.Name)
+	case strings.ContainsAny(d.Name, "<=>"):
+		return fmt.Errorf("invalid version constraint in %q", d.Name)
+	case strings.ContainsAny(d.Name[:1], "?!~("):
+		return fmt.Errorf("mod name %q starts with a dependency prefix", d.Name)
+	}
+
+	switch d.Op {
+	case "":
+		if !d.Version.IsZero() {
+			return fmt.Errorf("version %s is missing an operator", d.Version)
+		}
+	case "<", "<=", "=", ">=", ">":
+		if err := d.Version.validate(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid operator %q", d.Op)
+	}
+	return nil
+}
+
+// String returns the dependency in the format used by info.json.
+func (d Dependency) String() string {
+	var b strings.Builder
+	if k := d.Kind.String(); k != "" {
+		b.WriteString(k)
+		b.WriteByte(' ')
+	}
+	b.WriteString(d.Name)
+	if d.Op != "" {
+		fmt.Fprintf(&b, " %s %s", d.Op, d.Version)
+	}
+	return b.String()
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+// It returns an error if the dependency could not be read back by
+// [ParseDependency], so that invalid dependencies are never written to an
+// info.json file.
+func (d Dependency) MarshalText() ([]byte, error) {
+	if err := d.validate(); err != nil {
+		return nil, fmt.Errorf("marshal dependency: %w", err)
+	}
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler].
+func (d *Dependency) UnmarshalText(text []byte) error {
+	pd, err := ParseDependency(string(text))
+	if err != nil {
+		return err
+	}
+	*d = pd
+	return nil
+}
+
+// Allows reports whether version v of the dependency satisfies the
+// dependency's version constraint.
+func (d Dependency) Allows(v Version) bool {
+	c := v.Compare(d.Version)
+	switch d.Op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case "=":
+		return c == 0
+	case ">=":
+		return c >= 0
+	case ">":
+		return c > 0
+	}
+	return true
+}
@@ -0,0 +1,48 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import "testing"
+
+func FuzzParseDependency(f *testing.F) {
+	for _, s := range []string{
+		"base",
+		"base >= 1.1",
+		"? bobplates >= 0.18.0",
+		"(?) space-exploration = 0.6.138",
+		"! angelsrefining",
+		"~ flib>=0.12.0",
+		"base < 2.0.28.1",
+		"base > 1.01.0",
+	} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		d, err := ParseDependency(s)
+		if err != nil {
+			return
+		}
+
+		b, err := d.MarshalText()
+		if err != nil {
+			t.Fatalf("marshal %#v, parsed from %q: %v", d, s, err)
+		}
+		d2, err := ParseDependency(string(b))
+		if err != nil {
+			t.Fatalf("parse %q, marshaled from %#v: %v", b, d, err)
+		}
+		if d2 != d {
+			t.Fatalf("round trip of %q: got %#v, want %#v", s, d2, d)
+		}
+	})
+}
+
+func TestDependencyMarshalTextVersionRange(t *testing.T) {
+	d := Dependency{Name: "a", Op: ">=", Version: Version{Major: 70000}}
+	if b, err := d.MarshalText(); err == nil {
+		t.Errorf("MarshalText() = %q, want an error for a major version over 65535", b)
+	}
+}
@@ -0,0 +1,325 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Install copies the mod archive at zipPath into the installation's mods
+// directory, removes any other installed versions of the same mod, and adds
+// the mod to mod-list.json.
+// When enable is true, the mod is also enabled; otherwise, newly-added mods
+// are disabled, and mods that were already in mod-list.json keep their
+// current state.
+//
+// The archive's file name must be of the form "NAME_VERSION.zip", which is how
+// the mod portal names its files.
+func Install(installDir, zipPath string, enable bool) error {
+	mp := modpath(zipPath)
+	if mp.version().IsZero() {
+		return fmt.Errorf("cannot determine mod version from file name: %s", filepath.Base(zipPath))
+	}
+	return install(installDir, zipPath, mp.name(), filepath.Base(zipPath), enable)
+}
+
+// InstallFile is like [Install], but reads the mod's name and version from
+// the info.json file inside the archive, rather than from the archive's file
+// name, so that archives that were not downloaded from the mod portal can be
+// installed.
+// The archive is copied into the mods directory as "NAME_VERSION.zip".
+//
+// InstallFile returns the archive's info.json.
+func InstallFile(installDir, zipPath string, enable bool) (Info, error) {
+	info, err := LoadFileInfo(zipPath)
+	if err != nil {
+		return Info{}, err
+	}
+	if err := install(installDir, zipPath, info.Name, info.FileName(), enable); err != nil {
+		return Info{}, err
+	}
+	return info, nil
+}
+
+// LoadFileInfo reads the info.json file out of the mod archive at zipPath, and
+// checks that the mod's name and version are usable as the archive's
+// file name.
+func LoadFileInfo(zipPath string) (Info, error) {
+	info, err := LoadInfo(zipPath)
+	if err != nil {
+		return Info{}, err
+	}
+	if info.Name == "" || strings.ContainsAny(info.Name, `/\`) {
+		return Info{}, fmt.Errorf("invalid mod name in info.json: %q", info.Name)
+	}
+	if _, err := ParseVersion(info.Version); err != nil {
+		return Info{}, fmt.Errorf("info.json: %w", err)
+	}
+	return info, nil
+}
+
+// InstallDir packages the mod source directory dir with [PackDir], and
+// installs the archive into the installation's mods directory, replacing any
+// other installed version of the mod.
+// The mod is also enabled.
+//
+// InstallDir returns the mod's info.json.
+func InstallDir(installDir, dir string) (Info, error) {
+	modDir := filepath.Join(installDir, "mods")
+	if err := os.MkdirAll(modDir, fs.ModePerm); err != nil {
+		return Info{}, fmt.Errorf("make directory %q: %w", modDir, err)
+	}
+
+	dst, info, err := PackDirFile(dir, modDir)
+	if err != nil {
+		return Info{}, err
+	}
+	if err := removeLink(modDir, info.Name); err != nil {
+		return Info{}, err
+	}
+	if err := removeOtherVersions(modDir, info.Name, dst); err != nil {
+		return Info{}, fmt.Errorf("remove other versions: %w", err)
+	}
+
+	if err := updateModList(installDir, func(list *ModList) error {
+		list.Add(info.Name, true)
+		return nil
+	}); err != nil {
+		return Info{}, fmt.Errorf("update mod list: %w", err)
+	}
+	return info, nil
+}
+
+// LinkDir installs the mod source directory dir by creating a symbolic link
+// to it, named after the mod, in the installation's mods directory.
+// The game loads unpacked mods from directories, so changes to the mod's
+// files take effect the next time the game is started, without reinstalling
+// the mod.
+// Any installed archives of the mod are removed, and the mod is enabled.
+//
+// LinkDir returns the mod's info.json.
+func LinkDir(installDir, dir string) (Info, error) {
+	info, err := readSourceInfo(dir)
+	if err != nil {
+		return Info{}, err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return Info{}, fmt.Errorf("absolute path: %w", err)
+	}
+
+	modDir := filepath.Join(installDir, "mods")
+	if err := os.MkdirAll(modDir, fs.ModePerm); err != nil {
+		return Info{}, fmt.Errorf("make directory %q: %w", modDir, err)
+	}
+	if err := removeLink(modDir, info.Name); err != nil {
+		return Info{}, err
+	}
+	link := filepath.Join(modDir, info.Name)
+	if err := os.Symlink(abs, link); err != nil {
+		return Info{}, fmt.Errorf("link mod directory: %w", err)
+	}
+	if err := removeOtherVersions(modDir, info.Name, link); err != nil {
+		return Info{}, fmt.Errorf("remove other versions: %w", err)
+	}
+
+	if err := updateModList(installDir, func(list *ModList) error {
+		list.Add(info.Name, true)
+		return nil
+	}); err != nil {
+		return Info{}, fmt.Errorf("update mod list: %w", err)
+	}
+	return info, nil
+}
+
+// removeLink removes the symbolic link to the named mod's source directory
+// created by [LinkDir], if there is one.
+// Directories that are not symbolic links are left alone.
+func removeLink(modDir, name string) error {
+	path := filepath.Join(modDir, name)
+	fi, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if fi.Mode()&fs.ModeSymlink == 0 {
+		return nil
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("remove link: %w", err)
+	}
+	return nil
+}
+
+// install copies the mod archive at src into the installation's mods
+// directory as fileName.
+func install(installDir, src, name, fileName string, enable bool) error {
+	modDir := filepath.Join(installDir, "mods")
+	if err := os.MkdirAll(modDir, fs.ModePerm); err != nil {
+		return fmt.Errorf("make directory %q: %w", modDir, err)
+	}
+
+	dst := filepath.Join(modDir, fileName)
+	if err := copyFile(dst, src); err != nil {
+		return fmt.Errorf("copy %s: %w", fileName, err)
+	}
+
+	if err := removeOtherVersions(modDir, name, dst); err != nil {
+		return fmt.Errorf("remove other versions: %w", err)
+	}
+
+	if err := updateModList(installDir, func(list *ModList) error {
+		list.Add(name, enable)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("update mod list: %w", err)
+	}
+
+	return nil
+}
+
+// copyFile copies src to dst.
+// The copy is written to a temporary file in dst's directory, which is then
+// renamed to dst, so that dst is never left partially written.
+func copyFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".install-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), dst)
+}
+
+// removeOtherVersions deletes every installed copy of the named mod from
+// modDir, as found by [modPaths], except for keep.
+// Symbolic links are removed without touching the directories they point to.
+func removeOtherVersions(modDir, name, keep string) error {
+	paths, err := modPaths(modDir, name)
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		if p == keep {
+			continue
+		}
+		fi, err := os.Lstat(p)
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			err = os.RemoveAll(p)
+		} else {
+			err = os.Remove(p)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InstalledPaths returns every installed copy of the named mod in the
+// installation's mods directory, which [Uninstall] removes: its
+// "NAME_VERSION.zip" archives, its unpacked "NAME" and "NAME_VERSION"
+// directories, and symbolic links with those names, like the ones created by
+// [LinkDir].
+func InstalledPaths(installDir, name string) ([]string, error) {
+	return modPaths(filepath.Join(installDir, "mods"), name)
+}
+
+// modPaths implements [InstalledPaths] for the mods directory modDir.
+func modPaths(modDir, name string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(modDir, name+"_*"))
+	if err != nil {
+		return nil, fmt.Errorf("glob: %w", err)
+	}
+	matches = append(matches, filepath.Join(modDir, name))
+
+	var paths []string
+	for _, m := range matches {
+		fi, err := os.Lstat(m)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		base := filepath.Base(m)
+		switch {
+		case fi.Mode().IsRegular():
+			if !strings.HasSuffix(base, ".zip") || modpath(m).name() != name {
+				continue
+			}
+		case fi.IsDir() || fi.Mode()&fs.ModeSymlink != 0:
+			// Skip other mods whose names start with name, like
+			// "bobplates_extended" for "bobplates".
+			if v, ok := strings.CutPrefix(base, name+"_"); ok {
+				if _, err := ParseVersion(v); err != nil {
+					continue
+				}
+			}
+		default:
+			continue
+		}
+		paths = append(paths, m)
+	}
+	return paths, nil
+}
+
+// Uninstall removes all installed versions of the named mod from the
+// installation's mods directory, as found by [InstalledPaths], and removes
+// the mod from mod-list.json.
+func Uninstall(installDir, name string) error {
+	modDir := filepath.Join(installDir, "mods")
+	if err := removeOtherVersions(modDir, name, ""); err != nil {
+		return fmt.Errorf("remove installed versions: %w", err)
+	}
+
+	return updateModList(installDir, func(list *ModList) error {
+		list.Remove(name)
+		return nil
+	})
+}
+
+// updateModList loads the installation's mod-list.json, calls fn to modify
+// it, and saves it.
+func updateModList(installDir string, fn func(*ModList) error) error {
+	list, err := LoadModList(installDir)
+	if err != nil {
+		return err
+	}
+	if err := fn(list); err != nil {
+		return err
+	}
+	return list.Save(installDir)
+}
@@ -0,0 +1,191 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Lockfile records the exact set of mods in an installation, so the same set
+// of mods can be reproduced elsewhere with [Lockfile.Sync].
+//
+// Mods that ship with the game, like "base", are not recorded.
+type Lockfile struct {
+	Mods []LockedMod `json:"mods"`
+}
+
+// LockedMod is a single entry in a [Lockfile].
+type LockedMod struct {
+	Name    string  `json:"name"`
+	Version Version `json:"version"`
+	SHA1    string  `json:"sha1"`
+	Enabled bool    `json:"enabled"`
+}
+
+// FileName returns the name of the mod's archive, as it would appear in an
+// installation's mods directory.
+func (m LockedMod) FileName() string {
+	return fmt.Sprintf("%s_%s.zip", m.Name, m.Version)
+}
+
+// Lock creates a [Lockfile] from the mods currently installed to installDir.
+func Lock(installDir string) (*Lockfile, error) {
+	installed, err := Load(installDir)
+	if err != nil {
+		return nil, fmt.Errorf("load mods: %w", err)
+	}
+
+	var lock Lockfile
+	for _, m := range installed {
+		// Unpacked mods have no archive to lock.
+		n := len(m.Versions)
+		if n == 0 || m.IsDir(m.Versions[n-1]) {
+			continue
+		}
+
+		lm := LockedMod{
+			Name:    m.Name,
+			Version: m.Versions[n-1],
+			Enabled: m.Enabled,
+		}
+		sum, err := fileSHA1(filepath.Join(installDir, "mods", lm.FileName()))
+		if err != nil {
+			return nil, fmt.Errorf("hash %s: %w", lm.FileName(), err)
+		}
+		lm.SHA1 = sum
+
+		lock.Mods = append(lock.Mods, lm)
+	}
+
+	return &lock, nil
+}
+
+// LoadLockfile reads a [Lockfile] from path.
+func LoadLockfile(path string) (*Lockfile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open lockfile: %w", err)
+	}
+	defer f.Close()
+
+	var lock Lockfile
+	if err := json.NewDecoder(f).Decode(&lock); err != nil {
+		return nil, fmt.Errorf("decode json: %w", err)
+	}
+	return &lock, nil
+}
+
+// Save writes the lockfile to path.
+// Entries are sorted by name so that lockfiles produce stable diffs when kept
+// under version control.
+func (l *Lockfile) Save(path string) error {
+	slices.SortFunc(l.Mods, func(a, b LockedMod) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode json: %w", err)
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// FetchFunc downloads the given version of a mod, returning the path to the
+// downloaded archive.
+type FetchFunc func(ctx context.Context, name string, version Version) (string, error)
+
+// Sync makes the mods installed to installDir match the lockfile exactly.
+// Locked mods that are missing, or whose archives do not match the recorded
+// SHA1, are retrieved with fetch and installed.
+// Installed mods that are not in the lockfile are removed, and every locked
+// mod is enabled or disabled as recorded.
+func (l *Lockfile) Sync(ctx context.Context, installDir string, fetch FetchFunc) error {
+	modDir := filepath.Join(installDir, "mods")
+	for _, lm := range l.Mods {
+		dst := filepath.Join(modDir, lm.FileName())
+		sum, err := fileSHA1(dst)
+		if err == nil && strings.EqualFold(sum, lm.SHA1) {
+			continue
+		} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("hash %s: %w", lm.FileName(), err)
+		}
+
+		path, err := fetch(ctx, lm.Name, lm.Version)
+		if err != nil {
+			return fmt.Errorf("fetch %s: %w", lm.FileName(), err)
+		}
+
+		sum, err = fileSHA1(path)
+		if err != nil {
+			return fmt.Errorf("hash %s: %w", path, err)
+		}
+		if !strings.EqualFold(sum, lm.SHA1) {
+			return fmt.Errorf("%s: sha1 %w: have %s, want %s", lm.FileName(), ErrChecksumMismatch, sum, lm.SHA1)
+		}
+
+		if err := Install(installDir, path, false); err != nil {
+			return fmt.Errorf("install %s: %w", lm.FileName(), err)
+		}
+	}
+
+	locked := make(map[string]LockedMod, len(l.Mods))
+	for _, lm := range l.Mods {
+		locked[lm.Name] = lm
+	}
+
+	// Remove every archive in the mods directory that is not in the
+	// lockfile, regardless of whether it is listed in mod-list.json.
+	archives, err := filepath.Glob(filepath.Join(modDir, "*.zip"))
+	if err != nil {
+		return fmt.Errorf("glob: %w", err)
+	}
+	for _, a := range archives {
+		name := modpath(a).name()
+		if lm, ok := locked[name]; ok {
+			if filepath.Base(a) != lm.FileName() {
+				if err := os.Remove(a); err != nil {
+					return fmt.Errorf("remove %s: %w", filepath.Base(a), err)
+				}
+			}
+			continue
+		}
+		if err := Uninstall(installDir, name); err != nil {
+			return fmt.Errorf("uninstall %s: %w", name, err)
+		}
+	}
+
+	return updateModList(installDir, func(list *ModList) error {
+		for _, lm := range l.Mods {
+			list.setEnabled(lm.Name, lm.Enabled)
+		}
+		return nil
+	})
+}
+
+// fileSHA1 returns the hex-encoded SHA1 hash of the file at path.
+func fileSHA1(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,117 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Manifest is a portable description of the mods in an installation.
+// It is a superset of mod-list.json: every entry has a name and an enabled
+// flag, and entries for mods that are not built into the game also carry the
+// installed version.
+type Manifest struct {
+	Mods []ManifestEntry `json:"mods"`
+}
+
+// ManifestEntry is a single mod in a [Manifest].
+type ManifestEntry struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+
+	// The installed version of the mod.
+	// Empty for mods that ship with the game, like "base".
+	Version string `json:"version,omitempty"`
+}
+
+// Export creates a [Manifest] of the mods installed to installDir.
+func Export(installDir string) (*Manifest, error) {
+	installed, err := Load(installDir)
+	if err != nil {
+		return nil, fmt.Errorf("load mods: %w", err)
+	}
+
+	m := &Manifest{Mods: make([]ManifestEntry, len(installed))}
+	for i, mod := range installed {
+		e := ManifestEntry{Name: mod.Name, Enabled: mod.Enabled}
+		if n := len(mod.Versions); n != 0 {
+			e.Version = mod.Versions[n-1].String()
+		}
+		m.Mods[i] = e
+	}
+
+	return m, nil
+}
+
+// ReadFrom implements the [io.ReaderFrom] interface, populating the values in m from the contents in r.
+// On a successful invocation, ReadFrom will return 0, nil.
+func (m *Manifest) ReadFrom(r io.Reader) (int64, error) {
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return 0, fmt.Errorf("decode json: %w", err)
+	}
+	return 0, nil
+}
+
+// WriteTo implements the [io.WriterTo] interface, and will encode the data in m to w.
+// On a successful invocation, WriteTo returns 0, nil.
+func (m *Manifest) WriteTo(w io.Writer) (int64, error) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return 0, fmt.Errorf("encode json: %w", err)
+	}
+	return 0, nil
+}
+
+// Import installs the mods described by the manifest into installDir.
+// Mods that are not installed at the version recorded in the manifest are
+// retrieved with fetch, and installed.
+// Afterwards, every mod in the manifest is enabled or disabled as recorded.
+//
+// Unlike [Lockfile.Sync], Import does not remove mods that are not in the
+// manifest.
+func (m *Manifest) Import(ctx context.Context, installDir string, fetch FetchFunc) error {
+	for _, e := range m.Mods {
+		if e.Version == "" {
+			continue
+		}
+
+		v, err := ParseVersion(e.Version)
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.Name, err)
+		}
+
+		dst := filepath.Join(installDir, "mods", fmt.Sprintf("%s_%s.zip", e.Name, v))
+		if _, err := os.Stat(dst); err == nil {
+			continue
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("stat %s: %w", dst, err)
+		}
+
+		path, err := fetch(ctx, e.Name, v)
+		if err != nil {
+			return fmt.Errorf("fetch %s %s: %w", e.Name, v, err)
+		}
+
+		if err := Install(installDir, path, false); err != nil {
+			return fmt.Errorf("install %s %s: %w", e.Name, v, err)
+		}
+	}
+
+	return updateModList(installDir, func(list *ModList) error {
+		for _, e := range m.Mods {
+			list.Add(e.Name, false)
+			list.setEnabled(e.Name, e.Enabled)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,99 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"database/sql"
+	"os"
+	"time"
+)
+
+// Counter is a metric that only goes up.
+// It is satisfied by prometheus.Counter.
+type Counter interface {
+	Add(float64)
+}
+
+// Observer is a metric that records a distribution of values, like a
+// histogram or summary.
+// It is satisfied by prometheus.Histogram, prometheus.Summary, and
+// prometheus.Observer.
+type Observer interface {
+	Observe(float64)
+}
+
+// Metrics are the metrics a [Cache] reports to, for services that embed a
+// cache and want to monitor it.
+// Any of the fields may be nil, to not report that metric.
+type Metrics struct {
+	// Pages of the mod list retrieved from the mod portal by
+	// [Cache.Pull] and [Cache.PullSince].
+	PagesPulled Counter
+
+	// Mods written to the cache database by [Cache.Update],
+	// [Cache.FullInfo], and [Cache.ShortInfo].
+	RowsUpserted Counter
+
+	// Bytes of mod archives downloaded by [Cache.Download], and the
+	// methods that call it, like [Cache.Get].
+	BytesDownloaded Counter
+
+	// Calls to [Cache.Download] that found the release already in the
+	// cache's mods directory, and those that had to download it.
+	CacheHits   Counter
+	CacheMisses Counter
+
+	// Time taken by queries that read from the cache database, like
+	// [Cache.Search] and [Cache.LatestRelease], in seconds.
+	QueryDuration Observer
+}
+
+// SetMetrics sets the metrics the cache reports to, replacing any set
+// before.
+func (c *Cache) SetMetrics(m Metrics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics = m
+}
+
+// getMetrics returns the metrics set with [Cache.SetMetrics].
+func (c *Cache) getMetrics() Metrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.metrics
+}
+
+// add adds n to counter, if it is set.
+func add(counter Counter, n float64) {
+	if counter != nil {
+		counter.Add(n)
+	}
+}
+
+// rowsAffected returns the number of rows changed by a statement, for
+// Metrics.RowsUpserted.
+// Errors are ignored, since SQLite always reports the number of rows.
+func rowsAffected(res sql.Result) int64 {
+	n, _ := res.RowsAffected()
+	return n
+}
+
+// countDownload adds the size of a newly-downloaded archive to
+// Metrics.BytesDownloaded.
+func (c *Cache) countDownload(path string) {
+	if info, err := os.Stat(path); err == nil {
+		add(c.getMetrics().BytesDownloaded, float64(info.Size()))
+	}
+}
+
+// observeQuery records the time since started in Metrics.QueryDuration.
+// It is meant to be deferred:
+//
+//	defer c.observeQuery(time.Now())
+func (c *Cache) observeQuery(started time.Time) {
+	if o := c.getMetrics().QueryDuration; o != nil {
+		o.Observe(time.Since(started).Seconds())
+	}
+}
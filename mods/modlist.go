@@ -0,0 +1,375 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ModList holds the contents of an installation's "mods/mod-list.json" file,
+// which records the mods that are enabled or disabled.
+//
+// Fields in the file that ModList does not know about are kept, and written
+// back out by [ModList.Save], so that lists written by newer versions of
+// Factorio are not damaged.
+type ModList struct {
+	Mods []ModListEntry `json:"mods"`
+
+	extra map[string]json.RawMessage
+}
+
+// ModListEntry is a single mod in a [ModList].
+type ModListEntry struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+
+	extra map[string]json.RawMessage
+}
+
+// LoadModList reads mod-list.json from the installation directory.
+// If the file does not exist, a list only containing the enabled "base" mod
+// is returned.
+func LoadModList(installDir string) (*ModList, error) {
+	list := &ModList{Mods: []ModListEntry{{Name: "base", Enabled: true}}}
+
+	data, err := os.ReadFile(modListPath(installDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return list, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read mod list: %w", err)
+	}
+
+	if err := json.Unmarshal(data, list); err != nil {
+		return nil, fmt.Errorf("decode json: %w", err)
+	}
+	return list, nil
+}
+
+func modListPath(installDir string) string {
+	return filepath.Join(installDir, "mods", "mod-list.json")
+}
+
+// Save writes the list to mod-list.json in the installation directory.
+//
+// Mods are written in a stable order, with "base" first, and the rest sorted
+// by name, the same way Factorio writes the file.
+// The list is written to a temporary file first, so the game never sees a
+// partially-written list.
+func (l *ModList) Save(installDir string) error {
+	l.sort()
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode json: %w", err)
+	}
+
+	path := modListPath(installDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("make mods directory: %w", err)
+	}
+	if err := writeFileAtomic(path, append(data, '\n')); err != nil {
+		return fmt.Errorf("write mod list: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path, and renames
+// it to path, so that readers never see a partially-written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		return fmt.Errorf("chmod temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("rename temp file: %w", err)
+	}
+	return nil
+}
+
+// sort orders the list with "base" first, followed by every other mod,
+// sorted case-insensitively by name.
+func (l *ModList) sort() {
+	slices.SortStableFunc(l.Mods, func(a, b ModListEntry) int {
+		switch {
+		case a.Name == b.Name:
+			return 0
+		case a.Name == "base":
+			return -1
+		case b.Name == "base":
+			return 1
+		}
+		if c := strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+}
+
+// Lookup returns the list's entry for the named mod.
+// Lookup returns false if the mod is not in the list.
+func (l *ModList) Lookup(name string) (ModListEntry, bool) {
+	i := slices.IndexFunc(l.Mods, func(e ModListEntry) bool { return e.Name == name })
+	if i < 0 {
+		return ModListEntry{}, false
+	}
+	return l.Mods[i], true
+}
+
+// Add adds the named mod to the list, if it is not already listed.
+// When enabled is true, the mod is enabled, even if it was already in the
+// list; otherwise, an existing entry is left as-is.
+func (l *ModList) Add(name string, enabled bool) {
+	for i, e := range l.Mods {
+		if e.Name == name {
+			if enabled {
+				l.Mods[i].Enabled = true
+			}
+			return
+		}
+	}
+	l.Mods = append(l.Mods, ModListEntry{Name: name, Enabled: enabled})
+}
+
+// Remove removes the named mod from the list.
+// Remove returns false if the mod is not in the list.
+func (l *ModList) Remove(name string) bool {
+	n := len(l.Mods)
+	l.Mods = slices.DeleteFunc(l.Mods, func(e ModListEntry) bool { return e.Name == name })
+	return len(l.Mods) != n
+}
+
+// Enable enables the named mod.
+// Enable returns false if the mod is not in the list.
+func (l *ModList) Enable(name string) bool {
+	return l.setEnabled(name, true)
+}
+
+// Disable disables the named mod.
+// Disable returns false if the mod is not in the list.
+func (l *ModList) Disable(name string) bool {
+	return l.setEnabled(name, false)
+}
+
+func (l *ModList) setEnabled(name string, enabled bool) bool {
+	for i, e := range l.Mods {
+		if e.Name == name {
+			l.Mods[i].Enabled = enabled
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON implements [encoding/json.Marshaler].
+func (l ModList) MarshalJSON() ([]byte, error) {
+	mods := l.Mods
+	if mods == nil {
+		mods = []ModListEntry{}
+	}
+	return marshalObject(l.extra, "mods", mods)
+}
+
+// UnmarshalJSON implements [encoding/json.Unmarshaler].
+func (l *ModList) UnmarshalJSON(data []byte) error {
+	var mods []ModListEntry
+	extra, err := unmarshalObject(data, "mods", &mods)
+	if err != nil {
+		return err
+	}
+	l.Mods, l.extra = mods, extra
+	return nil
+}
+
+// MarshalJSON implements [encoding/json.Marshaler].
+func (e ModListEntry) MarshalJSON() ([]byte, error) {
+	return marshalObject(e.extra, "name", e.Name, "enabled", e.Enabled)
+}
+
+// UnmarshalJSON implements [encoding/json.Unmarshaler].
+func (e *ModListEntry) UnmarshalJSON(data []byte) error {
+	var (
+		name    string
+		enabled bool
+	)
+	extra, err := unmarshalObject(data, "name", &name, "enabled", &enabled)
+	if err != nil {
+		return err
+	}
+	e.Name, e.Enabled, e.extra = name, enabled, extra
+	return nil
+}
+
+// marshalObject encodes a JSON object holding the given key/value pairs, in
+// order, followed by the fields in extra, sorted by key.
+// Unlike [json.Marshal], characters like "<" and ">" are not escaped, since
+// they are common in dependency strings.
+func marshalObject(extra map[string]json.RawMessage, kv ...any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	buf.WriteByte('{')
+	write := func(key string, v any) error {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		if err := enc.Encode(key); err != nil {
+			return err
+		}
+		buf.Truncate(buf.Len() - 1) // Encode adds a newline.
+		buf.WriteByte(':')
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		buf.Truncate(buf.Len() - 1)
+		return nil
+	}
+
+	for i := 0; i+1 < len(kv); i += 2 {
+		if err := write(kv[i].(string), kv[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	keys := make([]string, 0, len(extra))
+	for k := range extra {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		if err := write(k, extra[k]); err != nil {
+			return nil, err
+		}
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// unmarshalObject decodes the JSON object in data, storing the value of each
+// of the given keys in the pointer that follows it, and returns the fields
+// whose keys were not given.
+func unmarshalObject(data []byte, kv ...any) (map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		key := kv[i].(string)
+		raw, ok := fields[key]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(raw, kv[i+1]); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		delete(fields, key)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+// EnableMods enables the named mods in the mod-list.json file of the
+// installation in installDir, adding installed mods that are not listed
+// yet.
+//
+// Every mod must be installed, or be one of the built-in mods of the game
+// and its installed expansions; otherwise, an error wrapping
+// [ErrModNotFound] is returned, and the file is not changed.
+// The previous contents of mod-list.json are kept in mod-list.json.bak.
+func EnableMods(installDir string, names ...string) error {
+	return setModsEnabled(installDir, names, true)
+}
+
+// DisableMods disables the named mods in the mod-list.json file of the
+// installation in installDir, without uninstalling them.
+//
+// Every mod must be listed in mod-list.json, or be installed; otherwise, an
+// error wrapping [ErrModNotFound] is returned, and the file is not changed.
+// The previous contents of mod-list.json are kept in mod-list.json.bak.
+func DisableMods(installDir string, names ...string) error {
+	return setModsEnabled(installDir, names, false)
+}
+
+// setModsEnabled implements [EnableMods] and [DisableMods].
+func setModsEnabled(installDir string, names []string, enabled bool) error {
+	list, err := LoadModList(installDir)
+	if err != nil {
+		return err
+	}
+
+	// Built-in mods are checked against the game's data directory, when
+	// it can be read.
+	builtins, err := Builtins(installDir)
+	if err != nil {
+		builtins = nil
+	}
+
+	var missing []string
+	for _, name := range names {
+		installed := IsBuiltin(name)
+		if installed && builtins != nil {
+			_, installed = builtins[name]
+		} else if !installed {
+			m := M{Name: name}
+			if err := m.findInstalledVersions(installDir); err != nil {
+				return fmt.Errorf("find installed versions of %s: %w", name, err)
+			}
+			installed = len(m.Versions) > 0
+		}
+		_, listed := list.Lookup(name)
+		if !installed && (enabled || !listed) {
+			missing = append(missing, name)
+			continue
+		}
+
+		if !list.setEnabled(name, enabled) {
+			list.Add(name, enabled)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrModNotFound, strings.Join(missing, ", "))
+	}
+
+	if err := backupModList(installDir); err != nil {
+		return err
+	}
+	return list.Save(installDir)
+}
+
+// backupModList copies mod-list.json to mod-list.json.bak, if it exists.
+func backupModList(installDir string) error {
+	path := modListPath(installDir)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("read mod list: %w", err)
+	}
+	if err := writeFileAtomic(path+".bak", data); err != nil {
+		return fmt.Errorf("back up mod list: %w", err)
+	}
+	return nil
+}
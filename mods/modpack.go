@@ -0,0 +1,253 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/nesv/factorio-tools/mods/settings"
+)
+
+// Modpack is a declarative, version-controllable description of a set of mods
+// and their settings.
+// Unlike a [Lockfile], which records exact versions and hashes, a modpack
+// lists the mods a curator wants, with optional version constraints, and
+// leaves dependency resolution to [Cache.Resolve].
+type Modpack struct {
+	Name string       `json:"name"`
+	Mods []ModpackMod `json:"mods"`
+
+	// Settings overrides the values of mod settings in mod-settings.dat.
+	// Settings are keyed by scope, then by the setting's name.
+	Settings map[settings.Scope]map[string]any `json:"settings,omitempty"`
+}
+
+// ModpackMod is a single mod in a [Modpack].
+type ModpackMod struct {
+	Name string `json:"name"`
+
+	// An optional version constraint, like ">= 1.2.0" or "= 1.2.3".
+	Version string `json:"version,omitempty"`
+
+	// Mods are enabled, unless Disabled is true.
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// Dependency returns the mod as a required [Dependency], which can be passed
+// to [Cache.Resolve].
+func (m ModpackMod) Dependency() (Dependency, error) {
+	d, err := ParseDependency(strings.TrimSpace(m.Name + " " + m.Version))
+	if err != nil {
+		return Dependency{}, err
+	}
+	if d.Kind != Required || d.Name != m.Name {
+		return Dependency{}, fmt.Errorf("invalid modpack entry for %s: %q", m.Name, m.Version)
+	}
+	return d, nil
+}
+
+// CreateModpack creates a [Modpack] with the given name from the mods
+// installed to installDir.
+// Each mod is constrained to its installed version, or newer.
+// Mods that ship with the game, like "base", are not included.
+//
+// When withSettings is true, the values of all settings in the installation's
+// mod-settings.dat are included as well.
+func CreateModpack(name, installDir string, withSettings bool) (*Modpack, error) {
+	installed, err := Load(installDir)
+	if err != nil {
+		return nil, fmt.Errorf("load mods: %w", err)
+	}
+
+	pack := &Modpack{Name: name, Mods: []ModpackMod{}}
+	for _, m := range installed {
+		n := len(m.Versions)
+		if n == 0 {
+			continue
+		}
+		pack.Mods = append(pack.Mods, ModpackMod{
+			Name:     m.Name,
+			Version:  ">= " + m.Versions[n-1].String(),
+			Disabled: !m.Enabled,
+		})
+	}
+
+	if withSettings {
+		s, err := settings.Load(installDir)
+		if err != nil {
+			return nil, fmt.Errorf("load mod settings: %w", err)
+		}
+		pack.Settings = make(map[settings.Scope]map[string]any)
+		for _, scope := range settings.Scopes() {
+			for _, name := range s.Names(scope) {
+				v, ok := s.Get(scope, name)
+				if !ok {
+					continue
+				}
+				if pack.Settings[scope] == nil {
+					pack.Settings[scope] = make(map[string]any)
+				}
+				pack.Settings[scope][name] = v.Interface()
+			}
+		}
+	}
+
+	return pack, nil
+}
+
+// LoadModpack reads a [Modpack] from path.
+func LoadModpack(path string) (*Modpack, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open modpack: %w", err)
+	}
+	defer f.Close()
+
+	var pack Modpack
+	if _, err := pack.ReadFrom(f); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return &pack, nil
+}
+
+// ReadFrom implements the [io.ReaderFrom] interface, populating the values in p from the contents in r.
+// On a successful invocation, ReadFrom will return 0, nil.
+func (p *Modpack) ReadFrom(r io.Reader) (int64, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(p); err != nil {
+		return 0, fmt.Errorf("decode json: %w", err)
+	}
+	return 0, nil
+}
+
+// WriteTo implements the [io.WriterTo] interface, and will encode the data in p to w.
+// On a successful invocation, WriteTo returns 0, nil.
+func (p *Modpack) WriteTo(w io.Writer) (int64, error) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(p); err != nil {
+		return 0, fmt.Errorf("encode json: %w", err)
+	}
+	return 0, nil
+}
+
+// Dependencies returns every mod in the modpack as a [Dependency].
+func (p *Modpack) Dependencies() ([]Dependency, error) {
+	deps := make([]Dependency, len(p.Mods))
+	for i, m := range p.Mods {
+		d, err := m.Dependency()
+		if err != nil {
+			return nil, err
+		}
+		deps[i] = d
+	}
+	return deps, nil
+}
+
+// ApplyModList enables or disables every mod in the modpack in list, adding
+// mods that are not already listed.
+func (p *Modpack) ApplyModList(list *ModList) {
+	for _, m := range p.Mods {
+		list.Add(m.Name, !m.Disabled)
+		if m.Disabled {
+			list.Disable(m.Name)
+		}
+	}
+}
+
+// ApplySettings sets the value of every setting in the modpack in s.
+// Settings that already exist in s keep their type, so that, for example,
+// integer settings are not turned into floating-point numbers.
+// New integer settings are stored as [settings.Number] values, unless s was
+// written by Factorio 2.0 or later, which has an integer type.
+func (p *Modpack) ApplySettings(s *settings.Settings) error {
+	for scope := range p.Settings {
+		if !slices.Contains(settings.Scopes(), scope) {
+			return fmt.Errorf("unknown settings scope: %s", scope)
+		}
+	}
+
+	for _, scope := range settings.Scopes() {
+		names := make([]string, 0, len(p.Settings[scope]))
+		for name := range p.Settings[scope] {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		for _, name := range names {
+			v, err := settingValue(p.Settings[scope][name])
+			if err != nil {
+				return fmt.Errorf("setting %s: %w", name, err)
+			}
+			if old, ok := s.Get(scope, name); ok {
+				if v, err = convertSetting(v, old.Type); err != nil {
+					return fmt.Errorf("setting %s: %w", name, err)
+				}
+				v.AnyType = old.AnyType
+			} else if v.Type == settings.Int && s.Version.Main < 2 {
+				v = settings.NumberValue(float64(v.Int))
+			}
+			s.Set(scope, name, v)
+		}
+	}
+	return nil
+}
+
+// settingValue converts a setting's value, decoded from a modpack, to a
+// property tree value.
+// Numbers without a fractional part are decoded as integers, and are
+// converted to the setting's type by [convertSetting].
+func settingValue(x any) (settings.Value, error) {
+	switch x := x.(type) {
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return settings.Value{Type: settings.Int, Int: i}, nil
+		}
+		f, err := x.Float64()
+		if err != nil {
+			return settings.Value{}, err
+		}
+		return settings.NumberValue(f), nil
+	case map[string]any:
+		m := make(map[string]any, len(x))
+		for k, v := range x {
+			if n, ok := v.(json.Number); ok {
+				f, err := n.Float64()
+				if err != nil {
+					return settings.Value{}, err
+				}
+				v = f
+			}
+			m[k] = v
+		}
+		return settings.ValueOf(m)
+	}
+	return settings.ValueOf(x)
+}
+
+// convertSetting converts the numeric value v to the type t of an existing
+// setting.
+// Other values must already have the type t.
+func convertSetting(v settings.Value, t settings.Type) (settings.Value, error) {
+	if v.Type == t {
+		return v, nil
+	}
+	switch {
+	case v.Type == settings.Int && t == settings.Number:
+		return settings.NumberValue(float64(v.Int)), nil
+	case v.Type == settings.Int && t == settings.Uint && v.Int >= 0:
+		return settings.Value{Type: settings.Uint, Uint: uint64(v.Int)}, nil
+	case v.Type == settings.Number && t == settings.Int && v.Number == float64(int64(v.Number)):
+		return settings.Value{Type: settings.Int, Int: int64(v.Number)}, nil
+	}
+	return settings.Value{}, fmt.Errorf("cannot use a %s value for a %s setting", v.Type, t)
+}
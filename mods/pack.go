@@ -0,0 +1,214 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// PackDir packages the mod source directory dir into a mod archive, and
+// writes it to dst.
+// The directory must contain the mod's info.json file, which must pass
+// [Info.Validate].
+// Within the archive, the mod's files are placed in a single top-level
+// directory named "NAME_VERSION", as the game expects.
+// Files that are not part of the mod, like version control directories,
+// editor settings, and other mod archives, are not included; see [isJunk].
+//
+// PackDir returns the mod's info.json, which can be used to name the
+// archive with [Info.FileName].
+func PackDir(dir string, dst io.Writer) (Info, error) {
+	info, err := readSourceInfo(dir)
+	if err != nil {
+		return Info{}, err
+	}
+	top := info.Name + "_" + info.Version
+
+	zw := zip.NewWriter(dst)
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if rel != "." && isJunk(d) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hdr, err := zip.FileInfoHeader(fi)
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(top, filepath.ToSlash(rel))
+		hdr.Method = zip.Deflate
+
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	})
+	if err != nil {
+		return Info{}, fmt.Errorf("add files: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return Info{}, fmt.Errorf("close zip: %w", err)
+	}
+
+	return info, nil
+}
+
+// PackDirFile packages the mod source directory dir, like [PackDir], into an
+// archive named "NAME_VERSION.zip" in destDir, and returns the archive's
+// path.
+// The archive is written to a temporary file first, so a failure never
+// leaves a partial archive in destDir.
+func PackDirFile(dir, destDir string) (string, Info, error) {
+	tmp, err := os.CreateTemp(destDir, packTempPrefix+"*")
+	if err != nil {
+		return "", Info{}, fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	info, err := PackDir(dir, tmp)
+	if err != nil {
+		return "", Info{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", Info{}, fmt.Errorf("close temp file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return "", Info{}, fmt.Errorf("chmod temp file: %w", err)
+	}
+
+	dst := filepath.Join(destDir, info.FileName())
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return "", Info{}, fmt.Errorf("rename temp file: %w", err)
+	}
+	return dst, info, nil
+}
+
+// packTempPrefix is the prefix of the temporary files written by
+// [PackDirFile], which are left out of archives in case destDir is the mod's
+// source directory.
+const packTempPrefix = ".pack-"
+
+// LoadDirInfo reads the info.json file of the mod source directory dir.
+func LoadDirInfo(dir string) (Info, error) {
+	info, err := readInfoFile(filepath.Join(dir, "info.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		// A common mistake is to give the directory containing the
+		// mod's directory.
+		if sub, ok := nestedModDir(dir); ok {
+			return Info{}, fmt.Errorf("no info.json in %s; did you mean %s?", dir, sub)
+		}
+		return Info{}, fmt.Errorf("no info.json in %s", dir)
+	}
+	return info, err
+}
+
+// readSourceInfo reads and validates the info.json file of the mod source
+// directory dir.
+func readSourceInfo(dir string) (Info, error) {
+	info, err := LoadDirInfo(dir)
+	if err != nil {
+		return Info{}, err
+	}
+	if err := info.Validate(); err != nil {
+		return Info{}, fmt.Errorf("invalid info.json:\n%w", err)
+	}
+	return info, nil
+}
+
+// nestedModDir returns the path to the only subdirectory of dir, if that
+// subdirectory contains an info.json file.
+func nestedModDir(dir string) (string, bool) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", false
+	}
+	var sub string
+	for _, e := range entries {
+		if isJunk(e) {
+			continue
+		}
+		if !e.IsDir() || sub != "" {
+			return "", false
+		}
+		sub = filepath.Join(dir, e.Name())
+	}
+	if sub == "" {
+		return "", false
+	}
+	if _, err := os.Stat(filepath.Join(sub, "info.json")); err != nil {
+		return "", false
+	}
+	return sub, true
+}
+
+// isJunk reports whether a file or directory in a mod source directory should
+// be left out of the mod's archive.
+func isJunk(d fs.DirEntry) bool {
+	name := d.Name()
+	if d.IsDir() {
+		switch name {
+		case ".git", ".hg", ".svn", ".github", ".gitlab", ".vscode", ".idea", "__MACOSX":
+			return true
+		}
+		return false
+	}
+	switch name {
+	case ".DS_Store", "Thumbs.db", "desktop.ini", ".gitignore", ".gitattributes", ".gitmodules", ".editorconfig":
+		return true
+	}
+	if strings.HasPrefix(name, packTempPrefix) {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	return strings.HasSuffix(name, "~") || ext == ".swp" || ext == ".zip"
+}
+
+// readInfoFile reads the info.json file at path.
+func readInfoFile(path string) (Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Info{}, fmt.Errorf("open info.json: %w", err)
+	}
+	defer f.Close()
+
+	var info Info
+	if err := json.NewDecoder(f).Decode(&info); err != nil {
+		return Info{}, fmt.Errorf("decode info.json: %w", err)
+	}
+	return info, nil
+}
@@ -0,0 +1,84 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Pins holds the versions that mods have been pinned to, keyed by the mod's
+// name.
+// A pinned mod must never be installed or upgraded to a version newer than
+// the one it is pinned to.
+type Pins map[string]Version
+
+// LoadPins reads pins from the file at path.
+// If the file does not exist, an empty set of pins is returned.
+func LoadPins(path string) (Pins, error) {
+	pins := make(Pins)
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return pins, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("open pins: %w", err)
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&pins); err != nil {
+		return nil, fmt.Errorf("decode json: %w", err)
+	}
+	return pins, nil
+}
+
+// Save writes the pins to the file at path, creating any missing parent
+// directories.
+func (p Pins) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), fs.ModePerm); err != nil {
+		return fmt.Errorf("make directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode json: %w", err)
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Allows reports whether the named mod may be installed at version v.
+// Mods that are not pinned may be installed at any version.
+func (p Pins) Allows(name string, v Version) bool {
+	pinned, ok := p[name]
+	if !ok {
+		return true
+	}
+	return v.Compare(pinned) <= 0
+}
+
+// Select returns the newest release, out of releases, that the pins allow for
+// the named mod.
+// If none of the releases are allowed, Select returns false.
+func (p Pins) Select(name string, releases []Release) (Release, bool) {
+	var (
+		best    Release
+		bestV   Version
+		matched bool
+	)
+	for _, r := range releases {
+		v, err := ParseVersion(r.Version)
+		if err != nil || !p.Allows(name, v) {
+			continue
+		}
+		if !matched || v.Compare(bestV) > 0 {
+			best, bestV, matched = r, v, true
+		}
+	}
+	return best, matched
+}
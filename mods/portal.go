@@ -0,0 +1,237 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/nesv/factorio-tools/httputil"
+)
+
+// DefaultPortalURL is the base URL of the Factorio mod portal.
+const DefaultPortalURL = "https://mods.factorio.com"
+
+// PortalClient makes requests to the [Mod portal API], without caching any
+// of the results.
+// The zero value is ready to use, and talks to [DefaultPortalURL].
+//
+// [Mod portal API]: https://wiki.factorio.com/Mod_portal_API
+type PortalClient struct {
+	// BaseURL of the mod portal, or of a server that implements the same
+	// API.
+	// When empty, [DefaultPortalURL] is used.
+	BaseURL string
+
+	// HTTPClient is used to send requests.
+	// When nil, the client returned by [httputil.Client] is used.
+	HTTPClient *http.Client
+
+	// APIKey is a factorio.com API key, sent as a bearer token to the
+	// endpoints that require one, like the [Mod upload API] used by
+	// [PortalClient.Publish].
+	// API keys are created at https://factorio.com/profile, with the
+	// usages each endpoint requires, and are separate from the username
+	// and token that [PortalClient.Download] sends in the query string.
+	//
+	// [Mod upload API]: https://wiki.factorio.com/Mod_upload_API
+	APIKey string
+}
+
+// ListOptions control which mods are returned by [PortalClient.List].
+// Zero values are not sent, so the mod portal's defaults apply.
+type ListOptions struct {
+	// Page of results to return, starting from 1.
+	Page int
+
+	// Number of results per page, or -1 for all results on a single
+	// page.
+	PageSize int
+
+	// Field to sort results by, like "name", "created_at", or
+	// "updated_at".
+	Sort string
+
+	// Either "asc" or "desc".
+	SortOrder string
+
+	// Only return the named mods.
+	Namelist []string
+}
+
+func (o ListOptions) values() url.Values {
+	q := url.Values{}
+	if o.Page > 0 {
+		q.Set("page", strconv.Itoa(o.Page))
+	}
+	switch {
+	case o.PageSize < 0:
+		q.Set("page_size", "max")
+	case o.PageSize > 0:
+		q.Set("page_size", strconv.Itoa(o.PageSize))
+	}
+	if o.Sort != "" {
+		q.Set("sort", o.Sort)
+	}
+	if o.SortOrder != "" {
+		q.Set("sort_order", o.SortOrder)
+	}
+	if len(o.Namelist) > 0 {
+		q.Set("namelist", strings.Join(o.Namelist, ","))
+	}
+	return q
+}
+
+// List returns a page of mods from the "/api/mods" endpoint.
+func (p *PortalClient) List(ctx context.Context, opts ListOptions) (*ListPage, error) {
+	urlStr := p.url("/api/mods")
+	if q := opts.values(); len(q) > 0 {
+		urlStr += "?" + q.Encode()
+	}
+
+	var list ListPage
+	if err := p.getJSON(ctx, urlStr, "", &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Get returns the short description of the named mod, from the
+// "/api/mods/{name}" endpoint.
+// The releases it returns do not include the mod's dependencies; use
+// [PortalClient.GetFull] for those.
+func (p *PortalClient) Get(ctx context.Context, name string) (*ModInfo, error) {
+	var info ModInfo
+	if err := p.getJSON(ctx, p.url("/api/mods/"+url.PathEscape(name)), name, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// GetFull returns all of the information the mod portal has about the named
+// mod, from the "/api/mods/{name}/full" endpoint.
+func (p *PortalClient) GetFull(ctx context.Context, name string) (*ModInfo, error) {
+	var info ModInfo
+	if err := p.getJSON(ctx, p.url("/api/mods/"+url.PathEscape(name)+"/full"), name, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Download downloads the given release to dst, using the factorio.com
+// username and token to authenticate.
+// The downloaded file must match the release's SHA1 checksum; otherwise, dst
+// is left untouched.
+// An error wrapping [ErrAuthFailed] is returned when the mod portal rejects
+// the username and token.
+func (p *PortalClient) Download(ctx context.Context, r Release, dst, username, token string) error {
+	if r.DownloadURL == "" {
+		return errors.New("release is missing a download url")
+	}
+	if username == "" || token == "" {
+		return fmt.Errorf("%w: username and token are required to download mods from the mod portal", ErrAuthRequired)
+	}
+
+	q := url.Values{}
+	q.Set("username", username)
+	q.Set("token", token)
+	urlStr := p.url(r.DownloadURL) + "?" + q.Encode()
+
+	return downloadFile(ctx, p.getHeader, dst, urlStr, nil, Checksums{SHA1: r.SHA1})
+}
+
+// url returns the absolute URL for the given path on the mod portal.
+func (p *PortalClient) url(path string) string {
+	base := p.BaseURL
+	if base == "" {
+		base = DefaultPortalURL
+	}
+	return strings.TrimSuffix(base, "/") + path
+}
+
+// getJSON decodes the JSON response from urlStr into v.
+// When name is not empty, a 404 response is reported as [ErrModNotFound].
+func (p *PortalClient) getJSON(ctx context.Context, urlStr, name string, v any) error {
+	resp, err := p.getHeader(ctx, urlStr, nil)
+	if err != nil {
+		return fmt.Errorf("http get %q: %w", urlStr, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusNotFound && name != "":
+		return fmt.Errorf("%w: %s", ErrModNotFound, name)
+	default:
+		return &statusError{url: urlStr, status: resp.Status, code: resp.StatusCode}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode json: %w", err)
+	}
+	return nil
+}
+
+// getHeader issues a GET request to urlStr, with the given headers added to
+// the request.
+func (p *PortalClient) getHeader(ctx context.Context, urlStr string, header http.Header) (*http.Response, error) {
+	if p.HTTPClient == nil {
+		return httputil.GetHeader(ctx, urlStr, header)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+	for k, vv := range header {
+		req.Header[k] = vv
+	}
+	req.Header.Set("user-agent", httputil.UserAgent)
+	return p.HTTPClient.Do(req)
+}
+
+// newAPIKeyRequest returns a request for the given path on the mod portal,
+// authenticated with the client's API key.
+// An error wrapping [ErrAuthRequired] is returned when the client does not
+// have an API key.
+func (p *PortalClient) newAPIKeyRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	if p.APIKey == "" {
+		return nil, fmt.Errorf("%w: an api key is required for %s", ErrAuthRequired, path)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.url(path), body)
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+	req.Header.Set("authorization", "Bearer "+p.APIKey)
+	return req, nil
+}
+
+// do sends req with the client's HTTPClient, or with [httputil.Do].
+func (p *PortalClient) do(req *http.Request) (*http.Response, error) {
+	if p.HTTPClient == nil {
+		return httputil.Do(req)
+	}
+	req.Header.Set("user-agent", httputil.UserAgent)
+	return p.HTTPClient.Do(req)
+}
+
+// statusError is returned by [PortalClient] methods when the mod portal
+// responds with an unexpected status.
+type statusError struct {
+	url    string
+	status string
+	code   int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("http get %q: %s", e.url, e.status)
+}
@@ -9,61 +9,75 @@ import (
 	"time"
 )
 
-type modlist struct {
-	Pagination pagination      `json:"pagination"`
-	Results    []modlistResult `json:"results"`
+// ListPage is a page of results from the "/api/mods" endpoint of the
+// [Mod portal API].
+//
+// [Mod portal API]: https://wiki.factorio.com/Mod_portal_API
+type ListPage struct {
+	Pagination Pagination   `json:"pagination"`
+	Results    []ListResult `json:"results"`
 }
 
-type pagination struct {
+// Pagination describes where a [ListPage] is in the full list of results.
+type Pagination struct {
 	Count     int             `json:"count"`      // Total number of mods that matched filters
-	Links     paginationLinks `json:"links"`      // Links to mod portal api request, preserving all filters and search queries
+	Links     PaginationLinks `json:"links"`      // Links to mod portal api request, preserving all filters and search queries
 	Page      int             `json:"page"`       // Current page number
 	PageCount int             `json:"page_count"` // Total number of pages returned
 	PageSize  int             `json:"page_size"`  // Number of results per page
 }
 
-type paginationLinks struct {
+// PaginationLinks are the URLs of other pages of results.
+// Links that do not apply, like the previous page of the first page, are nil.
+type PaginationLinks struct {
 	First *string `json:"first"`
 	Prev  *string `json:"prev"`
 	Next  *string `json:"next"`
 	Last  *string `json:"last"`
 }
 
-type modlistResult struct {
+// ListResult is a single mod in a [ListPage].
+type ListResult struct {
 	// Available on all endpoints.
-	DownloadsCount int          `json:"downloads_count"` // Number of downloads
-	Name           string       `json:"name"`            // Machine-readable ID
-	Owner          string       `json:"owner"`           // Factorio username of the mod's author
-	Releases       []modRelease `json:"releases"`        // Available versions of the mod available for download
-	Summary        string       `json:"summary"`         // Short mod description
-	Title          string       `json:"title"`           // Human-readable name for the mod
-	Category       string       `json:"category"`        // Single category describing the mod
+	DownloadsCount int       `json:"downloads_count"` // Number of downloads
+	Name           string    `json:"name"`            // Machine-readable ID
+	Owner          string    `json:"owner"`           // Factorio username of the mod's author
+	Releases       []Release `json:"releases"`        // Available versions of the mod available for download
+	Summary        string    `json:"summary"`         // Short mod description
+	Title          string    `json:"title"`           // Human-readable name for the mod
+	Category       string    `json:"category"`        // Single category describing the mod
 
 	// Only available on the "/api/mods" endpoint.
-	LatestRelease modRelease `json:"latest_release"` // Latest version of the mod available for download
+	LatestRelease Release `json:"latest_release"` // Latest version of the mod available for download
 
 	// Available on the "short" and "full" endpoints.
 	Thumbnail string `json:"thumbnail"` // Relative URL path to the thumbnail of the mod
 
 	// Available on the "full" endpoint.
-	Changelog   string     `json:"changelog"`   // Recent changes to the mod
-	CreatedAt   time.Time  `json:"created_at"`  // When the mod was created
-	Description string     `json:"description"` // Longer description of the mod, in text-only format
-	SourceURL   string     `json:"source_url"`  // URL to the mod's source code
-	Homepage    string     `json:"homepage"`    // URL to the mod's main project page, but could be anything
-	Tags        []string   `json:"tags"`        // List of tag names to categorize the mod
-	License     modLicense `json:"license"`     // License that applies to the mod
+	Changelog   string    `json:"changelog"`   // Recent changes to the mod
+	CreatedAt   time.Time `json:"created_at"`  // When the mod was created
+	Description string    `json:"description"` // Longer description of the mod, in text-only format
+	SourceURL   string    `json:"source_url"`  // URL to the mod's source code
+	Homepage    string    `json:"homepage"`    // URL to the mod's main project page, but could be anything
+	Tags        []string  `json:"tags"`        // List of tag names to categorize the mod
+	License     License   `json:"license"`     // License that applies to the mod
 }
 
-func (r modlistResult) thumbnailURL() string {
-	relpath := r.Thumbnail
+// noThumbnail is the thumbnail path reported by the mod portal for mods that
+// do not have a thumbnail.
+const noThumbnail = "/assets/.thumb.png"
+
+// thumbnailURL returns the absolute URL of a thumbnail, given the relative
+// path reported by the mod portal.
+func thumbnailURL(relpath string) string {
 	if relpath == "" {
-		relpath = "/assets/.thumb.png"
+		relpath = noThumbnail
 	}
-	return "https://assets-mod.factorio.com" + r.Thumbnail
+	return "https://assets-mod.factorio.com" + relpath
 }
 
-type modRelease struct {
+// Release describes a single, downloadable version of a mod.
+type Release struct {
 	DownloadURL string    `json:"download_url"`
 	FileName    string    `json:"file_name"`
 	ReleasedAt  time.Time `json:"released_at"`
@@ -71,17 +85,81 @@ type modRelease struct {
 	SHA1        string    `json:"sha1"`
 
 	// Copy of the mod's info.json file.
-	// In the "/api/mod/{name}" (a.k.a. "short") endpoint, only contains
+	// In the "/api/mods/{name}" (a.k.a. "short") endpoint, only contains
 	// "factorio_version".
-	// In the "/api/mod/{name}/full" endpoint, also contains an array of
+	// In the "/api/mods/{name}/full" endpoint, also contains an array of
 	// dependencies.
 	InfoJSON json.RawMessage `json:"info_json"`
 }
 
-type modLicense struct {
+// License describes the license that applies to a mod.
+type License struct {
 	Description string `json:"description"`
 	ID          string `json:"id"`
 	Name        string `json:"name"`
 	Title       string `json:"title"`
 	URL         string `json:"url"`
 }
+
+// FactorioVersion returns the "factorio_version" field from the release's
+// copy of the mod's info.json file.
+// If the field is not set, or the info.json could not be decoded, an empty
+// string is returned.
+func (r Release) FactorioVersion() string {
+	var v struct {
+		FactorioVersion string `json:"factorio_version"`
+	}
+	if err := json.Unmarshal(r.InfoJSON, &v); err != nil {
+		return ""
+	}
+	return v.FactorioVersion
+}
+
+// Image is a screenshot, or other image, shown on a mod's page on the mod
+// portal.
+type Image struct {
+	ID        string `json:"id"`
+	Thumbnail string `json:"thumbnail"` // URL of a smaller copy of the image
+	URL       string `json:"url"`
+}
+
+// ModInfo holds the information about a mod returned by the
+// "/api/mods/{name}" (a.k.a. "short") and "/api/mods/{name}/full" endpoints
+// of the [Mod portal API].
+// Fields that are only returned by the full endpoint are marked as such, and
+// are left as zero values by the short endpoint.
+//
+// [Mod portal API]: https://wiki.factorio.com/Mod_portal_API
+type ModInfo struct {
+	// Available on the short and full endpoints.
+	Name           string    `json:"name"`            // Machine-readable ID
+	Title          string    `json:"title"`           // Human-readable name for the mod
+	Owner          string    `json:"owner"`           // Factorio username of the mod's author
+	Summary        string    `json:"summary"`         // Short mod description
+	Category       string    `json:"category"`        // Single category describing the mod
+	DownloadsCount int       `json:"downloads_count"` // Number of downloads
+	Score          float64   `json:"score"`           // Score of the mod, used for sorting mods on the mod portal
+	Thumbnail      string    `json:"thumbnail"`       // Relative URL path to the thumbnail of the mod
+	Releases       []Release `json:"releases"`        // Available versions of the mod, oldest first
+
+	// Only available on the full endpoint.
+	Description string    `json:"description"` // Longer description of the mod, in text-only format
+	Tags        []string  `json:"tags"`        // List of tag names to categorize the mod
+	Changelog   string    `json:"changelog"`   // Recent changes to the mod
+	CreatedAt   time.Time `json:"created_at"`  // When the mod was created
+	UpdatedAt   time.Time `json:"updated_at"`  // When the mod was last updated
+	SourceURL   string    `json:"source_url"`  // URL to the mod's source code
+	Homepage    string    `json:"homepage"`    // URL to the mod's main project page, but could be anything
+	License     License   `json:"license"`     // License that applies to the mod
+	Images      []Image   `json:"images"`      // Images shown on the mod's page
+	Deprecated  bool      `json:"deprecated"`  // Whether the mod has been marked as deprecated by its author
+}
+
+// LatestRelease returns the most-recent release of the mod.
+// If the mod has no releases, LatestRelease returns false.
+func (m ModInfo) LatestRelease() (Release, bool) {
+	if len(m.Releases) == 0 {
+		return Release{}, false
+	}
+	return m.Releases[len(m.Releases)-1], true
+}
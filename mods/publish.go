@@ -0,0 +1,144 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Publish uploads the mod archive at zipPath as a new release of a mod that
+// already exists on the mod portal, like [PortalClient.Publish], using the
+// given API key.
+func Publish(ctx context.Context, apiKey, zipPath string) (Info, error) {
+	return (&PortalClient{APIKey: apiKey}).Publish(ctx, zipPath)
+}
+
+// Publish uploads the mod archive at zipPath as a new release of a mod that
+// already exists on the mod portal, using the [Mod upload API].
+// The mod's name is read from the archive's info.json file.
+//
+// The client's API key must have the "ModPortal: Upload Mods" usage.
+//
+// [Mod upload API]: https://wiki.factorio.com/Mod_upload_API
+func (p *PortalClient) Publish(ctx context.Context, zipPath string) (Info, error) {
+	if p.APIKey == "" {
+		return Info{}, fmt.Errorf("%w: an api key is required to publish mods", ErrAuthRequired)
+	}
+
+	info, err := LoadFileInfo(zipPath)
+	if err != nil {
+		return Info{}, err
+	}
+
+	form := url.Values{"mod": {info.Name}}
+	req, err := p.newAPIKeyRequest(ctx, http.MethodPost, "/api/v2/mods/releases/init_upload", strings.NewReader(form.Encode()))
+	if err != nil {
+		return Info{}, err
+	}
+	req.Header.Set("content-type", "application/x-www-form-urlencoded")
+
+	var upload struct {
+		UploadURL string `json:"upload_url"`
+	}
+	if err := p.doUploadRequest(req, &upload); err != nil {
+		return Info{}, fmt.Errorf("init upload: %w", err)
+	}
+	if upload.UploadURL == "" {
+		return Info{}, errors.New("init upload: no upload url in response")
+	}
+
+	if err := p.uploadFile(ctx, upload.UploadURL, zipPath); err != nil {
+		return Info{}, fmt.Errorf("upload %s: %w", filepath.Base(zipPath), err)
+	}
+	return info, nil
+}
+
+// uploadFile sends the file at path to urlStr, as the "file" field of a
+// multipart form.
+func (p *PortalClient) uploadFile(ctx context.Context, urlStr, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat: %w", err)
+	}
+
+	// Write the multipart headers and trailer separately, so the archive
+	// can be streamed from disk with a known content length.
+	var head, tail bytes.Buffer
+	mw := multipart.NewWriter(&head)
+	if _, err := mw.CreateFormFile("file", filepath.Base(path)); err != nil {
+		return fmt.Errorf("create form file: %w", err)
+	}
+	tail.WriteString("\r\n--" + mw.Boundary() + "--\r\n")
+
+	body := io.MultiReader(&head, f, &tail)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, urlStr, body)
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+	req.ContentLength = int64(head.Len()) + st.Size() + int64(tail.Len())
+	req.Header.Set("content-type", mw.FormDataContentType())
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := p.doUploadRequest(req, &result); err != nil {
+		return err
+	}
+	if !result.Success {
+		return errors.New("upload was not successful")
+	}
+	return nil
+}
+
+// doUploadRequest sends req, and decodes the JSON response into v.
+// Errors reported by the mod portal are returned as errors, wrapping
+// [ErrAuthFailed] or [ErrModNotFound] where they apply.
+func (p *PortalClient) doUploadRequest(req *http.Request, v any) error {
+	resp, err := p.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error   string `json:"error"`
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			return fmt.Errorf("unexpected response: %s", resp.Status)
+		}
+		err := fmt.Errorf("%s (%s)", apiErr.Message, apiErr.Error)
+		switch apiErr.Error {
+		case "InvalidApiKey", "Forbidden":
+			return fmt.Errorf("%w: %w", ErrAuthFailed, err)
+		case "UnknownMod":
+			return fmt.Errorf("%w: %w", ErrModNotFound, err)
+		}
+		return err
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode json: %w", err)
+	}
+	return nil
+}
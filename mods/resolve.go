@@ -0,0 +1,644 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Resolved is a single mod that was selected by a [Resolver].
+type Resolved struct {
+	Name    string
+	Version Version
+
+	// The release to install.
+	// Release is the zero value when Installed is true.
+	Release Release
+
+	// Installed is true when the mod is already installed at a version that
+	// satisfies all constraints, and does not need to be downloaded.
+	Installed bool
+
+	// Requested is true when the mod was one of the mods passed to
+	// [Resolver.Resolve], rather than a dependency.
+	Requested bool
+}
+
+// ReleaseSource provides the release history of mods to a [Resolver].
+// [Cache] is a ReleaseSource.
+type ReleaseSource interface {
+	// Releases returns every release of the named mod.
+	// Only releases whose info includes the mod's dependencies can be
+	// resolved correctly.
+	Releases(ctx context.Context, name string) ([]Release, error)
+}
+
+// Releases returns every release of the named mod, retrieved with
+// [Cache.FullInfo].
+func (c *Cache) Releases(ctx context.Context, name string) ([]Release, error) {
+	info, err := c.FullInfo(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return info.Releases, nil
+}
+
+// ResolveOption is a functional option that can be passed to [NewResolver],
+// or [Cache.Resolve], to adjust how dependencies are resolved.
+type ResolveOption func(*Resolver)
+
+// WithInstalled tells the resolver which mods are already installed, and at
+// which version.
+// Installed mods are kept at their installed version, as long as it satisfies
+// every constraint placed on the mod.
+func WithInstalled(installed map[string]Version) ResolveOption {
+	return func(r *Resolver) {
+		r.installed = installed
+	}
+}
+
+// WithInstalledDependencies tells the resolver the dependencies declared in
+// the info.json of each installed mod, keyed by the mod's name, so that the
+// selected mods can be checked against the installed mods that declare them
+// incompatible.
+// Only the installed mods passed to [WithInstalled] are checked.
+func WithInstalledDependencies(deps map[string][]Dependency) ResolveOption {
+	return func(r *Resolver) {
+		r.installedDeps = deps
+	}
+}
+
+// WithUpgrades tells the resolver to prefer the newest releases of the named
+// installed mods over their installed versions, which are only kept when no
+// newer release can be used.
+// When no names are given, every mod is upgraded.
+func WithUpgrades(names ...string) ResolveOption {
+	return func(r *Resolver) {
+		r.upgradeAll = len(names) == 0
+		r.upgrades = make(map[string]bool, len(names))
+		for _, name := range names {
+			r.upgrades[name] = true
+		}
+	}
+}
+
+// WithPins prevents the resolver from selecting any release newer than the
+// version a mod is pinned to.
+func WithPins(pins Pins) ResolveOption {
+	return func(r *Resolver) {
+		r.pins = pins
+	}
+}
+
+// WithoutDependencies stops the resolver from walking the dependencies of
+// the requested mods, so only the requested mods are selected.
+// Incompatibilities declared by the requested mods are still checked.
+func WithoutDependencies() ResolveOption {
+	return func(r *Resolver) {
+		r.noDeps = true
+	}
+}
+
+// WithGameVersion checks every dependency on the "base" mod against v, the
+// version of Factorio the mods will be installed for.
+// Without this option, dependencies on "base" are ignored.
+func WithGameVersion(v Version) ResolveOption {
+	return func(r *Resolver) {
+		r.game = &v
+	}
+}
+
+// WithBuiltins tells the resolver which built-in mods, like "base" and
+// "space-age", the installation provides, and at which version, as returned
+// by [Builtins].
+// Built-in mods are never selected for download; a required dependency on a
+// built-in mod the installation does not provide cannot be satisfied.
+// Without this option, dependencies on built-in mods other than "base" are
+// ignored.
+func WithBuiltins(builtins map[string]Version) ResolveOption {
+	return func(r *Resolver) {
+		r.builtins = builtins
+	}
+}
+
+// maxResolveSteps limits the number of releases a [Resolver] will try
+// before giving up.
+const maxResolveSteps = 10000
+
+// Resolver selects a set of releases that satisfies the dependencies of a
+// set of requested mods.
+//
+// A Resolver backtracks: when the newest release of a mod cannot be used
+// with the rest of the set, older releases are tried, and earlier choices
+// are revisited, until every constraint is satisfied or every combination
+// has been ruled out.
+type Resolver struct {
+	source        ReleaseSource
+	installed     map[string]Version
+	installedDeps map[string][]Dependency // Set by WithInstalledDependencies.
+	pins          Pins
+	noDeps        bool
+	game          *Version
+	builtins      map[string]Version
+
+	upgrades   map[string]bool // Set by WithUpgrades.
+	upgradeAll bool
+
+	requested []Dependency
+	releases  map[string][]Release // Cached results from source.
+	selected  map[string]*selection
+	order     []string // Names in selected, in the order they were chosen.
+	steps     int
+	conflict  *ResolveError // The first conflict that was found.
+}
+
+// selection is a release chosen by the resolver, along with its
+// dependencies.
+type selection struct {
+	Resolved
+	deps []Dependency
+}
+
+// NewResolver returns a new [Resolver] that reads release histories from
+// source.
+func NewResolver(source ReleaseSource, options ...ResolveOption) *Resolver {
+	r := &Resolver{source: source}
+	for _, opt := range options {
+		opt(r)
+	}
+	return r
+}
+
+// Resolve is a convenience wrapper for [NewResolver] and [Resolver.Resolve],
+// using c as the source of each mod's release history.
+func (c *Cache) Resolve(ctx context.Context, requested []Dependency, options ...ResolveOption) ([]Resolved, error) {
+	return NewResolver(c, options...).Resolve(ctx, requested)
+}
+
+// Resolve walks the transitive required dependencies of the requested mods,
+// and returns the complete set of mods that must be installed, sorted by
+// name.
+// Each mod appears once, at a version that satisfies every version
+// constraint placed on it by the requested mods, and by every other mod in
+// the set.
+// Installed versions are preferred, followed by the newest releases, unless
+// the mod is being upgraded; see [WithUpgrades].
+//
+// Version constraints on optional dependencies are honored when the
+// optional dependency is in the set, or installed, and no mod in the set may
+// be incompatible with another mod in the set, or with an installed mod.
+// Incompatibilities declared by installed mods that are not in the set are
+// only checked when their dependencies are given with
+// [WithInstalledDependencies].
+//
+// When no such set exists, the returned error is a [*ResolveError]
+// explaining the first conflict that was found.
+func (r *Resolver) Resolve(ctx context.Context, requested []Dependency) ([]Resolved, error) {
+	r.requested = requested
+	r.releases = make(map[string][]Release)
+	r.selected = make(map[string]*selection)
+	r.order = nil
+	r.steps = 0
+	r.conflict = nil
+
+	var pending []string
+	for _, d := range requested {
+		pending = append(pending, d.Name)
+	}
+	if err := r.solve(ctx, pending); err != nil {
+		if errors.Is(err, errConflict) {
+			return nil, r.conflict
+		}
+		return nil, err
+	}
+
+	resolved := make([]Resolved, 0, len(r.selected))
+	for _, s := range r.selected {
+		resolved = append(resolved, s.Resolved)
+	}
+	slices.SortFunc(resolved, func(a, b Resolved) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return resolved, nil
+}
+
+// errConflict is returned by [Resolver.solve] when the current set of
+// choices cannot be completed.
+// The details are kept in Resolver.conflict.
+var errConflict = errors.New("conflict")
+
+// solve selects a release of every mod named in pending, and their
+// dependencies.
+// Mods are chosen in order; when a mod has no usable release, solve returns
+// errConflict so the caller can try its next candidate.
+func (r *Resolver) solve(ctx context.Context, pending []string) error {
+	for len(pending) > 0 {
+		if _, ok := r.selected[pending[0]]; !ok && !IsBuiltin(pending[0]) {
+			break
+		}
+		pending = pending[1:]
+	}
+	if len(pending) == 0 {
+		if name, c, ok := r.checkInstalled(); !ok {
+			r.addConflict(name, c)
+			return errConflict
+		}
+		return nil
+	}
+	name, rest := pending[0], pending[1:]
+
+	candidates, err := r.candidates(ctx, name)
+	if err != nil {
+		return err
+	}
+	if len(candidates) == 0 {
+		r.addConflict(name, nil)
+		return errConflict
+	}
+
+	for _, s := range candidates {
+		if r.steps++; r.steps > maxResolveSteps {
+			return fmt.Errorf("gave up after trying %d releases", maxResolveSteps)
+		}
+		if other, c, ok := r.check(s); !ok {
+			r.addConflict(other, c)
+			continue
+		}
+
+		r.selected[name] = s
+		r.order = append(r.order, name)
+
+		next := slices.Clone(rest)
+		if !r.noDeps {
+			for _, d := range s.deps {
+				if d.Kind == Required || d.Kind == NoLoadOrder {
+					next = append(next, d.Name)
+				}
+			}
+		}
+
+		err := r.solve(ctx, next)
+		if err == nil {
+			return nil
+		}
+
+		delete(r.selected, name)
+		r.order = r.order[:len(r.order)-1]
+		if !errors.Is(err, errConflict) {
+			return err
+		}
+	}
+	return errConflict
+}
+
+// candidates returns the releases of the named mod that satisfy every
+// constraint currently placed on it, in the order they should be tried: the
+// installed version first, then from newest to oldest.
+func (r *Resolver) candidates(ctx context.Context, name string) ([]*selection, error) {
+	constraints := r.constraints(name)
+	allows := func(v Version) bool {
+		for _, c := range constraints {
+			if c.Dependency.Kind == Incompatible || !c.Dependency.Allows(v) {
+				return false
+			}
+		}
+		return r.pins.Allows(name, v)
+	}
+
+	// The release history of an installed mod is only needed to find its
+	// dependencies, or a newer release, so an installed mod can be
+	// resolved without it (for example, when offline).
+	releases, err := r.releasesOf(ctx, name)
+	if err != nil && !r.isInstalled(name) {
+		return nil, fmt.Errorf("get mod info: %w", err)
+	}
+	fetchErr := err
+
+	var candidates []*selection
+	if v, ok := r.installed[name]; ok && allows(v) {
+		s := &selection{Resolved: Resolved{Name: name, Version: v, Installed: true}}
+
+		// Mods that are not on the mod portal (for example, private
+		// mods) are treated as not having any dependencies.
+		if i := slices.IndexFunc(releases, func(rel Release) bool { return sameVersion(rel.Version, v.String()) }); i >= 0 {
+			var err error
+			if s.deps, err = releaseDependencies(name, releases[i]); err != nil {
+				return nil, err
+			}
+		}
+		candidates = append(candidates, s)
+	}
+
+	for _, rel := range releases {
+		v, err := ParseVersion(rel.Version)
+		if err != nil || !allows(v) {
+			continue
+		}
+		if iv, ok := r.installed[name]; ok && v.Compare(iv) == 0 {
+			continue
+		}
+		deps, err := releaseDependencies(name, rel)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, &selection{
+			Resolved: Resolved{Name: name, Version: v, Release: rel},
+			deps:     deps,
+		})
+	}
+
+	if len(candidates) == 0 && fetchErr != nil {
+		return nil, fmt.Errorf("get mod info: %w", fetchErr)
+	}
+
+	// Keep the installed version first, unless the mod is being upgraded,
+	// and sort the rest from newest to oldest.
+	start := 0
+	if len(candidates) > 0 && candidates[0].Installed && !r.upgrading(name) {
+		start = 1
+	}
+	slices.SortStableFunc(candidates[start:], func(a, b *selection) int {
+		return b.Version.Compare(a.Version)
+	})
+
+	requested := slices.ContainsFunc(r.requested, func(d Dependency) bool { return d.Name == name })
+	for _, s := range candidates {
+		s.Requested = requested
+	}
+	return candidates, nil
+}
+
+// check reports whether s can be added to the current selection.
+// When it cannot, check returns the name of the conflicting mod, and the
+// constraint that s would have placed on it.
+func (r *Resolver) check(s *selection) (string, *Constraint, bool) {
+	// The constraints on s itself were checked by candidates, so only
+	// the constraints that s places on other mods are left.
+	for _, d := range s.deps {
+		c := &Constraint{Dependency: d, From: s.Name, FromVersion: s.Version}
+		if IsBuiltin(d.Name) {
+			v, provided, checked := r.builtinVersion(d.Name)
+			switch {
+			case !checked || d.Kind == Incompatible:
+			case (d.Kind == Required || d.Kind == NoLoadOrder) && !provided:
+				return d.Name, c, false
+			case provided && !d.Allows(v):
+				return d.Name, c, false
+			}
+			continue
+		}
+
+		// Mods that are installed, but not selected, may still be
+		// selected at another version, so they are checked by
+		// checkInstalled once every mod has been selected.
+		other, ok := r.selected[d.Name]
+		if !ok {
+			continue
+		}
+		if d.Kind == Incompatible || !d.Allows(other.Version) {
+			return d.Name, c, false
+		}
+	}
+	return "", nil, true
+}
+
+// builtinVersion returns the version of the named built-in mod, and whether
+// the installation provides it.
+// checked is false when the resolver was not told which built-in mods are
+// provided, so constraints on the mod cannot be checked.
+func (r *Resolver) builtinVersion(name string) (v Version, provided, checked bool) {
+	if name == "base" && r.game != nil {
+		return *r.game, true, true
+	}
+	if r.builtins == nil {
+		return Version{}, false, false
+	}
+	v, provided = r.builtins[name]
+	return v, provided, true
+}
+
+// checkInstalled reports whether the mods that are installed, but were not
+// selected, satisfy the constraints placed on them by the selected mods, and
+// whether none of them declare a selected mod incompatible.
+// When they do not, checkInstalled returns the name of the conflicting mod,
+// and, for an incompatibility declared by an installed mod, the constraint it
+// places on it.
+func (r *Resolver) checkInstalled() (string, *Constraint, bool) {
+	for _, from := range r.order {
+		s := r.selected[from]
+		for _, d := range s.deps {
+			if _, ok := r.selected[d.Name]; ok {
+				continue
+			}
+			v, ok := r.installed[d.Name]
+			if !ok {
+				continue
+			}
+			if d.Kind == Incompatible || !d.Allows(v) {
+				return d.Name, nil, false
+			}
+		}
+	}
+
+	names := make([]string, 0, len(r.installedDeps))
+	for name := range r.installedDeps {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		// A selected mod replaces the installed version, whose
+		// dependencies no longer apply.
+		if _, ok := r.selected[name]; ok {
+			continue
+		}
+		v, ok := r.installed[name]
+		if !ok {
+			continue
+		}
+		for _, d := range r.installedDeps[name] {
+			if d.Kind != Incompatible {
+				continue
+			}
+			if _, ok := r.selected[d.Name]; ok {
+				return d.Name, &Constraint{Dependency: d, From: name, FromVersion: v}, false
+			}
+		}
+	}
+	return "", nil, true
+}
+
+func (r *Resolver) upgrading(name string) bool {
+	return r.upgradeAll || r.upgrades[name]
+}
+
+func (r *Resolver) isInstalled(name string) bool {
+	_, ok := r.installed[name]
+	return ok
+}
+
+func (r *Resolver) releasesOf(ctx context.Context, name string) ([]Release, error) {
+	if rr, ok := r.releases[name]; ok {
+		return rr, nil
+	}
+	rr, err := r.source.Releases(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	r.releases[name] = rr
+	return rr, nil
+}
+
+// constraints returns every constraint placed on the named mod by the
+// requested mods, and the currently-selected mods, in the order they were
+// added.
+func (r *Resolver) constraints(name string) []Constraint {
+	var cc []Constraint
+	for _, d := range r.requested {
+		if d.Name == name {
+			cc = append(cc, Constraint{Dependency: d})
+		}
+	}
+	for _, from := range r.order {
+		s := r.selected[from]
+		for _, d := range s.deps {
+			if d.Name == name {
+				cc = append(cc, Constraint{Dependency: d, From: from, FromVersion: s.Version})
+			}
+		}
+	}
+	return cc
+}
+
+// addConflict records the first conflict found while resolving, on the
+// named mod.
+// extra is a constraint that was being added when the conflict was found,
+// and may be nil.
+func (r *Resolver) addConflict(name string, extra *Constraint) {
+	if r.conflict != nil {
+		return
+	}
+	err := &ResolveError{Mod: name, Constraints: r.constraints(name)}
+	if extra != nil {
+		err.Constraints = append(err.Constraints, *extra)
+	}
+	if IsBuiltin(name) {
+		err.Builtin = true
+		if v, ok, _ := r.builtinVersion(name); ok {
+			err.Installed = &v
+		}
+	} else if s, ok := r.selected[name]; ok {
+		v := s.Version
+		err.Selected = &v
+	} else if v, ok := r.installed[name]; ok {
+		err.Installed = &v
+	}
+	if v, ok := r.pins[name]; ok {
+		err.Pinned = &v
+	}
+	r.conflict = err
+}
+
+func releaseDependencies(name string, rel Release) ([]Dependency, error) {
+	info, err := rel.Info()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", name, rel.Version, err)
+	}
+	deps, err := info.ParseDependencies()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", name, rel.Version, err)
+	}
+	return deps, nil
+}
+
+// Constraint is a dependency, along with the mod that declared it.
+type Constraint struct {
+	Dependency Dependency
+
+	// The mod, and the version of the mod, that declared the dependency.
+	// From is empty when the dependency was requested.
+	From        string
+	FromVersion Version
+}
+
+func (c Constraint) String() string {
+	d := c.Dependency
+	kind := d.Kind
+	d.Kind = Required
+	switch {
+	case c.From == "":
+		return d.String() + " (requested)"
+	case kind == Incompatible:
+		return fmt.Sprintf("%s (incompatible with %s %s)", d.Name, c.From, c.FromVersion)
+	case kind == Optional || kind == HiddenOptional:
+		return fmt.Sprintf("%s (optional for %s %s)", d, c.From, c.FromVersion)
+	}
+	return fmt.Sprintf("%s (required by %s %s)", d, c.From, c.FromVersion)
+}
+
+// ResolveError is returned by [Resolver.Resolve] when no set of releases
+// satisfies every constraint.
+// It describes the first conflict that was found, which is the one that
+// prevented the newest releases from being used.
+//
+// ResolveError wraps [ErrReleaseNotFound].
+type ResolveError struct {
+	// The mod that could not be selected.
+	Mod string
+
+	// Every constraint placed on Mod when the conflict was found.
+	Constraints []Constraint
+
+	// The version of Mod that is installed, if any.
+	// For the "base" mod, this is the version given with
+	// [WithGameVersion].
+	Installed *Version
+
+	// Builtin is true when Mod ships with the game, or one of its
+	// expansions, rather than being downloaded from the mod portal.
+	// When Installed is nil, the installation does not provide the mod.
+	Builtin bool
+
+	// The version of Mod that was already selected, if any.
+	Selected *Version
+
+	// The version Mod is pinned to, if any.
+	Pinned *Version
+}
+
+func (e *ResolveError) Error() string {
+	cc := make([]string, 0, len(e.Constraints)+3)
+	for _, c := range e.Constraints {
+		cc = append(cc, c.String())
+	}
+	switch {
+	case e.Installed != nil && e.Mod == "base":
+		cc = append(cc, fmt.Sprintf("base = %s (game version)", e.Installed))
+	case e.Installed != nil && e.Builtin:
+		cc = append(cc, fmt.Sprintf("%s = %s (installed with the game)", e.Mod, e.Installed))
+	case e.Installed != nil:
+		cc = append(cc, fmt.Sprintf("%s = %s (installed)", e.Mod, e.Installed))
+	case e.Builtin:
+		cc = append(cc, fmt.Sprintf("%s is not installed (it ships with an expansion of the game)", e.Mod))
+	}
+	if e.Selected != nil {
+		cc = append(cc, fmt.Sprintf("%s = %s (selected)", e.Mod, e.Selected))
+	}
+	if e.Pinned != nil {
+		cc = append(cc, fmt.Sprintf("%s <= %s (pinned)", e.Mod, e.Pinned))
+	}
+	what := "release"
+	if e.Builtin {
+		what = "installed version"
+	}
+	return fmt.Sprintf("%s: no %s of %s satisfies all constraints: %s", ErrReleaseNotFound, what, e.Mod, strings.Join(cc, ", "))
+}
+
+func (e *ResolveError) Unwrap() error {
+	return ErrReleaseNotFound
+}
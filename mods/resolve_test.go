@@ -0,0 +1,88 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// releaseMap is a [ReleaseSource] of releases, keyed by mod name.
+type releaseMap map[string][]Release
+
+func (m releaseMap) Releases(ctx context.Context, name string) ([]Release, error) {
+	rr, ok := m[name]
+	if !ok {
+		return nil, ErrModNotFound
+	}
+	return rr, nil
+}
+
+func testRelease(t *testing.T, name, version string, deps ...string) Release {
+	t.Helper()
+	info, err := json.Marshal(Info{Name: name, Version: version, Dependencies: deps})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Release{Version: version, InfoJSON: info}
+}
+
+func TestResolveInstalledIncompatible(t *testing.T) {
+	source := releaseMap{
+		"flib":     {testRelease(t, "flib", "0.12.0")},
+		"ltn":      {testRelease(t, "ltn", "2.0.0", "! cybersyn")},
+		"cybersyn": {testRelease(t, "cybersyn", "1.3.0", "flib >= 0.12.0")},
+	}
+	installed := map[string]Version{"ltn": parseVersion("2.0.0")}
+	requested := []Dependency{{Name: "cybersyn"}}
+
+	// Only the installed mod declares the incompatibility.
+	r := NewResolver(source,
+		WithInstalled(installed),
+		WithInstalledDependencies(map[string][]Dependency{
+			"ltn": {{Name: "cybersyn", Kind: Incompatible}},
+		}),
+	)
+	_, err := r.Resolve(context.Background(), requested)
+	var rerr *ResolveError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("got error %v, want a *ResolveError", err)
+	}
+	if rerr.Mod != "cybersyn" {
+		t.Errorf("got conflict on %q, want %q", rerr.Mod, "cybersyn")
+	}
+	found := false
+	for _, c := range rerr.Constraints {
+		if c.From == "ltn" && c.Dependency.Kind == Incompatible {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("constraints %v do not include the incompatibility declared by ltn", rerr.Constraints)
+	}
+
+	// Upgrading the installed mod replaces its dependencies with the
+	// selected release's.
+	requested = append(requested, Dependency{Name: "ltn"})
+	source["ltn"] = append(source["ltn"], testRelease(t, "ltn", "2.1.0"))
+	r = NewResolver(source,
+		WithInstalled(installed),
+		WithInstalledDependencies(map[string][]Dependency{
+			"ltn": {{Name: "cybersyn", Kind: Incompatible}},
+		}),
+		WithUpgrades("ltn"),
+	)
+	resolved, err := r.Resolve(context.Background(), requested)
+	if err != nil {
+		t.Fatalf("resolve with ltn upgraded: %v", err)
+	}
+	for _, m := range resolved {
+		if m.Name == "ltn" && m.Version.String() != "2.1.0" {
+			t.Errorf("got ltn %s, want 2.1.0", m.Version)
+		}
+	}
+}
@@ -5,32 +5,24 @@
 package mods
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"slices"
-	"strconv"
 	"strings"
 	"time"
 )
 
 // Load collects all of the mods currently installed to the installation directory.
 func Load(installationDir string) ([]M, error) {
-	modDir := filepath.Join(installationDir, "mods")
-	f, err := os.Open(filepath.Join(modDir, "mod-list.json"))
+	list, err := LoadModList(installationDir)
 	if err != nil {
-		return nil, fmt.Errorf("open mod list: %w", err)
-	}
-	defer f.Close()
-
-	var list modlistjson
-	if err := json.NewDecoder(f).Decode(&list); err != nil {
-		return nil, fmt.Errorf("decode json: %w", err)
+		return nil, err
 	}
 
 	mods := make([]M, len(list.Mods))
-	for i, m := range list.Mods {
+	for i, e := range list.Mods {
+		m := M{Name: e.Name, Enabled: e.Enabled}
 		if err := m.findInstalledVersions(installationDir); err != nil {
 			return nil, fmt.Errorf("find installed versions: %w", err)
 		}
@@ -49,10 +41,6 @@ func Load(installationDir string) ([]M, error) {
 	return mods, nil
 }
 
-type modlistjson struct {
-	Mods []M `json:"mods"`
-}
-
 type M struct {
 	Name    string `json:"name"`
 	Enabled bool   `json:"enabled"`
@@ -72,6 +60,43 @@ type M struct {
 
 	// The mod's category.
 	Category string `json:"-"`
+
+	// The number of times the mod has been downloaded from the mod portal.
+	Downloads int `json:"-"`
+
+	// The mod's title, as shown on the mod portal.
+	Title string `json:"-"`
+
+	// The name of the factorio.com user who owns the mod.
+	Owner string `json:"-"`
+
+	// The URL of the mod's thumbnail image, or an empty string if the mod
+	// does not have a thumbnail.
+	ThumbnailURL string `json:"-"`
+
+	// The version of Factorio the latest release targets, like "1.1".
+	FactorioVersion string `json:"-"`
+
+	// Versions that are installed as unpacked directories, rather than
+	// archives, keyed by version.
+	dirs map[Version]string
+}
+
+// Path returns the path to version v of the mod in the installation
+// directory: the mod's unpacked directory, when v is installed as one, or
+// its "NAME_VERSION.zip" archive.
+func (m M) Path(installDir string, v Version) string {
+	if dir, ok := m.dirs[v]; ok {
+		return dir
+	}
+	return filepath.Join(installDir, "mods", fmt.Sprintf("%s_%s.zip", m.Name, v))
+}
+
+// IsDir reports whether version v of the mod is installed as an unpacked
+// directory.
+func (m M) IsDir(v Version) bool {
+	_, ok := m.dirs[v]
+	return ok
 }
 
 func (m *M) findInstalledVersions(installDir string) error {
@@ -86,73 +111,75 @@ func (m *M) findInstalledVersions(installDir string) error {
 		mp := modpath(match)
 		versions[i] = mp.version()
 	}
-	slices.SortFunc(versions, func(a, b Version) int {
-		if a.Major > b.Major {
-			return 3
-		} else if a.Major < b.Major {
-			return -3
-		}
-		if a.Minor > b.Minor {
-			return 2
-		} else if a.Minor < b.Minor {
-			return -2
-		}
-		if a.Patch > b.Patch {
-			return 1
-		} else if a.Patch < b.Patch {
-			return -1
+
+	dirs, err := installedDirs(installDir, m.Name)
+	if err != nil {
+		return err
+	}
+	for v := range dirs {
+		if !slices.Contains(versions, v) {
+			versions = append(versions, v)
 		}
-		return 0
-	})
+	}
+
+	slices.SortFunc(versions, Version.Compare)
 	m.Versions = versions
+	m.dirs = dirs
 
 	return nil
 }
 
-type modpath string
-
-func (m modpath) version() Version {
-	base := filepath.Base(string(m))
-	i := strings.LastIndex(base, "_")
-	if i == -1 {
-		return Version{}
+// installedDirs finds the unpacked copies of the named mod in the
+// installation's mods directory, which are common while developing a mod.
+// The game loads directories named "NAME" or "NAME_VERSION", including
+// symbolic links to directories, whose info.json names the mod.
+// The returned map is keyed by the version in each directory's info.json.
+func installedDirs(installDir, name string) (map[Version]string, error) {
+	modDir := filepath.Join(installDir, "mods")
+	matches, err := filepath.Glob(filepath.Join(modDir, name+"_*"))
+	if err != nil {
+		return nil, fmt.Errorf("glob: %w", err)
 	}
-	vs := base[i+1 : strings.LastIndex(base, ".zip")]
-	return parseVersion(vs)
-}
+	matches = append(matches, filepath.Join(modDir, name))
 
-func parseVersion(version string) Version {
-	fields := strings.SplitN(version, ".", 3)
-	var major, minor, patch int
-	if len(fields) >= 1 {
-		n, err := strconv.Atoi(fields[0])
-		if err == nil {
-			major = n
+	var dirs map[Version]string
+	for _, match := range matches {
+		if fi, err := os.Stat(match); err != nil || !fi.IsDir() {
+			continue
 		}
-	}
-	if len(fields) >= 2 {
-		n, err := strconv.Atoi(fields[1])
-		if err == nil {
-			minor = n
+		info, err := LoadDirInfo(match)
+		if err != nil || info.Name != name {
+			continue
 		}
-	}
-	if len(fields) == 3 {
-		n, err := strconv.Atoi(fields[2])
-		if err == nil {
-			patch = n
+		v, err := ParseVersion(info.Version)
+		if err != nil {
+			continue
+		}
+		if dirs == nil {
+			dirs = make(map[Version]string)
 		}
+		dirs[v] = match
 	}
-	return Version{Major: major, Minor: minor, Patch: patch}
+	return dirs, nil
 }
 
-type Version struct {
-	Major, Minor, Patch int
-}
+type modpath string
 
-func (v Version) String() string {
-	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+func (m modpath) name() string {
+	base := filepath.Base(string(m))
+	i := strings.LastIndex(base, "_")
+	if i == -1 {
+		return strings.TrimSuffix(base, ".zip")
+	}
+	return base[:i]
 }
 
-func (v Version) IsZero() bool {
-	return v.Major == 0 && v.Minor == 0 && v.Patch == 0
+func (m modpath) version() Version {
+	base := filepath.Base(string(m))
+	i := strings.LastIndex(base, "_")
+	if i == -1 {
+		return Version{}
+	}
+	vs := base[i+1 : strings.LastIndex(base, ".zip")]
+	return parseVersion(vs)
 }
@@ -0,0 +1,217 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package settings reads and writes Factorio's "mod-settings.dat" file, which
+// holds the values of every mod setting, stored in Factorio's binary
+// [property tree] format.
+//
+// [property tree]: https://wiki.factorio.com/Property_tree
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Scope is the name of one of the top-level sections of mod-settings.dat.
+type Scope string
+
+const (
+	Startup        Scope = "startup"          // Settings that can only be changed before the game starts.
+	RuntimeGlobal  Scope = "runtime-global"   // Map settings, that apply to all players.
+	RuntimePerUser Scope = "runtime-per-user" // Settings that each player sets for themselves.
+)
+
+// Scopes returns all of the setting scopes, in the order they appear in
+// mod-settings.dat.
+func Scopes() []Scope {
+	return []Scope{Startup, RuntimeGlobal, RuntimePerUser}
+}
+
+// Version is the version of Factorio that wrote a mod-settings.dat file.
+type Version struct {
+	Main      uint16
+	Major     uint16
+	Minor     uint16
+	Developer uint16
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d-%d", v.Main, v.Major, v.Minor, v.Developer)
+}
+
+// Load reads "mods/mod-settings.dat" from the installation directory.
+func Load(installDir string) (*Settings, error) {
+	f, err := os.Open(Path(installDir))
+	if err != nil {
+		return nil, fmt.Errorf("open mod-settings.dat: %w", err)
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Path returns the path to the mod-settings.dat file in the installation
+// directory.
+func Path(installDir string) string {
+	return filepath.Join(installDir, "mods", "mod-settings.dat")
+}
+
+// Read reads [Settings] from r.
+func Read(r io.Reader) (*Settings, error) {
+	s := new(Settings)
+	if _, err := s.ReadFrom(r); err != nil {
+		return nil, fmt.Errorf("read from: %w", err)
+	}
+	return s, nil
+}
+
+// Settings holds the contents of a mod-settings.dat file.
+type Settings struct {
+	// The version of Factorio that last wrote the file.
+	Version Version
+
+	// The root of the property tree.
+	// It is a dictionary, with one dictionary for each [Scope], each of
+	// which maps a setting's name to a dictionary holding the setting's
+	// "value".
+	Tree Value
+}
+
+// ReadFrom implements the [io.ReaderFrom] interface, decoding the contents of
+// a mod-settings.dat file from r into s.
+func (s *Settings) ReadFrom(r io.Reader) (int64, error) {
+	d := &decoder{r: r}
+
+	for _, p := range []*uint16{&s.Version.Main, &s.Version.Major, &s.Version.Minor, &s.Version.Developer} {
+		v, err := d.uint16()
+		if err != nil {
+			return d.n, fmt.Errorf("read version: %w", err)
+		}
+		*p = v
+	}
+
+	// Since 0.17, the version is followed by an unused boolean.
+	if _, err := d.bool(); err != nil {
+		return d.n, fmt.Errorf("read header: %w", err)
+	}
+
+	tree, err := d.value()
+	if err != nil {
+		return d.n, fmt.Errorf("decode property tree: %w", err)
+	}
+	if tree.Type != Dictionary {
+		return d.n, fmt.Errorf("root of property tree is a %s, not a dictionary", tree.Type)
+	}
+	s.Tree = tree
+
+	return d.n, nil
+}
+
+// WriteTo implements the [io.WriterTo] interface, encoding s to w in the
+// mod-settings.dat format.
+func (s *Settings) WriteTo(w io.Writer) (int64, error) {
+	e := &encoder{w: w}
+
+	for _, v := range []uint16{s.Version.Main, s.Version.Major, s.Version.Minor, s.Version.Developer} {
+		if err := e.uint16(v); err != nil {
+			return e.n, fmt.Errorf("write version: %w", err)
+		}
+	}
+	if err := e.bool(false); err != nil {
+		return e.n, fmt.Errorf("write header: %w", err)
+	}
+
+	if err := e.value(s.Tree); err != nil {
+		return e.n, fmt.Errorf("encode property tree: %w", err)
+	}
+	return e.n, nil
+}
+
+// Save writes s to "mods/mod-settings.dat" in the installation directory.
+// The file is written to a temporary file first, and renamed into place.
+// An existing file keeps its permissions; a new one is created with 0644.
+func (s *Settings) Save(installDir string) error {
+	path := Path(installDir)
+	mode := fs.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("stat mod settings: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".mod-settings-*")
+	if err != nil {
+		return fmt.Errorf("create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := s.WriteTo(tmp); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get returns the value of the named setting in the given scope.
+func (s *Settings) Get(scope Scope, name string) (Value, bool) {
+	sc, ok := s.Tree.Get(string(scope))
+	if !ok {
+		return Value{}, false
+	}
+	setting, ok := sc.Get(name)
+	if !ok {
+		return Value{}, false
+	}
+	v, ok := setting.Get("value")
+	if !ok {
+		return Value{}, false
+	}
+	return *v, true
+}
+
+// Set sets the value of the named setting in the given scope, adding the
+// scope and setting if they do not already exist.
+func (s *Settings) Set(scope Scope, name string, value Value) {
+	if s.Tree.Type != Dictionary {
+		s.Tree = DictionaryValue()
+	}
+
+	sc, ok := s.Tree.Get(string(scope))
+	if !ok || sc.Type != Dictionary {
+		s.Tree.Set(string(scope), DictionaryValue())
+		sc, _ = s.Tree.Get(string(scope))
+	}
+
+	setting, ok := sc.Get(name)
+	if !ok || setting.Type != Dictionary {
+		sc.Set(name, DictionaryValue())
+		setting, _ = sc.Get(name)
+	}
+
+	setting.Set("value", value)
+}
+
+// Names returns the names of all settings in the given scope, in the order
+// they are stored in the file.
+func (s *Settings) Names(scope Scope) []string {
+	sc, ok := s.Tree.Get(string(scope))
+	if !ok {
+		return nil
+	}
+	names := make([]string, 0, len(sc.Entries))
+	for _, e := range sc.Entries {
+		names = append(names, e.Key)
+	}
+	return names
+}
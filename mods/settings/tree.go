@@ -0,0 +1,440 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package settings
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Type is the type of a [Value] in a property tree.
+type Type uint8
+
+const (
+	None       Type = iota // No value.
+	Bool                   // A boolean.
+	Number                 // A 64-bit floating-point number.
+	String                 // A string.
+	List                   // An ordered list of values.
+	Dictionary             // A map of string keys to values, in insertion order.
+	Int                    // A signed 64-bit integer (Factorio 2.0 and later).
+	Uint                   // An unsigned 64-bit integer (Factorio 2.0 and later).
+)
+
+func (t Type) String() string {
+	switch t {
+	case None:
+		return "none"
+	case Bool:
+		return "bool"
+	case Number:
+		return "number"
+	case String:
+		return "string"
+	case List:
+		return "list"
+	case Dictionary:
+		return "dictionary"
+	case Int:
+		return "int"
+	case Uint:
+		return "uint"
+	}
+	return fmt.Sprintf("Type(%d)", uint8(t))
+}
+
+// Value is a node in a Factorio [property tree].
+//
+// Only the field matching the value's Type is meaningful.
+// Lists and dictionaries both store their elements in Entries; the elements
+// of a list usually have empty keys.
+//
+// [property tree]: https://wiki.factorio.com/Property_tree
+type Value struct {
+	Type Type
+
+	// AnyType is a flag stored alongside every value in the binary format.
+	// It is not used by Factorio, but is preserved when a tree is encoded.
+	AnyType bool
+
+	Bool    bool
+	Number  float64
+	String  string
+	Int     int64
+	Uint    uint64
+	Entries []Entry
+}
+
+// Entry is a single element of a list or dictionary [Value].
+type Entry struct {
+	Key   string
+	Value Value
+}
+
+// BoolValue returns a [Value] holding b.
+func BoolValue(b bool) Value { return Value{Type: Bool, Bool: b} }
+
+// NumberValue returns a [Value] holding f.
+func NumberValue(f float64) Value { return Value{Type: Number, Number: f} }
+
+// StringValue returns a [Value] holding s.
+func StringValue(s string) Value { return Value{Type: String, String: s} }
+
+// DictionaryValue returns an empty dictionary [Value].
+func DictionaryValue() Value { return Value{Type: Dictionary} }
+
+// Get returns the value stored under key in the dictionary v.
+// The returned pointer refers to the value in v, and can be used to modify
+// it.
+func (v *Value) Get(key string) (*Value, bool) {
+	if v.Type != Dictionary {
+		return nil, false
+	}
+	for i := range v.Entries {
+		if v.Entries[i].Key == key {
+			return &v.Entries[i].Value, true
+		}
+	}
+	return nil, false
+}
+
+// Set stores value under key in the dictionary v, replacing any existing
+// value with the same key.
+// New keys are added to the end of the dictionary.
+//
+// Set panics if v is not a dictionary.
+func (v *Value) Set(key string, value Value) {
+	if v.Type != Dictionary {
+		panic("settings: Set called on " + v.Type.String() + " value")
+	}
+	if p, ok := v.Get(key); ok {
+		*p = value
+		return
+	}
+	v.Entries = append(v.Entries, Entry{Key: key, Value: value})
+}
+
+// Delete removes key from the dictionary v, and reports whether it was
+// present.
+func (v *Value) Delete(key string) bool {
+	if v.Type != Dictionary {
+		return false
+	}
+	for i := range v.Entries {
+		if v.Entries[i].Key == key {
+			v.Entries = append(v.Entries[:i], v.Entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Interface returns v as a plain Go value: nil, bool, float64, string, int64,
+// uint64, []any for lists, or map[string]any for dictionaries.
+// It is mostly useful for printing a tree, or encoding it as JSON.
+func (v Value) Interface() any {
+	switch v.Type {
+	case Bool:
+		return v.Bool
+	case Number:
+		return v.Number
+	case String:
+		return v.String
+	case Int:
+		return v.Int
+	case Uint:
+		return v.Uint
+	case List:
+		l := make([]any, len(v.Entries))
+		for i, e := range v.Entries {
+			l[i] = e.Value.Interface()
+		}
+		return l
+	case Dictionary:
+		m := make(map[string]any, len(v.Entries))
+		for _, e := range v.Entries {
+			m[e.Key] = e.Value.Interface()
+		}
+		return m
+	}
+	return nil
+}
+
+// ValueOf is the inverse of [Value.Interface]: it converts x, which is
+// usually a value decoded from JSON, to a [Value].
+// Integers are converted to [Number] values, and dictionary keys are sorted.
+func ValueOf(x any) (Value, error) {
+	switch x := x.(type) {
+	case nil:
+		return Value{}, nil
+	case bool:
+		return BoolValue(x), nil
+	case float64:
+		return NumberValue(x), nil
+	case int:
+		return NumberValue(float64(x)), nil
+	case int64:
+		return Value{Type: Int, Int: x}, nil
+	case uint64:
+		return Value{Type: Uint, Uint: x}, nil
+	case string:
+		return StringValue(x), nil
+	case []any:
+		v := Value{Type: List}
+		for _, e := range x {
+			ev, err := ValueOf(e)
+			if err != nil {
+				return Value{}, err
+			}
+			v.Entries = append(v.Entries, Entry{Value: ev})
+		}
+		return v, nil
+	case map[string]any:
+		v := DictionaryValue()
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			ev, err := ValueOf(x[k])
+			if err != nil {
+				return Value{}, err
+			}
+			v.Entries = append(v.Entries, Entry{Key: k, Value: ev})
+		}
+		return v, nil
+	}
+	return Value{}, fmt.Errorf("cannot convert %T to a property tree value", x)
+}
+
+// decoder reads property trees from r, keeping track of the number of bytes
+// read.
+type decoder struct {
+	r io.Reader
+	n int64
+}
+
+func (d *decoder) read(p []byte) error {
+	n, err := io.ReadFull(d.r, p)
+	d.n += int64(n)
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (d *decoder) byte() (byte, error) {
+	var b [1]byte
+	err := d.read(b[:])
+	return b[0], err
+}
+
+func (d *decoder) bool() (bool, error) {
+	b, err := d.byte()
+	return b != 0, err
+}
+
+func (d *decoder) uint16() (uint16, error) {
+	var b [2]byte
+	err := d.read(b[:])
+	return binary.LittleEndian.Uint16(b[:]), err
+}
+
+func (d *decoder) uint32() (uint32, error) {
+	var b [4]byte
+	err := d.read(b[:])
+	return binary.LittleEndian.Uint32(b[:]), err
+}
+
+func (d *decoder) uint64() (uint64, error) {
+	var b [8]byte
+	err := d.read(b[:])
+	return binary.LittleEndian.Uint64(b[:]), err
+}
+
+// string reads a property tree string: an "empty" flag, followed by a
+// space-optimized length and the string's bytes, when the string is not
+// empty.
+func (d *decoder) string() (string, error) {
+	empty, err := d.bool()
+	if err != nil || empty {
+		return "", err
+	}
+
+	b, err := d.byte()
+	if err != nil {
+		return "", err
+	}
+	n := uint32(b)
+	if b == 0xff {
+		if n, err = d.uint32(); err != nil {
+			return "", err
+		}
+	}
+
+	// The length comes from the file, so rather than allocating n bytes
+	// up front, which a corrupt file could make 4 GiB, the buffer only
+	// grows as the bytes are read.
+	var sb strings.Builder
+	m, err := io.Copy(&sb, io.LimitReader(d.r, int64(n)))
+	d.n += m
+	if err != nil {
+		return "", err
+	}
+	if m < int64(n) {
+		return "", io.ErrUnexpectedEOF
+	}
+	return sb.String(), nil
+}
+
+func (d *decoder) value() (Value, error) {
+	t, err := d.byte()
+	if err != nil {
+		return Value{}, err
+	}
+	v := Value{Type: Type(t)}
+	if v.AnyType, err = d.bool(); err != nil {
+		return Value{}, err
+	}
+
+	switch v.Type {
+	case None:
+	case Bool:
+		v.Bool, err = d.bool()
+	case Number:
+		var u uint64
+		u, err = d.uint64()
+		v.Number = math.Float64frombits(u)
+	case String:
+		v.String, err = d.string()
+	case Int:
+		var u uint64
+		u, err = d.uint64()
+		v.Int = int64(u)
+	case Uint:
+		v.Uint, err = d.uint64()
+	case List, Dictionary:
+		var n uint32
+		if n, err = d.uint32(); err != nil {
+			return Value{}, err
+		}
+		// Entries are appended as they are read, rather than
+		// allocated up front, so a corrupt count fails at the end of
+		// the input instead of exhausting memory.
+		for i := uint32(0); i < n; i++ {
+			var e Entry
+			if e.Key, err = d.string(); err != nil {
+				return Value{}, err
+			}
+			if e.Value, err = d.value(); err != nil {
+				return Value{}, err
+			}
+			v.Entries = append(v.Entries, e)
+		}
+	default:
+		return Value{}, fmt.Errorf("unknown property tree type %d at offset %d", t, d.n-2)
+	}
+	if err != nil {
+		return Value{}, err
+	}
+
+	return v, nil
+}
+
+// encoder writes property trees to w, keeping track of the number of bytes
+// written.
+type encoder struct {
+	w io.Writer
+	n int64
+}
+
+func (e *encoder) write(p []byte) error {
+	n, err := e.w.Write(p)
+	e.n += int64(n)
+	return err
+}
+
+func (e *encoder) bool(b bool) error {
+	if b {
+		return e.write([]byte{1})
+	}
+	return e.write([]byte{0})
+}
+
+func (e *encoder) uint16(u uint16) error {
+	return e.write(binary.LittleEndian.AppendUint16(nil, u))
+}
+
+func (e *encoder) uint32(u uint32) error {
+	return e.write(binary.LittleEndian.AppendUint32(nil, u))
+}
+
+func (e *encoder) uint64(u uint64) error {
+	return e.write(binary.LittleEndian.AppendUint64(nil, u))
+}
+
+func (e *encoder) string(s string) error {
+	if err := e.bool(s == ""); err != nil || s == "" {
+		return err
+	}
+
+	if len(s) < 0xff {
+		if err := e.write([]byte{byte(len(s))}); err != nil {
+			return err
+		}
+	} else {
+		if err := e.write([]byte{0xff}); err != nil {
+			return err
+		}
+		if err := e.uint32(uint32(len(s))); err != nil {
+			return err
+		}
+	}
+	return e.write([]byte(s))
+}
+
+func (e *encoder) value(v Value) error {
+	if err := e.write([]byte{byte(v.Type)}); err != nil {
+		return err
+	}
+	if err := e.bool(v.AnyType); err != nil {
+		return err
+	}
+
+	switch v.Type {
+	case None:
+		return nil
+	case Bool:
+		return e.bool(v.Bool)
+	case Number:
+		return e.uint64(math.Float64bits(v.Number))
+	case String:
+		return e.string(v.String)
+	case Int:
+		return e.uint64(uint64(v.Int))
+	case Uint:
+		return e.uint64(v.Uint)
+	case List, Dictionary:
+		if err := e.uint32(uint32(len(v.Entries))); err != nil {
+			return err
+		}
+		for _, ent := range v.Entries {
+			if err := e.string(ent.Key); err != nil {
+				return err
+			}
+			if err := e.value(ent.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown property tree type %d", uint8(v.Type))
+}
@@ -0,0 +1,85 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/nesv/factorio-tools/httputil"
+)
+
+// Thumbnail downloads the named mod's thumbnail image into the cache
+// directory, and returns the path to the image.
+// Thumbnails that have already been downloaded are not downloaded again.
+//
+// The thumbnail's location is read from the cache database, as recorded by
+// [Cache.Update]; if the mod is not in the database, its details are
+// retrieved with [Cache.FullInfo].
+// Thumbnail returns a non-nil error if the mod does not have a thumbnail.
+func (c *Cache) Thumbnail(ctx context.Context, name string) (string, error) {
+	var relpath sql.NullString
+	err := c.db.QueryRowContext(ctx, `SELECT thumbnail FROM mods WHERE name = ?`, name).Scan(&relpath)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("query thumbnail: %w", err)
+	}
+	if !relpath.Valid || relpath.String == "" {
+		info, err := c.FullInfo(ctx, name)
+		if err != nil {
+			return "", fmt.Errorf("get mod info: %w", err)
+		}
+		relpath.String = info.Thumbnail
+	}
+	if relpath.String == "" || relpath.String == noThumbnail {
+		return "", fmt.Errorf("%s does not have a thumbnail", name)
+	}
+
+	dir := filepath.Join(c.dir, "thumbnails")
+	if err := os.MkdirAll(dir, fs.ModePerm); err != nil {
+		return "", fmt.Errorf("make directory %q: %w", dir, err)
+	}
+
+	dst := filepath.Join(dir, name+path.Ext(relpath.String))
+	if _, err := os.Stat(dst); err == nil {
+		return dst, nil
+	}
+
+	urlStr := thumbnailURL(relpath.String)
+	resp, err := httputil.Get(ctx, urlStr)
+	if err != nil {
+		return "", fmt.Errorf("http get %q: %w", urlStr, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("http get %q: %s", urlStr, resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".thumbnail-*")
+	if err != nil {
+		return "", fmt.Errorf("create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		return "", fmt.Errorf("download thumbnail: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return "", err
+	}
+
+	return dst, nil
+}
@@ -0,0 +1,99 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	modNameRe         = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	factorioVersionRe = regexp.MustCompile(`^\d+\.\d+$`)
+)
+
+// maxNameLength is the longest mod name, or title, the game accepts.
+const maxNameLength = 100
+
+// Validate checks the info.json file against the rules the game and the mod
+// portal apply to mods:
+//
+//   - "name", "version", "title", and "author" are required;
+//   - the name may only contain letters, digits, "-", and "_", and the name
+//     and title may be at most 100 characters long;
+//   - the version must be "MAJOR.MINOR.PATCH", with each number between 0
+//     and 65535;
+//   - "factorio_version", when given, must be "MAJOR.MINOR", like "1.1";
+//   - each dependency must be a valid dependency string.
+//
+// The returned error joins all of the problems that were found with
+// [errors.Join], or is nil if there are none.
+func (i Info) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	switch {
+	case i.Name == "":
+		add("name: missing")
+	case !modNameRe.MatchString(i.Name):
+		add("name: %q may only contain letters, digits, \"-\", and \"_\"", i.Name)
+	case len(i.Name) > maxNameLength:
+		add("name: longer than %d characters", maxNameLength)
+	}
+
+	if i.Version == "" {
+		add("version: missing")
+	} else if !validModVersion(i.Version) {
+		add("version: %q is not MAJOR.MINOR.PATCH, with each number between 0 and 65535", i.Version)
+	}
+
+	if strings.TrimSpace(i.Title) == "" {
+		add("title: missing")
+	} else if utf8.RuneCountInString(i.Title) > maxNameLength {
+		add("title: longer than %d characters", maxNameLength)
+	}
+
+	if strings.TrimSpace(i.Author) == "" {
+		add("author: missing")
+	}
+
+	if i.FactorioVersion != "" && !factorioVersionRe.MatchString(i.FactorioVersion) {
+		add("factorio_version: %q is not MAJOR.MINOR, like \"1.1\"", i.FactorioVersion)
+	}
+
+	for _, s := range i.Dependencies {
+		d, err := ParseDependency(s)
+		if err != nil {
+			add("dependencies: %w", err)
+			continue
+		}
+		if d.Name == i.Name && i.Name != "" {
+			add("dependencies: %q depends on the mod itself", s)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validModVersion reports whether s is a mod version, as the game accepts
+// them.
+func validModVersion(s string) bool {
+	fields := strings.Split(s, ".")
+	if len(fields) != 3 {
+		return false
+	}
+	for _, f := range fields {
+		if _, err := strconv.ParseUint(f, 10, 16); err != nil {
+			return false
+		}
+	}
+	return true
+}
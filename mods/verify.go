@@ -0,0 +1,82 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// VerifyResult is the result of checking the integrity of a single installed
+// mod archive.
+type VerifyResult struct {
+	Name    string  `json:"name"`
+	Version Version `json:"version"`
+	Path    string  `json:"path"`
+
+	Want string `json:"want,omitempty"` // SHA1 published by the mod portal.
+	Have string `json:"have,omitempty"` // SHA1 of the installed archive.
+
+	// Err is set when the archive could not be verified, for example if
+	// the release is unknown to the mod portal.
+	Err error `json:"-"`
+}
+
+// OK reports whether the installed archive matches the published SHA1.
+func (r VerifyResult) OK() bool {
+	return r.Err == nil && strings.EqualFold(r.Want, r.Have)
+}
+
+// Verify hashes the archives of every mod installed to installDir, and
+// compares them against the SHA1 published by the mod portal for that
+// release.
+// The latest release recorded in the cache database is checked first; for
+// older versions, the cache's release history is used.
+func (c *Cache) Verify(ctx context.Context, installDir string) ([]VerifyResult, error) {
+	installed, err := Load(installDir)
+	if err != nil {
+		return nil, fmt.Errorf("load mods: %w", err)
+	}
+
+	var results []VerifyResult
+	for _, m := range installed {
+		for _, v := range m.Versions {
+			// Unpacked mods have no archive to hash.
+			if m.IsDir(v) {
+				continue
+			}
+			r := VerifyResult{
+				Name:    m.Name,
+				Version: v,
+				Path:    m.Path(installDir, v),
+			}
+
+			if r.Have, err = fileSHA1(r.Path); err != nil {
+				r.Err = fmt.Errorf("hash archive: %w", err)
+			} else {
+				r.Want, r.Err = c.releaseSHA1(ctx, m.Name, v)
+			}
+
+			results = append(results, r)
+		}
+	}
+
+	return results, nil
+}
+
+// releaseSHA1 returns the SHA1 that the mod portal publishes for the given
+// release of a mod.
+func (c *Cache) releaseSHA1(ctx context.Context, name string, v Version) (string, error) {
+	if latest, err := c.LatestRelease(ctx, name); err == nil && sameVersion(latest.Version, v.String()) {
+		return latest.SHA1, nil
+	}
+
+	r, err := c.Release(ctx, name, v.String())
+	if err != nil {
+		return "", err
+	}
+	return r.SHA1, nil
+}
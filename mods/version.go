@@ -0,0 +1,135 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mods
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Version is a mod or game version, in Factorio's format.
+//
+// Unlike semantic versions, Factorio versions may have between two and four
+// numeric components, like "0.18", "1.1.107", or "2.0.28.1", and each
+// component may have leading zeros, like "1.1.01".
+// Missing components are zero, so "0.18" and "0.18.0" are the same version.
+type Version struct {
+	Major, Minor, Patch int
+
+	// Build is the optional fourth component.
+	Build int
+}
+
+// ParseVersion parses a version string in Factorio's format: two to four
+// dot-separated numbers, each between 0 and 65535.
+func ParseVersion(s string) (Version, error) {
+	fields := strings.Split(strings.TrimSpace(s), ".")
+	if len(fields) < 2 || len(fields) > 4 {
+		return Version{}, fmt.Errorf("invalid version: %q", s)
+	}
+
+	var n [4]int
+	for i, f := range fields {
+		u, err := strconv.ParseUint(f, 10, 16)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid version: %q", s)
+		}
+		n[i] = int(u)
+	}
+	return Version{Major: n[0], Minor: n[1], Patch: n[2], Build: n[3]}, nil
+}
+
+// validate checks that each component of v is between 0 and 65535, so that
+// v.String() can be read back with [ParseVersion].
+func (v Version) validate() error {
+	for _, n := range []int{v.Major, v.Minor, v.Patch, v.Build} {
+		if n < 0 || n > math.MaxUint16 {
+			return fmt.Errorf("invalid version %s: components must be between 0 and %d", v, math.MaxUint16)
+		}
+	}
+	return nil
+}
+
+// parseVersion is a lenient form of [ParseVersion], for versions taken from
+// file names.
+// Components that are missing, or are not numbers, are zero.
+func parseVersion(version string) Version {
+	if v, err := ParseVersion(version); err == nil {
+		return v
+	}
+
+	var n [4]int
+	for i, f := range strings.SplitN(version, ".", 4) {
+		if x, err := strconv.Atoi(f); err == nil {
+			n[i] = x
+		}
+	}
+	return Version{Major: n[0], Minor: n[1], Patch: n[2], Build: n[3]}
+}
+
+// String returns the version as "MAJOR.MINOR.PATCH", followed by ".BUILD"
+// when the build component is not zero.
+// Leading zeros are not kept, so "1.1.01" is returned as "1.1.1".
+func (v Version) String() string {
+	if v.Build != 0 {
+		return fmt.Sprintf("%d.%d.%d.%d", v.Major, v.Minor, v.Patch, v.Build)
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+func (v Version) IsZero() bool {
+	return v == Version{}
+}
+
+// Compare returns -1 if v is less than w, 1 if v is greater than w, and 0 if
+// they are equal.
+func (v Version) Compare(w Version) int {
+	switch {
+	case v.Major != w.Major:
+		return cmp.Compare(v.Major, w.Major)
+	case v.Minor != w.Minor:
+		return cmp.Compare(v.Minor, w.Minor)
+	case v.Patch != w.Patch:
+		return cmp.Compare(v.Patch, w.Patch)
+	default:
+		return cmp.Compare(v.Build, w.Build)
+	}
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (v Version) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler].
+func (v *Version) UnmarshalText(text []byte) error {
+	pv, err := ParseVersion(string(text))
+	if err != nil {
+		return err
+	}
+	*v = pv
+	return nil
+}
+
+// sameVersion reports whether the version strings a and b name the same
+// version, like "1.1.01" and "1.1.1".
+// Strings that are not valid versions are compared as-is.
+func sameVersion(a, b string) bool {
+	if a == b {
+		return true
+	}
+	va, err := ParseVersion(a)
+	if err != nil {
+		return false
+	}
+	vb, err := ParseVersion(b)
+	if err != nil {
+		return false
+	}
+	return va == vb
+}
@@ -0,0 +1,262 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package notify posts messages about what happens on a server, like players
+// joining, or the server crashing, to webhooks, Discord, and Slack.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/nesv/factorio-tools/httputil"
+)
+
+// Event identifies what a [Notification] is about.
+type Event string
+
+const (
+	PlayerJoin  Event = "player-join"
+	PlayerLeave Event = "player-leave"
+	ServerStart Event = "server-start"
+	ServerStop  Event = "server-stop"
+	ServerCrash Event = "server-crash"
+	Upgrade     Event = "upgrade"
+	ModUpdate   Event = "mod-update"
+)
+
+// Events lists every [Event].
+var Events = []Event{PlayerJoin, PlayerLeave, ServerStart, ServerStop, ServerCrash, Upgrade, ModUpdate}
+
+// defaultMessages are the templates of the messages sent for each event,
+// when the config does not set one.
+var defaultMessages = map[Event]string{
+	PlayerJoin:  "{{.Player}} joined the game",
+	PlayerLeave: "{{.Player}} left the game",
+	ServerStart: "The server started",
+	ServerStop:  "The server stopped",
+	ServerCrash: "The server crashed: {{.Error}}",
+	Upgrade:     "{{if .From}}Upgraded Factorio {{.From}} -> {{.To}}{{else}}Installed Factorio {{.To}}{{end}}",
+	ModUpdate:   "Updated {{.Mod}} {{.From}} -> {{.To}}",
+}
+
+// Notification is something that happened, which the message sent for it is
+// made from.
+type Notification struct {
+	Event Event     `json:"event"`
+	Time  time.Time `json:"time"`
+
+	// The player who joined or left.
+	Player string `json:"player,omitempty"`
+
+	// The mod that was updated.
+	Mod string `json:"mod,omitempty"`
+
+	// The versions of the game, or mod, before and after it was upgraded.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+
+	// The error the server crashed with.
+	Error string `json:"error,omitempty"`
+}
+
+// Target types accepted in [Target.Type].
+const (
+	TargetWebhook = "webhook"
+	TargetDiscord = "discord"
+	TargetSlack   = "slack"
+)
+
+// Target is where notifications are posted.
+type Target struct {
+	// Type is the kind of service URL belongs to:
+	//
+	//   - "discord" and "slack" post the message to a Discord, or Slack,
+	//     incoming webhook.
+	//   - "webhook" posts the [Notification] as JSON, with the message in
+	//     its "text" field.
+	Type string `json:"type"`
+	URL  string `json:"url"`
+
+	// Events are the events posted to the target.
+	// When it is empty, every event is.
+	Events []Event `json:"events,omitempty"`
+
+	// Headers are added to the requests made to a "webhook" target, like
+	// an "Authorization" header.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Config is the configuration of a [Notifier], as read by [Load].
+type Config struct {
+	Targets []Target `json:"targets"`
+
+	// Messages override the templates of the messages sent for each event,
+	// which are [text/template] templates executed with the
+	// [Notification], like "{{.Player}} joined the game".
+	Messages map[Event]string `json:"messages,omitempty"`
+}
+
+// Notifier posts notifications to the targets in its [Config].
+// A nil Notifier posts nothing.
+type Notifier struct {
+	targets  []Target
+	messages map[Event]*template.Template
+}
+
+// DefaultConfigPath returns the path of the config file read by the tools in
+// this repository: "facsrv/notify.json" in the user's config directory.
+func DefaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "facsrv", "notify.json"), nil
+}
+
+// Load reads a [Config] from the JSON file at path, and returns a [Notifier]
+// for it.
+// A missing file is not an error; the returned Notifier posts nothing.
+func Load(path string) (*Notifier, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read notify config: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	n, err := New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return n, nil
+}
+
+// New returns a [Notifier] for cfg, after checking it.
+func New(cfg Config) (*Notifier, error) {
+	for i, t := range cfg.Targets {
+		switch t.Type {
+		case TargetWebhook, TargetDiscord, TargetSlack:
+		default:
+			return nil, fmt.Errorf("target %d: unknown type %q", i+1, t.Type)
+		}
+		if t.URL == "" {
+			return nil, fmt.Errorf("target %d: url is required", i+1)
+		}
+		for _, e := range t.Events {
+			if !slices.Contains(Events, e) {
+				return nil, fmt.Errorf("target %d: unknown event %q", i+1, e)
+			}
+		}
+	}
+
+	n := &Notifier{targets: cfg.Targets, messages: make(map[Event]*template.Template)}
+	for _, e := range Events {
+		text, ok := cfg.Messages[e]
+		if !ok {
+			text = defaultMessages[e]
+		}
+		tmpl, err := template.New(string(e)).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("message for %s: %w", e, err)
+		}
+		n.messages[e] = tmpl
+	}
+	for e := range cfg.Messages {
+		if !slices.Contains(Events, e) {
+			return nil, fmt.Errorf("message for unknown event %q", e)
+		}
+	}
+	return n, nil
+}
+
+// Notify posts a message for notification to every target that it is for.
+// Its time is set to now, when it is zero.
+// Every target is tried, and their errors are joined.
+func (n *Notifier) Notify(ctx context.Context, notification Notification) error {
+	if n == nil {
+		return nil
+	}
+	if notification.Time.IsZero() {
+		notification.Time = time.Now()
+	}
+
+	tmpl, ok := n.messages[notification.Event]
+	if !ok {
+		return fmt.Errorf("unknown event %q", notification.Event)
+	}
+	var text strings.Builder
+	if err := tmpl.Execute(&text, notification); err != nil {
+		return fmt.Errorf("message for %s: %w", notification.Event, err)
+	}
+
+	var errs []error
+	for _, t := range n.targets {
+		if len(t.Events) > 0 && !slices.Contains(t.Events, notification.Event) {
+			continue
+		}
+		if err := post(ctx, t, notification, text.String()); err != nil {
+			errs = append(errs, fmt.Errorf("notify %s: %w", t.Type, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// post posts text, the message for n, to t.
+func post(ctx context.Context, t Target, n Notification, text string) error {
+	var body any
+	switch t.Type {
+	case TargetDiscord:
+		body = map[string]string{"content": text}
+	case TargetSlack:
+		body = map[string]string{"text": text}
+	default:
+		body = struct {
+			Notification
+			Text string `json:"text"`
+		}{n, text}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("encode json: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+	if t.Type == TargetWebhook {
+		for k, v := range t.Headers {
+			req.Header.Set(k, v)
+		}
+	}
+	req.Header.Set("content-type", "application/json")
+
+	resp, err := httputil.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
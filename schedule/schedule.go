@@ -0,0 +1,216 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package schedule parses cron-like schedules, like "0 4 * * *", and works
+// out when they next run.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is when something runs, as parsed by [Parse].
+type Schedule struct {
+	// The values each field of a cron expression matches, as bit sets.
+	minute, hour, dom, month, dow uint64
+
+	// Whether the day-of-month, and day-of-week, fields were "*"; when
+	// both are restricted, a day matching either runs, like with cron(8).
+	domStar, dowStar bool
+
+	// every, when it is not zero, runs the schedule at a fixed interval,
+	// instead of on the fields above.
+	every time.Duration
+}
+
+// macros are the shorthands accepted by [Parse], and the expressions they
+// stand for.
+var macros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// field is the range of values of a field of a cron expression.
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Parse parses a schedule, which is either:
+//
+//   - a cron expression of five fields: minute, hour, day of month, month,
+//     and day of week, where 0 and 7 are Sunday, like "30 4 * * 1-5";
+//     each field is "*", a value, a range like "1-5", a step like "*/15" or
+//     "0-30/10", or a comma-separated list of those;
+//   - one of "@hourly", "@daily" (or "@midnight"), "@weekly", or "@monthly";
+//   - or "@every DURATION", like "@every 90m", which runs at a fixed
+//     interval from when the schedule is first used.
+//
+// Times are in the local time zone.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, ok := strings.CutPrefix(spec, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil {
+			return Schedule{}, fmt.Errorf("parse %q: %w", spec, err)
+		}
+		if every < time.Second {
+			return Schedule{}, fmt.Errorf("parse %q: interval must be at least one second", spec)
+		}
+		return Schedule{every: every}, nil
+	}
+	if expr, ok := macros[spec]; ok {
+		spec = expr
+	} else if strings.HasPrefix(spec, "@") {
+		return Schedule{}, fmt.Errorf("parse %q: unknown macro", spec)
+	}
+
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return Schedule{}, fmt.Errorf("parse %q: expected %d fields, got %d", spec, len(fields), len(parts))
+	}
+	var sets [5]uint64
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return Schedule{}, fmt.Errorf("parse %q: %s: %w", spec, fields[i].name, err)
+		}
+		sets[i] = set
+	}
+
+	s := Schedule{
+		minute:  sets[0],
+		hour:    sets[1],
+		dom:     sets[2],
+		month:   sets[3],
+		dow:     sets[4],
+		domStar: parts[2] == "*",
+		dowStar: parts[4] == "*",
+	}
+	// Sunday is both 0 and 7.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+// parseField parses one field of a cron expression into the set of values it
+// matches.
+func parseField(s string, f field) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+			step = n
+		}
+
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = parseValue(a, f); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(b, f); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q", rng)
+			}
+		default:
+			n, err := parseValue(rng, f)
+			if err != nil {
+				return 0, err
+			}
+			lo = n
+			if !hasStep {
+				hi = n
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// parseValue parses a single value of a field, checking that it is in range.
+func parseValue(s string, f field) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if n < f.min || n > f.max {
+		return 0, fmt.Errorf("%d is out of range %d-%d", n, f.min, f.max)
+	}
+	return n, nil
+}
+
+// Next returns the first time after t that the schedule runs.
+// Cron expressions run on whole minutes; "@every" schedules run t plus their
+// interval.
+// The zero time is returned for schedules that never run, like "0 0 30 2 *".
+func (s Schedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every)
+	}
+
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// Every schedule that runs at all runs within five years, which
+	// covers the 29th of February.
+	limit := t.AddDate(5, 0, 0)
+	// Skip ahead a month, day, or hour at a time while they do not match.
+	for t.Before(limit) {
+		if s.month&(1<<int(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<t.Hour()) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<t.Minute()) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches reports whether the day of t matches the day-of-month and
+// day-of-week fields.
+func (s Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
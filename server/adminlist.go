@@ -0,0 +1,79 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// adminlistFile is the name of the adminlist in an installation directory.
+const adminlistFile = "server-adminlist.json"
+
+// Adminlist is the list of a server's admins, as stored in
+// "server-adminlist.json".
+// Player names are compared case-insensitively, as the game does.
+type Adminlist []string
+
+// LoadAdminlist reads "server-adminlist.json" from the installation
+// directory.
+// When the file does not exist, the adminlist is empty.
+func LoadAdminlist(installDir string) (Adminlist, error) {
+	a := Adminlist{}
+	if _, err := readJSONFile(filepath.Join(installDir, adminlistFile), &a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Save writes the adminlist to "server-adminlist.json" in the installation
+// directory.
+// A running server only reads the file when it starts; use [RCON.Promote]
+// and [RCON.Demote] to change its admins without restarting it.
+func (a Adminlist) Save(installDir string) error {
+	if a == nil {
+		a = Adminlist{}
+	}
+	return writeJSONFile(filepath.Join(installDir, adminlistFile), a)
+}
+
+// Contains reports whether the named player is an admin.
+func (a Adminlist) Contains(name string) bool {
+	return slices.ContainsFunc(a, func(s string) bool { return strings.EqualFold(s, name) })
+}
+
+// Add adds the named player to the adminlist, and reports whether they were
+// not already on it.
+func (a *Adminlist) Add(name string) bool {
+	if a.Contains(name) {
+		return false
+	}
+	*a = append(*a, name)
+	return true
+}
+
+// Remove removes the named player from the adminlist, and reports whether
+// they were on it.
+func (a *Adminlist) Remove(name string) bool {
+	n := len(*a)
+	*a = slices.DeleteFunc(*a, func(s string) bool { return strings.EqualFold(s, name) })
+	return len(*a) != n
+}
+
+// Promote makes the named player an admin of the running server, with
+// "/promote".
+// Players who have not joined the server yet are promoted when they first
+// join.
+func (c *RCON) Promote(ctx context.Context, name string) error {
+	return c.playerCommand(ctx, "/promote", name)
+}
+
+// Demote removes the named player from the running server's admins, with
+// "/demote".
+func (c *RCON) Demote(ctx context.Context, name string) error {
+	return c.playerCommand(ctx, "/demote", name)
+}
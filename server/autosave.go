@@ -0,0 +1,115 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// autosavePruneInterval is how often [Installation.Start] prunes autosaves,
+// when [StartOptions.AutosaveRetention] is set.
+const autosavePruneInterval = 5 * time.Minute
+
+// autosaveRegexp matches the names of the autosaves written by the server,
+// which cycles through "_autosave1.zip" to "_autosaveN.zip", N being the
+// "autosave_slots" server setting.
+var autosaveRegexp = regexp.MustCompile(`^_autosave[0-9]+\.zip$`)
+
+// AutosaveRetention selects the autosaves that [PruneAutosaves] keeps.
+// The newest autosave is always kept.
+type AutosaveRetention struct {
+	// Keep the newest Keep autosaves, when it is not zero.
+	Keep int
+
+	// Remove autosaves older than MaxAge, when it is not zero.
+	MaxAge time.Duration
+}
+
+// IsZero reports whether r keeps every autosave.
+func (r AutosaveRetention) IsZero() bool {
+	return r == AutosaveRetention{}
+}
+
+// PruneAutosaves removes the autosaves in the installation's saves directory
+// that are not kept by r, and returns their paths.
+// This stops old autosaves from filling up the disk, like the ones left
+// behind when the "autosave_slots" server setting is lowered.
+func PruneAutosaves(installDir string, r AutosaveRetention) ([]string, error) {
+	if r.IsZero() {
+		return nil, nil
+	}
+
+	type autosave struct {
+		path    string
+		modTime time.Time
+	}
+	dir := filepath.Join(installDir, savesDir)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read saves directory: %w", err)
+	}
+	var autosaves []autosave
+	for _, e := range entries {
+		if !e.Type().IsRegular() || !autosaveRegexp.MatchString(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // Removed by the server, while cycling through slots.
+		}
+		autosaves = append(autosaves, autosave{path: filepath.Join(dir, e.Name()), modTime: info.ModTime()})
+	}
+	slices.SortFunc(autosaves, func(a, b autosave) int { return b.modTime.Compare(a.modTime) })
+
+	var removed []string
+	for i, a := range autosaves {
+		switch {
+		case i == 0:
+			continue
+		case r.Keep > 0 && i >= r.Keep:
+		case r.MaxAge > 0 && time.Since(a.modTime) > r.MaxAge:
+		default:
+			continue
+		}
+		if err := os.Remove(a.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return removed, fmt.Errorf("remove autosave: %w", err)
+		}
+		removed = append(removed, a.path)
+	}
+	return removed, nil
+}
+
+// pruneAutosaves calls [PruneAutosaves] every [autosavePruneInterval], until
+// done is closed.
+func pruneAutosaves(ctx context.Context, installDir string, r AutosaveRetention, done <-chan struct{}) {
+	t := time.NewTicker(autosavePruneInterval)
+	defer t.Stop()
+	for {
+		removed, err := PruneAutosaves(installDir, r)
+		for _, path := range removed {
+			slog.DebugContext(ctx, "removed autosave", "path", path)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "prune autosaves", "err", err)
+		}
+
+		select {
+		case <-t.C:
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,275 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// backupTimeFormat is the format of the time in a backup's name.
+const backupTimeFormat = "20060102T150405Z"
+
+// settingsData are the files, other than saves, that [Backup] backs up when
+// [BackupOptions.Settings] is set.
+var settingsData = []string{
+	"server-adminlist.json",
+	"server-banlist.json",
+	"server-whitelist.json",
+	filepath.Join("data", "server-settings.json"),
+	filepath.Join("data", "map-gen-settings.json"),
+	filepath.Join("data", "map-settings.json"),
+	filepath.Join("mods", "mod-list.json"),
+	filepath.Join("mods", "mod-settings.dat"),
+}
+
+// BackupOptions control what [Backup] backs up, and which older backups it
+// keeps.
+type BackupOptions struct {
+	// Dir is the directory the backup is written to.
+	// Defaults to [DefaultBackupDir].
+	Dir string
+
+	// Save is the name of the save to back up, like "mymap".
+	// When it is empty, every save is backed up.
+	Save string
+
+	// Settings also backs up the server's settings, its admin, ban, and
+	// white lists, and its mod list and mod settings.
+	Settings bool
+
+	// Retention selects the older backups of the same save, or of every
+	// save, to keep; the others are removed.
+	// The zero value keeps every backup.
+	Retention Retention
+}
+
+// Retention selects the backups to keep, out of the ones made of a save.
+// A backup is kept when any of the rules keeps it.
+type Retention struct {
+	// Keep the newest KeepLast backups.
+	KeepLast int
+
+	// Keep the newest backup of each of the last KeepDaily days, and
+	// KeepWeekly weeks, that have backups.
+	KeepDaily  int
+	KeepWeekly int
+}
+
+// IsZero reports whether r keeps every backup.
+func (r Retention) IsZero() bool {
+	return r == Retention{}
+}
+
+// BackupResult describes a backup made by [Backup].
+type BackupResult struct {
+	// Path is the path to the backup.
+	Path string
+
+	// Removed are the paths to the older backups removed by the
+	// retention rules.
+	Removed []string
+}
+
+// DefaultBackupDir returns the directory [Backup] writes backups of the
+// installation in installDir to, by default.
+// That is the installation's "backups" directory, or, when installDir is the
+// "current" symlink in a directory managed by [Upgrade], the "backups"
+// directory next to it, which also holds the backups made by [Upgrade].
+func DefaultBackupDir(installDir string) string {
+	installDir = filepath.Clean(installDir)
+	if filepath.Base(installDir) == currentLink {
+		return filepath.Join(filepath.Dir(installDir), backupsDir)
+	}
+	return filepath.Join(installDir, backupsDir)
+}
+
+// Backup writes the installation's saves, or one of them, to a gzipped
+// tarball named after the save and the current time, like
+// "mymap-20250102T150405Z.tar.gz", or "saves-20250102T150405Z.tar.gz" when
+// every save is backed up.
+// Older backups of the same save are then removed, following
+// opts.Retention.
+// Backups are restored with [Restore].
+func Backup(installDir string, opts BackupOptions) (BackupResult, error) {
+	dir := opts.Dir
+	if dir == "" {
+		dir = DefaultBackupDir(installDir)
+	}
+
+	prefix, entries := savesDir, []string{savesDir}
+	if opts.Save != "" {
+		name, err := saveName(opts.Save)
+		if err != nil {
+			return BackupResult{}, err
+		}
+		rel := filepath.Join(savesDir, name+".zip")
+		if _, err := os.Stat(filepath.Join(installDir, rel)); err != nil {
+			return BackupResult{}, fmt.Errorf("stat save: %w", err)
+		}
+		prefix, entries = name, []string{rel}
+	}
+	if opts.Settings {
+		entries = append(entries, settingsData...)
+	}
+
+	var (
+		result BackupResult
+		err    error
+	)
+	if result.Path, err = writeBackup(dir, prefix, installDir, entries); err != nil {
+		return BackupResult{}, err
+	}
+	if !opts.Retention.IsZero() {
+		result.Removed, err = pruneBackups(dir, prefix, opts.Retention)
+	}
+	return result, err
+}
+
+// pruneBackups removes the backups in dir named after prefix that are not
+// kept by r, and returns their paths.
+func pruneBackups(dir, prefix string, r Retention) ([]string, error) {
+	type backup struct {
+		path string
+		time time.Time
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, prefix+"-*.tar.gz"))
+	if err != nil {
+		return nil, fmt.Errorf("list backups: %w", err)
+	}
+	var backups []backup
+	for _, path := range matches {
+		name := strings.TrimSuffix(filepath.Base(path), ".tar.gz")
+		i := strings.LastIndexByte(name, '-')
+		if name[:i] != prefix {
+			continue // A backup of a save whose name starts with prefix.
+		}
+		t, err := time.Parse(backupTimeFormat, name[i+1:])
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: path, time: t})
+	}
+	slices.SortFunc(backups, func(a, b backup) int { return b.time.Compare(a.time) })
+
+	keep := make([]bool, len(backups))
+	for i := range min(r.KeepLast, len(backups)) {
+		keep[i] = true
+	}
+	keepNewest := func(n int, period func(time.Time) string) {
+		seen := make(map[string]bool)
+		for i, b := range backups {
+			p := period(b.time)
+			if seen[p] {
+				continue
+			}
+			if len(seen) == n {
+				break
+			}
+			seen[p] = true
+			keep[i] = true
+		}
+	}
+	keepNewest(r.KeepDaily, func(t time.Time) string { return t.Format(time.DateOnly) })
+	keepNewest(r.KeepWeekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+
+	var removed []string
+	for i, b := range backups {
+		if keep[i] {
+			continue
+		}
+		if err := os.Remove(b.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return removed, fmt.Errorf("remove backup: %w", err)
+		}
+		removed = append(removed, b.path)
+	}
+	return removed, nil
+}
+
+// Restore extracts a backup made by [Backup], or by [Upgrade], into the
+// installation directory, replacing the files it holds, and returns the
+// paths of the restored files, relative to installDir.
+// Restored saves are newer than the other saves, so a server started with
+// the latest save loads them.
+// The server should be stopped first, since it writes its lists and
+// autosaves over the restored files.
+func Restore(installDir, archive string) ([]string, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, fmt.Errorf("open backup: %w", err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("read backup: %w", err)
+	}
+	defer zr.Close()
+
+	var restored []string
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return restored, fmt.Errorf("read backup: %w", err)
+		}
+
+		rel := filepath.FromSlash(hdr.Name)
+		if !filepath.IsLocal(rel) {
+			return restored, fmt.Errorf("backup contains a file outside of the installation: %s", hdr.Name)
+		}
+		path := filepath.Join(installDir, rel)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, fs.ModePerm); err != nil {
+				return restored, fmt.Errorf("make directory: %w", err)
+			}
+		case tar.TypeReg:
+			if err := restoreFile(path, tr, hdr.FileInfo().Mode().Perm()); err != nil {
+				return restored, fmt.Errorf("restore %s: %w", hdr.Name, err)
+			}
+			restored = append(restored, rel)
+		}
+	}
+	return restored, nil
+}
+
+// restoreFile writes the contents of r to a temporary file next to path,
+// which then replaces path.
+func restoreFile(path string, r io.Reader, perm fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), fs.ModePerm); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
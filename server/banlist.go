@@ -0,0 +1,123 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// banlistFile is the name of the banlist in an installation directory.
+const banlistFile = "server-banlist.json"
+
+// Ban is a player banned from a server.
+type Ban struct {
+	Username string `json:"username"`
+
+	// Reason is shown to the player when they try to join.
+	Reason string `json:"reason,omitempty"`
+
+	// Address is the IP address the player was banned from, when the ban
+	// was made while they were connected.
+	Address string `json:"address,omitempty"`
+}
+
+// UnmarshalJSON implements [encoding/json.Unmarshaler].
+// Older versions of the game stored bans as plain player names, which are
+// also accepted.
+func (b *Ban) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*b = Ban{Username: name}
+		return nil
+	}
+
+	type ban Ban // Without the UnmarshalJSON method.
+	var v ban
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*b = Ban(v)
+	return nil
+}
+
+// Banlist is the list of players banned from a server, as stored in
+// "server-banlist.json".
+// Player names are compared case-insensitively, as the game does.
+type Banlist []Ban
+
+// LoadBanlist reads "server-banlist.json" from the installation directory.
+// When the file does not exist, the banlist is empty.
+func LoadBanlist(installDir string) (Banlist, error) {
+	b := Banlist{}
+	if _, err := readJSONFile(filepath.Join(installDir, banlistFile), &b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Save writes the banlist to "server-banlist.json" in the installation
+// directory.
+// A running server only reads the file when it starts; use [RCON.Ban] and
+// [RCON.Unban] to change its banlist without restarting it.
+func (b Banlist) Save(installDir string) error {
+	if b == nil {
+		b = Banlist{}
+	}
+	return writeJSONFile(filepath.Join(installDir, banlistFile), b)
+}
+
+// Find returns the ban of the named player, and whether they are banned.
+func (b Banlist) Find(name string) (Ban, bool) {
+	i := slices.IndexFunc(b, func(ban Ban) bool { return strings.EqualFold(ban.Username, name) })
+	if i < 0 {
+		return Ban{}, false
+	}
+	return b[i], true
+}
+
+// Add adds ban to the banlist, and reports whether the player was not
+// already banned.
+// The reason of an existing ban is replaced, when ban has one.
+func (b *Banlist) Add(ban Ban) bool {
+	for i := range *b {
+		if strings.EqualFold((*b)[i].Username, ban.Username) {
+			if ban.Reason != "" {
+				(*b)[i].Reason = ban.Reason
+			}
+			return false
+		}
+	}
+	*b = append(*b, ban)
+	return true
+}
+
+// Remove removes the named player from the banlist, and reports whether they
+// were on it.
+func (b *Banlist) Remove(name string) bool {
+	n := len(*b)
+	*b = slices.DeleteFunc(*b, func(ban Ban) bool { return strings.EqualFold(ban.Username, name) })
+	return len(*b) != n
+}
+
+// Ban bans the named player from the running server, with "/ban", kicking
+// them if they are connected.
+// The server also saves the ban to its "server-banlist.json".
+func (c *RCON) Ban(ctx context.Context, name, reason string) error {
+	if strings.ContainsAny(reason, "\r\n") {
+		return fmt.Errorf("ban reason %q has more than one line", reason)
+	}
+	return c.playerCommand(ctx, "/ban", name, reason)
+}
+
+// Unban lifts the named player's ban from the running server, with
+// "/unban".
+func (c *RCON) Unban(ctx context.Context, name string) error {
+	return c.playerCommand(ctx, "/unban", name)
+}
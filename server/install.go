@@ -0,0 +1,311 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+
+	"github.com/nesv/factorio-tools/httputil"
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// Release channels accepted by [InstallOptions.Version].
+const (
+	Stable       = "stable"
+	Experimental = "experimental"
+)
+
+const (
+	latestReleasesURL = "https://factorio.com/api/latest-releases"
+
+	// downloadURL is the format of the URL the headless server for a
+	// version of Factorio is downloaded from.
+	// It does not require logging in.
+	downloadURL = "https://factorio.com/get-download/%s/headless/linux64"
+
+	// How long the installed server has to print its version.
+	versionTimeout = 30 * time.Second
+)
+
+// InstallOptions control how [Install] installs the Factorio headless server.
+type InstallOptions struct {
+	// Version to install: an exact version, like "2.0.28"; the latest
+	// release in a series, like "2.0.x"; or the latest release in a
+	// channel, [Stable] or [Experimental].
+	// Defaults to [Stable].
+	Version string
+
+	// DownloadDir is where the server's archive is downloaded to.
+	// Interrupted downloads are resumed from where they left off, and
+	// archives that have already been downloaded are not downloaded again.
+	// Defaults to "facsrv" in the user's cache directory.
+	DownloadDir string
+
+	// Progress shows the download's progress when it is not nil, usually
+	// os.Stderr.
+	Progress io.Writer
+}
+
+// Install downloads the Factorio headless server, and extracts it into dir,
+// returning the version that was installed.
+// Files in dir that are not part of the server, like saves, mods, and
+// "data/server-settings.json", are left alone, so Install can also be used to
+// upgrade an existing installation.
+//
+// The server archive is extracted with tar(1), since it is compressed with
+// xz, which the standard library cannot read.
+// After extracting it, Install checks that the server runs, and that it
+// reports the expected version.
+func Install(ctx context.Context, dir string, opts InstallOptions) (string, error) {
+	version, err := ResolveVersion(ctx, opts.Version)
+	if err != nil {
+		return "", err
+	}
+
+	downloadDir := opts.DownloadDir
+	if downloadDir == "" {
+		d, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("user cache dir: %w", err)
+		}
+		downloadDir = filepath.Join(d, "facsrv")
+	}
+	if err := os.MkdirAll(downloadDir, fs.ModePerm); err != nil {
+		return "", fmt.Errorf("make download directory: %w", err)
+	}
+
+	archive := filepath.Join(downloadDir, "factorio-headless_linux_"+version+".tar.xz")
+	if _, err := os.Stat(archive); errors.Is(err, fs.ErrNotExist) {
+		urlStr := fmt.Sprintf(downloadURL, version)
+		if err := download(ctx, archive, urlStr, version, opts.Progress); err != nil {
+			return "", fmt.Errorf("download factorio %s: %w", version, err)
+		}
+	} else if err != nil {
+		return "", fmt.Errorf("stat archive: %w", err)
+	}
+
+	if err := extract(ctx, dir, archive); err != nil {
+		return "", err
+	}
+
+	installed, err := InstalledVersion(ctx, dir)
+	if err != nil {
+		return "", fmt.Errorf("check installed server: %w", err)
+	}
+	if installed != version {
+		return "", fmt.Errorf("installed server reports version %s, want %s", installed, version)
+	}
+	return version, nil
+}
+
+// ResolveVersion returns the exact version of Factorio named by version,
+// which is one of the values accepted by [InstallOptions.Version].
+// Series, like "2.0.x", are resolved to the latest stable or experimental
+// release in that series; older series have to be given exactly.
+func ResolveVersion(ctx context.Context, version string) (string, error) {
+	if version == "" {
+		version = Stable
+	}
+
+	series, isSeries := strings.CutSuffix(version, ".x")
+	if !isSeries && version != Stable && version != Experimental {
+		if _, err := mods.ParseVersion(version); err != nil {
+			return "", fmt.Errorf("invalid version: %q", version)
+		}
+		return version, nil
+	}
+
+	latest, err := latestReleases(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case version == Stable:
+		return latest[Stable], nil
+	case version == Experimental:
+		return latest[Experimental], nil
+	}
+
+	var best mods.Version
+	for _, v := range latest {
+		pv, err := mods.ParseVersion(v)
+		if err != nil || !strings.HasPrefix(v, series+".") {
+			continue
+		}
+		if pv.Compare(best) > 0 {
+			best = pv
+		}
+	}
+	if best == (mods.Version{}) {
+		return "", fmt.Errorf("no stable or experimental release in the %s series; the latest releases are %s (stable) and %s (experimental)",
+			version, latest[Stable], latest[Experimental])
+	}
+	return best.String(), nil
+}
+
+// latestReleases returns the latest version of the headless server in each
+// release channel.
+func latestReleases(ctx context.Context) (map[string]string, error) {
+	resp, err := httputil.Get(ctx, latestReleasesURL)
+	if err != nil {
+		return nil, fmt.Errorf("get latest releases: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get latest releases: %s", resp.Status)
+	}
+
+	var channels map[string]struct {
+		Headless string `json:"headless"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&channels); err != nil {
+		return nil, fmt.Errorf("decode latest releases: %w", err)
+	}
+
+	latest := make(map[string]string, len(channels))
+	for name, c := range channels {
+		latest[name] = c.Headless
+	}
+	if latest[Stable] == "" || latest[Experimental] == "" {
+		return nil, errors.New("latest releases are missing the headless server")
+	}
+	return latest, nil
+}
+
+// download downloads urlStr to dst.
+// The file is written to dst+".part" first, which is picked up again by later
+// calls, so interrupted downloads are resumed instead of started over.
+func download(ctx context.Context, dst, urlStr, version string, progress io.Writer) error {
+	part := dst + ".part"
+	f, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("open partial download: %w", err)
+	}
+	defer f.Close()
+
+	if err := httputil.Retry(ctx, func(ctx context.Context) error {
+		offset, err := f.Seek(0, io.SeekEnd)
+		if err != nil {
+			return fmt.Errorf("seek partial download: %w", err)
+		}
+
+		header := make(http.Header)
+		if offset > 0 {
+			header.Set("range", fmt.Sprintf("bytes=%d-", offset))
+		}
+		resp, err := httputil.GetHeader(ctx, urlStr, header)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusPartialContent:
+			slog.DebugContext(ctx, "resuming download", "file", filepath.Base(dst), "offset", offset)
+		case http.StatusOK:
+			// The server ignored the range, so start over.
+			if err := f.Truncate(0); err != nil {
+				return fmt.Errorf("truncate partial download: %w", err)
+			}
+			if offset, err = f.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("seek partial download: %w", err)
+			}
+		case http.StatusRequestedRangeNotSatisfiable:
+			// The partial download is already complete.
+			return nil
+		case http.StatusNotFound:
+			return fmt.Errorf("no headless server for factorio %s", version)
+		default:
+			return errors.New(resp.Status)
+		}
+
+		var w io.Writer = f
+		if progress != nil {
+			total := int64(-1)
+			if resp.ContentLength >= 0 {
+				total = offset + resp.ContentLength
+			}
+			bar := progressbar.NewOptions64(total,
+				progressbar.OptionShowBytes(true),
+				progressbar.OptionSetPredictTime(true),
+				progressbar.OptionSetDescription("Downloading Factorio "+version),
+				progressbar.OptionSetWriter(progress),
+			)
+			bar.Set64(offset)
+			defer bar.Exit()
+			w = io.MultiWriter(f, bar)
+		}
+
+		if _, err := io.Copy(w, resp.Body); err != nil {
+			return fmt.Errorf("write %s: %w", filepath.Base(dst), err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close partial download: %w", err)
+	}
+	if err := os.Rename(part, dst); err != nil {
+		return fmt.Errorf("rename partial download: %w", err)
+	}
+	return nil
+}
+
+// extract extracts the server archive into dir, without the archive's
+// top-level "factorio" directory.
+func extract(ctx context.Context, dir, archive string) error {
+	if err := os.MkdirAll(dir, fs.ModePerm); err != nil {
+		return fmt.Errorf("make install directory: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "tar", "-xJf", archive, "-C", dir, "--strip-components=1")
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("extract %s: %w: %s", filepath.Base(archive), err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// InstalledVersion returns the version of the Factorio server installed in
+// dir, as reported by running it with "--version".
+func InstalledVersion(ctx context.Context, dir string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, versionTimeout)
+	defer cancel()
+
+	bin := filepath.Join(dir, "bin", "x64", "factorio")
+	out, err := exec.CommandContext(ctx, bin, "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("run %s --version: %w", bin, err)
+	}
+
+	// The first line looks like:
+	//
+	//	Version: 2.0.28 (build 80710, linux64, headless, space-age)
+	line, _, _ := bytes.Cut(out, []byte("\n"))
+	fields := strings.Fields(string(line))
+	if len(fields) < 2 || fields[0] != "Version:" {
+		return "", fmt.Errorf("unexpected output from %s --version: %q", bin, line)
+	}
+	return fields[1], nil
+}
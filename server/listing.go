@@ -0,0 +1,203 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nesv/factorio-tools/httputil"
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// DefaultListingURL is the base URL of the matchmaking server, which lists
+// public games.
+const DefaultListingURL = "https://multiplayer.factorio.com"
+
+// ErrGameNotFound is returned by [ListingClient.GameDetails] when the
+// matchmaking server has no game with the requested ID, usually because the
+// server stopped sending it heartbeats.
+var ErrGameNotFound = errors.New("game not found")
+
+// ListingClient makes requests to the [Multiplayer API] of the matchmaking
+// server, which lists the public games the game's "Browse public games"
+// screen shows.
+// The zero value is ready to use, and talks to [DefaultListingURL].
+//
+// [Multiplayer API]: https://wiki.factorio.com/Multiplayer_API
+type ListingClient struct {
+	// BaseURL of the matchmaking server, or of a server that implements
+	// the same API.
+	// When empty, [DefaultListingURL] is used.
+	BaseURL string
+
+	// HTTPClient is used to send requests.
+	// When nil, the client returned by [httputil.Client] is used.
+	HTTPClient *http.Client
+}
+
+// Game is a game listed by the matchmaking server.
+type Game struct {
+	ID          int      `json:"game_id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+
+	// The public address the server was reached at, like
+	// "203.0.113.1:34197".
+	HostAddress string `json:"host_address"`
+
+	// 0 means unlimited.
+	MaxPlayers int `json:"max_players"`
+
+	// Names of the connected players.
+	Players []string `json:"players"`
+
+	ApplicationVersion GameVersion `json:"application_version"`
+
+	// Minutes the map has been played for.
+	GameTimeElapsed int `json:"game_time_elapsed"`
+
+	HasPassword    bool `json:"has_password"`
+	HeadlessServer bool `json:"headless_server"`
+	HasMods        bool `json:"has_mods"`
+	ModCount       int  `json:"mod_count"`
+
+	// Only returned by [ListingClient.GameDetails].
+	Mods []GameMod `json:"mods,omitempty"`
+
+	// Seconds since the Unix epoch when the server last sent the
+	// matchmaking server a heartbeat.
+	// Only returned by [ListingClient.GameDetails].
+	LastHeartbeat float64 `json:"last_heartbeat,omitempty"`
+}
+
+// LastHeartbeatTime returns when the server last sent the matchmaking server a
+// heartbeat, or the zero time when it is not known.
+func (g Game) LastHeartbeatTime() time.Time {
+	if g.LastHeartbeat == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(int64(g.LastHeartbeat * 1000))
+}
+
+// GameVersion is the version of the game a listed server runs.
+type GameVersion struct {
+	GameVersion  string `json:"game_version"`
+	BuildVersion int    `json:"build_version"`
+
+	// Like "headless".
+	BuildMode string `json:"build_mode"`
+
+	// Like "linux64".
+	Platform string `json:"platform"`
+}
+
+// GameMod is a mod enabled on a listed server.
+type GameMod struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Games returns every public game, from the "/get-games" endpoint, which
+// requires a factorio.com username and token.
+// An error wrapping [mods.ErrAuthRequired] is returned when either is empty,
+// and one wrapping [mods.ErrAuthFailed] when the matchmaking server rejects
+// them.
+func (c *ListingClient) Games(ctx context.Context, username, token string) ([]Game, error) {
+	if username == "" || token == "" {
+		return nil, fmt.Errorf("%w: a username and token are required to list games", mods.ErrAuthRequired)
+	}
+	q := url.Values{"username": {username}, "token": {token}}
+
+	var games []Game
+	if err := c.getJSON(ctx, c.url("/get-games")+"?"+q.Encode(), &games); err != nil {
+		return nil, err
+	}
+	return games, nil
+}
+
+// GameDetails returns the game with the given ID, from the
+// "/get-game-details/{id}" endpoint, including its mods.
+// An error wrapping [ErrGameNotFound] is returned when there is no such game.
+func (c *ListingClient) GameDetails(ctx context.Context, id int) (*Game, error) {
+	var game Game
+	err := c.getJSON(ctx, c.url("/get-game-details/"+strconv.Itoa(id)), &game)
+	if errors.Is(err, errNotFound) {
+		return nil, fmt.Errorf("%w: %d", ErrGameNotFound, id)
+	} else if err != nil {
+		return nil, err
+	}
+	return &game, nil
+}
+
+// errNotFound is returned by getJSON for "404 Not Found" responses.
+var errNotFound = errors.New("not found")
+
+// getJSON issues a GET request to urlStr, and decodes the JSON response into
+// v.
+func (c *ListingClient) getJSON(ctx context.Context, urlStr string, v any) error {
+	resp, err := c.get(ctx, urlStr)
+	if err != nil {
+		// Do not leak the token in the query string.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("http get %s: %w", c.url(""), err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return errNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w: %s", mods.ErrAuthFailed, resp.Status)
+	default:
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Message != "" {
+			return fmt.Errorf("unexpected response: %s: %s", resp.Status, apiErr.Message)
+		}
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode json: %w", err)
+	}
+	return nil
+}
+
+// get issues a GET request to urlStr.
+func (c *ListingClient) get(ctx context.Context, urlStr string) (*http.Response, error) {
+	if c.HTTPClient == nil {
+		return httputil.GetHeader(ctx, urlStr, nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+	req.Header.Set("user-agent", httputil.UserAgent)
+	return c.HTTPClient.Do(req)
+}
+
+// url returns the URL of path on the matchmaking server.
+func (c *ListingClient) url(path string) string {
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultListingURL
+	}
+	return strings.TrimSuffix(base, "/") + path
+}
@@ -0,0 +1,245 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package logs parses the Factorio server's logs into typed events, like
+// players joining, chat messages, and saves.
+//
+// It reads both "factorio-current.log", where each line starts with the
+// number of seconds since the server started, like:
+//
+//	 0.000 2024-11-02 18:00:00; Factorio 2.0.28 (build 80710, linux64, headless, space-age)
+//	60.123 Info AppManagerStates.cpp:1843: Saving to _autosave1 (blocking).
+//
+// and the console log, written with the server's "--console-log" option,
+// where lines start with the time, like:
+//
+//	2024-11-02 18:04:11 [JOIN] alice joined the game
+//
+// The server's standard output holds both kinds of lines.
+// Matches are not anchored to the start of the line, so lines with a prefix,
+// like those read from journalctl(1), are parsed as well.
+package logs
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind is the kind of an [Event].
+type Kind string
+
+const (
+	// The banner the server logs when it starts, with its version.
+	KindVersion Kind = "version"
+
+	// A player joined or left the game.
+	KindJoin  Kind = "join"
+	KindLeave Kind = "leave"
+
+	// A chat message, from a player, or from "<server>".
+	KindChat Kind = "chat"
+
+	// The server started saving the map, and finished saving it.
+	KindSaveStarted  Kind = "save-started"
+	KindSaveFinished Kind = "save-finished"
+
+	// An error logged by the server.
+	KindError Kind = "error"
+
+	// A player desynchronised from the server.
+	KindDesync Kind = "desync"
+)
+
+// Kinds returns every kind of event, in the order they are declared.
+func Kinds() []Kind {
+	return []Kind{
+		KindVersion,
+		KindJoin,
+		KindLeave,
+		KindChat,
+		KindSaveStarted,
+		KindSaveFinished,
+		KindError,
+		KindDesync,
+	}
+}
+
+// Event is something that happened on the server, parsed from a line of its
+// logs.
+type Event struct {
+	Kind Kind `json:"kind"`
+
+	// When it happened, in the server's local time.
+	// Events from "factorio-current.log" only have a time after the
+	// server's banner has been read, since their lines only hold the time
+	// since the server started.
+	Time time.Time `json:"time"`
+
+	// The player who joined, left, or sent a chat message.
+	Player string `json:"player,omitempty"`
+
+	// The chat message, the error, the name of the save being saved, or,
+	// for [KindVersion], the server's version.
+	Message string `json:"message,omitempty"`
+
+	// The line the event was parsed from.
+	Line string `json:"line"`
+}
+
+// timeLayout is the layout of the times in the server's logs.
+const timeLayout = "2006-01-02 15:04:05"
+
+var (
+	// consoleLine matches a line of the console log.
+	consoleLine = regexp.MustCompile(`(\d{4}-\d\d-\d\d \d\d:\d\d:\d\d) \[(JOIN|LEAVE|CHAT)\] (.*)$`)
+
+	// logLine matches a line of factorio-current.log: the seconds since
+	// the server started, and the message, which is prefixed with its
+	// level and source, or, for the banner, with the time.
+	logLine = regexp.MustCompile(`(\d+\.\d{3}) (?:(\d{4}-\d\d-\d\d \d\d:\d\d:\d\d); )?(.*)$`)
+
+	banner  = regexp.MustCompile(`^Factorio (\S+) \(build \d+`)
+	leveled = regexp.MustCompile(`^(Error|Warning|Info|Verbose) \S+: (.*)$`)
+	saving  = regexp.MustCompile(`^Saving (?:to|game as) (\S+?)\.?(?: \(.*\))?\.?$`)
+)
+
+// Parse parses a line of the server's logs, and reports whether it is one of
+// the events [Kind] describes.
+// Events parsed from lines of "factorio-current.log" have no time; use a
+// [Scanner] to read them.
+func Parse(line string) (Event, bool) {
+	return parse(line, time.Time{})
+}
+
+// parse is like [Parse], but gives events from "factorio-current.log" a time,
+// relative to when the server started, when started is not zero.
+func parse(line string, started time.Time) (Event, bool) {
+	if m := consoleLine.FindStringSubmatch(line); m != nil {
+		t, err := time.ParseInLocation(timeLayout, m[1], time.Local)
+		if err != nil {
+			return Event{}, false
+		}
+		e := Event{Time: t, Line: line}
+		switch m[2] {
+		case "JOIN":
+			player, ok := strings.CutSuffix(m[3], " joined the game")
+			if !ok {
+				return Event{}, false
+			}
+			e.Kind, e.Player = KindJoin, player
+		case "LEAVE":
+			player, ok := strings.CutSuffix(m[3], " left the game")
+			if !ok {
+				return Event{}, false
+			}
+			e.Kind, e.Player = KindLeave, player
+		case "CHAT":
+			player, message, ok := strings.Cut(m[3], ": ")
+			if !ok {
+				return Event{}, false
+			}
+			e.Kind, e.Player, e.Message = KindChat, player, message
+		}
+		return e, true
+	}
+
+	m := logLine.FindStringSubmatch(line)
+	if m == nil {
+		return Event{}, false
+	}
+	e := Event{Line: line}
+	if m[2] != "" {
+		bm := banner.FindStringSubmatch(m[3])
+		if bm == nil {
+			return Event{}, false
+		}
+		t, err := time.ParseInLocation(timeLayout, m[2], time.Local)
+		if err != nil {
+			return Event{}, false
+		}
+		e.Kind, e.Time, e.Message = KindVersion, t, bm[1]
+		return e, true
+	}
+
+	lm := leveled.FindStringSubmatch(m[3])
+	if lm == nil {
+		return Event{}, false
+	}
+	level, message := lm[1], lm[2]
+	switch {
+	case strings.Contains(strings.ToLower(message), "desync"):
+		e.Kind = KindDesync
+	case level == "Error":
+		e.Kind = KindError
+	case message == "Saving finished":
+		e.Kind, message = KindSaveFinished, ""
+	case saving.MatchString(message):
+		e.Kind = KindSaveStarted
+		message = saving.FindStringSubmatch(message)[1]
+	default:
+		return Event{}, false
+	}
+	e.Message = message
+	if !started.IsZero() {
+		if secs, err := strconv.ParseFloat(m[1], 64); err == nil {
+			e.Time = started.Add(time.Duration(secs * float64(time.Second)))
+		}
+	}
+	return e, true
+}
+
+// Scanner reads [Event]s from the server's logs, skipping the lines that are
+// not events.
+// It is used like a [bufio.Scanner].
+type Scanner struct {
+	sc      *bufio.Scanner
+	started time.Time
+	event   Event
+}
+
+// maxLineLength is the length of the longest line a [Scanner] reads; the
+// server logs long lines, like the list of mods it loaded.
+const maxLineLength = 1 << 20
+
+// NewScanner returns a [Scanner] reading from r.
+func NewScanner(r io.Reader) *Scanner {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, maxLineLength)
+	return &Scanner{sc: sc}
+}
+
+// Scan advances to the next event, which is then available through
+// [Scanner.Event].
+// It returns false when there are no more events, or reading failed; see
+// [Scanner.Err].
+func (s *Scanner) Scan() bool {
+	for s.sc.Scan() {
+		e, ok := parse(s.sc.Text(), s.started)
+		if !ok {
+			continue
+		}
+		if e.Kind == KindVersion {
+			// Times in factorio-current.log are relative to the
+			// banner, which the server logs when it starts.
+			s.started = e.Time
+		}
+		s.event = e
+		return true
+	}
+	return false
+}
+
+// Event returns the event read by the last call to [Scanner.Scan].
+func (s *Scanner) Event() Event {
+	return s.event
+}
+
+// Err returns the first error that was encountered while reading.
+func (s *Scanner) Err() error {
+	return s.sc.Err()
+}
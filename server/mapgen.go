@@ -0,0 +1,219 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// Bounds of the multipliers in [MapGenSettings], matching the ones offered
+// by the game's map generator.
+const (
+	// minMultiplier is the smallest multiplier; 0 disables a control.
+	minMultiplier = 0
+	maxMultiplier = 6
+
+	// maxMapSize is the largest width or height of a map, in tiles.
+	maxMapSize = 2_000_000
+)
+
+// DefaultMapGenSettings returns [MapGenSettings] with default values set,
+// like the game's "data/map-gen-settings.example.json".
+func DefaultMapGenSettings() *MapGenSettings {
+	controls := make(map[string]AutoplaceSettings)
+	for _, name := range []string{
+		"coal",
+		"stone",
+		"copper-ore",
+		"iron-ore",
+		"uranium-ore",
+		"crude-oil",
+		"trees",
+		"enemy-base",
+	} {
+		controls[name] = AutoplaceSettings{Frequency: 1, Size: 1, Richness: 1}
+	}
+
+	return &MapGenSettings{
+		TerrainSegmentation:               1,
+		Water:                             1,
+		StartingArea:                      1,
+		DefaultEnableAllAutoplaceControls: true,
+		AutoplaceControls:                 controls,
+		CliffSettings: CliffSettings{
+			Name:                   "cliff",
+			CliffElevation0:        10,
+			CliffElevationInterval: 40,
+			Richness:               1,
+		},
+		StartingPoints: []Position{{X: 0, Y: 0}},
+	}
+}
+
+// ReadMapGenSettings reads in [MapGenSettings] from r.
+// Settings missing from r keep their values from [DefaultMapGenSettings],
+// as they do when the game reads the file.
+func ReadMapGenSettings(r io.Reader) (MapGenSettings, error) {
+	s := DefaultMapGenSettings()
+	if _, err := s.ReadFrom(r); err != nil {
+		return MapGenSettings{}, fmt.Errorf("read from: %w", err)
+	}
+	return *s, nil
+}
+
+// MapGenSettings holds the settings used to generate a new map, as given to
+// the game with "--map-gen-settings".
+type MapGenSettings struct {
+	// Inverse of the scale of water on the map.
+	TerrainSegmentation float64 `json:"terrain_segmentation"` // default: 1
+
+	// Multiplier for water coverage.
+	Water float64 `json:"water"` // default: 1
+
+	// Width and height of the map, in tiles.
+	// 0 means infinite.
+	Width  uint `json:"width"`  // default: 0
+	Height uint `json:"height"` // default: 0
+
+	// Multiplier for the radius of the area around the starting points that
+	// is free of enemies.
+	StartingArea float64 `json:"starting_area"` // default: 1
+
+	// Enemies do not attack until they are attacked.
+	PeacefulMode bool `json:"peaceful_mode"` // default: false
+
+	// No enemy bases are generated.
+	// Only read by Factorio 2.0, and later.
+	NoEnemiesMode bool `json:"no_enemies_mode"` // default: false
+
+	// Whether autoplace controls that are not in AutoplaceControls are
+	// enabled, with their default settings.
+	DefaultEnableAllAutoplaceControls bool `json:"default_enable_all_autoplace_controls"` // default: true
+
+	// Settings for the placement of resources, trees, and enemy bases,
+	// keyed by the name of their autoplace control, like "iron-ore" or
+	// "enemy-base".
+	AutoplaceControls map[string]AutoplaceSettings `json:"autoplace_controls"`
+
+	// Settings for the placement of cliffs.
+	CliffSettings CliffSettings `json:"cliff_settings"`
+
+	// Overrides for the noise expressions used to generate the map, like
+	// "control-setting:moisture:bias".
+	// Values are usually strings, but can also be numbers.
+	PropertyExpressionNames map[string]any `json:"property_expression_names,omitempty"`
+
+	// Where players spawn.
+	StartingPoints []Position `json:"starting_points"`
+
+	// Seed of the map's random number generator.
+	// When it is nil, a random seed is used.
+	Seed *uint32 `json:"seed"` // default: null
+}
+
+// AutoplaceSettings control how something, like a resource, is placed on the
+// map.
+// Each value is a multiplier of the game's default; 0 disables the control.
+type AutoplaceSettings struct {
+	// How often it is placed.
+	Frequency float64 `json:"frequency"` // default: 1
+
+	// How large each patch is.
+	Size float64 `json:"size"` // default: 1
+
+	// How much each patch holds.
+	// Ignored by controls without a richness, like "trees".
+	Richness float64 `json:"richness"` // default: 1
+}
+
+// CliffSettings control how cliffs are placed on the map.
+type CliffSettings struct {
+	// Name of the cliff prototype.
+	Name string `json:"name"` // default: cliff
+
+	// Elevation of the first row of cliffs.
+	CliffElevation0 float64 `json:"cliff_elevation_0"` // default: 10
+
+	// Elevation difference between successive rows of cliffs, which is
+	// inversely proportional to their frequency.
+	CliffElevationInterval float64 `json:"cliff_elevation_interval"` // default: 40
+
+	// Multiplier of how continuous cliffs are; 0 disables them.
+	Richness float64 `json:"richness"` // default: 1
+}
+
+// Position is a position on a map, in tiles.
+type Position struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Validate checks that the settings are within the bounds accepted by the
+// game's map generator.
+func (s *MapGenSettings) Validate() error {
+	var errs []error
+	checkMultiplier := func(name string, v float64) {
+		if v < minMultiplier || v > maxMultiplier {
+			errs = append(errs, fmt.Errorf("%s must be between %d and %d, not %g", name, minMultiplier, maxMultiplier, v))
+		}
+	}
+
+	checkMultiplier("terrain_segmentation", s.TerrainSegmentation)
+	checkMultiplier("water", s.Water)
+	checkMultiplier("starting_area", s.StartingArea)
+	if s.Width > maxMapSize {
+		errs = append(errs, fmt.Errorf("width must be at most %d, not %d", maxMapSize, s.Width))
+	}
+	if s.Height > maxMapSize {
+		errs = append(errs, fmt.Errorf("height must be at most %d, not %d", maxMapSize, s.Height))
+	}
+
+	names := make([]string, 0, len(s.AutoplaceControls))
+	for name := range s.AutoplaceControls {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		c := s.AutoplaceControls[name]
+		checkMultiplier(name+" frequency", c.Frequency)
+		checkMultiplier(name+" size", c.Size)
+		checkMultiplier(name+" richness", c.Richness)
+	}
+
+	if s.CliffSettings.Name == "" {
+		errs = append(errs, errors.New("cliff_settings: name is required"))
+	}
+	if s.CliffSettings.CliffElevationInterval <= 0 {
+		errs = append(errs, fmt.Errorf("cliff_settings: cliff_elevation_interval must be positive, not %g", s.CliffSettings.CliffElevationInterval))
+	}
+	checkMultiplier("cliff_settings: richness", s.CliffSettings.Richness)
+
+	return errors.Join(errs...)
+}
+
+// ReadFrom implements the [io.ReaderFrom] interface, populating the values in s from the contents in r.
+// On a successful invocation, ReadFrom will return 0, nil.
+func (s *MapGenSettings) ReadFrom(r io.Reader) (int64, error) {
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(s); err != nil {
+		return 0, fmt.Errorf("decode json: %w", err)
+	}
+	return 0, nil
+}
+
+// WriteTo implements the [io.WriterTo] interface, and will encode the data in s to w.
+// On a successful invocation, WriteTo returns 0, nil.
+func (s *MapGenSettings) WriteTo(w io.Writer) (int64, error) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		return 0, fmt.Errorf("encode json: %w", err)
+	}
+	return 0, nil
+}
@@ -0,0 +1,352 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// DefaultMapSettings returns [MapSettings] with default values set, like the
+// game's "data/map-settings.example.json".
+func DefaultMapSettings() *MapSettings {
+	return &MapSettings{
+		Pollution: PollutionSettings{
+			Enabled:                                 true,
+			DiffusionRatio:                          0.02,
+			MinToDiffuse:                            15,
+			Ageing:                                  1,
+			ExpectedMaxPerChunk:                     150,
+			MinToShowPerChunk:                       50,
+			MinPollutionToDamageTrees:               60,
+			PollutionWithMaxForestDamage:            150,
+			PollutionPerTreeDamage:                  50,
+			PollutionRestoredPerTreeDamage:          10,
+			MaxPollutionToRestoreTrees:              20,
+			EnemyAttackPollutionConsumptionModifier: 1,
+		},
+		EnemyEvolution: EnemyEvolutionSettings{
+			Enabled:         true,
+			TimeFactor:      0.000004,
+			DestroyFactor:   0.002,
+			PollutionFactor: 0.0000009,
+		},
+		EnemyExpansion: EnemyExpansionSettings{
+			Enabled:                          true,
+			MinBaseSpacing:                   3,
+			MaxExpansionDistance:             7,
+			FriendlyBaseInfluenceRadius:      2,
+			EnemyBuildingInfluenceRadius:     2,
+			BuildingCoefficient:              0.1,
+			OtherBaseCoefficient:             2,
+			NeighbouringChunkCoefficient:     0.5,
+			NeighbouringBaseChunkCoefficient: 0.4,
+			MaxCollidingTilesCoefficient:     0.9,
+			SettlerGroupMinSize:              5,
+			SettlerGroupMaxSize:              20,
+			MinExpansionCooldown:             4 * 3600,
+			MaxExpansionCooldown:             60 * 3600,
+		},
+		UnitGroup: UnitGroupSettings{
+			MinGroupGatheringTime:          3600,
+			MaxGroupGatheringTime:          10 * 3600,
+			MaxWaitTimeForLateMembers:      2 * 3600,
+			MaxGroupRadius:                 30,
+			MinGroupRadius:                 5,
+			MaxMemberSpeedupWhenBehind:     1.4,
+			MaxMemberSlowdownWhenAhead:     0.6,
+			MaxGroupSlowdownFactor:         0.3,
+			MaxGroupMemberFallbackFactor:   3,
+			MemberDisownDistance:           10,
+			TickToleranceWhenMemberArrives: 60,
+			MaxGatheringUnitGroups:         30,
+			MaxUnitGroupSize:               200,
+		},
+		Steering: SteeringSettings{
+			Default: Steering{
+				Radius:           1.2,
+				SeparationForce:  0.005,
+				SeparationFactor: 1.2,
+			},
+			Moving: Steering{
+				Radius:           3,
+				SeparationForce:  0.01,
+				SeparationFactor: 3,
+			},
+		},
+		PathFinder: PathFinderSettings{
+			Fwd2BwdRatio:                                  5,
+			GoalPressureRatio:                             2,
+			MaxStepsWorkedPerTick:                         100,
+			MaxWorkDonePerTick:                            8000,
+			UsePathCache:                                  true,
+			ShortCacheSize:                                5,
+			LongCacheSize:                                 25,
+			ShortCacheMinCacheableDistance:                10,
+			ShortCacheMinAlgoStepsToCache:                 50,
+			LongCacheMinCacheableDistance:                 30,
+			CacheMaxConnectToCacheStepsMultiplier:         100,
+			CacheAcceptPathStartDistanceRatio:             0.2,
+			CacheAcceptPathEndDistanceRatio:               0.15,
+			NegativeCacheAcceptPathStartDistanceRatio:     0.3,
+			NegativeCacheAcceptPathEndDistanceRatio:       0.3,
+			CachePathStartDistanceRatingMultiplier:        10,
+			CachePathEndDistanceRatingMultiplier:          20,
+			StaleEnemyWithSameDestinationCollisionPenalty: 30,
+			IgnoreMovingEnemyCollisionDistance:            5,
+			EnemyWithDifferentDestinationCollisionPenalty: 30,
+			GeneralEntityCollisionPenalty:                 10,
+			GeneralEntitySubsequentCollisionPenalty:       3,
+			ExtendedCollisionPenalty:                      3,
+			MaxClientsToAcceptAnyNewRequest:               10,
+			MaxClientsToAcceptShortNewRequest:             100,
+			DirectDistanceToConsiderShortRequest:          100,
+			ShortRequestMaxSteps:                          1000,
+			ShortRequestRatio:                             0.5,
+			MinStepsToCheckPathFindTermination:            2000,
+			StartToGoalCostMultiplierToTerminatePathFind:  2000,
+			OverloadLevels:                                []uint{0, 100, 500},
+			OverloadMultipliers:                           []float64{2, 3, 4},
+			NegativePathCacheDelayInterval:                20,
+		},
+		MaxFailedBehaviorCount: 3,
+	}
+}
+
+// ReadMapSettings reads in [MapSettings] from r.
+// Settings missing from r keep their values from [DefaultMapSettings], as
+// they do when the game reads the file.
+func ReadMapSettings(r io.Reader) (MapSettings, error) {
+	s := DefaultMapSettings()
+	if _, err := s.ReadFrom(r); err != nil {
+		return MapSettings{}, fmt.Errorf("read from: %w", err)
+	}
+	return *s, nil
+}
+
+// MapSettings holds the settings of a new map that are not about generating
+// its terrain, as given to the game with "--map-settings".
+// Times are in ticks; there are 60 ticks in a second.
+type MapSettings struct {
+	Pollution      PollutionSettings      `json:"pollution"`
+	EnemyEvolution EnemyEvolutionSettings `json:"enemy_evolution"`
+	EnemyExpansion EnemyExpansionSettings `json:"enemy_expansion"`
+	UnitGroup      UnitGroupSettings      `json:"unit_group"`
+	Steering       SteeringSettings       `json:"steering"`
+	PathFinder     PathFinderSettings     `json:"path_finder"`
+
+	// How many times a unit can fail to find a path, or get to its
+	// destination, before it gives up on it.
+	MaxFailedBehaviorCount uint `json:"max_failed_behavior_count"` // default: 3
+}
+
+// PollutionSettings control how pollution spreads, and how it damages trees.
+// Amounts are per 60 ticks.
+type PollutionSettings struct {
+	Enabled bool `json:"enabled"` // default: true
+
+	// Share of a chunk's pollution that spreads to each neighbouring chunk.
+	DiffusionRatio float64 `json:"diffusion_ratio"` // default: 0.02
+
+	// Pollution a chunk needs before it spreads.
+	MinToDiffuse float64 `json:"min_to_diffuse"` // default: 15
+
+	// Modifier of how quickly pollution is absorbed by the ground.
+	Ageing float64 `json:"ageing"` // default: 1
+
+	// Pollution at which chunks are shown darkest on the map.
+	ExpectedMaxPerChunk float64 `json:"expected_max_per_chunk"` // default: 150
+
+	// Pollution a chunk needs before it is shown on the map.
+	MinToShowPerChunk float64 `json:"min_to_show_per_chunk"` // default: 50
+
+	MinPollutionToDamageTrees      float64 `json:"min_pollution_to_damage_trees"`      // default: 60
+	PollutionWithMaxForestDamage   float64 `json:"pollution_with_max_forest_damage"`   // default: 150
+	PollutionPerTreeDamage         float64 `json:"pollution_per_tree_damage"`          // default: 50
+	PollutionRestoredPerTreeDamage float64 `json:"pollution_restored_per_tree_damage"` // default: 10
+	MaxPollutionToRestoreTrees     float64 `json:"max_pollution_to_restore_trees"`     // default: 20
+
+	// Multiplier of the pollution absorbed by enemy units sent to attack.
+	EnemyAttackPollutionConsumptionModifier float64 `json:"enemy_attack_pollution_consumption_modifier"` // default: 1
+}
+
+// EnemyEvolutionSettings control how quickly enemies evolve.
+type EnemyEvolutionSettings struct {
+	Enabled bool `json:"enabled"` // default: true
+
+	// Evolution per tick.
+	TimeFactor float64 `json:"time_factor"` // default: 0.000004
+
+	// Evolution per destroyed enemy spawner.
+	DestroyFactor float64 `json:"destroy_factor"` // default: 0.002
+
+	// Evolution per unit of pollution produced.
+	PollutionFactor float64 `json:"pollution_factor"` // default: 0.0000009
+}
+
+// EnemyExpansionSettings control how enemies build new bases.
+// Distances are in chunks.
+type EnemyExpansionSettings struct {
+	Enabled bool `json:"enabled"` // default: true
+
+	// Distance between enemy bases.
+	MinBaseSpacing uint `json:"min_base_spacing"` // default: 3
+
+	// Distance from existing bases that new bases are built at.
+	MaxExpansionDistance uint `json:"max_expansion_distance"` // default: 7
+
+	// Candidate chunks for new bases are scored with these, lower scores
+	// being better.
+	FriendlyBaseInfluenceRadius      uint    `json:"friendly_base_influence_radius"`      // default: 2
+	EnemyBuildingInfluenceRadius     uint    `json:"enemy_building_influence_radius"`     // default: 2
+	BuildingCoefficient              float64 `json:"building_coefficient"`                // default: 0.1
+	OtherBaseCoefficient             float64 `json:"other_base_coefficient"`              // default: 2
+	NeighbouringChunkCoefficient     float64 `json:"neighbouring_chunk_coefficient"`      // default: 0.5
+	NeighbouringBaseChunkCoefficient float64 `json:"neighbouring_base_chunk_coefficient"` // default: 0.4
+
+	// Largest share of a chunk that can be covered by obstacles, like
+	// water, for a base to be built in it.
+	MaxCollidingTilesCoefficient float64 `json:"max_colliding_tiles_coefficient"` // default: 0.9
+
+	// Size of the groups of units sent to build a new base.
+	SettlerGroupMinSize uint `json:"settler_group_min_size"` // default: 5
+	SettlerGroupMaxSize uint `json:"settler_group_max_size"` // default: 20
+
+	// Time between expansions; it shortens as enemies evolve.
+	MinExpansionCooldown uint `json:"min_expansion_cooldown"` // default: 14400
+	MaxExpansionCooldown uint `json:"max_expansion_cooldown"` // default: 216000
+}
+
+// UnitGroupSettings control how enemy units gather into groups, and move
+// together.
+type UnitGroupSettings struct {
+	// Time a group gathers for before it attacks.
+	MinGroupGatheringTime uint `json:"min_group_gathering_time"` // default: 3600
+	MaxGroupGatheringTime uint `json:"max_group_gathering_time"` // default: 36000
+
+	// Time a group waits for members that are late, after it finished
+	// gathering.
+	MaxWaitTimeForLateMembers uint `json:"max_wait_time_for_late_members"` // default: 7200
+
+	// Radius of a group, in tiles.
+	MaxGroupRadius float64 `json:"max_group_radius"` // default: 30
+	MinGroupRadius float64 `json:"min_group_radius"` // default: 5
+
+	// How much members speed up, or slow down, to keep up with the group.
+	MaxMemberSpeedupWhenBehind float64 `json:"max_member_speedup_when_behind"` // default: 1.4
+	MaxMemberSlowdownWhenAhead float64 `json:"max_member_slowdown_when_ahead"` // default: 0.6
+
+	// How much the group slows down when members fall behind.
+	MaxGroupSlowdownFactor float64 `json:"max_group_slowdown_factor"` // default: 0.3
+
+	// Members further behind than this, times the group's radius, make the
+	// group stop and wait for them.
+	MaxGroupMemberFallbackFactor float64 `json:"max_group_member_fallback_factor"` // default: 3
+
+	// Members further away than this, times the group's radius, leave it.
+	MemberDisownDistance float64 `json:"member_disown_distance"` // default: 10
+
+	TickToleranceWhenMemberArrives uint `json:"tick_tolerance_when_member_arrives"` // default: 60
+
+	// Number of groups that can gather at the same time.
+	MaxGatheringUnitGroups uint `json:"max_gathering_unit_groups"` // default: 30
+
+	// Number of units in a group.
+	MaxUnitGroupSize uint `json:"max_unit_group_size"` // default: 200
+}
+
+// SteeringSettings control how units keep apart from each other.
+type SteeringSettings struct {
+	// For units standing still.
+	Default Steering `json:"default"`
+
+	// For units that are moving.
+	Moving Steering `json:"moving"`
+}
+
+// Steering controls how a unit keeps apart from other units.
+type Steering struct {
+	// Units within this distance, in tiles, push each other apart.
+	Radius           float64 `json:"radius"`
+	SeparationForce  float64 `json:"separation_force"`
+	SeparationFactor float64 `json:"separation_factor"`
+
+	ForceUnitFuzzyGotoBehavior bool `json:"force_unit_fuzzy_goto_behavior"` // default: false
+}
+
+// PathFinderSettings control how units find their paths.
+// The game's documentation of these is sparse; they are best left alone,
+// unless the path finder is using too much time.
+type PathFinderSettings struct {
+	// Ratio of steps taken by the search from the start, to the steps
+	// taken by the search from the goal.
+	Fwd2BwdRatio uint `json:"fwd2bwd_ratio"` // default: 5
+
+	// Higher values make the search prefer paths closer to the goal.
+	GoalPressureRatio float64 `json:"goal_pressure_ratio"` // default: 2
+
+	MaxStepsWorkedPerTick uint `json:"max_steps_worked_per_tick"` // default: 100
+	MaxWorkDonePerTick    uint `json:"max_work_done_per_tick"`    // default: 8000
+
+	UsePathCache                              bool    `json:"use_path_cache"`                                  // default: true
+	ShortCacheSize                            uint    `json:"short_cache_size"`                                // default: 5
+	LongCacheSize                             uint    `json:"long_cache_size"`                                 // default: 25
+	ShortCacheMinCacheableDistance            float64 `json:"short_cache_min_cacheable_distance"`              // default: 10
+	ShortCacheMinAlgoStepsToCache             uint    `json:"short_cache_min_algo_steps_to_cache"`             // default: 50
+	LongCacheMinCacheableDistance             float64 `json:"long_cache_min_cacheable_distance"`               // default: 30
+	CacheMaxConnectToCacheStepsMultiplier     uint    `json:"cache_max_connect_to_cache_steps_multiplier"`     // default: 100
+	CacheAcceptPathStartDistanceRatio         float64 `json:"cache_accept_path_start_distance_ratio"`          // default: 0.2
+	CacheAcceptPathEndDistanceRatio           float64 `json:"cache_accept_path_end_distance_ratio"`            // default: 0.15
+	NegativeCacheAcceptPathStartDistanceRatio float64 `json:"negative_cache_accept_path_start_distance_ratio"` // default: 0.3
+	NegativeCacheAcceptPathEndDistanceRatio   float64 `json:"negative_cache_accept_path_end_distance_ratio"`   // default: 0.3
+	CachePathStartDistanceRatingMultiplier    float64 `json:"cache_path_start_distance_rating_multiplier"`     // default: 10
+	CachePathEndDistanceRatingMultiplier      float64 `json:"cache_path_end_distance_rating_multiplier"`       // default: 20
+
+	StaleEnemyWithSameDestinationCollisionPenalty float64 `json:"stale_enemy_with_same_destination_collision_penalty"` // default: 30
+	IgnoreMovingEnemyCollisionDistance            float64 `json:"ignore_moving_enemy_collision_distance"`              // default: 5
+	EnemyWithDifferentDestinationCollisionPenalty float64 `json:"enemy_with_different_destination_collision_penalty"`  // default: 30
+	GeneralEntityCollisionPenalty                 float64 `json:"general_entity_collision_penalty"`                    // default: 10
+	GeneralEntitySubsequentCollisionPenalty       float64 `json:"general_entity_subsequent_collision_penalty"`         // default: 3
+	ExtendedCollisionPenalty                      float64 `json:"extended_collision_penalty"`                          // default: 3
+
+	MaxClientsToAcceptAnyNewRequest      uint    `json:"max_clients_to_accept_any_new_request"`     // default: 10
+	MaxClientsToAcceptShortNewRequest    uint    `json:"max_clients_to_accept_short_new_request"`   // default: 100
+	DirectDistanceToConsiderShortRequest uint    `json:"direct_distance_to_consider_short_request"` // default: 100
+	ShortRequestMaxSteps                 uint    `json:"short_request_max_steps"`                   // default: 1000
+	ShortRequestRatio                    float64 `json:"short_request_ratio"`                       // default: 0.5
+
+	MinStepsToCheckPathFindTermination           uint    `json:"min_steps_to_check_path_find_termination"`             // default: 2000
+	StartToGoalCostMultiplierToTerminatePathFind float64 `json:"start_to_goal_cost_multiplier_to_terminate_path_find"` // default: 2000
+
+	// When the number of pending requests reaches each of OverloadLevels,
+	// the work done per tick is multiplied by the matching
+	// OverloadMultipliers.
+	OverloadLevels      []uint    `json:"overload_levels"`      // default: [0, 100, 500]
+	OverloadMultipliers []float64 `json:"overload_multipliers"` // default: [2, 3, 4]
+
+	NegativePathCacheDelayInterval uint `json:"negative_path_cache_delay_interval"` // default: 20
+}
+
+// ReadFrom implements the [io.ReaderFrom] interface, populating the values in s from the contents in r.
+// On a successful invocation, ReadFrom will return 0, nil.
+func (s *MapSettings) ReadFrom(r io.Reader) (int64, error) {
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(s); err != nil {
+		return 0, fmt.Errorf("decode json: %w", err)
+	}
+	return 0, nil
+}
+
+// WriteTo implements the [io.WriterTo] interface, and will encode the data in s to w.
+// On a successful invocation, WriteTo returns 0, nil.
+func (s *MapSettings) WriteTo(w io.Writer) (int64, error) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		return 0, fmt.Errorf("encode json: %w", err)
+	}
+	return 0, nil
+}
@@ -0,0 +1,135 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CreateMapOptions control how [Installation.CreateMap] generates a map.
+type CreateMapOptions struct {
+	// Preset is the name of the game's map generation preset to start
+	// from, like "rich-resources" or "death-world".
+	// MapGenSettings and MapSettings override it.
+	Preset string
+
+	// MapGenSettings and MapSettings are the settings to generate the map
+	// with, when they are not nil.
+	// MapGenSettings is checked with [MapGenSettings.Validate] first.
+	MapGenSettings *MapGenSettings
+	MapSettings    *MapSettings
+
+	// Seed of the map's random number generator, overriding the one in
+	// MapGenSettings, when it is not nil.
+	Seed *uint32
+
+	// Output receives the game's output while it generates the map, when
+	// it is not nil.
+	Output io.Writer
+}
+
+// CreateMap generates a new map, and saves it as "saves/NAME.zip" in the
+// installation directory, returning the save's path.
+// An existing save is never overwritten.
+//
+// The map is generated by running the game with "--create", which cannot be
+// done while the installation's server is running, since only one instance
+// of the game can use the installation's directory at a time.
+func (i *Installation) CreateMap(ctx context.Context, name string, opts CreateMapOptions) (string, error) {
+	name, err := saveName(name)
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(i.Dir, savesDir)
+	if err := os.MkdirAll(dir, fs.ModePerm); err != nil {
+		return "", fmt.Errorf("make saves directory: %w", err)
+	}
+	save := filepath.Join(dir, name+".zip")
+	if _, err := os.Stat(save); err == nil {
+		return "", fmt.Errorf("save %s already exists", filepath.Base(save))
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("stat save: %w", err)
+	}
+
+	tmp, err := os.MkdirTemp("", "facsrv-map-")
+	if err != nil {
+		return "", fmt.Errorf("make temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	args := []string{"--create", save}
+	if opts.Preset != "" {
+		args = append(args, "--preset", opts.Preset)
+	}
+	if opts.MapGenSettings != nil {
+		if err := opts.MapGenSettings.Validate(); err != nil {
+			return "", fmt.Errorf("invalid map gen settings: %w", err)
+		}
+		p := filepath.Join(tmp, "map-gen-settings.json")
+		if err := writeSettingsFile(p, opts.MapGenSettings); err != nil {
+			return "", err
+		}
+		args = append(args, "--map-gen-settings", p)
+	}
+	if opts.MapSettings != nil {
+		p := filepath.Join(tmp, "map-settings.json")
+		if err := writeSettingsFile(p, opts.MapSettings); err != nil {
+			return "", err
+		}
+		args = append(args, "--map-settings", p)
+	}
+	if opts.Seed != nil {
+		args = append(args, "--map-gen-seed", strconv.FormatUint(uint64(*opts.Seed), 10))
+	}
+
+	output := &lineBuffer{max: 10}
+	cmd := exec.CommandContext(ctx, i.binary(), args...)
+	cmd.Dir = i.Dir
+	cmd.Stdout = output
+	if opts.Output != nil {
+		cmd.Stdout = io.MultiWriter(output, opts.Output)
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("create map: %w: %s", err, strings.Join(output.lines(), "\n"))
+	}
+	return save, nil
+}
+
+// saveName returns the name of a save in an installation's saves directory,
+// without its ".zip" extension.
+func saveName(name string) (string, error) {
+	name = strings.TrimSuffix(name, ".zip")
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid save name %q", name)
+	}
+	return name, nil
+}
+
+// writeSettingsFile writes settings to a new file at path.
+func writeSettingsFile(path string, settings io.WriterTo) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", filepath.Base(path), err)
+	}
+	defer f.Close()
+	if _, err := settings.WriteTo(f); err != nil {
+		return fmt.Errorf("write %s: %w", filepath.Base(path), err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// DefaultGamePort is the UDP port the server hosts the game on, when none is
+// given.
+const DefaultGamePort = 34197
+
+// ErrNoReply is returned by [Ping] when the server does not reply in time.
+var ErrNoReply = errors.New("no reply from server")
+
+// Network message types of the game's UDP protocol, in the low 5 bits of the
+// first byte of each message.
+// The layout of the messages [Ping] sends and reads follows the community's
+// documentation of the protocol, which Wube does not publish.
+const (
+	msgConnectionRequest      = 2
+	msgConnectionRequestReply = 3
+	msgTypeMask               = 0x1f
+)
+
+// pingTimeout is how long [Ping] waits for a reply, when ctx has no deadline.
+const pingTimeout = 5 * time.Second
+
+// pingResend is how often [Ping] resends its request, since UDP packets can
+// be lost.
+const pingResend = time.Second
+
+// PingResult is the reply to a [Ping].
+type PingResult struct {
+	// The time between sending the request, and receiving the reply.
+	RTT time.Duration
+
+	// The version of the server, from the reply.
+	Version mods.Version
+}
+
+// Ping checks that a server is reachable on its game port, at addr, like
+// "example.com:34197", and measures its latency, without needing RCON.
+// The port defaults to [DefaultGamePort].
+//
+// Ping sends the connection request the game sends when joining a server,
+// and waits for the server's reply; it never confirms the connection, so the
+// server forgets about it. The request is resent every second, since UDP
+// packets can be lost, and the RTT is measured from the request the server
+// replied to.
+// An error wrapping [ErrNoReply] is returned when there is no reply before
+// ctx is done, or within 5 seconds.
+func Ping(ctx context.Context, addr string) (PingResult, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, strconv.Itoa(DefaultGamePort))
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, pingTimeout)
+		defer cancel()
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return PingResult{}, fmt.Errorf("dial: %w", err)
+	}
+	defer conn.Close()
+
+	// The connection request holds the client's version, which is left
+	// zero, since the server only checks it once the connection is
+	// confirmed, and an ID the server echoes back.
+	// Each request gets its own ID, so a reply is matched to the request
+	// it answers, even when it is the reply to a request that was resent.
+	base := rand.Uint32()
+	sent := make(map[uint32]time.Time)
+	req := make([]byte, 10)
+	req[0] = msgConnectionRequest
+
+	deadline, _ := ctx.Deadline()
+	buf := make([]byte, 1500)
+	for seq := uint32(0); ; seq++ {
+		id := base + seq
+		binary.LittleEndian.PutUint32(req[6:], id)
+		sent[id] = time.Now()
+		if _, err := conn.Write(req); err != nil {
+			return PingResult{}, fmt.Errorf("ping %s: %w", addr, err)
+		}
+		readDeadline := sent[id].Add(pingResend)
+		if deadline.Before(readDeadline) {
+			readDeadline = deadline
+		}
+		conn.SetReadDeadline(readDeadline)
+
+		for {
+			n, err := conn.Read(buf)
+			received := time.Now()
+			if isTimeout(err) {
+				break
+			} else if err != nil {
+				return PingResult{}, fmt.Errorf("ping %s: %w", addr, err)
+			}
+			// Replies that are not to one of the requests are
+			// ignored.
+			reply := buf[:n]
+			if len(reply) < 10 || reply[0]&msgTypeMask != msgConnectionRequestReply {
+				continue
+			}
+			at, ok := sent[binary.LittleEndian.Uint32(reply[6:])]
+			if !ok {
+				continue
+			}
+			// The version is followed by a 16-bit build number,
+			// which is too small for the build numbers of newer
+			// versions, so it is not read.
+			return PingResult{
+				RTT: received.Sub(at),
+				Version: mods.Version{
+					Major: int(reply[1]),
+					Minor: int(reply[2]),
+					Patch: int(reply[3]),
+				},
+			}, nil
+		}
+		if !time.Now().Before(deadline) {
+			return PingResult{}, fmt.Errorf("ping %s: %w", addr, ErrNoReply)
+		}
+	}
+}
+
+// isTimeout reports whether err is a network timeout.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Ping pings the installation's server, on localhost, with [Ping].
+// The port is the one the server was last started on, or [DefaultGamePort].
+func (i *Installation) Ping(ctx context.Context) (PingResult, error) {
+	i.mu.Lock()
+	port := i.port
+	i.mu.Unlock()
+	if port == 0 {
+		port = DefaultGamePort
+	}
+	return Ping(ctx, net.JoinHostPort("localhost", strconv.Itoa(port)))
+}
@@ -0,0 +1,157 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/nesv/factorio-tools/server/logs"
+)
+
+// Player is a player connected to a server.
+type Player struct {
+	Name string
+
+	// Admin is true when the player is one of the server's admins.
+	Admin bool
+
+	// JoinedAt is when the player joined the game, or the zero time when
+	// it is not known.
+	// It is set by [SetJoinTimes].
+	JoinedAt time.Time
+}
+
+// Players returns the players connected to the server, sorted by name, using
+// the "/players online" and "/admins" commands.
+func (c *RCON) Players(ctx context.Context) ([]Player, error) {
+	out, err := c.Exec(ctx, "/players online")
+	if err != nil {
+		return nil, fmt.Errorf("list players: %w", err)
+	}
+	names := parsePlayerList(out)
+
+	out, err = c.Exec(ctx, "/admins")
+	if err != nil {
+		return nil, fmt.Errorf("list admins: %w", err)
+	}
+	admins := parsePlayerList(out)
+
+	players := make([]Player, len(names))
+	for i, name := range names {
+		players[i] = Player{Name: name, Admin: slices.Contains(admins, name)}
+	}
+	slices.SortFunc(players, func(a, b Player) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return players, nil
+}
+
+// Say posts message to the game's chat, from the server.
+// Messages starting with "/" are rejected, since the server would run them as
+// commands, as are messages with line breaks, or other control characters.
+func (c *RCON) Say(ctx context.Context, message string) error {
+	if err := checkChatMessage(message); err != nil {
+		return err
+	}
+	if _, err := c.Exec(ctx, message); err != nil {
+		return fmt.Errorf("say: %w", err)
+	}
+	return nil
+}
+
+// checkChatMessage returns an error when message cannot be posted to the
+// game's chat with [RCON.Say].
+func checkChatMessage(message string) error {
+	if strings.HasPrefix(strings.TrimSpace(message), "/") {
+		return fmt.Errorf("message %q would run a command", message)
+	}
+	if i := strings.IndexFunc(message, unicode.IsControl); i >= 0 {
+		return fmt.Errorf("message %q contains the control character %U", message, []rune(message[i:])[0])
+	}
+	return nil
+}
+
+// playerName matches the names of factorio.com accounts.
+var playerName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// ValidPlayerName reports whether name can be the name of a player, so that it
+// can be passed to a command safely.
+func ValidPlayerName(name string) bool {
+	return playerName.MatchString(name)
+}
+
+// playerCommand runs command, like "/whitelist add", with the named player as
+// its argument, followed by rest, when it is not empty.
+// The name is checked with [ValidPlayerName] first, so that it cannot add
+// arguments, or other commands.
+func (c *RCON) playerCommand(ctx context.Context, command, name string, rest ...string) error {
+	if !ValidPlayerName(name) {
+		return fmt.Errorf("invalid player name %q", name)
+	}
+	line := command + " " + name
+	if r := strings.Join(rest, " "); r != "" {
+		line += " " + r
+	}
+	if _, err := c.Exec(ctx, line); err != nil {
+		return fmt.Errorf("%s %s: %w", command, name, err)
+	}
+	return nil
+}
+
+// parsePlayerList returns the player names in the output of "/players" or
+// "/admins", which looks like:
+//
+//	Online players (2):
+//	  alice (online)
+//	  bob (online)
+func parsePlayerList(out string) []string {
+	var names []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasSuffix(line, ":") {
+			continue
+		}
+		name, _, _ := strings.Cut(line, " ")
+		names = append(names, name)
+	}
+	return names
+}
+
+// JoinTimes reads a server's console log, like the one written with the
+// server's "--console-log" option, or its standard output, and returns when
+// each player that is still in the game last joined it.
+func JoinTimes(r io.Reader) (map[string]time.Time, error) {
+	joined := make(map[string]time.Time)
+	sc := logs.NewScanner(r)
+	for sc.Scan() {
+		switch e := sc.Event(); e.Kind {
+		case logs.KindJoin:
+			joined[e.Player] = e.Time
+		case logs.KindLeave:
+			delete(joined, e.Player)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read console log: %w", err)
+	}
+	return joined, nil
+}
+
+// SetJoinTimes sets the JoinedAt time of each player in players that has
+// one in joined, as returned by [JoinTimes].
+func SetJoinTimes(players []Player, joined map[string]time.Time) {
+	for i, p := range players {
+		if t, ok := joined[p.Name]; ok {
+			players[i].JoinedAt = t
+		}
+	}
+}
@@ -0,0 +1,31 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package server
+
+import "testing"
+
+func TestCheckChatMessage(t *testing.T) {
+	for _, tt := range []struct {
+		message string
+		ok      bool
+	}{
+		{"The server restarts in 5 minutes.", true},
+		{"Grüße aus dem Werk", true},
+		{"/quit", false},
+		{"  /ban someone", false},
+		{"first line\n/quit", false},
+		{"first line\r/quit", false},
+		{"tab\tseparated", false},
+		{"escape \x1b[31mred", false},
+		{"next line\u0085/quit", false},
+	} {
+		err := checkChatMessage(tt.message)
+		if tt.ok && err != nil {
+			t.Errorf("checkChatMessage(%q): %v", tt.message, err)
+		} else if !tt.ok && err == nil {
+			t.Errorf("checkChatMessage(%q): got no error", tt.message)
+		}
+	}
+}
@@ -0,0 +1,497 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nesv/factorio-tools/server/logs"
+)
+
+// ErrNotRunning is returned when stopping, or signalling, an [Installation]
+// whose server is not running.
+var ErrNotRunning = errors.New("server is not running")
+
+// stopTimeout is how long the server is given to save the map and exit, after
+// the context passed to [Installation.Start] is cancelled, before it is
+// killed.
+const stopTimeout = time.Minute
+
+// errNoRCON is returned by [Installation.save] when the server was started
+// without RCON.
+var errNoRCON = errors.New("rcon is not enabled")
+
+// outputLines is the number of lines of output kept by an [Installation].
+const outputLines = 200
+
+// Installation is a Factorio headless server installed in a directory, like
+// one installed by [Install], which can be started and stopped.
+// The zero value is not usable; set Dir.
+type Installation struct {
+	Dir string
+
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	started time.Time
+	port    int           // The game port the server was last started with.
+	done    chan struct{} // Closed when the running server exits.
+	exitErr error
+	output  *lineBuffer
+
+	// The RCON interface of the running server, which the map is saved
+	// through before the server is stopped.
+	rconAddr, rconPassword string
+
+	// The save the server was started with, which is empty when it loaded
+	// the latest save.
+	saveName string
+
+	// The save the server last logged that it started writing, and the
+	// number of saves it has started.
+	saving     string
+	saveStarts int
+
+	// saved is notified, and replaced, each time the server logs that it
+	// finished saving the map.
+	saved *saveNotice
+}
+
+// saveNotice tells the waiters in [Installation.save] that the server
+// finished writing a save.
+type saveNotice struct {
+	done chan struct{} // Closed once the save has finished.
+
+	// Set before done is closed: the save that was written, the number it
+	// was started as, counting from 1, and the notice for the next save.
+	name  string
+	start int
+	next  *saveNotice
+}
+
+// StartOptions control how [Installation.Start] starts the server.
+type StartOptions struct {
+	// Save is the path to the save file to host.
+	// When it is empty, the latest save in the installation's "saves"
+	// directory is loaded.
+	Save string
+
+	// ServerSettings is the path to the server settings file.
+	// Defaults to "data/server-settings.json" in the installation
+	// directory, when it exists.
+	ServerSettings string
+
+	// Port is the UDP port to host the game on, when it is not zero.
+	Port int
+
+	// RCONPort and RCONPassword enable the server's RCON interface, when
+	// RCONPort is not zero.
+	// Default to the "rcon_port" and "rcon_password" server settings.
+	//
+	// Factorio only reads the password from its command line, so other
+	// users on the host can see it, for example with ps(1), unless /proc
+	// is mounted with "hidepid=2".
+	RCONPort     int
+	RCONPassword string
+
+	// UseWhitelist makes the server only let the players on its
+	// whitelist join; see [Whitelist].
+	UseWhitelist bool
+
+	// Args are added to the server's command line, after the ones set by
+	// the options above.
+	Args []string
+
+	// Stdout and Stderr receive the server's output, as it is written.
+	// The last lines of output are also kept, and can be retrieved with
+	// [Installation.Output].
+	Stdout, Stderr io.Writer
+
+	// AutosaveRetention, when it is not zero, selects the autosaves that
+	// are kept while the server is running; the others are removed every
+	// few minutes, with [PruneAutosaves].
+	AutosaveRetention AutosaveRetention
+
+	// PIDFile, when it is not empty, is the path the server's process ID
+	// is written to while it is running.
+	PIDFile string
+}
+
+// Status describes the state of an [Installation]'s server.
+type Status struct {
+	Running bool
+	PID     int
+
+	// When the server was last started.
+	StartedAt time.Time
+
+	// The error the server exited with, when it is not running and has
+	// exited since it was last started.
+	ExitErr error
+}
+
+// binary returns the path to the server's executable.
+func (i *Installation) binary() string {
+	return filepath.Join(i.Dir, "bin", "x64", "factorio")
+}
+
+// Start starts the server in the background, and returns once it has been
+// started.
+// When ctx is cancelled, the server is stopped the same way as with
+// [Installation.Stop], and killed if it has not exited after a minute.
+func (i *Installation) Start(ctx context.Context, opts StartOptions) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.cmd != nil {
+		return fmt.Errorf("server is already running, with pid %d", i.cmd.Process.Pid)
+	}
+
+	args, err := i.startArgs(&opts)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, i.binary(), args...)
+	cmd.Dir = i.Dir
+	cmd.Cancel = func() error {
+		saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), stopTimeout)
+		defer cancel()
+		i.saveBeforeStop(saveCtx)
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = stopTimeout
+
+	output := &lineBuffer{max: outputLines, onLine: i.watchLine}
+	cmd.Stdout = output
+	if opts.Stdout != nil {
+		cmd.Stdout = io.MultiWriter(output, opts.Stdout)
+	}
+	cmd.Stderr = output
+	if opts.Stderr != nil {
+		cmd.Stderr = io.MultiWriter(output, opts.Stderr)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start server: %w", err)
+	}
+	if opts.PIDFile != "" {
+		if err := os.WriteFile(opts.PIDFile, []byte(strconv.Itoa(cmd.Process.Pid)+"\n"), 0o644); err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return fmt.Errorf("write pid file: %w", err)
+		}
+	}
+
+	i.cmd, i.started, i.exitErr, i.output = cmd, time.Now(), nil, output
+	i.port = opts.Port
+	i.done = make(chan struct{})
+	i.saveName, i.saving, i.saveStarts = opts.Save, "", 0
+	i.saved = &saveNotice{done: make(chan struct{})}
+	i.rconAddr, i.rconPassword = "", ""
+	if opts.RCONPort != 0 {
+		i.rconAddr = net.JoinHostPort("localhost", strconv.Itoa(opts.RCONPort))
+		i.rconPassword = opts.RCONPassword
+	}
+
+	if !opts.AutosaveRetention.IsZero() {
+		go pruneAutosaves(ctx, i.Dir, opts.AutosaveRetention, i.done)
+	}
+
+	go func(done chan struct{}) {
+		err := cmd.Wait()
+		if opts.PIDFile != "" {
+			os.Remove(opts.PIDFile)
+		}
+
+		i.mu.Lock()
+		i.cmd, i.exitErr = nil, err
+		i.mu.Unlock()
+		close(done)
+	}(i.done)
+
+	return nil
+}
+
+// startArgs returns the server's command-line arguments for opts.
+// The RCON port and password in opts are set from the server settings, when
+// they are not set.
+func (i *Installation) startArgs(opts *StartOptions) ([]string, error) {
+	var args []string
+	if opts.Save != "" {
+		args = append(args, "--start-server", opts.Save)
+	} else {
+		args = append(args, "--start-server-load-latest")
+	}
+
+	settings := opts.ServerSettings
+	if settings == "" {
+		p := filepath.Join(i.Dir, "data", "server-settings.json")
+		if _, err := os.Stat(p); err == nil {
+			settings = p
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("stat server settings: %w", err)
+		}
+	}
+	if settings != "" {
+		args = append(args, "--server-settings", settings)
+
+		if opts.RCONPort == 0 {
+			s, err := loadSettingsFile(settings)
+			if err != nil {
+				return nil, err
+			}
+			opts.RCONPort, opts.RCONPassword = int(s.RCONPort), s.RCONPassword
+		}
+	}
+
+	if opts.Port != 0 {
+		args = append(args, "--port", strconv.Itoa(opts.Port))
+	}
+	if opts.UseWhitelist {
+		args = append(args, "--use-server-whitelist")
+	}
+	if opts.RCONPort != 0 {
+		args = append(args, "--rcon-port", strconv.Itoa(opts.RCONPort), "--rcon-password", opts.RCONPassword)
+	}
+	return append(args, opts.Args...), nil
+}
+
+// Stop saves the map, and asks the server to exit, by interrupting it, and
+// waits for it to exit.
+//
+// When the server was started with RCON, the map is first saved with
+// [RCON.Save], and Stop waits for the server to log that it finished that
+// save, so that no progress is lost even when the server does not save the
+// map while exiting; otherwise, the server is interrupted straight away.
+// When ctx is done first, the server is killed.
+// An error wrapping [ErrNotRunning] is returned when the server is not
+// running.
+func (i *Installation) Stop(ctx context.Context) error {
+	i.mu.Lock()
+	running, done := i.cmd != nil, i.done
+	i.mu.Unlock()
+	if !running {
+		return ErrNotRunning
+	}
+
+	i.saveBeforeStop(ctx)
+	if err := i.Signal(os.Interrupt); errors.Is(err, ErrNotRunning) {
+		// The server exited while saving.
+		<-done
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	i.mu.Lock()
+	cmd := i.cmd
+	i.mu.Unlock()
+	if cmd != nil {
+		cmd.Process.Kill()
+	}
+	<-done
+	return fmt.Errorf("server did not exit in time, and was killed: %w", ctx.Err())
+}
+
+// saveBeforeStop saves the map with [Installation.save], before the server is
+// stopped.
+// Errors are only logged, since the server is stopped anyway.
+func (i *Installation) saveBeforeStop(ctx context.Context) {
+	err := i.save(ctx)
+	if errors.Is(err, errNoRCON) {
+		slog.DebugContext(ctx, "not saving the map before stopping the server", "err", err)
+	} else if err != nil {
+		slog.WarnContext(ctx, "cannot save the map before stopping the server", "err", err)
+	}
+}
+
+// save saves the map through the server's RCON interface, and waits for the
+// server to log that it finished writing that save: one it started after the
+// request, under the name of the save it was started with, or under any name
+// other than an autosave's when it loaded the latest save.
+// An error wrapping [errNoRCON] is returned when the server was started
+// without RCON.
+func (i *Installation) save(ctx context.Context) error {
+	i.mu.Lock()
+	addr, password, done := i.rconAddr, i.rconPassword, i.done
+	want, n, after := i.saveName, i.saved, i.saveStarts
+	i.mu.Unlock()
+	if addr == "" {
+		return errNoRCON
+	}
+
+	conn, err := DialRCON(ctx, addr, password)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.Save(ctx); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-n.done:
+		case <-done:
+			return ErrNotRunning
+		case <-ctx.Done():
+			return fmt.Errorf("wait for save: %w", ctx.Err())
+		}
+		if n.start > after && isRequestedSave(n.name, want) {
+			return nil
+		}
+		n = n.next
+	}
+}
+
+// isRequestedSave reports whether name, from the server's log line, is the
+// save written by [RCON.Save] for a server started with the save want.
+func isRequestedSave(name, want string) bool {
+	name = strings.TrimSuffix(filepath.Base(name), ".zip")
+	if want == "" {
+		return !autosaveRegexp.MatchString(name + ".zip")
+	}
+	return name == strings.TrimSuffix(filepath.Base(want), ".zip")
+}
+
+// watchLine is called with each line of the server's output, to notice when
+// it starts, and finishes, saving the map.
+func (i *Installation) watchLine(line string) {
+	e, ok := logs.Parse(line)
+	if !ok {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	switch e.Kind {
+	case logs.KindSaveStarted:
+		i.saving = e.Message
+		i.saveStarts++
+	case logs.KindSaveFinished:
+		n := i.saved
+		n.name, n.start = i.saving, i.saveStarts
+		n.next = &saveNotice{done: make(chan struct{})}
+		i.saved = n.next
+		close(n.done)
+	}
+}
+
+// Signal sends sig to the server.
+func (i *Installation) Signal(sig os.Signal) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.cmd == nil {
+		return ErrNotRunning
+	}
+	if err := i.cmd.Process.Signal(sig); err != nil {
+		return fmt.Errorf("signal server: %w", err)
+	}
+	return nil
+}
+
+// Wait waits for the server to exit, and returns the error it exited with.
+func (i *Installation) Wait() error {
+	i.mu.Lock()
+	done := i.done
+	i.mu.Unlock()
+	if done == nil {
+		return ErrNotRunning
+	}
+
+	<-done
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.exitErr
+}
+
+// Status returns the state of the server.
+func (i *Installation) Status() Status {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	s := Status{StartedAt: i.started, ExitErr: i.exitErr}
+	if i.cmd != nil {
+		s.Running = true
+		s.PID = i.cmd.Process.Pid
+	}
+	return s
+}
+
+// Output returns the last lines the server wrote to its standard output and
+// standard error, oldest first.
+func (i *Installation) Output() []string {
+	i.mu.Lock()
+	output := i.output
+	i.mu.Unlock()
+	if output == nil {
+		return nil
+	}
+	return output.lines()
+}
+
+// lineBuffer is an [io.Writer] that keeps the last max lines written to it.
+type lineBuffer struct {
+	max int
+
+	// onLine, when it is not nil, is called with each line written.
+	onLine func(line string)
+
+	mu      sync.Mutex
+	buf     []string
+	partial []byte
+}
+
+func (b *lineBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	data := append(b.partial, p...)
+	var lines []string
+	for {
+		line, rest, ok := bytes.Cut(data, []byte("\n"))
+		if !ok {
+			break
+		}
+		lines = append(lines, string(bytes.TrimRight(line, "\r")))
+		data = rest
+	}
+	b.buf = append(b.buf, lines...)
+	b.partial = append([]byte(nil), data...)
+
+	if over := len(b.buf) - b.max; over > 0 {
+		b.buf = append([]string(nil), b.buf[over:]...)
+	}
+	b.mu.Unlock()
+
+	if b.onLine != nil {
+		for _, line := range lines {
+			b.onLine(line)
+		}
+	}
+	return len(p), nil
+}
+
+// lines returns a copy of the buffered lines.
+func (b *lineBuffer) lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.buf...)
+}
@@ -0,0 +1,182 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrRCONAuth is returned by [DialRCON] when the server rejects the RCON
+// password.
+var ErrRCONAuth = errors.New("rcon password rejected")
+
+// DefaultRCONPort is the port RCON clients connect to when none is given.
+const DefaultRCONPort = 27015
+
+// Packet types of the [Source RCON protocol], which Factorio implements.
+//
+// [Source RCON protocol]: https://developer.valvesoftware.com/wiki/Source_RCON_Protocol
+const (
+	rconResponseValue = 0
+	rconExecCommand   = 2
+	rconAuthResponse  = 2
+	rconAuth          = 3
+)
+
+// maxRCONPacket is the largest packet RCON will read, as a guard against
+// reading garbage from something that is not an RCON server.
+const maxRCONPacket = 1 << 20
+
+// RCON is a connection to a server's RCON interface, which is enabled by
+// starting the server with "--rcon-port" and "--rcon-password", or with
+// [StartOptions.RCONPort].
+// Commands are run one at a time; RCON is safe for concurrent use.
+type RCON struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	r      *bufio.Reader
+	nextID int32
+}
+
+// DialRCON connects to the RCON interface at addr, like "localhost:27015",
+// and authenticates with password.
+// An error wrapping [ErrRCONAuth] is returned when the password is wrong.
+func DialRCON(ctx context.Context, addr, password string) (*RCON, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial rcon: %w", err)
+	}
+
+	c := &RCON{conn: conn, r: bufio.NewReader(conn), nextID: 1}
+	if err := c.auth(ctx, password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// auth authenticates the connection.
+// Some servers send an empty response value before the auth response, which
+// is skipped.
+func (c *RCON) auth(ctx context.Context, password string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.setDeadline(ctx)()
+
+	id := c.id()
+	if err := c.write(id, rconAuth, password); err != nil {
+		return fmt.Errorf("send rcon password: %w", err)
+	}
+	for {
+		respID, typ, _, err := c.read()
+		if err != nil {
+			return fmt.Errorf("read rcon auth response: %w", err)
+		}
+		if typ != rconAuthResponse {
+			continue
+		}
+		if respID == -1 || respID != id {
+			return ErrRCONAuth
+		}
+		return nil
+	}
+}
+
+// Exec runs a command, like "/players" or "/c game.print('hello')", and
+// returns its output.
+func (c *RCON) Exec(ctx context.Context, command string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.setDeadline(ctx)()
+
+	id := c.id()
+	if err := c.write(id, rconExecCommand, command); err != nil {
+		return "", fmt.Errorf("send rcon command: %w", err)
+	}
+	for {
+		respID, typ, body, err := c.read()
+		if err != nil {
+			return "", fmt.Errorf("read rcon response: %w", err)
+		}
+		if respID == id && typ == rconResponseValue {
+			return body, nil
+		}
+	}
+}
+
+// Close closes the connection.
+func (c *RCON) Close() error {
+	return c.conn.Close()
+}
+
+// id returns the ID for the next request.
+// Request IDs must be positive, since -1 means authentication failed.
+func (c *RCON) id() int32 {
+	id := c.nextID
+	c.nextID++
+	if c.nextID <= 0 {
+		c.nextID = 1
+	}
+	return id
+}
+
+// setDeadline sets the connection's deadline to ctx's, and returns a function
+// that clears it.
+func (c *RCON) setDeadline(ctx context.Context) func() {
+	deadline, _ := ctx.Deadline()
+	c.conn.SetDeadline(deadline)
+
+	// Unblock reads and writes when ctx is cancelled.
+	stop := context.AfterFunc(ctx, func() {
+		c.conn.SetDeadline(time.Now())
+	})
+	return func() {
+		stop()
+		c.conn.SetDeadline(time.Time{})
+	}
+}
+
+// write sends a packet.
+func (c *RCON) write(id, typ int32, body string) error {
+	// The size counts the ID, type, body, and the two null bytes that end
+	// the packet, but not itself.
+	buf := make([]byte, 0, 14+len(body))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(10+len(body)))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(id))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(typ))
+	buf = append(buf, body...)
+	buf = append(buf, 0, 0)
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+// read reads a packet.
+func (c *RCON) read() (id, typ int32, body string, err error) {
+	var hdr [12]byte
+	if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+		return 0, 0, "", err
+	}
+	size := int32(binary.LittleEndian.Uint32(hdr[0:4]))
+	id = int32(binary.LittleEndian.Uint32(hdr[4:8]))
+	typ = int32(binary.LittleEndian.Uint32(hdr[8:12]))
+	if size < 10 || size > maxRCONPacket {
+		return 0, 0, "", fmt.Errorf("invalid rcon packet size: %d", size)
+	}
+
+	data := make([]byte, size-8)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return 0, 0, "", err
+	}
+	return id, typ, string(data[:len(data)-2]), nil
+}
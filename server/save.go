@@ -0,0 +1,248 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"archive/zip"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/nesv/factorio-tools/mods"
+)
+
+// maxSaveMods is the largest number of mods [InspectSave] reads from a save,
+// so that a corrupt save cannot make it allocate without bound.
+const maxSaveMods = 10000
+
+// SaveInfo describes a save, as read from the header of its level data by
+// [InspectSave].
+type SaveInfo struct {
+	// The version of Factorio that wrote the save.
+	Version mods.Version `json:"version"`
+
+	// The campaign and level of scenarios; for freeplay maps, the level is
+	// "freeplay".
+	Campaign string `json:"campaign,omitempty"`
+	Level    string `json:"level"`
+
+	// The mod providing the scenario, usually "base".
+	BaseMod string `json:"base_mod"`
+
+	// The mods enabled when the map was saved, which the game needs to
+	// load it, including "base" and the expansions.
+	Mods []SaveMod `json:"mods"`
+}
+
+// SaveMod is a mod enabled in a save.
+type SaveMod struct {
+	Name    string       `json:"name"`
+	Version mods.Version `json:"version"`
+
+	// CRC is the game's checksum of the mod's files.
+	CRC uint32 `json:"crc"`
+}
+
+// InspectSave reads the version of Factorio, and the exact mods and versions,
+// a save was last saved with.
+//
+// They are read from the header of the save's level data, using the layout
+// written since Factorio 0.17; a save whose header does not fit that layout
+// is reported as corrupt, or unsupported.
+func InspectSave(path string) (SaveInfo, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return SaveInfo{}, fmt.Errorf("open save: %w", err)
+	}
+	defer zr.Close()
+
+	r, err := openLevel(&zr.Reader)
+	if err != nil {
+		return SaveInfo{}, err
+	}
+	defer r.Close()
+
+	info, err := readSaveHeader(r)
+	if err != nil {
+		return SaveInfo{}, fmt.Errorf("read save header: %w", err)
+	}
+	return info, nil
+}
+
+// openLevel opens the level data in a save.
+// Newer versions of Factorio split it into zlib-compressed "level.dat0",
+// "level.dat1", and so on, the first of which starts with the header; older
+// ones write a single, uncompressed "level.dat".
+func openLevel(zr *zip.Reader) (io.ReadCloser, error) {
+	var level *zip.File
+	for _, f := range zr.File {
+		switch path.Base(f.Name) {
+		case "level.dat0":
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("open %s: %w", f.Name, err)
+			}
+			r, err := zlib.NewReader(rc)
+			if err != nil {
+				rc.Close()
+				return nil, fmt.Errorf("decompress %s: %w", f.Name, err)
+			}
+			return struct {
+				io.Reader
+				io.Closer
+			}{r, rc}, nil
+		case "level.dat":
+			level = f
+		}
+	}
+	if level == nil {
+		return nil, errors.New("save has no level data; is it a Factorio save?")
+	}
+	rc, err := level.Open()
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", level.Name, err)
+	}
+	return rc, nil
+}
+
+// readSaveHeader reads the header of a save's level data.
+func readSaveHeader(r io.Reader) (SaveInfo, error) {
+	d := &saveDecoder{r: r}
+	var info SaveInfo
+	info.Version = mods.Version{
+		Major: int(d.uint16()),
+		Minor: int(d.uint16()),
+		Patch: int(d.uint16()),
+		Build: int(d.uint16()),
+	}
+	d.byte() // Unused since 0.17.
+	info.Campaign = d.string()
+	info.Level = d.string()
+	info.BaseMod = d.string()
+	d.byte()   // Difficulty.
+	d.byte()   // Finished.
+	d.byte()   // Player won.
+	d.string() // Next level.
+	d.byte()   // Can continue.
+	d.byte()   // Finished but continuing.
+	d.byte()   // Saving replay.
+	d.byte()   // Allow non-admin debug options.
+	d.read(3)  // The version of Factorio the map was loaded from...
+	d.uint16() // ...and its build.
+	d.byte()   // Allowed commands.
+
+	n := d.optimizedUint32()
+	if d.err == nil && n > maxSaveMods {
+		return SaveInfo{}, fmt.Errorf("save has %d mods; it is corrupt, or was saved by an unsupported version of factorio (%s)", n, info.Version)
+	}
+	for range n {
+		m := SaveMod{Name: d.string()}
+		m.Version = mods.Version{
+			Major: int(d.optimizedUint16()),
+			Minor: int(d.optimizedUint16()),
+			Patch: int(d.optimizedUint16()),
+		}
+		m.CRC = d.uint32()
+		if d.err != nil {
+			break
+		}
+		if m.Name == "" || strings.ContainsAny(m.Name, "/\\\x00") {
+			return SaveInfo{}, fmt.Errorf("invalid mod name %q; the save is corrupt, or was saved by an unsupported version of factorio (%s)", m.Name, info.Version)
+		}
+		info.Mods = append(info.Mods, m)
+	}
+	if d.err != nil {
+		return SaveInfo{}, d.err
+	}
+	return info, nil
+}
+
+// saveDecoder reads the little-endian values in a save's header.
+// After the first error, reads return zero values, and the error is kept in
+// err.
+type saveDecoder struct {
+	r   io.Reader
+	err error
+}
+
+func (d *saveDecoder) read(n int) []byte {
+	b := make([]byte, n)
+	if d.err != nil {
+		return b
+	}
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		d.err = err
+	}
+	return b
+}
+
+func (d *saveDecoder) byte() byte {
+	return d.read(1)[0]
+}
+
+func (d *saveDecoder) uint16() uint16 {
+	return binary.LittleEndian.Uint16(d.read(2))
+}
+
+func (d *saveDecoder) uint32() uint32 {
+	return binary.LittleEndian.Uint32(d.read(4))
+}
+
+// optimizedUint16 reads a space-optimized uint16: a byte, or, when that byte
+// is 0xff, a uint16.
+func (d *saveDecoder) optimizedUint16() uint16 {
+	if b := d.byte(); b != 0xff {
+		return uint16(b)
+	}
+	return d.uint16()
+}
+
+// optimizedUint32 reads a space-optimized uint32: a byte, or, when that byte
+// is 0xff, a uint32.
+func (d *saveDecoder) optimizedUint32() uint32 {
+	if b := d.byte(); b != 0xff {
+		return uint32(b)
+	}
+	return d.uint32()
+}
+
+// string reads a string: a space-optimized length, followed by the string's
+// bytes.
+func (d *saveDecoder) string() string {
+	n := d.optimizedUint32()
+	if d.err != nil {
+		return ""
+	}
+
+	// Like the mod count, the length comes from the file, so the buffer
+	// only grows as the bytes are read.
+	var sb strings.Builder
+	m, err := io.Copy(&sb, io.LimitReader(d.r, int64(n)))
+	if err != nil {
+		d.err = err
+	} else if m < int64(n) {
+		d.err = io.ErrUnexpectedEOF
+	}
+	return sb.String()
+}
+
+// Save asks the server to save the map it is running, under the name it was
+// loaded from, with the "/server-save" command.
+// The server saves the map in the background; the save is done once the
+// server logs that saving finished.
+func (c *RCON) Save(ctx context.Context) error {
+	if _, err := c.Exec(ctx, "/server-save"); err != nil {
+		return fmt.Errorf("save: %w", err)
+	}
+	return nil
+}
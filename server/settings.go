@@ -5,11 +5,15 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 )
 
 // DefaultSettings returns [Settings] with default values set.
@@ -33,10 +37,14 @@ func DefaultSettings() *Settings {
 
 // LoadSettings loads "data/server-settings.json" from the installation directory.
 func LoadSettings(installDir string) (Settings, error) {
-	settingsPath := filepath.Join(installDir, "data", "server-settings.json")
-	f, err := os.Open(settingsPath)
+	return loadSettingsFile(filepath.Join(installDir, "data", "server-settings.json"))
+}
+
+// loadSettingsFile loads the server settings file at path.
+func loadSettingsFile(path string) (Settings, error) {
+	f, err := os.Open(path)
 	if err != nil {
-		return Settings{}, fmt.Errorf("open server-settings.json: %w", err)
+		return Settings{}, fmt.Errorf("open %s: %w", filepath.Base(path), err)
 	}
 	defer f.Close()
 	return ReadSettings(f)
@@ -52,6 +60,10 @@ func ReadSettings(r io.Reader) (Settings, error) {
 }
 
 // Settings holds the settings for the Factorio game server.
+//
+// Keys in the file that Settings does not know about, like ones added by
+// newer versions of Factorio, or the "_comment" keys of the example file,
+// are kept, and written back out by [Settings.WriteTo].
 type Settings struct {
 	// Name of the game as it will appear in the game listing.
 	Name string `json:"name"`
@@ -124,6 +136,11 @@ type Settings struct {
 	// Whether the server should be paused when no players are present.
 	AutoPause bool `json:"auto_pause"` // default: true
 
+	// Whether the server should be paused while a player is connecting,
+	// so that they do not have to catch up with the game.
+	// Only read by Factorio 2.0, and later.
+	AutoPauseWhenPlayersConnect bool `json:"auto_pause_when_players_connect"` // default: false
+
 	// Only allow admins to pause the game.
 	OnlyAdminsCanPauseTheGame bool `json:"only_admins_can_pause_the_game"` // default: true
 
@@ -145,6 +162,81 @@ type Settings struct {
 	MinimumSegmentSizePeerCount uint `json:"minimum_segment_size_peer_count"` // default: 20
 	MaximumSegmentSize          uint `json:"maximum_segment_size"`            // default: 100
 	MaximumSegmentSizePeerCount uint `json:"maximum_segment_size_peer_count"` // default: 10
+
+	// The server's RCON interface.
+	// Factorio does not read these, since RCON is enabled on the command
+	// line, but it ignores them, so they can be kept alongside the other
+	// settings: [Installation.Start] passes them to the server, and
+	// "facsrv rcon" connects with them.
+	RCONPort     uint   `json:"rcon_port,omitempty"`
+	RCONPassword string `json:"rcon_password,omitempty"`
+
+	extra map[string]json.RawMessage
+}
+
+// settingsKeys are the keys of the fields of [Settings].
+var settingsKeys = func() []string {
+	var keys []string
+	t := reflect.TypeOf(Settings{})
+	for i := range t.NumField() {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}()
+
+// plainSettings is [Settings], without its MarshalJSON and UnmarshalJSON
+// methods.
+type plainSettings Settings
+
+// MarshalJSON implements [encoding/json.Marshaler].
+// Unknown keys are written after the known ones, sorted.
+func (s Settings) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(plainSettings(s))
+	if err != nil || len(s.extra) == 0 {
+		return data, err
+	}
+
+	keys := make([]string, 0, len(s.extra))
+	for k := range s.extra {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	buf := bytes.NewBuffer(bytes.TrimSuffix(data, []byte("}")))
+	for _, k := range keys {
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteByte(',')
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(s.extra[k])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON implements [encoding/json.Unmarshaler].
+func (s *Settings) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*plainSettings)(s)); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for _, k := range settingsKeys {
+		delete(fields, k)
+	}
+	s.extra = nil
+	if len(fields) > 0 {
+		s.extra = fields
+	}
+	return nil
 }
 
 // Visibility controls how the Factorio server will advertise itself.
@@ -0,0 +1,29 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Tick returns the game's current tick: the number of ticks since the map was
+// created.
+//
+// The tick is read with a Lua command, which, like any other Lua command,
+// disables achievements for the save the server is running.
+func (c *RCON) Tick(ctx context.Context) (uint64, error) {
+	out, err := c.Exec(ctx, "/silent-command rcon.print(game.tick)")
+	if err != nil {
+		return 0, fmt.Errorf("read tick: %w", err)
+	}
+	tick, err := strconv.ParseUint(strings.TrimSpace(out), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("read tick: unexpected output %q", strings.TrimSpace(out))
+	}
+	return tick, nil
+}
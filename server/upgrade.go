@@ -0,0 +1,453 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Names of the entries in a directory managed by [Upgrade].
+const (
+	// The directory holding each installed version of the server, like
+	// "versions/2.0.28".
+	versionsDir = "versions"
+
+	// Symlinks to the installed version that is in use, and the one that
+	// was in use before the last upgrade.
+	currentLink  = "current"
+	previousLink = "previous"
+
+	// The directory holding the backups made before each upgrade.
+	backupsDir = "backups"
+
+	// The directory holding the saves, which each version's "saves"
+	// links to.
+	savesDir = "saves"
+)
+
+// userData are the files and directories in an installation that belong to
+// the server's operator, rather than to the game, relative to the
+// installation directory.
+// They are copied into each new version by [Upgrade].
+// Saves are not copied, but shared between versions; see [shareSaves].
+var userData = []string{
+	"config",
+	"mods",
+	"scenarios",
+	"script-output",
+	"player-data.json",
+	"server-adminlist.json",
+	"server-banlist.json",
+	"server-whitelist.json",
+	filepath.Join("data", "server-settings.json"),
+	filepath.Join("data", "map-gen-settings.json"),
+	filepath.Join("data", "map-settings.json"),
+}
+
+// backupData are the entries in userData that [Upgrade] backs up.
+var backupData = []string{
+	"saves",
+	"server-adminlist.json",
+	"server-banlist.json",
+	"server-whitelist.json",
+	filepath.Join("data", "server-settings.json"),
+}
+
+// UpgradeOptions control how [Upgrade] installs a new version of the server.
+type UpgradeOptions struct {
+	InstallOptions
+
+	// Check, when it is not nil, is called after the "current" symlink
+	// has been switched to the new version, with the path to the new
+	// version's directory.
+	// It can restart the server, and check that it started.
+	// When it returns an error, the upgrade is rolled back.
+	Check func(ctx context.Context, installDir string) error
+}
+
+// UpgradeResult describes an upgrade made by [Upgrade].
+type UpgradeResult struct {
+	// The versions upgraded from and to.
+	// From is empty for the first version installed into a directory.
+	From, To string
+
+	// Backup is the path to the archive of the previous version's saves
+	// and server settings, if there was a previous version.
+	Backup string
+}
+
+// Upgrade installs a version of the Factorio headless server into dir, which
+// holds each installed version in its own directory, like
+// "dir/versions/2.0.28", and a "current" symlink to the version in use.
+// Point the server's service, and facmod's --directory, at "dir/current".
+//
+// Before installing the new version, the current version's saves and server
+// settings are backed up to "dir/backups", as a gzipped tarball.
+// Its mods, settings, and other files belonging to the server's operator are
+// then copied into the new version, and "current" is switched to it,
+// atomically.
+// Saves are kept in "dir/saves", and each version's "saves" directory is a
+// symlink to it, so that no progress is lost when switching versions.
+// The version that was in use is kept, and linked from "dir/previous", so the
+// upgrade can be undone with [Rollback].
+// When opts.Check returns an error, Upgrade rolls back itself.
+func Upgrade(ctx context.Context, dir string, opts UpgradeOptions) (UpgradeResult, error) {
+	if _, err := os.Stat(filepath.Join(dir, "bin", "x64", "factorio")); err == nil {
+		return UpgradeResult{}, fmt.Errorf("%s contains a server installed in place; upgrade needs a directory of versioned installations", dir)
+	}
+
+	version, err := ResolveVersion(ctx, opts.Version)
+	if err != nil {
+		return UpgradeResult{}, err
+	}
+	opts.Version = version
+
+	var result UpgradeResult
+	oldDir, err := linkTarget(dir, currentLink)
+	if err != nil {
+		return UpgradeResult{}, err
+	}
+	if oldDir != "" {
+		if result.From, err = InstalledVersion(ctx, oldDir); err != nil {
+			return UpgradeResult{}, fmt.Errorf("check current server: %w", err)
+		}
+		if result.From == version {
+			return UpgradeResult{}, fmt.Errorf("factorio %s is already installed", version)
+		}
+		if result.Backup, err = backup(dir, oldDir, result.From); err != nil {
+			return UpgradeResult{}, err
+		}
+	}
+
+	newDir := filepath.Join(dir, versionsDir, version)
+	if result.To, err = Install(ctx, newDir, opts.InstallOptions); err != nil {
+		return result, err
+	}
+	if oldDir != "" {
+		if err := copyUserData(newDir, oldDir); err != nil {
+			return result, err
+		}
+	}
+	if err := shareSaves(dir, newDir, oldDir); err != nil {
+		return result, err
+	}
+
+	if err := switchVersion(dir, newDir, oldDir); err != nil {
+		return result, err
+	}
+
+	if opts.Check != nil {
+		if err := opts.Check(ctx, newDir); err != nil {
+			if oldDir == "" {
+				return result, fmt.Errorf("check upgraded server: %w", err)
+			}
+			if _, rerr := Rollback(dir); rerr != nil {
+				return result, fmt.Errorf("check upgraded server: %w; roll back: %w", err, rerr)
+			}
+			return result, fmt.Errorf("check upgraded server: %w; rolled back to %s", err, result.From)
+		}
+	}
+
+	return result, nil
+}
+
+// Rollback switches the "current" symlink in a directory managed by [Upgrade]
+// back to the version that was in use before the last upgrade, and returns
+// the path to that version's directory.
+// Rolling back again undoes the rollback.
+func Rollback(dir string) (string, error) {
+	prevDir, err := linkTarget(dir, previousLink)
+	if err != nil {
+		return "", err
+	}
+	if prevDir == "" {
+		return "", errors.New("there is no previous version to roll back to")
+	}
+	curDir, err := linkTarget(dir, currentLink)
+	if err != nil {
+		return "", err
+	}
+	if err := switchVersion(dir, prevDir, curDir); err != nil {
+		return "", err
+	}
+	return prevDir, nil
+}
+
+// linkTarget returns the directory the named symlink in dir points to, or an
+// empty string if the symlink does not exist.
+func linkTarget(dir, name string) (string, error) {
+	target, err := os.Readlink(filepath.Join(dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("read %s symlink: %w", name, err)
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(dir, target)
+	}
+	return target, nil
+}
+
+// switchVersion points the "current" symlink in dir at newDir, and the
+// "previous" symlink at oldDir, if it is not empty.
+func switchVersion(dir, newDir, oldDir string) error {
+	if oldDir != "" {
+		if err := replaceSymlink(dir, previousLink, oldDir); err != nil {
+			return err
+		}
+	}
+	return replaceSymlink(dir, currentLink, newDir)
+}
+
+// replaceSymlink atomically replaces the named symlink in dir with one
+// pointing at target, relative to dir.
+func replaceSymlink(dir, name, target string) error {
+	rel, err := filepath.Rel(dir, target)
+	if err != nil {
+		return fmt.Errorf("make %s symlink: %w", name, err)
+	}
+	tmp := filepath.Join(dir, "."+name+".tmp")
+	os.Remove(tmp)
+	if err := os.Symlink(rel, tmp); err != nil {
+		return fmt.Errorf("make %s symlink: %w", name, err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, name)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("replace %s symlink: %w", name, err)
+	}
+	return nil
+}
+
+// backup writes the saves and server settings in installDir to a gzipped
+// tarball in dir's backups directory, and returns its path.
+func backup(dir, installDir, version string) (string, error) {
+	return writeBackup(filepath.Join(dir, backupsDir), "factorio-"+version, installDir, backupData)
+}
+
+// writeBackup writes the entries in installDir to a gzipped tarball named
+// after prefix and the current time, like "saves-20250102T150405Z.tar.gz", in
+// backups, and returns its path.
+func writeBackup(backups, prefix, installDir string, entries []string) (string, error) {
+	if err := os.MkdirAll(backups, fs.ModePerm); err != nil {
+		return "", fmt.Errorf("make backups directory: %w", err)
+	}
+
+	name := prefix + "-" + time.Now().UTC().Format(backupTimeFormat) + ".tar.gz"
+	path := filepath.Join(backups, name)
+	tmp, err := os.CreateTemp(backups, ".backup-*")
+	if err != nil {
+		return "", fmt.Errorf("create backup: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	zw := gzip.NewWriter(tmp)
+	tw := tar.NewWriter(zw)
+	for _, rel := range entries {
+		if err := addToTar(tw, installDir, rel); err != nil {
+			return "", fmt.Errorf("back up %s: %w", rel, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", fmt.Errorf("write backup: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return "", fmt.Errorf("write backup: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("close backup: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("rename backup: %w", err)
+	}
+	return path, nil
+}
+
+// addToTar adds the file or directory at root/rel to tw.
+// When root/rel is a symlink, like a version's shared "saves" directory, the
+// files it points to are added under rel.
+// Missing files are skipped.
+func addToTar(tw *tar.Writer, root, rel string) error {
+	start, err := filepath.EvalSymlinks(filepath.Join(root, rel))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	err = filepath.WalkDir(start, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+
+		name, err := filepath.Rel(start, path)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(filepath.Join(rel, name))
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// copyUserData copies the files in userData from oldDir to newDir.
+func copyUserData(newDir, oldDir string) error {
+	for _, rel := range userData {
+		src := filepath.Join(oldDir, rel)
+		err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			name, err := filepath.Rel(oldDir, path)
+			if err != nil {
+				return err
+			}
+			dst := filepath.Join(newDir, name)
+
+			switch {
+			case d.IsDir():
+				return os.MkdirAll(dst, fs.ModePerm)
+			case d.Type()&fs.ModeSymlink != 0:
+				// Mods linked for development with "facmod watch".
+				// They are replaced, because newDir may be a version
+				// that was installed before, when upgrading back to it
+				// after a rollback.
+				target, err := os.Readlink(path)
+				if err != nil {
+					return err
+				}
+				if err := os.Remove(dst); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					return err
+				}
+				return os.Symlink(target, dst)
+			case d.Type().IsRegular():
+				return copyFile(dst, path)
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("copy %s: %w", rel, err)
+		}
+	}
+	return nil
+}
+
+// shareSaves makes newDir's "saves" directory a symlink to dir's shared saves
+// directory, creating it if it does not exist.
+//
+// When oldDir, the version in use, still has its own saves directory, from
+// before saves were shared, it is moved to dir and replaced with a symlink.
+// When newDir has its own saves directory, it is a stale copy made by an
+// earlier upgrade, and it is kept as "saves.unshared".
+func shareSaves(dir, newDir, oldDir string) error {
+	shared := filepath.Join(dir, savesDir)
+	if _, err := os.Stat(shared); errors.Is(err, fs.ErrNotExist) {
+		if oldDir != "" && isDir(filepath.Join(oldDir, savesDir)) {
+			if err := os.Rename(filepath.Join(oldDir, savesDir), shared); err != nil {
+				return fmt.Errorf("move saves: %w", err)
+			}
+			if err := linkSaves(shared, oldDir); err != nil {
+				return err
+			}
+		} else if err := os.MkdirAll(shared, fs.ModePerm); err != nil {
+			return fmt.Errorf("make saves directory: %w", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("check saves directory: %w", err)
+	}
+
+	saves := filepath.Join(newDir, savesDir)
+	if isDir(saves) {
+		if err := os.Rename(saves, saves+".unshared"); err != nil {
+			return fmt.Errorf("move unshared saves: %w", err)
+		}
+	}
+	return linkSaves(shared, newDir)
+}
+
+// linkSaves replaces the "saves" entry in installDir with a relative symlink
+// to the shared saves directory.
+func linkSaves(shared, installDir string) error {
+	rel, err := filepath.Rel(installDir, shared)
+	if err != nil {
+		return fmt.Errorf("link saves: %w", err)
+	}
+	saves := filepath.Join(installDir, savesDir)
+	if err := os.Remove(saves); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("link saves: %w", err)
+	}
+	if err := os.Symlink(rel, saves); err != nil {
+		return fmt.Errorf("link saves: %w", err)
+	}
+	return nil
+}
+
+// isDir reports whether path is a directory, and not a symlink to one.
+func isDir(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.IsDir()
+}
+
+// copyFile copies the regular file at src to dst, keeping its permissions.
+func copyFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), fs.ModePerm); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
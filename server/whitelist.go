@@ -0,0 +1,96 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// whitelistFile is the name of the whitelist in an installation directory.
+const whitelistFile = "server-whitelist.json"
+
+// Whitelist is the list of players allowed to join a server that was started
+// with "--use-server-whitelist", or whose whitelist was enabled with
+// [RCON.SetWhitelistEnabled], as stored in "server-whitelist.json".
+// Player names are compared case-insensitively, as the game does.
+type Whitelist []string
+
+// LoadWhitelist reads "server-whitelist.json" from the installation
+// directory.
+// When the file does not exist, the whitelist is empty.
+func LoadWhitelist(installDir string) (Whitelist, error) {
+	w := Whitelist{}
+	if _, err := readJSONFile(filepath.Join(installDir, whitelistFile), &w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Save writes the whitelist to "server-whitelist.json" in the installation
+// directory.
+// A running server only reads the file when it starts; use
+// [RCON.WhitelistAdd] and [RCON.WhitelistRemove] to change its whitelist
+// without restarting it.
+func (w Whitelist) Save(installDir string) error {
+	if w == nil {
+		w = Whitelist{}
+	}
+	return writeJSONFile(filepath.Join(installDir, whitelistFile), w)
+}
+
+// Contains reports whether the named player is on the whitelist.
+func (w Whitelist) Contains(name string) bool {
+	return slices.ContainsFunc(w, func(s string) bool { return strings.EqualFold(s, name) })
+}
+
+// Add adds the named player to the whitelist, and reports whether they were
+// not already on it.
+func (w *Whitelist) Add(name string) bool {
+	if w.Contains(name) {
+		return false
+	}
+	*w = append(*w, name)
+	return true
+}
+
+// Remove removes the named player from the whitelist, and reports whether
+// they were on it.
+func (w *Whitelist) Remove(name string) bool {
+	n := len(*w)
+	*w = slices.DeleteFunc(*w, func(s string) bool { return strings.EqualFold(s, name) })
+	return len(*w) != n
+}
+
+// WhitelistAdd adds the named player to the running server's whitelist, with
+// "/whitelist add".
+// The server also saves the change to its "server-whitelist.json".
+func (c *RCON) WhitelistAdd(ctx context.Context, name string) error {
+	return c.playerCommand(ctx, "/whitelist add", name)
+}
+
+// WhitelistRemove removes the named player from the running server's
+// whitelist, with "/whitelist remove".
+func (c *RCON) WhitelistRemove(ctx context.Context, name string) error {
+	return c.playerCommand(ctx, "/whitelist remove", name)
+}
+
+// SetWhitelistEnabled turns the running server's whitelist on or off, with
+// "/whitelist enable" or "/whitelist disable".
+// The setting only lasts until the server is restarted; start it with
+// [StartOptions.UseWhitelist] to keep the whitelist on.
+func (c *RCON) SetWhitelistEnabled(ctx context.Context, enabled bool) error {
+	command := "/whitelist disable"
+	if enabled {
+		command = "/whitelist enable"
+	}
+	if _, err := c.Exec(ctx, command); err != nil {
+		return fmt.Errorf("%s: %w", command, err)
+	}
+	return nil
+}